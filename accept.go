@@ -0,0 +1,117 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"fmt"
+	"mime"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Accept sets the Accept header to the given media types, in order of
+// preference, each optionally with a q-value (e.g. "application/xml;q=0.8");
+// the q-values are validated and normalised, and an invalid one makes Make
+// fail.
+func (f *Builder) Accept(types ...string) *Builder {
+	return f.qualified("Accept", types)
+}
+
+// AcceptLanguage sets the Accept-Language header to the given language tags,
+// in order of preference, each optionally with a q-value (e.g. "en;q=0.5"), as
+// in Accept.
+func (f *Builder) AcceptLanguage(languages ...string) *Builder {
+	return f.qualified("Accept-Language", languages)
+}
+
+// qualified sets the given header to the given values with q-values.
+func (f *Builder) qualified(header string, values []string) *Builder {
+	formatted := make([]string, 0, len(values))
+	for _, value := range values {
+		v, err := qualify(value)
+		if err != nil {
+			f.err = fmt.Errorf("%s: %w", header, err)
+			return f
+		}
+		formatted = append(formatted, v)
+	}
+	return f.SetHeader(header, strings.Join(formatted, ", "))
+}
+
+// qvalue is the syntax of q-values.
+var qvalue = regexp.MustCompile(`^(0(\.[0-9]{0,3})?|1(\.0{0,3})?)$`)
+
+// qualify normalises the given value with an optional q-value: the q-value
+// must be between 0 and 1, with at most three decimals (RFC 7231, section
+// 5.3.1), and is omitted if 1.
+func qualify(value string) (string, error) {
+	parts := strings.Split(value, ";")
+	result := []string{strings.TrimSpace(parts[0])}
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if len(part) < 2 || !strings.EqualFold(part[:2], "q=") {
+			result = append(result, part)
+			continue
+		}
+		if !qvalue.MatchString(part[2:]) {
+			return "", fmt.Errorf("invalid q-value in %q", value)
+		}
+		q, _ := strconv.ParseFloat(part[2:], 64)
+		if q < 1 {
+			result = append(result, "q="+strconv.FormatFloat(q, 'f', -1, 64))
+		}
+	}
+	return strings.Join(result, ";"), nil
+}
+
+// negotiated returns the preferred media type in the given Accept header, if
+// any, ignoring wildcards.
+func negotiated(accept string) string {
+	type candidate struct {
+		mediaType string
+		q         float64
+	}
+	candidates := []candidate{}
+	for _, value := range strings.Split(accept, ",") {
+		mediaType, parameters, err := mime.ParseMediaType(value)
+		if err != nil || strings.HasSuffix(mediaType, "/*") {
+			continue
+		}
+		q := 1.0
+		if v, ok := parameters["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{mediaType, q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[0].mediaType
+}
+
+// contentType returns the content type the response body is decoded as with
+// the given options: its Content-Type or, with the NegotiatedType option, the
+// type preferred by the request if the Content-Type is missing or generic.
+func (r *Response) contentType(options DecodeOptions) string {
+	contentType := r.Header.Get("Content-Type")
+	if options&NegotiatedType == 0 || r.Request == nil {
+		return contentType
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "", "application/octet-stream", "text/plain":
+		if preferred := negotiated(r.Request.Header.Get("Accept")); preferred != "" {
+			return preferred
+		}
+	}
+	return contentType
+}
@@ -0,0 +1,63 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccept(t *testing.T) {
+	request, err := New("http://www.example.com/").
+		Accept("application/json", "application/xml ; q=0.800", "*/*;q=0.1", "text/html;level=1;q=1.0").
+		AcceptLanguage("it-IT", "en;q=0.5").
+		Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "application/json, application/xml;q=0.8, */*;q=0.1, text/html;level=1"; request.Header.Get("Accept") != expected {
+		t.Fatalf("expected %q, got %q", expected, request.Header.Get("Accept"))
+	}
+	if expected := "it-IT, en;q=0.5"; request.Header.Get("Accept-Language") != expected {
+		t.Fatalf("expected %q, got %q", expected, request.Header.Get("Accept-Language"))
+	}
+	for _, invalid := range []string{"text/html;q=2", "text/html;q=0.1234", "text/html;q=abc"} {
+		if _, err := New("http://www.example.com/").Accept(invalid).Make(); err == nil {
+			t.Fatalf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestNegotiatedType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(`<item><name>xml</name></item>`))
+	}))
+	defer server.Close()
+	type item struct {
+		Name string `xml:"name"`
+	}
+	base := New(server.URL).Accept("application/json;q=0.5", "application/xml")
+	var v item
+	response, err := NewRequestor(nil).Do(context.Background(), base.New("", ""))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := response.Decode(&v); err == nil {
+		t.Fatalf("expected the body to be decoded as JSON")
+	}
+	response, err = NewRequestor(nil).Do(context.Background(), base.New("", "").DecodeOptions(NegotiatedType))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := response.Decode(&v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Name != "xml" {
+		t.Fatalf("expected the body to be decoded as XML, got %+v", v)
+	}
+}
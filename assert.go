@@ -0,0 +1,251 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Exchange is a completed request/response exchange, as seen by assertions and
+// value extractors.
+type Exchange struct {
+	// Request is the request that was sent.
+	Request *http.Request
+	// Response is the response that was received, if the server responded with
+	// a 2xx status code; its body has already been read into Body.
+	Response *Response
+	// Body is the response body (or a bounded snapshot of it, if the server
+	// responded with a non-2xx status code).
+	Body []byte
+	// Latency is the time it took to get the response.
+	Latency time.Duration
+	// Err is the error returned by the Requestor, if any.
+	Err error
+}
+
+// StatusCode returns the status code of the response, whether it was a 2xx or
+// not, or 0 if no response was received.
+func (e *Exchange) StatusCode() int {
+	if e.Response != nil {
+		return e.Response.StatusCode
+	}
+	return StatusCode(e.Err)
+}
+
+// Header returns the response headers, whether the response was a 2xx or not,
+// or nil if no response was received.
+func (e *Exchange) Header() http.Header {
+	if e.Response != nil {
+		return e.Response.Header
+	}
+	var httpErr *HTTPError
+	if errors.As(e.Err, &httpErr) {
+		return httpErr.Header
+	}
+	return nil
+}
+
+// Assertion is a check evaluated against a completed exchange.
+type Assertion func(exchange *Exchange) AssertionResult
+
+// evaluate runs all the given assertions against the exchange.
+func evaluate(exchange *Exchange, assertions []Assertion) []AssertionResult {
+	results := make([]AssertionResult, 0, len(assertions))
+	for _, assertion := range assertions {
+		results = append(results, assertion(exchange))
+	}
+	return results
+}
+
+// StatusIn asserts that the response status code is one of the given ones.
+func StatusIn(codes ...int) Assertion {
+	return func(exchange *Exchange) AssertionResult {
+		result := AssertionResult{Name: fmt.Sprintf("status in %v", codes)}
+		status := exchange.StatusCode()
+		for _, code := range codes {
+			if status == code {
+				result.Passed = true
+				return result
+			}
+		}
+		result.Message = fmt.Sprintf("status is %d", status)
+		return result
+	}
+}
+
+// HeaderEquals asserts that the response has the given header value, ignoring
+// media type parameters for Content-Type (e.g. "; charset=utf-8").
+func HeaderEquals(name, value string) Assertion {
+	return func(exchange *Exchange) AssertionResult {
+		result := AssertionResult{Name: fmt.Sprintf("header %s is %q", name, value)}
+		actual := exchange.Header().Get(name)
+		if http.CanonicalHeaderKey(name) == "Content-Type" && !strings.Contains(value, ";") {
+			actual = strings.TrimSpace(strings.SplitN(actual, ";", 2)[0])
+		}
+		result.Passed = strings.EqualFold(actual, value)
+		if !result.Passed {
+			result.Message = fmt.Sprintf("header %s is %q", name, exchange.Header().Get(name))
+		}
+		return result
+	}
+}
+
+// BodyContains asserts that the response body contains the given text.
+func BodyContains(text string) Assertion {
+	return func(exchange *Exchange) AssertionResult {
+		result := AssertionResult{Name: fmt.Sprintf("body contains %q", text), Passed: bytes.Contains(exchange.Body, []byte(text))}
+		if !result.Passed {
+			result.Message = "text not found in body"
+		}
+		return result
+	}
+}
+
+// JSONPathExists asserts that the JSON response body has a value at the given
+// path expression (e.g. "$.items[0].id").
+func JSONPathExists(path string) Assertion {
+	return func(exchange *Exchange) AssertionResult {
+		result := AssertionResult{Name: fmt.Sprintf("%s exists", path)}
+		_, found, err := lookupJSONPath(exchange.Body, path)
+		switch {
+		case err != nil:
+			result.Message = err.Error()
+		case !found:
+			result.Message = "no value at path"
+		default:
+			result.Passed = true
+		}
+		return result
+	}
+}
+
+// JSONPathEquals asserts that the value at the given path expression in the
+// JSON response body (e.g. "$.status") is equal to the given one, once encoded
+// as JSON; numbers are compared by value.
+func JSONPathEquals(path string, expected interface{}) Assertion {
+	return func(exchange *Exchange) AssertionResult {
+		result := AssertionResult{Name: fmt.Sprintf("%s is %s", path, render(expected))}
+		actual, found, err := lookupJSONPath(exchange.Body, path)
+		if err != nil {
+			result.Message = err.Error()
+			return result
+		}
+		if !found {
+			result.Message = "no value at path"
+			return result
+		}
+		var normalised interface{}
+		data, err := json.Marshal(expected)
+		if err == nil {
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.UseNumber()
+			err = decoder.Decode(&normalised)
+		}
+		if err != nil {
+			result.Message = fmt.Sprintf("invalid expected value: %v", err)
+			return result
+		}
+		result.Passed = equal(actual, normalised)
+		if !result.Passed {
+			result.Message = fmt.Sprintf("value is %s", render(actual))
+		}
+		return result
+	}
+}
+
+// LatencyUnder asserts that the response was received within the given time.
+func LatencyUnder(limit time.Duration) Assertion {
+	return func(exchange *Exchange) AssertionResult {
+		result := AssertionResult{Name: fmt.Sprintf("latency under %v", limit), Passed: exchange.Latency < limit}
+		if !result.Passed {
+			result.Message = fmt.Sprintf("latency is %v", exchange.Latency)
+		}
+		return result
+	}
+}
+
+// Assert sends the request generated by the given Builder and evaluates the
+// given assertions against the completed exchange, returning the outcome, which
+// can be recorded and reported (see ResultRecorder and Reporter) or checked in
+// a test via Check. As in scenarios, a non-2xx response is a failure only if
+// there are no assertions.
+func (r *Requestor) Assert(ctx context.Context, f *Builder, assertions ...Assertion) Result {
+	started := time.Now()
+	exchange := r.Execute(ctx, f)
+	result := Result{
+		StatusCode: exchange.StatusCode(),
+		Started:    started,
+		Latency:    exchange.Latency,
+		Assertions: evaluate(exchange, assertions),
+	}
+	if exchange.Request != nil {
+		result.Method = exchange.Request.Method
+		result.URL = RedactURL(exchange.Request.URL)
+		result.Name = result.Method + " " + result.URL
+	}
+	if exchange.Err != nil && (!IsHTTPError(exchange.Err) || len(assertions) == 0) {
+		result.Error = exchange.Err.Error()
+	}
+	return result
+}
+
+// Check reports the error and the failed assertions of the result, if any, via
+// the given testing.T, and returns whether it passed.
+func (r Result) Check(t TestingT) bool {
+	t.Helper()
+	if r.Error != "" {
+		t.Errorf("%s: %s", r.Name, r.Error)
+	}
+	for _, assertion := range r.Assertions {
+		if !assertion.Passed {
+			t.Errorf("%s: assertion %q failed: %s", r.Name, assertion.Name, assertion.Message)
+		}
+	}
+	return r.Passed()
+}
+
+// Execute sends the request generated by the given Builder and reads the whole
+// response body, returning the completed exchange; errors are reported in the
+// exchange rather than returned.
+func (r *Requestor) Execute(ctx context.Context, f *Builder) *Exchange {
+	request, err := r.build(f)
+	if err != nil {
+		return &Exchange{Err: err}
+	}
+	if ctx != nil {
+		request = f.bind(ctx, request)
+	}
+	return r.exchange(f, request)
+}
+
+// exchange sends the given request, generated by the given Builder, and reads
+// the whole response body.
+func (r *Requestor) exchange(f *Builder, request *http.Request) *Exchange {
+	exchange := &Exchange{Request: request}
+	started := time.Now()
+	response, err := r.do(f, request)
+	if err != nil {
+		exchange.Latency = time.Since(started)
+		exchange.Err = err
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			exchange.Body = httpErr.Body
+		}
+		return exchange
+	}
+	defer response.Body.Close()
+	exchange.Response = response
+	exchange.Body, exchange.Err = ioutil.ReadAll(response.Body)
+	exchange.Latency = time.Since(started)
+	return exchange
+}
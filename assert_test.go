@@ -0,0 +1,67 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAssert(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"down"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"status":"ok","checks":[{"name":"db","latency":12}]}`))
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(nil)
+	result := requestor.Assert(context.Background(), New(server.URL+"/health"),
+		StatusIn(200, 204),
+		HeaderEquals("Content-Type", "application/json"),
+		JSONPathEquals("$.status", "ok"),
+		JSONPathEquals("$.checks[0].latency", 12),
+		JSONPathExists("$.checks[0].name"),
+		BodyContains(`"db"`),
+		LatencyUnder(time.Minute),
+	)
+	if !result.Check(t) || len(result.Assertions) != 7 || result.Name != "GET "+server.URL+"/health" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	result = requestor.Assert(context.Background(), New(server.URL+"/down"),
+		StatusIn(200),
+		JSONPathEquals("$.status", "ok"),
+		JSONPathExists("$.missing"),
+		LatencyUnder(0),
+	)
+	if result.Passed() || result.Error != "" || result.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	for _, assertion := range result.Assertions {
+		if assertion.Passed || assertion.Message == "" {
+			t.Fatalf("expected assertion %q to fail with a message", assertion.Name)
+		}
+	}
+	if result.Assertions[0].Message != "status is 503" || result.Assertions[1].Message != `value is "down"` {
+		t.Fatalf("unexpected messages %+v", result.Assertions)
+	}
+	recorder := &recordingT{}
+	if result.Check(recorder) || len(recorder.errors) != 4 {
+		t.Fatalf("expected 4 reported failures, got %v", recorder.errors)
+	}
+
+	result = requestor.Assert(context.Background(), New(server.URL+"/down"))
+	if result.Passed() || result.Error == "" {
+		t.Fatalf("expected a non-2xx response to fail without assertions")
+	}
+}
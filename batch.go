@@ -0,0 +1,154 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// DefaultBatchConcurrency is the default maximum number of requests of a Batch
+// that are executed concurrently.
+const DefaultBatchConcurrency = 8
+
+// Batch is a set of requests executed with bounded concurrency, whose outcomes
+// are collected in input order.
+type Batch struct {
+	requestor   *Requestor
+	builders    []*Builder
+	concurrency int
+	attempts    int
+	backoff     time.Duration
+	progress    func(completed, total int)
+}
+
+// Batch creates a batch out of the requests generated by the given builders.
+func (r *Requestor) Batch(builders ...*Builder) *Batch {
+	return &Batch{
+		requestor:   r,
+		builders:    builders,
+		concurrency: DefaultBatchConcurrency,
+	}
+}
+
+// BatchFrom creates a batch of n requests, the i-th of which is generated by a
+// child of the given template customised by the given function, e.g. to set
+// the URL variables from the i-th input, as follows:
+//
+//	batch := requestor.BatchFrom(api.New(http.MethodGet, "/users/{id}"), len(ids), func(i int, f *Builder) {
+//		f.SetVariable("id", ids[i])
+//	})
+func (r *Requestor) BatchFrom(template *Builder, n int, customize func(i int, f *Builder)) *Batch {
+	builders := make([]*Builder, n)
+	for i := range builders {
+		builders[i] = template.New("", "")
+		if customize != nil {
+			customize(i, builders[i])
+		}
+	}
+	return r.Batch(builders...)
+}
+
+// Concurrency sets the maximum number of requests executed concurrently.
+func (b *Batch) Concurrency(concurrency int) *Batch {
+	if concurrency > 0 {
+		b.concurrency = concurrency
+	}
+	return b
+}
+
+// Retry makes the batch retry each failed item up to the given number of
+// attempts overall, with an exponential backoff starting at the given delay;
+// the Requestor retry decision (see RetryIf) applies, but items are retried
+// whatever their method, so this should only be enabled for requests that are
+// safe to repeat.
+func (b *Batch) Retry(attempts int, backoff time.Duration) *Batch {
+	b.attempts = attempts
+	b.backoff = backoff
+	return b
+}
+
+// Progress sets a callback invoked each time an item completes, with the number
+// of completed items and the batch size; calls are serialised.
+func (b *Batch) Progress(callback func(completed, total int)) *Batch {
+	b.progress = callback
+	return b
+}
+
+// Run executes the batch, and returns the exchanges in input order; if any item
+// failed, it also returns a *BatchError. Once the context is done, the items
+// not yet started fail with the context error.
+func (b *Batch) Run(ctx context.Context) ([]*Exchange, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	exchanges := make([]*Exchange, len(b.builders))
+	errs := make([]error, len(b.builders))
+	semaphore := make(chan struct{}, b.concurrency)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	completed := 0
+	for i, f := range b.builders {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			exchanges[i] = &Exchange{Err: ctx.Err()}
+			errs[i] = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, f *Builder) {
+			defer wg.Done()
+			exchange := b.run(ctx, f)
+			<-semaphore
+			exchanges[i] = exchange
+			errs[i] = exchange.Err
+			lock.Lock()
+			defer lock.Unlock()
+			completed++
+			if b.progress != nil {
+				b.progress(completed, len(b.builders))
+			}
+		}(i, f)
+	}
+	wg.Wait()
+	return exchanges, NewBatchError(errs)
+}
+
+// run executes a single item, retrying it as needed.
+func (b *Batch) run(ctx context.Context, f *Builder) *Exchange {
+	request, err := b.requestor.build(f)
+	if err != nil {
+		return &Exchange{Err: err}
+	}
+	request = f.bind(ctx, request)
+	classify, retryable := b.requestor.policy()
+	for attempt := 1; ; attempt++ {
+		current := request
+		if attempt > 1 {
+			if current, err = rewind(request); err != nil {
+				return &Exchange{Request: request, Err: err}
+			}
+		}
+		exchange := b.requestor.exchange(f, current)
+		if exchange.Err == nil || attempt >= b.attempts || ctx.Err() != nil || !retryable(classify(exchange.Err), exchange.Err) {
+			return exchange
+		}
+		var httpErr *HTTPError
+		errors.As(exchange.Err, &httpErr)
+		delay := backoff(b.backoff, attempt, httpErr)
+		log.Debugf("batch item %s %s failed (%v), retrying in %v", request.Method, RedactURL(request.URL), exchange.Err, delay)
+		b.requestor.publish(&RetryScheduled{At: time.Now(), Method: request.Method, URL: RedactURL(request.URL), Attempt: attempt + 1, Delay: delay, Err: exchange.Err})
+		if !sleep(ctx, delay) {
+			return exchange
+		}
+	}
+}
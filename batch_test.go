@@ -0,0 +1,85 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	var current, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&current, 1)
+		defer atomic.AddInt32(&current, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		id := strings.TrimPrefix(r.URL.Path, "/items/")
+		if id == "3" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(id))
+	}))
+	defer server.Close()
+
+	var lock sync.Mutex
+	calls := []int{}
+	requestor := NewRequestor(getClient())
+	batch := requestor.BatchFrom(New(server.URL+"/items/{id}"), 10, func(i int, f *Builder) {
+		f.Set().Variable("id", strconv.Itoa(i))
+	}).Concurrency(3).Progress(func(completed, total int) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls = append(calls, completed)
+	})
+	exchanges, err := batch.Run(context.Background())
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failures) != 1 || batchErr.Failures[0].Index != 3 || !IsNotFound(err) {
+		t.Fatalf("expected item 3 to fail, got %v", err)
+	}
+	for i, exchange := range exchanges {
+		if i != 3 && string(exchange.Body) != strconv.Itoa(i) {
+			t.Fatalf("invalid body for item %d: %q", i, exchange.Body)
+		}
+	}
+	if peak > 3 {
+		t.Fatalf("concurrency limit exceeded: %d", peak)
+	}
+	if len(calls) != 10 || calls[9] != 10 {
+		t.Fatalf("invalid progress calls: %v", calls)
+	}
+}
+
+func TestBatchRetry(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient())
+	exchanges, err := requestor.Batch(New(server.URL).Post()).Retry(2, time.Millisecond).Run(context.Background())
+	if err != nil || string(exchanges[0].Body) != "ok" {
+		t.Fatalf("expected the item to be retried, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValueEncoder formats a value extracted from a struct field as a query
+// parameter, header or form field value (see QueryParametersFrom, HeadersFrom
+// and WithFormEntity).
+type ValueEncoder func(value interface{}) string
+
+// RFC3339Time is a ValueEncoder formatting time.Time values as per RFC 3339,
+// e.g. "2006-01-02T15:04:05Z".
+func RFC3339Time(value interface{}) string {
+	return value.(time.Time).Format(time.RFC3339)
+}
+
+// EpochSeconds is a ValueEncoder formatting time.Time values as the number of
+// seconds since the Unix epoch.
+func EpochSeconds(value interface{}) string {
+	return strconv.FormatInt(value.(time.Time).Unix(), 10)
+}
+
+// ValueEncoder registers the ValueEncoder used to format the values of the same
+// type as the given sample, e.g.:
+//
+//	f.ValueEncoder(time.Time{}, request.EpochSeconds).
+//		ValueEncoder(Status(0), func(v interface{}) string { return v.(Status).Wire() })
+//
+// Values of types without a ValueEncoder are formatted with fmt; slices of
+// values with a ValueEncoder are formatted element by element, as multiple
+// values.
+func (f *Builder) ValueEncoder(sample interface{}, encoder ValueEncoder) *Builder {
+	encoders := map[reflect.Type]ValueEncoder{}
+	for t, e := range f.encoders {
+		encoders[t] = e
+	}
+	encoders[reflect.TypeOf(sample)] = encoder
+	// the map is shared with the children, thus replaced rather than modified
+	f.encoders = encoders
+	return f
+}
+
+// WithFormEntity sets the request body to the URL-encoded form extracted from
+// a struct (and tagged with "form") or from a map[string][]string, and the
+// Content-Type to "application/x-www-form-urlencoded", unless already set.
+func (f *Builder) WithFormEntity(source interface{}) *Builder {
+	form := url.Values{}
+	for key, values := range f.valuesFrom("form", source) {
+		form[key] = values
+	}
+	unlock := f.read()
+	contentType := f.headers.Get("Content-Type")
+	unlock()
+	if contentType == "" {
+		f.ContentType("application/x-www-form-urlencoded")
+	}
+	return f.WithEntity(strings.NewReader(form.Encode()))
+}
+
+// valuesFrom extracts the values tagged with the given tag from the given
+// struct or map, formatting them with the registered ValueEncoders.
+func (f *Builder) valuesFrom(tag string, source interface{}) map[string][]string {
+	defer f.read()()
+	return getValuesFromWith(tag, source, f.encoders)
+}
+
+// format formats the given value with the given ValueEncoders, if any of them
+// applies, or with fmt otherwise.
+func format(value interface{}, encoders map[reflect.Type]ValueEncoder) []string {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+		value = v.Interface()
+	}
+	if encoder, ok := encoders[reflect.TypeOf(value)]; ok {
+		return []string{encoder(value)}
+	}
+	if value != nil && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) {
+		if encoder, ok := encoders[v.Type().Elem()]; ok {
+			result := make([]string, v.Len())
+			for i := range result {
+				result[i] = encoder(v.Index(i).Interface())
+			}
+			return result
+		}
+	}
+	return []string{fmt.Sprintf("%v", value)}
+}
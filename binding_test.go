@@ -0,0 +1,80 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+type status int
+
+func (s status) wire() string {
+	return [...]string{"open", "closed"}[s]
+}
+
+func TestValueEncoders(t *testing.T) {
+	since := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	type filter struct {
+		Since    time.Time   `parameter:"since"`
+		Until    *time.Time  `parameter:"until"`
+		Status   status      `parameter:"status"`
+		Statuses []status    `parameter:"statuses"`
+		Dates    []time.Time `parameter:"dates"`
+		Other    int         `parameter:"other"`
+	}
+	source := filter{Since: since, Until: &since, Status: 1, Statuses: []status{0, 1}, Dates: []time.Time{since, since.Add(time.Second)}, Other: 7}
+	base := New("http://www.example.com/").
+		ValueEncoder(time.Time{}, RFC3339Time).
+		ValueEncoder(status(0), func(v interface{}) string { return v.(status).wire() })
+	f := base.New("", "").ValueEncoder(time.Time{}, EpochSeconds).QueryParametersFrom(source).
+		HeadersFrom(struct {
+			Since time.Time `header:"X-Since"`
+		}{since})
+	expected := map[string][]string{
+		"since":    {"1577934245"},
+		"until":    {"1577934245"},
+		"status":   {"closed"},
+		"statuses": {"open", "closed"},
+		"dates":    {"1577934245", "1577934246"},
+		"other":    {"7"},
+	}
+	for key, values := range expected {
+		if actual := f.parameters[key]; len(actual) != len(values) || actual[0] != values[0] || actual[len(actual)-1] != values[len(values)-1] {
+			t.Fatalf("parameter %s: expected %v, got %v", key, values, actual)
+		}
+	}
+	if f.headers.Get("X-Since") != "1577934245" {
+		t.Fatalf("expected epoch seconds in X-Since, got %q", f.headers.Get("X-Since"))
+	}
+	g := base.New("", "").QueryParametersFrom(source)
+	if g.parameters.Get("since") != "2020-01-02T03:04:05Z" {
+		t.Fatalf("expected RFC 3339 in the parent encoders, got %q", g.parameters.Get("since"))
+	}
+}
+
+func TestWithFormEntity(t *testing.T) {
+	type login struct {
+		Username string    `form:"username"`
+		Password string    `form:"password"`
+		When     time.Time `form:"when"`
+		Ignored  string
+	}
+	request, err := New("http://www.example.com/").
+		Post().
+		ValueEncoder(time.Time{}, EpochSeconds).
+		WithFormEntity(&login{Username: "john doe", Password: "a&b", When: time.Unix(42, 0)}).
+		Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		t.Fatalf("unexpected Content-Type %q", request.Header.Get("Content-Type"))
+	}
+	if data, _ := ioutil.ReadAll(request.Body); string(data) != "password=a%26b&username=john+doe&when=42" {
+		t.Fatalf("unexpected form %q", data)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithStringEntity sets the request body to the given string, and the
+// Content-Type to the given one, unless empty.
+func (f *Builder) WithStringEntity(entity string, contentType string) *Builder {
+	if contentType != "" {
+		f.ContentType(contentType)
+	}
+	return f.WithEntity(strings.NewReader(entity))
+}
+
+// WithBytesEntity sets the request body to the given bytes, and the
+// Content-Type to the given one, unless empty.
+func (f *Builder) WithBytesEntity(entity []byte, contentType string) *Builder {
+	if contentType != "" {
+		f.ContentType(contentType)
+	}
+	return f.WithEntity(bytes.NewReader(entity))
+}
+
+// WithFileEntity sets the request body to the contents of the file at the
+// given path, and the Content-Type, unless already set, according to its
+// extension (application/octet-stream if unknown). The file is opened when
+// the request body is first read, and re-opened for redirects and retries; its
+// size is determined by Make, which fails if the file does not exist.
+func (f *Builder) WithFileEntity(path string) *Builder {
+	f.contentTypeOf(path)
+	return f.WithEntity(&lazyBody{
+		open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+		stat: func() (os.FileInfo, error) {
+			return os.Stat(path)
+		},
+	})
+}
+
+// WithFSEntity sets the request body to the contents of the file with the
+// given name in the given file system (e.g. an embed.FS with fixtures), and
+// the Content-Type as in WithFileEntity; the file is opened lazily, and
+// re-opened for redirects and retries, as in WithFileEntity.
+func (f *Builder) WithFSEntity(fsys fs.FS, name string) *Builder {
+	f.contentTypeOf(name)
+	return f.WithEntity(&lazyBody{
+		open: func() (io.ReadCloser, error) {
+			return fsys.Open(name)
+		},
+		stat: func() (os.FileInfo, error) {
+			return fs.Stat(fsys, name)
+		},
+	})
+}
+
+// contentTypeOf sets the Content-Type, unless already set, according to the
+// extension of the given file name.
+func (f *Builder) contentTypeOf(name string) {
+	unlock := f.read()
+	contentType := f.headers.Get("Content-Type")
+	unlock()
+	if contentType != "" {
+		return
+	}
+	if contentType = mime.TypeByExtension(filepath.Ext(name)); contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	f.ContentType(contentType)
+}
+
+// lazyBody is a request body opened when first read, as many times as needed.
+type lazyBody struct {
+	open func() (io.ReadCloser, error)
+	stat func() (os.FileInfo, error)
+}
+
+// Read is only there to make lazyBody an io.Reader: the body is read via the
+// readers returned by reader.
+func (b *lazyBody) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+// reader returns a new reader of the body.
+func (b *lazyBody) reader() *lazyReader {
+	return &lazyReader{body: b}
+}
+
+// lazyReader reads a lazyBody, which is opened when first read.
+type lazyReader struct {
+	body   *lazyBody
+	reader io.ReadCloser
+	err    error
+}
+
+// Read opens the body if not done yet, and reads from it.
+func (r *lazyReader) Read(p []byte) (int, error) {
+	if r.reader == nil && r.err == nil {
+		r.reader, r.err = r.body.open()
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.reader.Read(p)
+}
+
+// Close closes the body, if it was opened.
+func (r *lazyReader) Close() error {
+	if r.reader == nil {
+		return nil
+	}
+	return r.reader.Close()
+}
+
+// ContentLength declares the size of the request body, e.g. for a stream whose
+// size is known to the caller but cannot be determined otherwise, so that it is
+// not sent chunked; the body must have exactly that size.
+func (f *Builder) ContentLength(length int64) *Builder {
+	f.length = &length
+	return f
+}
+
+// Chunked makes the request body be sent with chunked transfer encoding (over
+// HTTP/1.1), without a Content-Length, even if its size is known.
+func (f *Builder) Chunked() *Builder {
+	length := int64(-1)
+	f.length = &length
+	return f
+}
+
+// size sets the length of the body of the given request, and makes it
+// replayable via GetBody for redirects and retries, when it is known: besides
+// the in-memory bodies supported by http.NewRequest, this applies to regular
+// files and io.SectionReaders, which are read from their current offset via
+// ReadAt, and are not closed, so that they can be sent more than once.
+func (f *Builder) size(request *http.Request, body io.Reader) error {
+	var section func() *io.SectionReader
+	switch b := body.(type) {
+	case *lazyReader:
+		info, err := b.body.stat()
+		if err != nil {
+			return err
+		}
+		request.ContentLength = info.Size()
+		request.GetBody = func() (io.ReadCloser, error) {
+			return b.body.reader(), nil
+		}
+		if request.ContentLength == 0 {
+			request.Body, request.GetBody = http.NoBody, func() (io.ReadCloser, error) { return http.NoBody, nil }
+		}
+	case *os.File:
+		info, err := b.Stat()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			break
+		}
+		offset, err := b.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		section = func() *io.SectionReader { return io.NewSectionReader(b, offset, info.Size()-offset) }
+	case *io.SectionReader:
+		offset, err := b.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		section = func() *io.SectionReader { return io.NewSectionReader(b, offset, b.Size()-offset) }
+	}
+	if section != nil {
+		request.ContentLength = section().Size()
+		request.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(section()), nil
+		}
+		request.Body, _ = request.GetBody()
+		if request.ContentLength == 0 {
+			request.Body, request.GetBody = http.NoBody, func() (io.ReadCloser, error) { return http.NoBody, nil }
+		}
+	}
+	if f.length != nil && request.Body != nil && request.Body != http.NoBody {
+		request.ContentLength = *f.length
+	}
+	return nil
+}
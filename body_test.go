@@ -0,0 +1,169 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFileBodySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "body.txt")
+	if err := ioutil.WriteFile(path, []byte("skip:payload"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer file.Close()
+	file.Seek(5, io.SeekStart)
+	f := New("http://www.example.com/").Put().WithEntity(file)
+	for i := 0; i < 2; i++ {
+		request, err := f.Make()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if request.ContentLength != 7 || request.GetBody == nil {
+			t.Fatalf("expected a replayable body of 7 bytes, got %d bytes", request.ContentLength)
+		}
+		for j := 0; j < 2; j++ {
+			body, _ := request.GetBody()
+			if data, _ := ioutil.ReadAll(body); string(data) != "payload" {
+				t.Fatalf("expected \"payload\", got %q", data)
+			}
+		}
+		if data, _ := ioutil.ReadAll(request.Body); string(data) != "payload" {
+			t.Fatalf("expected \"payload\", got %q", data)
+		}
+	}
+	request, err := New("http://www.example.com/").Put().WithEntity(io.NewSectionReader(strings.NewReader("0123456789"), 2, 3)).Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.ContentLength != 3 || request.GetBody == nil {
+		t.Fatalf("expected a replayable body of 3 bytes, got %d bytes", request.ContentLength)
+	}
+}
+
+func TestContentLengthControl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Length", strconv.FormatInt(r.ContentLength, 10))
+		w.Header().Set("X-Chunked", strconv.FormatBool(len(r.TransferEncoding) > 0))
+		w.Header().Set("X-Body", string(data))
+	}))
+	defer server.Close()
+	testCases := []struct {
+		builder *Builder
+		length  string
+		chunked string
+	}{
+		{New(server.URL).Post().WithEntity(strings.NewReader("known")), "5", "false"},
+		{New(server.URL).Post().WithEntity(strings.NewReader("known")).Chunked(), "-1", "true"},
+		{New(server.URL).Post().WithEntity(ioutil.NopCloser(strings.NewReader("stream"))), "-1", "true"},
+		{New(server.URL).Post().WithEntity(ioutil.NopCloser(strings.NewReader("stream"))).ContentLength(6), "6", "false"},
+	}
+	for i, test := range testCases {
+		response, err := NewRequestor(nil).Do(context.Background(), test.builder)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		response.Body.Close()
+		if response.Header.Get("X-Length") != test.length || response.Header.Get("X-Chunked") != test.chunked {
+			t.Fatalf("test %d: expected length %s and chunked %s, got %s and %s", i, test.length, test.chunked, response.Header.Get("X-Length"), response.Header.Get("X-Chunked"))
+		}
+		if body := response.Header.Get("X-Body"); body != "known" && body != "stream" {
+			t.Fatalf("test %d: unexpected body %q", i, body)
+		}
+	}
+}
+
+func TestEntityHelpers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payload.json")
+	if err := ioutil.WriteFile(path, []byte(`{"a":1}`), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testCases := []struct {
+		builder     *Builder
+		contentType string
+		body        string
+	}{
+		{New("http://www.example.com/").WithStringEntity("text", "text/plain"), "text/plain", "text"},
+		{New("http://www.example.com/").WithBytesEntity([]byte{1, 2}, "application/octet-stream"), "application/octet-stream", "\x01\x02"},
+		{New("http://www.example.com/").WithFileEntity(path), "application/json", `{"a":1}`},
+		{New("http://www.example.com/").ContentType("text/plain").WithFileEntity(path), "text/plain", `{"a":1}`},
+	}
+	for i, test := range testCases {
+		for j := 0; j < 2; j++ {
+			request, err := test.builder.Make()
+			if err != nil {
+				t.Fatalf("test %d: unexpected error: %v", i, err)
+			}
+			if request.Header.Get("Content-Type") != test.contentType {
+				t.Fatalf("test %d: expected Content-Type %q, got %q", i, test.contentType, request.Header.Get("Content-Type"))
+			}
+			if request.ContentLength != int64(len(test.body)) || request.GetBody == nil {
+				t.Fatalf("test %d: expected a replayable body of %d bytes, got %d bytes", i, len(test.body), request.ContentLength)
+			}
+			body, _ := request.GetBody()
+			if data, _ := ioutil.ReadAll(body); string(data) != test.body {
+				t.Fatalf("test %d: expected %q, got %q", i, test.body, data)
+			}
+			body.Close()
+			if data, _ := ioutil.ReadAll(request.Body); string(data) != test.body {
+				t.Fatalf("test %d: expected %q, got %q", i, test.body, data)
+			}
+			request.Body.Close()
+		}
+	}
+	if _, err := New("http://www.example.com/").WithFileEntity(filepath.Join(t.TempDir(), "missing.bin")).Make(); !os.IsNotExist(err) {
+		t.Fatalf("expected a not-exist error, got %v", err)
+	}
+}
+
+func TestWithFSEntity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path != "/again" {
+			// the redirect replays the body
+			http.Redirect(w, r, "/again", http.StatusTemporaryRedirect)
+			return
+		}
+		w.Header().Set("X-Body", string(data))
+	}))
+	defer server.Close()
+	fsys := fstest.MapFS{"fixtures/user.json": &fstest.MapFile{Data: []byte(`{"user":1}`)}}
+	f := New(server.URL).Post().WithFSEntity(fsys, "fixtures/user.json")
+	request, err := f.Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.ContentLength != 10 || request.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected length %d or Content-Type %q", request.ContentLength, request.Header.Get("Content-Type"))
+	}
+	for i := 0; i < 2; i++ {
+		response, err := NewRequestor(nil).Do(context.Background(), f)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response.Body.Close()
+		if response.Header.Get("X-Body") != `{"user":1}` {
+			t.Fatalf("expected the file as the body, got %q", response.Header.Get("X-Body"))
+		}
+	}
+	if _, err := New(server.URL).WithFSEntity(fsys, "missing.json").Make(); err == nil {
+		t.Fatalf("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// ErrResponseTooLarge is returned when a response body exceeds the maximum
+// allowed size.
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// MaxResponseBytes sets the maximum size of the response bodies, after they
+// are decompressed, so that a misbehaving server cannot exhaust memory when
+// they are decoded: the responses announcing a larger Content-Length fail
+// right away, the others fail with ErrResponseTooLarge as soon as the limit is
+// exceeded while reading the body. Zero or a negative size removes the limit.
+func (f *Builder) MaxResponseBytes(size int64) *Builder {
+	f.maxResponseBytes = size
+	return f
+}
+
+// limit enforces the maximum response body size on the given response; the
+// bodies of non-2xx responses are already bounded by the error body limit.
+func (f *Builder) limit(request *http.Request, response *http.Response) error {
+	if f.maxResponseBytes <= 0 || response.Body == nil || response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil
+	}
+	if response.ContentLength > f.maxResponseBytes {
+		response.Body.Close()
+		return fmt.Errorf("%s %s: %w (%d bytes, limit is %d)", request.Method, RedactURL(request.URL), ErrResponseTooLarge, response.ContentLength, f.maxResponseBytes)
+	}
+	response.Body = &limitedBody{ReadCloser: response.Body, remaining: f.maxResponseBytes, limit: f.maxResponseBytes}
+	return nil
+}
+
+// limitedBody is a response body failing once more than a given number of
+// bytes are read from it.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+// Read reads from the body, failing once the limit is exceeded.
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, fmt.Errorf("%w (limit is %d bytes)", ErrResponseTooLarge, b.limit)
+	}
+	// read one byte more than allowed to detect bodies exceeding the limit
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), fmt.Errorf("%w (limit is %d bytes)", ErrResponseTooLarge, b.limit)
+	}
+	return n, err
+}
+
+// ReadAll reads the given body up to the given number of bytes, and fails with
+// ErrResponseTooLarge if it is larger.
+func ReadAll(body io.Reader, limit int64) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return data, err
+	}
+	if int64(len(data)) > limit {
+		return data[:limit], fmt.Errorf("%w (limit is %d bytes)", ErrResponseTooLarge, limit)
+	}
+	return data, nil
+}
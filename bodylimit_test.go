@@ -0,0 +1,53 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxResponseBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stream" {
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(`{"name": "` + strings.Repeat("x", 100) + `"}`))
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(nil)
+	api := New(server.URL + "/").MaxResponseBytes(64)
+	if _, err := requestor.Do(context.Background(), api.New("", "fixed")); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected too large error from the Content-Length, got %v", err)
+	}
+	response, err := requestor.Do(context.Background(), api.New("", "stream"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var target struct{ Name string }
+	if err := response.Decode(&target); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected too large error while reading, got %v", err)
+	}
+
+	response, err = requestor.Do(context.Background(), api.New("", "stream").MaxResponseBytes(200))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := response.Decode(&target); err != nil || len(target.Name) != 100 {
+		t.Fatalf("unexpected result %q (%v)", target.Name, err)
+	}
+
+	if data, err := ReadAll(strings.NewReader("hello"), 5); err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected result %q (%v)", data, err)
+	}
+	if data, err := ReadAll(strings.NewReader("hello world"), 5); !errors.Is(err, ErrResponseTooLarge) || string(data) != "hello" {
+		t.Fatalf("unexpected result %q (%v)", data, err)
+	}
+}
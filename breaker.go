@@ -0,0 +1,345 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// ErrCircuitOpen is returned when a request is rejected without being sent
+// because the circuit breaker protecting its target is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int8
+
+const (
+	// CircuitClosed is the normal state, in which requests flow through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen is the state in which requests are rejected straight away.
+	CircuitOpen
+	// CircuitHalfOpen is the state in which a single probe request is let
+	// through to check whether the target has recovered.
+	CircuitHalfOpen
+)
+
+// String returns the name of the circuit state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", s)
+}
+
+// CircuitBreaker is a circuit breaker: after a number of consecutive failures it
+// opens, rejecting all requests with ErrCircuitOpen for a while; then it moves
+// to half-open and lets a single probe request through: if it succeeds the
+// circuit is closed again, otherwise it is re-opened. It is safe for concurrent
+// use.
+type CircuitBreaker struct {
+	lock      sync.Mutex
+	name      string
+	threshold int
+	timeout   time.Duration
+	state     CircuitState
+	failures  int
+	opened    time.Time
+	probing   bool
+	onChange  func(name string, from, to CircuitState)
+	store     Store
+}
+
+// circuitSnapshot is the shared state of a circuit breaker, as kept in a Store.
+type circuitSnapshot struct {
+	State    CircuitState `json:"state"`
+	Failures int          `json:"failures"`
+	Opened   time.Time    `json:"opened"`
+}
+
+// circuitTTL is how long the shared state of an unused breaker is kept.
+const circuitTTL = 24 * time.Hour
+
+// NewCircuitBreaker returns a new, closed CircuitBreaker that opens after
+// threshold consecutive failures and stays open for the given duration.
+func NewCircuitBreaker(name string, threshold int, timeout time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{
+		name:      name,
+		threshold: threshold,
+		timeout:   timeout,
+	}
+}
+
+// OnStateChange sets a callback invoked whenever the breaker changes state; the
+// callback is invoked synchronously, so it should not block.
+func (b *CircuitBreaker) OnStateChange(callback func(name string, from, to CircuitState)) *CircuitBreaker {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.onChange = callback
+	return b
+}
+
+// Share makes the breaker keep its state (but not the half-open probe, which is
+// tracked per process) in the given Store, under the key "circuit/<name>", so
+// that all the processes using the same breaker name and store (e.g. a Redis
+// instance shared by a fleet) open and close the circuit together; if the store
+// is unavailable, the breaker falls back to its local state.
+func (b *CircuitBreaker) Share(store Store) *CircuitBreaker {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.store = store
+	b.load()
+	return b
+}
+
+// Name returns the name of the breaker.
+func (b *CircuitBreaker) Name() string {
+	return b.name
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker) State() CircuitState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.load()
+	b.expire(time.Now())
+	return b.state
+}
+
+// Allow returns ErrCircuitOpen if a request cannot be sent; otherwise, the
+// caller must report the outcome of the request via Success(), Failure() or
+// Cancel().
+func (b *CircuitBreaker) Allow() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.load()
+	b.expire(time.Now())
+	switch b.state {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Success reports that a request succeeded.
+func (b *CircuitBreaker) Success() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.load()
+	b.probing = false
+	if b.state != CircuitClosed || b.failures != 0 {
+		b.failures = 0
+		if b.state != CircuitClosed {
+			b.transition(CircuitClosed)
+		}
+		b.save()
+	}
+}
+
+// Failure reports that a request failed.
+func (b *CircuitBreaker) Failure() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.load()
+	b.failures++
+	b.probing = false
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.failures >= b.threshold) {
+		b.opened = time.Now()
+		b.transition(CircuitOpen)
+	}
+	b.save()
+}
+
+// Cancel reports that a request was abandoned without a meaningful outcome,
+// e.g. because its context was cancelled.
+func (b *CircuitBreaker) Cancel() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.probing = false
+}
+
+// expire moves an open breaker to half-open once the open duration has elapsed;
+// it must be called with the lock held.
+func (b *CircuitBreaker) expire(now time.Time) {
+	if b.state == CircuitOpen && now.Sub(b.opened) >= b.timeout {
+		b.transition(CircuitHalfOpen)
+		b.save()
+	}
+}
+
+// load refreshes the state of the breaker from the shared store, if any; it
+// must be called with the lock held.
+func (b *CircuitBreaker) load() {
+	if b.store == nil {
+		return
+	}
+	data, ok, err := b.store.Get(context.Background(), "circuit/"+b.name)
+	if err != nil {
+		log.Errorf("circuit breaker %q: error loading shared state: %v", b.name, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	snapshot := circuitSnapshot{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		log.Errorf("circuit breaker %q: invalid shared state: %v", b.name, err)
+		return
+	}
+	b.failures = snapshot.Failures
+	b.opened = snapshot.Opened
+	if snapshot.State != b.state {
+		b.transition(snapshot.State)
+	}
+}
+
+// save writes the state of the breaker to the shared store, if any; it must be
+// called with the lock held.
+func (b *CircuitBreaker) save() {
+	if b.store == nil {
+		return
+	}
+	data, _ := json.Marshal(circuitSnapshot{State: b.state, Failures: b.failures, Opened: b.opened})
+	if err := b.store.Set(context.Background(), "circuit/"+b.name, data, circuitTTL); err != nil {
+		log.Errorf("circuit breaker %q: error saving shared state: %v", b.name, err)
+	}
+}
+
+// transition changes the state of the breaker and notifies the callback; it
+// must be called with the lock held.
+func (b *CircuitBreaker) transition(to CircuitState) {
+	from := b.state
+	b.state = to
+	log.Debugf("circuit breaker %q: %v => %v", b.name, from, to)
+	if b.onChange != nil {
+		b.onChange(b.name, from, to)
+	}
+}
+
+// isCircuitFailure returns whether the outcome of a request should count as a
+// failure for circuit breaking purposes: transport errors and 5xx responses
+// do, client errors and cancellations do not.
+func isCircuitFailure(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500
+	}
+	return err != nil
+}
+
+// CircuitBreaker sets a circuit breaker shared by this builder and by all the
+// children created from it afterwards.
+func (f *Builder) CircuitBreaker(breaker *CircuitBreaker) *Builder {
+	f.breaker = breaker
+	return f
+}
+
+// CircuitBreaker enables per-host circuit breaking: each target host gets its
+// own breaker, named after the host, which opens after threshold consecutive
+// failures and stays open for the given duration; the optional callback is
+// invoked on every state change.
+func (r *Requestor) CircuitBreaker(threshold int, timeout time.Duration, onChange func(host string, from, to CircuitState)) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.breakerThreshold = threshold
+	r.breakerTimeout = timeout
+	r.breakerCallback = onChange
+	return r
+}
+
+// ShareCircuits makes the per-host circuit breakers keep their state in the
+// given Store, so that they are shared by all the processes using it.
+func (r *Requestor) ShareCircuits(store Store) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.breakerStore = store
+	return r
+}
+
+// Circuit returns the circuit breaker of the given target host (as in
+// URL.Host), if per-host circuit breaking is enabled and any request was sent
+// to the host.
+func (r *Requestor) Circuit(host string) (*CircuitBreaker, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	breaker, ok := r.breakers[strings.ToLower(host)]
+	return breaker, ok
+}
+
+// circuits returns the circuit breakers protecting the given request.
+func (r *Requestor) circuits(f *Builder, request *http.Request) []*CircuitBreaker {
+	breakers := []*CircuitBreaker{}
+	if f.breaker != nil {
+		breakers = append(breakers, f.breaker)
+	}
+	host := strings.ToLower(request.URL.Host)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.breakerThreshold > 0 {
+		breaker, ok := r.breakers[host]
+		if !ok {
+			breaker = NewCircuitBreaker(host, r.breakerThreshold, r.breakerTimeout).OnStateChange(r.circuitChanged)
+			if r.breakerStore != nil {
+				breaker.Share(r.breakerStore)
+			}
+			r.breakers[host] = breaker
+		}
+		breakers = append(breakers, breaker)
+	}
+	return breakers
+}
+
+// admit checks that all the circuit breakers protecting the given request allow
+// it, and returns them; the outcome of the request must then be reported to
+// them via settle().
+func (r *Requestor) admit(f *Builder, request *http.Request) ([]*CircuitBreaker, error) {
+	breakers := r.circuits(f, request)
+	for i, breaker := range breakers {
+		if err := breaker.Allow(); err != nil {
+			for _, allowed := range breakers[:i] {
+				allowed.Cancel()
+			}
+			return nil, fmt.Errorf("%s %s: %w (%s)", request.Method, RedactURL(request.URL), err, breaker.Name())
+		}
+	}
+	return breakers, nil
+}
+
+// settle reports the outcome of a request to the circuit breakers that admitted
+// it; if the request was never sent, or its context was cancelled, the outcome
+// is not meaningful.
+func settle(breakers []*CircuitBreaker, request *http.Request, sent bool, err error) {
+	for _, breaker := range breakers {
+		switch {
+		case !sent || (err != nil && request.Context().Err() != nil):
+			breaker.Cancel()
+		case isCircuitFailure(err):
+			breaker.Failure()
+		default:
+			breaker.Success()
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	transitions := []string{}
+	breaker := NewCircuitBreaker("test", 2, 20*time.Millisecond).OnStateChange(func(name string, from, to CircuitState) {
+		transitions = append(transitions, from.String()+"=>"+to.String())
+	})
+	for i := 0; i < 2; i++ {
+		if err := breaker.Allow(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		breaker.Failure()
+	}
+	if breaker.State() != CircuitOpen || breaker.Allow() != ErrCircuitOpen {
+		t.Fatalf("breaker should be open")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if breaker.State() != CircuitHalfOpen {
+		t.Fatalf("breaker should be half-open, is %v", breaker.State())
+	}
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("probe should be allowed: %v", err)
+	}
+	if err := breaker.Allow(); err != ErrCircuitOpen {
+		t.Fatalf("only one probe should be allowed")
+	}
+	breaker.Failure()
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("breaker should be open again")
+	}
+	time.Sleep(30 * time.Millisecond)
+	breaker.Allow()
+	breaker.Success()
+	if breaker.State() != CircuitClosed {
+		t.Fatalf("breaker should be closed")
+	}
+	expected := []string{"closed=>open", "open=>half-open", "half-open=>open", "open=>half-open", "half-open=>closed"}
+	if len(transitions) != len(expected) {
+		t.Fatalf("invalid transitions: %v", transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Fatalf("invalid transition %d: expected %q, got %q", i, expected[i], transitions[i])
+		}
+	}
+}
+
+func TestRequestorCircuitBreaker(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	var opened int32
+	requestor := NewRequestor(getClient()).CircuitBreaker(2, time.Minute, func(host string, from, to CircuitState) {
+		if to == CircuitOpen && host == u.Host {
+			atomic.AddInt32(&opened, 1)
+		}
+	})
+	for i := 0; i < 3; i++ {
+		requestor.Do(context.Background(), New(server.URL+"/missing"))
+	}
+	if breaker, ok := requestor.Circuit(u.Host); !ok || breaker.State() != CircuitClosed {
+		t.Fatalf("client errors should not open the circuit")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := requestor.Do(context.Background(), New(server.URL)); !IsServerError(err) {
+			t.Fatalf("expected server error, got %v", err)
+		}
+	}
+	if _, err := requestor.Do(context.Background(), New(server.URL)); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected open circuit, got %v", err)
+	}
+	if atomic.LoadInt32(&calls) != 5 || atomic.LoadInt32(&opened) != 1 {
+		t.Fatalf("expected 5 calls and 1 opening, got %d and %d", calls, opened)
+	}
+}
+
+func TestBuilderCircuitBreaker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient())
+	f := New(server.URL).CircuitBreaker(NewCircuitBreaker("api", 1, time.Minute))
+	requestor.Do(context.Background(), f)
+	if _, err := requestor.Do(context.Background(), f.New("", "/other")); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected circuit to be shared with child builder, got %v", err)
+	}
+}
+
+func TestSharedCircuitBreaker(t *testing.T) {
+	store := NewMemoryStore()
+	first := NewCircuitBreaker("api", 2, time.Minute).Share(store)
+	second := NewCircuitBreaker("api", 2, time.Minute).Share(store)
+	first.Allow()
+	first.Failure()
+	second.Allow()
+	second.Failure()
+	if first.State() != CircuitOpen || second.State() != CircuitOpen {
+		t.Fatalf("shared breakers should both be open, got %v and %v", first.State(), second.State())
+	}
+	if err := first.Allow(); err != ErrCircuitOpen {
+		t.Fatalf("expected open circuit, got %v", err)
+	}
+	other := NewCircuitBreaker("other", 2, time.Minute).Share(store)
+	if other.State() != CircuitClosed {
+		t.Fatalf("breakers with different names should not share state")
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// ErrOffline is returned in offline mode when no cached response is available
+// for a request.
+var ErrOffline = errors.New("no cached response available in offline mode")
+
+// CacheMode controls how the requests generated by a Builder use the cache of
+// the Requestor.
+type CacheMode int8
+
+const (
+	// CacheDefault uses the cache as per the HTTP caching rules.
+	CacheDefault CacheMode = iota
+	// CacheBypass neither serves responses from the cache nor stores them.
+	CacheBypass
+	// CacheRefresh always sends the request, and stores the response.
+	CacheRefresh
+)
+
+// CacheMode sets how the requests generated by this builder and its children
+// use the cache of the Requestor, if any; a request can also opt out of the
+// cache via its Cache-Control header ("no-store" bypasses it, "no-cache" and
+// "max-age=0" force revalidation).
+func (f *Builder) CacheMode(mode CacheMode) *Builder {
+	f.caching = mode
+	return f
+}
+
+// CacheStats contains the counters of the cache of a Requestor.
+type CacheStats struct {
+	// Hits is the number of requests served from the cache without contacting
+	// the server.
+	Hits int64
+	// StaleHits is the number of requests served with a stale response while
+	// it was revalidated in the background.
+	StaleHits int64
+	// Revalidations is the number of cached responses confirmed as current by
+	// the server ("304 Not Modified").
+	Revalidations int64
+	// Misses is the number of cacheable requests that got a full response.
+	Misses int64
+}
+
+// Cache enables a private HTTP cache as per RFC 7234, backed by the given
+// Store (e.g. a MemoryStore, a FileStore or, if nil, an LRUStore of
+// DefaultCacheSize entries): the 2xx responses to GET requests are stored
+// unless marked as "no-store", and served without contacting the server while
+// fresh (as per the "max-age" directive of the Cache-Control header, or the
+// Expires header); stale responses are revalidated (see Revalidate), or served
+// while being revalidated in the background within the "stale-while-revalidate"
+// window. Responses served from the cache have FromCache set.
+func (r *Requestor) Cache(store Store) *Requestor {
+	r.Revalidate(store)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.freshness = true
+	return r
+}
+
+// Offline switches the Requestor to offline (replay) mode: requests are never
+// sent to the network, and are served exclusively from the cache, whether the
+// cached responses are fresh or not; requests without a cached response fail
+// with ErrOffline. Together with a FileStore-backed cache populated while
+// online, this allows demos, development without connectivity and
+// deterministic tests against real recorded traffic.
+func (r *Requestor) Offline(offline bool) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.offline = offline
+	return r
+}
+
+// CacheStats returns the counters of the cache.
+func (r *Requestor) CacheStats() CacheStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.stats
+}
+
+// count updates the counters of the cache.
+func (r *Requestor) count(update func(stats *CacheStats)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	update(&r.stats)
+}
+
+// cacheControl parses the Cache-Control header into its directives, with
+// lowercase names and unquoted values.
+func cacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, directive := range strings.Split(strings.Join(header.Values("Cache-Control"), ","), ",") {
+		kv := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+		if kv[0] == "" {
+			continue
+		}
+		value := ""
+		if len(kv) == 2 {
+			value = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		}
+		directives[strings.ToLower(kv[0])] = value
+	}
+	return directives
+}
+
+// directiveSeconds returns the value of a Cache-Control directive expressing
+// seconds.
+func directiveSeconds(directives map[string]string, name string) (time.Duration, bool) {
+	value, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, true
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// lifetime returns the freshness lifetime of a response with the given headers.
+func lifetime(header http.Header) time.Duration {
+	directives := cacheControl(header)
+	if _, noCache := directives["no-cache"]; noCache {
+		return 0
+	}
+	if maxAge, ok := directiveSeconds(directives, "max-age"); ok {
+		return maxAge
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		expiration, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		date, err := http.ParseTime(header.Get("Date"))
+		if err != nil {
+			return time.Until(expiration)
+		}
+		return expiration.Sub(date)
+	}
+	return 0
+}
+
+// age returns the current age of the cached response.
+func (v *validation) age(now time.Time) time.Duration {
+	age := now.Sub(v.Stored)
+	if initial, err := strconv.ParseInt(v.Header.Get("Age"), 10, 64); err == nil && initial > 0 {
+		age += time.Duration(initial) * time.Second
+	}
+	return age
+}
+
+// response returns a new response out of the cached one.
+func (v *validation) response(request *http.Request, age time.Duration) *http.Response {
+	header := v.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	return &http.Response{
+		Status:        v.Status,
+		StatusCode:    v.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(v.Body)),
+		ContentLength: int64(len(v.Body)),
+		Request:       request,
+	}
+}
+
+// cached returns the cached response to the given request if it is fresh, or
+// if it is stale but within the stale-while-revalidate window, in which case it
+// is revalidated in the background, or whenever available in offline mode;
+// otherwise, it returns nil.
+func (r *Requestor) cached(f *Builder, request *http.Request) *Response {
+	if r.offline && r.cacheable(f, request) {
+		if cached := r.lookup(request); cached != nil {
+			r.count(func(stats *CacheStats) { stats.Hits++ })
+			return &Response{Response: cached.response(request, cached.age(time.Now())), FromCache: true}
+		}
+		return nil
+	}
+	if !r.freshness || f.caching == CacheRefresh || !r.cacheable(f, request) {
+		return nil
+	}
+	directives := cacheControl(request.Header)
+	if _, noCache := directives["no-cache"]; noCache {
+		return nil
+	}
+	cached := r.lookup(request)
+	if cached == nil {
+		return nil
+	}
+	now := time.Now()
+	age, fresh := cached.age(now), lifetime(cached.Header)
+	if maxAge, ok := directiveSeconds(directives, "max-age"); ok && maxAge < fresh {
+		fresh = maxAge
+	}
+	if age < fresh {
+		log.Debugf("serving fresh cached response for %q", RedactURL(request.URL))
+		r.count(func(stats *CacheStats) { stats.Hits++ })
+		return &Response{Response: cached.response(request, age), FromCache: true}
+	}
+	stale, ok := directiveSeconds(cacheControl(cached.Header), "stale-while-revalidate")
+	if !ok || age >= fresh+stale || !r.refresh(f, request) {
+		return nil
+	}
+	log.Debugf("serving stale cached response for %q while revalidating", RedactURL(request.URL))
+	r.count(func(stats *CacheStats) { stats.StaleHits++ })
+	return &Response{Response: cached.response(request, age), Attempts: 0, FromCache: true}
+}
+
+// refresh revalidates the cached response to the given request in the
+// background, unless it is already being revalidated; it returns false if the
+// request cannot be sent again.
+func (r *Requestor) refresh(f *Builder, request *http.Request) bool {
+	if !replayable(request) {
+		return false
+	}
+	key := cacheKey(request)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.refreshing[key] {
+		r.refreshing[key] = true
+		go func() {
+			defer func() {
+				r.lock.Lock()
+				delete(r.refreshing, key)
+				r.lock.Unlock()
+			}()
+			response, err := r.collapse(f, request.Clone(context.Background()))
+			if err != nil {
+				log.Errorf("error revalidating cached response for %q: %v", RedactURL(request.URL), err)
+				return
+			}
+			io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+		}()
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheControl(t *testing.T) {
+	header := http.Header{"Cache-Control": []string{`max-age=60, stale-while-revalidate="30"`, "Private"}}
+	directives := cacheControl(header)
+	if directives["max-age"] != "60" || directives["stale-while-revalidate"] != "30" {
+		t.Fatalf("invalid directives: %v", directives)
+	}
+	if _, ok := directives["private"]; !ok {
+		t.Fatalf("directives without a value should be parsed")
+	}
+	if lifetime(header) != time.Minute {
+		t.Fatalf("invalid lifetime: %v", lifetime(header))
+	}
+	header = http.Header{
+		"Date":    []string{"Mon, 02 Jan 2006 15:04:05 GMT"},
+		"Expires": []string{"Mon, 02 Jan 2006 15:05:05 GMT"},
+	}
+	if lifetime(header) != time.Minute {
+		t.Fatalf("invalid lifetime from Expires: %v", lifetime(header))
+	}
+}
+
+func readAll(t *testing.T, requestor *Requestor, f *Builder) *Response {
+	response, err := requestor.Do(context.Background(), f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if string(body) != "content" {
+		t.Fatalf("invalid body %q", body)
+	}
+	return response
+}
+
+func TestCache(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/private":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/stale":
+			w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		}
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient()).Cache(nil)
+	readAll(t, requestor, New(server.URL+"/fresh"))
+	if response := readAll(t, requestor, New(server.URL+"/fresh")); !response.FromCache || count != 1 {
+		t.Fatalf("expected a fresh cache hit, got %d requests", count)
+	}
+	if response := readAll(t, requestor, New(server.URL+"/fresh").CacheMode(CacheBypass)); response.FromCache || count != 2 {
+		t.Fatalf("expected the cache to be bypassed, got %d requests", count)
+	}
+	if response := readAll(t, requestor, New(server.URL+"/fresh").Set().Header("Cache-Control", "no-cache")); response.FromCache || count != 3 {
+		t.Fatalf("expected the request to skip the cache, got %d requests", count)
+	}
+
+	atomic.StoreInt32(&count, 0)
+	readAll(t, requestor, New(server.URL+"/private"))
+	if response := readAll(t, requestor, New(server.URL+"/private")); response.FromCache || count != 2 {
+		t.Fatalf("no-store responses should not be cached, got %d requests", count)
+	}
+
+	atomic.StoreInt32(&count, 0)
+	readAll(t, requestor, New(server.URL+"/stale"))
+	if response := readAll(t, requestor, New(server.URL+"/stale")); !response.FromCache {
+		t.Fatalf("expected a stale cache hit")
+	}
+	for i := 0; i < 100 && atomic.LoadInt32(&count) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&count); n != 2 {
+		t.Fatalf("expected the stale response to be revalidated in the background, got %d requests", n)
+	}
+
+	stats := requestor.CacheStats()
+	if stats.Hits != 1 || stats.StaleHits != 1 || stats.Misses != 6 {
+		t.Fatalf("invalid cache statistics: %+v", stats)
+	}
+}
+
+func TestOffline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("content"))
+	}))
+	requestor := NewRequestor(getClient()).Cache(nil)
+	readAll(t, requestor, New(server.URL+"/recorded"))
+	server.Close()
+
+	requestor.Offline(true)
+	if response := readAll(t, requestor, New(server.URL+"/recorded")); !response.FromCache {
+		t.Fatalf("expected the response to be replayed from the cache")
+	}
+	if _, err := requestor.Do(context.Background(), New(server.URL+"/missing")); !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected an offline error, got %v", err)
+	}
+	if _, err := requestor.Do(context.Background(), New(server.URL+"/recorded").Post()); !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected an offline error, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// placeholder matches the "{{name}}" placeholders in injection templates.
+var placeholder = regexp.MustCompile(`\{\{\s*([_a-zA-Z][\w.-]*)\s*\}\}`)
+
+// Interpolate replaces all the "{{name}}" placeholders in the given template
+// with the corresponding values; it returns an error if any placeholder has no
+// value.
+func (v Values) Interpolate(template string) (string, error) {
+	missing := []string{}
+	result := placeholder.ReplaceAllStringFunc(template, func(match string) string {
+		key := placeholder.FindStringSubmatch(match)[1]
+		value, ok := v[key]
+		if !ok {
+			missing = append(missing, key)
+			return match
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("no value for placeholders %s in %q", strings.Join(missing, ", "), template)
+	}
+	return result, nil
+}
+
+// Capture returns an Extractor that stores under the given name the value found
+// at the given path expression (e.g. "$.token" or "data.items[0].id") in the
+// JSON response body; it fails if the path cannot be resolved.
+func Capture(name, path string) Extractor {
+	return func(exchange *Exchange, values Values) error {
+		value, ok, err := lookupJSONPath(exchange.Body, path)
+		if err != nil {
+			return fmt.Errorf("error capturing %q: %w", name, err)
+		}
+		if !ok {
+			return fmt.Errorf("error capturing %q: path %q not found in response", name, path)
+		}
+		values[name] = stringify(value)
+		return nil
+	}
+}
+
+// CaptureHeader returns an Extractor that stores under the given name the value
+// of the given response header (e.g. "Location"); it fails if the header is
+// missing.
+func CaptureHeader(name, header string) Extractor {
+	return func(exchange *Exchange, values Values) error {
+		value := exchange.Header().Get(header)
+		if value == "" {
+			return fmt.Errorf("error capturing %q: header %q not found in response", name, header)
+		}
+		values[name] = value
+		return nil
+	}
+}
+
+// CaptureFunc returns an Extractor that stores under the given name the value
+// computed by the given function out of the exchange, e.g. after decoding the
+// response body into a struct.
+func CaptureFunc(name string, extract func(exchange *Exchange) (string, error)) Extractor {
+	return func(exchange *Exchange, values Values) error {
+		value, err := extract(exchange)
+		if err != nil {
+			return fmt.Errorf("error capturing %q: %w", name, err)
+		}
+		values[name] = value
+		return nil
+	}
+}
+
+// Injector modifies a Builder using the values extracted so far, e.g. to add an
+// "Authorization" header carrying a previously captured token.
+type Injector func(f *Builder, values Values) error
+
+// Inject returns an Injector that sets the given header to the interpolated
+// template, e.g. Inject("Authorization", "Bearer {{token}}").
+func Inject(header, template string) Injector {
+	return func(f *Builder, values Values) error {
+		value, err := values.Interpolate(template)
+		if err != nil {
+			return err
+		}
+		f.SetHeader(header, value)
+		return nil
+	}
+}
+
+// InjectParameter returns an Injector that sets the given query parameter to the
+// interpolated template.
+func InjectParameter(parameter, template string) Injector {
+	return func(f *Builder, values Values) error {
+		value, err := values.Interpolate(template)
+		if err != nil {
+			return err
+		}
+		f.SetQueryParameter(parameter, value)
+		return nil
+	}
+}
+
+// InjectVariable returns an Injector that sets the given URL variable (as in
+// "/users/{id}") to the interpolated template.
+func InjectVariable(variable, template string) Injector {
+	return func(f *Builder, values Values) error {
+		value, err := values.Interpolate(template)
+		if err != nil {
+			return err
+		}
+		f.SetVariable(variable, value)
+		return nil
+	}
+}
+
+// InjectBody returns an Injector that sets the request body to the
+// interpolated template, e.g. InjectBody(`{"order": "{{id}}"}`); the
+// Content-Type must be set separately.
+func InjectBody(template string) Injector {
+	return func(f *Builder, values Values) error {
+		value, err := values.Interpolate(template)
+		if err != nil {
+			return err
+		}
+		f.WithEntity(strings.NewReader(value))
+		return nil
+	}
+}
+
+// Inject adds injectors to the step; they are applied in order to the step
+// Builder before the request is sent.
+func (s *Step) Inject(injectors ...Injector) *Step {
+	s.injectors = append(s.injectors, injectors...)
+	return s
+}
+
+// Request appends a new step to the scenario, whose request is generated by a
+// child of the given Builder, to which the step injectors are applied; this
+// allows multi-step flows to be expressed declaratively, as follows:
+//
+//	scenario.Request("login", api.New(http.MethodPost, "/login")).
+//		Extract(Capture("token", "$.token"))
+//	scenario.Request("profile", api.New(http.MethodGet, "/me")).
+//		Inject(Inject("Authorization", "Bearer {{token}}"))
+func (s *Scenario) Request(name string, f *Builder) *Step {
+	return s.Step(name, func(values Values) *Builder {
+		return f.New("", "")
+	})
+}
@@ -0,0 +1,79 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	values := Values{"token": "abc", "user.id": "42"}
+	if s, err := values.Interpolate("Bearer {{token}} for {{ user.id }}"); err != nil || s != "Bearer abc for 42" {
+		t.Fatalf("invalid interpolation: got %q (%v)", s, err)
+	}
+	if _, err := values.Interpolate("{{token}} {{missing}}"); err == nil {
+		t.Fatalf("expected error on missing value")
+	}
+}
+
+func TestCapture(t *testing.T) {
+	exchange := &Exchange{
+		Response: &Response{Response: &http.Response{Header: http.Header{"Location": {"/users/42"}}}},
+		Body:     []byte(`{"data": {"token": "abc", "id": 42}}`),
+	}
+	values := Values{}
+	for _, extractor := range []Extractor{Capture("token", "$.data.token"), Capture("id", "data.id"), CaptureHeader("location", "Location")} {
+		if err := extractor(exchange, values); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if values["token"] != "abc" || values["id"] != "42" || values["location"] != "/users/42" {
+		t.Fatalf("invalid captured values: %v", values)
+	}
+	if err := Capture("missing", "data.missing")(exchange, values); err == nil {
+		t.Fatalf("expected error on missing path")
+	}
+	if err := CaptureHeader("missing", "X-Missing")(exchange, values); err == nil {
+		t.Fatalf("expected error on missing header")
+	}
+}
+
+func TestInject(t *testing.T) {
+	f := New("https://www.example.com/users/{id}")
+	values := Values{"token": "abc", "id": "42"}
+	for _, injector := range []Injector{Inject("Authorization", "Bearer {{token}}"), InjectParameter("q", "{{id}}"), InjectVariable("id", "{{id}}")} {
+		if err := injector(f, values); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	request, _ := f.Make()
+	if request.Header.Get("Authorization") != "Bearer abc" || request.URL.String() != "https://www.example.com/users/42?q=42" {
+		t.Fatalf("invalid request: %v %v", request.URL, request.Header)
+	}
+	if err := Inject("Authorization", "{{missing}}")(f, values); err == nil {
+		t.Fatalf("expected error on missing value")
+	}
+}
+
+func TestScenarioCaptureInject(t *testing.T) {
+	server := newScenarioServer()
+	defer server.Close()
+
+	api := New(server.URL)
+	scenario := NewScenario("declarative")
+	scenario.Request("login", api.New(http.MethodPost, "/login")).Extract(Capture("token", "$.token"))
+	scenario.Request("profile", api.New(http.MethodGet, "/profile")).Inject(Inject("Authorization", "Bearer {{token}}"))
+	scenario.Request("logout", api.New(http.MethodPost, "/profile")).Inject(Inject("Authorization", "Bearer {{missing}}"))
+
+	result := scenario.Run(context.Background(), NewRequestor(getClient()), nil)
+	if !result.Steps[0].Passed() || !result.Steps[1].Passed() {
+		t.Fatalf("first two steps should have passed: %+v", result.Steps)
+	}
+	if !result.Steps[2].Failed() {
+		t.Fatalf("third step should have failed on missing value: %+v", result.Steps[2])
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// RequestDefinition is the declarative definition of a request in a Catalog;
+// the path and the header values can refer to variables as "{name}", and the
+// body is a text/template rendered with the variables as data (e.g.
+// "{{.name}}"), which can use the functions of TemplateFuncs.
+type RequestDefinition struct {
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Method      string            `json:"method,omitempty" yaml:"method,omitempty"`
+	Path        string            `json:"path,omitempty" yaml:"path,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body        string            `json:"body,omitempty" yaml:"body,omitempty"`
+	// Expect lists the expected 2xx status codes (any, if empty).
+	Expect []int `json:"expect,omitempty" yaml:"expect,omitempty"`
+}
+
+// Catalog is a set of named requests, defined declaratively (e.g. in a YAML
+// file, to drive smoke tests) relative to a base Builder, and sent as
+// Operations named after them:
+//
+//	catalog, err := request.LoadCatalog("smoke.yaml", yamlcassette.Codec{}, api)
+//	response, err := catalog.Do(ctx, requestor, "GetUser", map[string]interface{}{"id": 42}, &user)
+type Catalog struct {
+	base        *Builder
+	definitions map[string]*RequestDefinition
+	operations  map[string]*Operation
+	bodies      map[string]*template.Template
+}
+
+// variable matches the references to variables in paths and header values.
+var variable = regexp.MustCompile(`\{([_a-zA-Z]\w*)\}`)
+
+// NewCatalog returns a catalog of the given request definitions, by name,
+// relative to the given base Builder; it fails if a body template is invalid.
+func NewCatalog(base *Builder, definitions map[string]*RequestDefinition) (*Catalog, error) {
+	c := &Catalog{
+		base:        base,
+		definitions: definitions,
+		operations:  map[string]*Operation{},
+		bodies:      map[string]*template.Template{},
+	}
+	for name, definition := range definitions {
+		if definition == nil {
+			return nil, fmt.Errorf("empty definition of request %q", name)
+		}
+		c.operations[name] = &Operation{
+			Name:        name,
+			Description: definition.Description,
+			Method:      definition.Method,
+			Path:        definition.Path,
+			Expect:      definition.Expect,
+		}
+		if definition.Body != "" {
+			body, err := template.New(name).Funcs(TemplateFuncs()).Option("missingkey=error").Parse(definition.Body)
+			if err != nil {
+				return nil, fmt.Errorf("invalid body of request %q: %w", name, err)
+			}
+			c.bodies[name] = body
+		}
+	}
+	return c, nil
+}
+
+// LoadCatalog loads the catalog of the request definitions in the file at the
+// given path, as a map of definitions by name, relative to the given base
+// Builder; the codec can be nil for JSON files, whereas other formats need
+// their own codec, e.g. yamlcassette.Codec for YAML.
+func LoadCatalog(path string, codec CassetteCodec, base *Builder) (*Catalog, error) {
+	if codec == nil {
+		if ext := strings.ToLower(filepath.Ext(path)); ext != ".json" {
+			return nil, fmt.Errorf("no codec for catalog %q", path)
+		}
+		codec = JSONCodec{}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	definitions := map[string]*RequestDefinition{}
+	if err := codec.Unmarshal(data, &definitions); err != nil {
+		return nil, fmt.Errorf("invalid catalog %q: %w", path, err)
+	}
+	return NewCatalog(base, definitions)
+}
+
+// Names returns the sorted names of the requests in the catalog.
+func (c *Catalog) Names() []string {
+	names := make([]string, 0, len(c.definitions))
+	for name := range c.definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Operation returns the operation of the request with the given name.
+func (c *Catalog) Operation(name string) (*Operation, bool) {
+	operation, ok := c.operations[name]
+	return operation, ok
+}
+
+// Builder returns a Builder generating the request with the given name, with
+// the given variables interpolated in its path, headers and body; it fails if
+// the request is unknown, or if the headers or the body refer to a missing
+// variable.
+func (c *Catalog) Builder(name string, variables map[string]interface{}) (*Builder, error) {
+	operation, ok := c.operations[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown request %q", name)
+	}
+	definition := c.definitions[name]
+	f := operation.Builder(c.base)
+	values := map[string]string{}
+	for key, value := range variables {
+		values[key] = fmt.Sprintf("%v", value)
+		f.SetVariable(key, value)
+	}
+	for key, value := range definition.Headers {
+		var missing []string
+		value = variable.ReplaceAllStringFunc(value, func(reference string) string {
+			v, ok := values[reference[1:len(reference)-1]]
+			if !ok {
+				missing = append(missing, reference)
+			}
+			return v
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("request %q: header %s refers to undefined %s", name, key, strings.Join(missing, ", "))
+		}
+		f.SetHeader(key, value)
+	}
+	if body, ok := c.bodies[name]; ok {
+		var buffer bytes.Buffer
+		if err := body.Execute(&buffer, variables); err != nil {
+			return nil, fmt.Errorf("request %q: error rendering body: %w", name, err)
+		}
+		f.WithEntity(bytes.NewReader(buffer.Bytes()))
+	}
+	return f, nil
+}
+
+// Do sends the request with the given name, with the given variables, as an
+// Operation (see Operation.Do), decoding the response into the given target.
+func (c *Catalog) Do(ctx context.Context, r *Requestor, name string, variables map[string]interface{}, target interface{}) (*Response, error) {
+	f, err := c.Builder(name, variables)
+	if err != nil {
+		return nil, err
+	}
+	return c.operations[name].Do(ctx, r, f, target)
+}
@@ -0,0 +1,79 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if len(body) == 0 {
+			body = []byte("null")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+		w.Write([]byte(`{"method":"` + r.Method + `","path":"` + r.URL.Path + `","tenant":"` + r.Header.Get("X-Tenant") + `","body":` + string(body) + `}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	if err := ioutil.WriteFile(path, []byte(`{
+		"GetUser": {"method": "GET", "path": "users/{id}", "headers": {"X-Tenant": "{tenant}"}},
+		"CreateUser": {"method": "POST", "path": "users", "body": "{\"name\":\"{{.name}}\"}", "expect": [201]},
+		"DeleteUser": {"method": "DELETE", "path": "users/{id}", "expect": [204]}
+	}`), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	catalog, err := LoadCatalog(path, nil, New(server.URL+"/"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if names := catalog.Names(); len(names) != 3 || names[0] != "CreateUser" {
+		t.Fatalf("unexpected names %v", names)
+	}
+
+	requestor := NewRequestor(nil)
+	var echo struct {
+		Method string
+		Path   string
+		Tenant string
+		Body   map[string]string
+	}
+	if _, err := catalog.Do(context.Background(), requestor, "GetUser", map[string]interface{}{"id": 42, "tenant": "acme"}, &echo); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if echo.Method != http.MethodGet || echo.Path != "/users/42" || echo.Tenant != "acme" {
+		t.Fatalf("unexpected echo %+v", echo)
+	}
+	if _, err := catalog.Do(context.Background(), requestor, "CreateUser", map[string]interface{}{"name": "alice"}, &echo); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if echo.Body["name"] != "alice" {
+		t.Fatalf("unexpected echo %+v", echo)
+	}
+
+	if _, err := catalog.Builder("GetUser", map[string]interface{}{"id": 42}); err == nil {
+		t.Fatalf("expected error for undefined header variable")
+	}
+	if _, err := catalog.Builder("CreateUser", nil); err == nil {
+		t.Fatalf("expected error for undefined body variable")
+	}
+	if _, err := catalog.Do(context.Background(), requestor, "DeleteUser", map[string]interface{}{"id": 42}, nil); !errors.Is(err, ErrUnexpectedStatus) {
+		t.Fatalf("expected unexpected status, got %v", err)
+	}
+	if _, err := catalog.Builder("Unknown", nil); err == nil {
+		t.Fatalf("expected error for unknown request")
+	}
+}
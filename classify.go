@@ -0,0 +1,162 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// ErrorClass is the class of the error with which a request failed, used by
+// retry policies to decide whether to retry (e.g. a DNS timeout is transient,
+// an invalid certificate is not).
+type ErrorClass int
+
+const (
+	// ClassNone means that there was no error.
+	ClassNone ErrorClass = iota
+	// ClassUnknown is any error not recognised by the classifier.
+	ClassUnknown
+	// ClassCanceled means that the request context was cancelled.
+	ClassCanceled
+	// ClassTimeout is a timeout other than a DNS or connect timeout, e.g. the
+	// request deadline or a read timeout.
+	ClassTimeout
+	// ClassDNSNotFound means that the host name does not exist (NXDOMAIN).
+	ClassDNSNotFound
+	// ClassDNSTimeout means that the DNS server did not answer in time.
+	ClassDNSTimeout
+	// ClassDNSFailure is any other (possibly temporary) DNS failure.
+	ClassDNSFailure
+	// ClassConnectionRefused means that nothing is listening on the target port.
+	ClassConnectionRefused
+	// ClassConnectionReset means that the connection was reset or closed
+	// unexpectedly by the peer.
+	ClassConnectionReset
+	// ClassConnectTimeout means that the connection could not be established in
+	// time.
+	ClassConnectTimeout
+	// ClassTLSHandshake is a failure of the TLS handshake other than a
+	// certificate error, e.g. a protocol mismatch or a handshake timeout.
+	ClassTLSHandshake
+	// ClassTLSCertificate means that the server certificate is not valid (e.g.
+	// expired, self-signed or for another host).
+	ClassTLSCertificate
+	// ClassHTTP means that the server responded with a non-2xx status code.
+	ClassHTTP
+	// ClassCircuitOpen means that the request was rejected by a circuit breaker.
+	ClassCircuitOpen
+	// ClassRateLimited means that the request was rejected by a client-side rate
+	// limiter configured to fail fast.
+	ClassRateLimited
+)
+
+// String returns the name of the error class.
+func (c ErrorClass) String() string {
+	switch c {
+	case ClassNone:
+		return "none"
+	case ClassUnknown:
+		return "unknown"
+	case ClassCanceled:
+		return "canceled"
+	case ClassTimeout:
+		return "timeout"
+	case ClassDNSNotFound:
+		return "dns-not-found"
+	case ClassDNSTimeout:
+		return "dns-timeout"
+	case ClassDNSFailure:
+		return "dns-failure"
+	case ClassConnectionRefused:
+		return "connection-refused"
+	case ClassConnectionReset:
+		return "connection-reset"
+	case ClassConnectTimeout:
+		return "connect-timeout"
+	case ClassTLSHandshake:
+		return "tls-handshake"
+	case ClassTLSCertificate:
+		return "tls-certificate"
+	case ClassHTTP:
+		return "http"
+	case ClassCircuitOpen:
+		return "circuit-open"
+	case ClassRateLimited:
+		return "rate-limited"
+	}
+	return fmt.Sprintf("ErrorClass(%d)", c)
+}
+
+// Classify returns the class of the given error, as returned by a Requestor.
+func Classify(err error) ErrorClass {
+	if err == nil {
+		return ClassNone
+	}
+	var httpErr *HTTPError
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var netErr net.Error
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalidCertificate x509.CertificateInvalidError
+	var invalidHostname x509.HostnameError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ClassCanceled
+	case errors.As(err, &httpErr):
+		return ClassHTTP
+	case errors.Is(err, ErrCircuitOpen):
+		return ClassCircuitOpen
+	case errors.Is(err, ErrRateLimitExceeded):
+		return ClassRateLimited
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsNotFound {
+			return ClassDNSNotFound
+		} else if dnsErr.IsTimeout {
+			return ClassDNSTimeout
+		}
+		return ClassDNSFailure
+	case errors.As(err, &unknownAuthority), errors.As(err, &invalidCertificate), errors.As(err, &invalidHostname):
+		return ClassTLSCertificate
+	case strings.Contains(err.Error(), "tls: ") || strings.Contains(err.Error(), "TLS handshake"):
+		// the TLS handshake errors are mostly unexported
+		return ClassTLSHandshake
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ClassConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ClassConnectionReset
+	case errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout():
+		return ClassConnectTimeout
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ClassTimeout
+	}
+	return ClassUnknown
+}
+
+// DefaultRetryable is the default retry decision: transient network failures
+// (DNS timeouts and failures, refused and reset connections, timeouts and TLS
+// handshake failures) and "408 Request Timeout", "429 Too Many Requests", "502
+// Bad Gateway", "503 Service Unavailable" and "504 Gateway Timeout" responses
+// are retried; non-existent hosts, certificate errors, cancellations and all
+// the other errors are not.
+func DefaultRetryable(class ErrorClass, err error) bool {
+	switch class {
+	case ClassDNSTimeout, ClassDNSFailure, ClassConnectionRefused, ClassConnectionReset, ClassConnectTimeout, ClassTimeout, ClassTLSHandshake:
+		return true
+	case ClassHTTP:
+		switch StatusCode(err) {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
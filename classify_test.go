@@ -0,0 +1,78 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err   error
+		class ErrorClass
+	}{
+		{nil, ClassNone},
+		{errors.New("boom"), ClassUnknown},
+		{fmt.Errorf("wrapped: %w", context.Canceled), ClassCanceled},
+		{context.DeadlineExceeded, ClassTimeout},
+		{&HTTPError{StatusCode: 503}, ClassHTTP},
+		{fmt.Errorf("GET x: %w (host)", ErrCircuitOpen), ClassCircuitOpen},
+		{ErrRateLimitExceeded, ClassRateLimited},
+		{&net.DNSError{Err: "no such host", Name: "x.invalid", IsNotFound: true}, ClassDNSNotFound},
+		{&net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}, ClassDNSTimeout},
+		{&net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}, ClassDNSFailure},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, ClassConnectionRefused},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, ClassConnectionReset},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}, ClassConnectTimeout},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, ClassTimeout},
+		{x509.UnknownAuthorityError{}, ClassTLSCertificate},
+		{x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}, ClassTLSCertificate},
+		{errors.New("net/http: TLS handshake timeout"), ClassTLSHandshake},
+		{errors.New("remote error: tls: protocol version not supported"), ClassTLSHandshake},
+	}
+	for _, test := range tests {
+		if class := Classify(test.err); class != test.class {
+			t.Fatalf("error %v: expected %v, got %v", test.err, test.class, class)
+		}
+	}
+}
+
+func TestClassifyRealErrors(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, err := NewRequestor(&http.Client{}).Do(context.Background(), New(server.URL))
+	if class := Classify(err); class != ClassTLSCertificate {
+		t.Fatalf("expected certificate error, got %v (%v)", class, err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	_, err = NewRequestor(getClient()).Do(context.Background(), New("http://"+address+"/"))
+	if class := Classify(err); class != ClassConnectionRefused {
+		t.Fatalf("expected connection refused, got %v (%v)", class, err)
+	}
+}
+
+func TestDefaultRetryable(t *testing.T) {
+	if !DefaultRetryable(ClassHTTP, &HTTPError{StatusCode: 503}) || DefaultRetryable(ClassHTTP, &HTTPError{StatusCode: 500}) {
+		t.Fatalf("invalid retry decision on HTTP errors")
+	}
+	if DefaultRetryable(ClassTLSCertificate, nil) || DefaultRetryable(ClassDNSNotFound, nil) || !DefaultRetryable(ClassConnectionReset, nil) {
+		t.Fatalf("invalid retry decision on network errors")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Command requestgen generates a typed API client out of a JSON spec of
+// endpoints (see package codegen), e.g. via go:generate:
+//
+//	//go:generate requestgen -spec users.json -out users_client.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/dihedron/go-requestor/codegen"
+)
+
+func main() {
+	spec := flag.String("spec", "", "path to the JSON spec of the endpoints")
+	out := flag.String("out", "", "path to the generated file (default: standard output)")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package name, if not in the spec")
+	flag.Parse()
+	if *spec == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*spec, *out, *pkg); err != nil {
+		fmt.Fprintf(os.Stderr, "requestgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(path, out, pkg string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	spec, err := codegen.ParseSpec(data)
+	if err != nil {
+		return err
+	}
+	if spec.Package == "" {
+		spec.Package = pkg
+	}
+	source, err := codegen.Generate(spec)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(source)
+		return err
+	}
+	return ioutil.WriteFile(out, source, 0644)
+}
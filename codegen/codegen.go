@@ -0,0 +1,337 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package codegen generates typed API clients out of a declarative spec of
+// endpoints; the generated methods use a request Builder and Requestor
+// internally, so that all the policies configured on them (retries, caching,
+// rate limits...) apply, while callers get compile-time safety. The generator
+// can be run via go:generate, with the requestgen command:
+//
+//	//go:generate requestgen -spec users.json -out users_client.go
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Spec is the declarative description of an API client.
+type Spec struct {
+	// Package is the name of the package of the generated code.
+	Package string `json:"package"`
+	// Client is the name of the generated client type (default "Client").
+	Client string `json:"client,omitempty"`
+	// Endpoints are the endpoints, each becoming a method of the client.
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// Endpoint is an API endpoint.
+type Endpoint struct {
+	// Name is the name of the generated method, e.g. "CreateUser".
+	Name string `json:"name"`
+	// Doc is the documentation of the method; if empty, a default one is
+	// generated.
+	Doc string `json:"doc,omitempty"`
+	// Method is the HTTP method.
+	Method string `json:"method"`
+	// Path is the endpoint path, relative to the client builder URL, with its
+	// variables, e.g. "/users/{id}".
+	Path string `json:"path"`
+	// Variables are the types of the path variables; missing ones are strings.
+	Variables []Parameter `json:"variables,omitempty"`
+	// Query are the query parameters, passed as arguments.
+	Query []Parameter `json:"query,omitempty"`
+	// Headers are the headers, passed as arguments.
+	Headers []Parameter `json:"headers,omitempty"`
+	// Body is the Go type of the JSON request body, e.g. "CreateUserRequest",
+	// passed as a pointer.
+	Body string `json:"body,omitempty"`
+	// Response is the Go type the JSON response body is decoded into, e.g.
+	// "User", returned as a pointer.
+	Response string `json:"response,omitempty"`
+	// Expect lists the expected 2xx status codes (any, if empty).
+	Expect []int `json:"expect,omitempty"`
+}
+
+// Parameter is a named, typed argument of a generated method.
+type Parameter struct {
+	// Name is the name of the variable, query parameter or header.
+	Name string `json:"name"`
+	// Type is the Go type of the argument (default "string"); non-string
+	// values are formatted with fmt.Sprint.
+	Type string `json:"type,omitempty"`
+	// Arg is the name of the argument; if empty, it is derived from Name.
+	Arg string `json:"arg,omitempty"`
+}
+
+// ParseSpec decodes a JSON spec.
+func ParseSpec(data []byte) (*Spec, error) {
+	spec := &Spec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	return spec, nil
+}
+
+// variable matches the variables in endpoint paths.
+var variable = regexp.MustCompile(`\{([_a-zA-Z]\w*)\}`)
+
+// argument is an argument of a generated method.
+type argument struct {
+	Name  string
+	Key   string
+	Type  string
+	Value string
+}
+
+// method is a generated method.
+type method struct {
+	Name      string
+	Doc       []string
+	Method    string
+	Path      string
+	Variables []argument
+	Query     []argument
+	Headers   []argument
+	Body      string
+	Response  string
+	Expect    []int
+	// Description is the documentation as a single line.
+	Description string
+}
+
+// Generate returns the formatted Go source code of the client described by the
+// given spec.
+func Generate(spec *Spec) ([]byte, error) {
+	if !token.IsIdentifier(spec.Package) {
+		return nil, fmt.Errorf("invalid package name %q", spec.Package)
+	}
+	client := spec.Client
+	if client == "" {
+		client = "Client"
+	}
+	if !token.IsIdentifier(client) {
+		return nil, fmt.Errorf("invalid client name %q", client)
+	}
+	methods := []method{}
+	names := map[string]bool{}
+	for _, endpoint := range spec.Endpoints {
+		m, err := newMethod(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("endpoint %q: %w", endpoint.Name, err)
+		}
+		if names[m.Name] || names[m.Name+"Operation"] {
+			return nil, fmt.Errorf("duplicate endpoint %q", m.Name)
+		}
+		names[m.Name] = true
+		names[m.Name+"Operation"] = true
+		methods = append(methods, m)
+	}
+	var buffer bytes.Buffer
+	err := source.Execute(&buffer, struct {
+		Package string
+		Client  string
+		Methods []method
+		Format  bool
+	}{spec.Package, client, methods, needsFormat(methods)})
+	if err != nil {
+		return nil, err
+	}
+	formatted, err := format.Source(buffer.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("invalid generated code: %w\n%s", err, buffer.Bytes())
+	}
+	return formatted, nil
+}
+
+func newMethod(endpoint Endpoint) (method, error) {
+	if !token.IsIdentifier(endpoint.Name) || !token.IsExported(endpoint.Name) {
+		return method{}, fmt.Errorf("invalid method name %q", endpoint.Name)
+	}
+	m := method{
+		Name:     endpoint.Name,
+		Method:   strings.ToUpper(endpoint.Method),
+		Path:     endpoint.Path,
+		Body:     endpoint.Body,
+		Response: endpoint.Response,
+		Expect:   endpoint.Expect,
+	}
+	if m.Method == "" {
+		m.Method = "GET"
+	}
+	if !token.IsIdentifier(m.Method) {
+		return method{}, fmt.Errorf("invalid method %q", endpoint.Method)
+	}
+	doc := endpoint.Doc
+	if doc == "" {
+		doc = fmt.Sprintf("%s sends a %s request to %s.", m.Name, m.Method, m.Path)
+	}
+	m.Doc = strings.Split(strings.TrimSpace(doc), "\n")
+	m.Description = strings.Join(strings.Fields(doc), " ")
+	used := map[string]bool{"ctx": true, "body": true, "f": true, "response": true, "result": true, "err": true}
+	add := func(p Parameter) (argument, error) {
+		name := p.Arg
+		if name == "" {
+			name = identifier(p.Name)
+		}
+		if !token.IsIdentifier(name) || token.IsKeyword(name) || used[name] {
+			return argument{}, fmt.Errorf("invalid or duplicate argument name %q", name)
+		}
+		used[name] = true
+		a := argument{Name: name, Key: p.Name, Type: p.Type, Value: name}
+		if a.Type == "" {
+			a.Type = "string"
+		}
+		if a.Type != "string" {
+			a.Value = "fmt.Sprint(" + name + ")"
+		}
+		return a, nil
+	}
+	types := map[string]Parameter{}
+	for _, p := range endpoint.Variables {
+		types[p.Name] = p
+	}
+	for _, match := range variable.FindAllStringSubmatch(endpoint.Path, -1) {
+		p, ok := types[match[1]]
+		if !ok {
+			p = Parameter{Name: match[1]}
+		}
+		a, err := add(p)
+		if err != nil {
+			return method{}, err
+		}
+		m.Variables = append(m.Variables, a)
+	}
+	for _, p := range endpoint.Query {
+		a, err := add(p)
+		if err != nil {
+			return method{}, err
+		}
+		m.Query = append(m.Query, a)
+	}
+	for _, p := range endpoint.Headers {
+		a, err := add(p)
+		if err != nil {
+			return method{}, err
+		}
+		m.Headers = append(m.Headers, a)
+	}
+	return m, nil
+}
+
+// identifier turns a parameter name (e.g. "X-Request-Id" or "page_size") into
+// a Go argument name (e.g. "xRequestId" or "pageSize").
+func identifier(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var buffer strings.Builder
+	for i, word := range words {
+		runes := []rune(word)
+		if i == 0 {
+			runes[0] = unicode.ToLower(runes[0])
+		} else {
+			runes[0] = unicode.ToUpper(runes[0])
+		}
+		buffer.WriteString(string(runes))
+	}
+	result := buffer.String()
+	if result == "" || unicode.IsDigit([]rune(result)[0]) {
+		result = "p" + result
+	}
+	return result
+}
+
+func needsFormat(methods []method) bool {
+	for _, m := range methods {
+		for _, list := range [][]argument{m.Variables, m.Query, m.Headers} {
+			for _, a := range list {
+				if a.Type != "string" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+var source = template.Must(template.New("client").Parse(`// Code generated by requestgen; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+{{- if .Format}}
+	"fmt"
+{{- end}}
+
+	request "github.com/dihedron/go-requestor"
+)
+
+// {{.Client}} is a typed client for the API.
+type {{.Client}} struct {
+	builder   *request.Builder
+	requestor *request.Requestor
+}
+
+// New{{.Client}} returns a new {{.Client}}, whose requests are generated by
+// children of the given builder (which holds the API base URL) and sent by
+// the given requestor.
+func New{{.Client}}(builder *request.Builder, requestor *request.Requestor) *{{.Client}} {
+	return &{{.Client}}{builder: builder, requestor: requestor}
+}
+{{range .Methods}}
+// {{.Name}}Operation is the {{.Name}} operation.
+var {{.Name}}Operation = &request.Operation{
+	Name:        "{{.Name}}",
+	Description: {{printf "%q" .Description}},
+	Method:      "{{.Method}}",
+	Path:        {{printf "%q" .Path}},
+	{{- if .Expect}}
+	Expect:      []int{ {{- range $i, $code := .Expect}}{{if $i}}, {{end}}{{$code}}{{end -}} },
+	{{- end}}
+	{{- if .Response}}
+	Response:    *new({{.Response}}),
+	{{- end}}
+}
+
+{{range .Doc}}// {{.}}
+{{end -}}
+func (c *{{$.Client}}) {{.Name}}(ctx context.Context
+	{{- range .Variables}}, {{.Name}} {{.Type}}{{end}}
+	{{- range .Query}}, {{.Name}} {{.Type}}{{end}}
+	{{- range .Headers}}, {{.Name}} {{.Type}}{{end}}
+	{{- if .Body}}, body *{{.Body}}{{end}}) ({{if .Response}}*{{.Response}}, {{end}}error) {
+	f := {{.Name}}Operation.Builder(c.builder)
+	{{- range .Variables}}
+	f.SetVariable("{{.Key}}", {{.Value}})
+	{{- end}}
+	{{- range .Query}}
+	f.SetQueryParameter("{{.Key}}", {{.Value}})
+	{{- end}}
+	{{- range .Headers}}
+	f.SetHeader("{{.Key}}", {{.Value}})
+	{{- end}}
+	{{- if .Body}}
+	f.WithJSONEntity(body)
+	{{- end}}
+	{{- if .Response}}
+	result := new({{.Response}})
+	if _, err := {{.Name}}Operation.Do(ctx, c.requestor, f, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+	{{- else}}
+	_, err := {{.Name}}Operation.Do(ctx, c.requestor, f, nil)
+	return err
+	{{- end}}
+}
+{{end}}`))
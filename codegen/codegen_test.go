@@ -0,0 +1,94 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package codegen
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const spec = `{
+	"package": "users",
+	"client": "UserClient",
+	"endpoints": [
+		{"name": "CreateUser", "method": "post", "path": "/users", "body": "CreateUserRequest", "response": "User", "expect": [201]},
+		{"name": "GetUser", "path": "/users/{id}", "variables": [{"name": "id", "type": "int64"}], "response": "User"},
+		{"name": "ListUsers", "path": "/users", "query": [{"name": "page_size", "type": "int"}], "headers": [{"name": "X-Tenant"}], "response": "Users"},
+		{"name": "DeleteUser", "method": "DELETE", "path": "/users/{id}", "doc": "DeleteUser removes a user."}
+	]
+}`
+
+func TestGenerate(t *testing.T) {
+	s, err := ParseSpec([]byte(spec))
+	if err != nil {
+		t.Fatalf("error parsing spec: %v", err)
+	}
+	source, err := Generate(s)
+	if err != nil {
+		t.Fatalf("error generating code: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "users.go", source, 0); err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, source)
+	}
+	code := string(source)
+	for _, expected := range []string{
+		"// Code generated by requestgen; DO NOT EDIT.",
+		"package users",
+		`"fmt"`,
+		"func NewUserClient(builder *request.Builder, requestor *request.Requestor) *UserClient {",
+		"func (c *UserClient) CreateUser(ctx context.Context, body *CreateUserRequest) (*User, error) {",
+		"var CreateUserOperation = &request.Operation{",
+		`Method:      "POST",`,
+		"Expect:      []int{201},",
+		"Response:    *new(User),",
+		"f := CreateUserOperation.Builder(c.builder)",
+		"f.WithJSONEntity(body)",
+		"if _, err := CreateUserOperation.Do(ctx, c.requestor, f, result); err != nil {",
+		"func (c *UserClient) GetUser(ctx context.Context, id int64) (*User, error) {",
+		`f.SetVariable("id", fmt.Sprint(id))`,
+		"func (c *UserClient) ListUsers(ctx context.Context, pageSize int, xTenant string) (*Users, error) {",
+		`f.SetQueryParameter("page_size", fmt.Sprint(pageSize))`,
+		`f.SetHeader("X-Tenant", xTenant)`,
+		"// DeleteUser removes a user.",
+		"func (c *UserClient) DeleteUser(ctx context.Context, id string) error {",
+		`Description: "DeleteUser removes a user.",`,
+		"_, err := DeleteUserOperation.Do(ctx, c.requestor, f, nil)",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Fatalf("expected generated code to contain %q, got:\n%s", expected, code)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []Spec{
+		{Package: "not a package"},
+		{Package: "api", Endpoints: []Endpoint{{Name: "getUser", Path: "/users"}}},
+		{Package: "api", Endpoints: []Endpoint{{Name: "Get", Path: "/a"}, {Name: "Get", Path: "/b"}}},
+		{Package: "api", Endpoints: []Endpoint{{Name: "Get", Path: "/{id}", Query: []Parameter{{Name: "id"}}}}},
+		{Package: "api", Endpoints: []Endpoint{{Name: "Get", Path: "/x", Query: []Parameter{{Name: "type"}}}}},
+	}
+	for i, test := range tests {
+		if _, err := Generate(&test); err == nil {
+			t.Fatalf("test %d: expected error", i)
+		}
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"id":           "id",
+		"page_size":    "pageSize",
+		"X-Request-Id": "xRequestId",
+		"2fa":          "p2fa",
+	}
+	for name, expected := range tests {
+		if actual := identifier(name); actual != expected {
+			t.Fatalf("expected %q for %q, got %q", expected, name, actual)
+		}
+	}
+}
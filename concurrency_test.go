@@ -0,0 +1,122 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentUse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	type payload struct {
+		Name string `json:"name"`
+	}
+	base := New(server.URL+"/").
+		UserAgent("worker/1.0").
+		Add().QueryParameter("version", "1").
+		WithJSONEntity(payload{Name: "acme"}).
+		Post()
+	requestor := NewRequestor(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f := base.New("", fmt.Sprintf("items/%d", i)).Set().Header("X-Item", fmt.Sprint(i))
+			if i%2 == 0 {
+				f.Var("tenant", "acme").WithJSONEntity(payload{Name: "${tenant}"})
+			}
+			// changing a shared builder does not corrupt it
+			base.Set().Header("X-Last", fmt.Sprint(i))
+			if _, err := base.Make(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			response, err := requestor.Do(context.Background(), f)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			var body []byte
+			if err := response.Decode(&body); err != nil || string(body) != `{"name":"acme"}` {
+				t.Errorf("unexpected body %q (%v)", body, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRequestHeadersNotShared(t *testing.T) {
+	base := New("http://www.example.com/").SetHeader("X-Base", "1")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			base.SetHeader("X-Last", fmt.Sprint(i))
+			request, err := base.Make()
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			// e.g. a middleware decorating the request
+			request.Header.Set("X-Middleware", fmt.Sprint(i))
+		}(i)
+	}
+	wg.Wait()
+	request, err := base.Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value := request.Header.Get("X-Middleware"); value != "" {
+		t.Fatalf("expected the request headers not to leak into the builder, got %q", value)
+	}
+}
+
+func TestConcurrentConfig(t *testing.T) {
+	base := New("http://www.example.com/").SetHeader("X-Base", "1").Credentials(&Credentials{Token: "env:TOKEN"})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			base.SetHeader("X-Last", fmt.Sprint(i)).SetQueryParameter("page", fmt.Sprint(i))
+			if config := base.Config(); config.Headers.Get("X-Base") != "1" || config.Credentials.Token != "env:TOKEN" {
+				t.Errorf("unexpected configuration %+v", config)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentReaders(t *testing.T) {
+	base := New("http://www.example.com/").TrackOverrides().SetHeader("X-Base", "1")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			base.SetHeader("X-Last", fmt.Sprint(i)).SetQueryParameter("page", fmt.Sprint(i)).SetVariable("id", fmt.Sprint(i))
+		}(i)
+		go func() {
+			defer wg.Done()
+			base.Describe()
+			base.Overrides()
+			_ = base.String()
+			base.New("", "").WithXMLEntity(struct{ Name string }{Name: "acme"})
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,109 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// Config is the serialisable configuration of a Builder, so that request
+// definitions can be kept in configuration files and reviewed like code; it
+// carries references to the credentials (see Credentials), never secrets.
+type Config struct {
+	Method      string            `json:"method,omitempty" yaml:"method,omitempty"`
+	URL         string            `json:"url,omitempty" yaml:"url,omitempty"`
+	Headers     http.Header       `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Parameters  url.Values        `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Variables   map[string]string `json:"variables,omitempty" yaml:"variables,omitempty"`
+	Credentials *Credentials      `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+}
+
+// Config returns the configuration of the builder: its method, URL, headers,
+// query parameters, variables and credentials references; the sensitive
+// headers (see RegisterSensitiveHeaders) and query parameters are left out, as
+// are the settings that cannot be serialised, e.g. the body and the transport.
+func (f *Builder) Config() *Config {
+	defer f.read()()
+	config := &Config{
+		Method:     f.method,
+		URL:        f.url,
+		Headers:    http.Header{},
+		Parameters: url.Values{},
+		Variables:  map[string]string{},
+	}
+	if f.credentials != nil {
+		credentials := *f.credentials
+		config.Credentials = &credentials
+	}
+	sensitive.lock.RLock()
+	defer sensitive.lock.RUnlock()
+	for key, values := range f.headers {
+		if !sensitive.headers[http.CanonicalHeaderKey(key)] && (f.credentials == nil || http.CanonicalHeaderKey(key) != http.CanonicalHeaderKey(f.credentials.header())) {
+			config.Headers[key] = append([]string(nil), values...)
+		}
+	}
+	for key, values := range f.parameters {
+		if !sensitive.parameters[key] {
+			config.Parameters[key] = append([]string(nil), values...)
+		}
+	}
+	for key, value := range f.variables {
+		config.Variables[key] = value
+	}
+	return config
+}
+
+// FromConfig returns a new request builder with the given configuration.
+func FromConfig(config *Config) *Builder {
+	f := New(config.URL).Method(config.Method)
+	for key, values := range config.Headers {
+		f.SetHeader(key, values...)
+	}
+	for key, values := range config.Parameters {
+		f.SetQueryParameter(key, values...)
+	}
+	for key, value := range config.Variables {
+		f.SetVariable(key, value)
+	}
+	if config.Credentials != nil {
+		f.Credentials(config.Credentials)
+	}
+	return f
+}
+
+// MarshalJSON encodes the configuration of the builder as JSON.
+func (f *Builder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.Config())
+}
+
+// UnmarshalJSON replaces the builder with one having the configuration
+// decoded from the given JSON data.
+func (f *Builder) UnmarshalJSON(data []byte) error {
+	config := &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return err
+	}
+	*f = *FromConfig(config)
+	return nil
+}
+
+// MarshalYAML returns the configuration of the builder, to be encoded as YAML
+// (e.g. by gopkg.in/yaml.v3).
+func (f *Builder) MarshalYAML() (interface{}, error) {
+	return f.Config(), nil
+}
+
+// UnmarshalYAML replaces the builder with one having the configuration decoded
+// by the given function (e.g. by gopkg.in/yaml.v3).
+func (f *Builder) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	config := &Config{}
+	if err := unmarshal(config); err != nil {
+		return err
+	}
+	*f = *FromConfig(config)
+	return nil
+}
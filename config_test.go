@@ -0,0 +1,53 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	os.Setenv("TEST_CONFIG_TOKEN", "secret")
+	defer os.Unsetenv("TEST_CONFIG_TOKEN")
+	api := New("https://api.example.com/v1/users/{id}").
+		Method(http.MethodPut).
+		UserAgent("worker/1.0").
+		Add().Header("Cookie", "session=secret").
+		Add().QueryParameter("verbose", "true").
+		Add().Variable("id", 42).
+		Credentials(&Credentials{Token: "env:TEST_CONFIG_TOKEN"})
+	data, err := json.Marshal(api)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"method":"PUT","url":"https://api.example.com/v1/users/{id}","headers":{"User-Agent":["worker/1.0"]},"parameters":{"verbose":["true"]},"variables":{"id":"42"},"credentials":{"token":"env:TEST_CONFIG_TOKEN"}}`
+	if string(data) != expected {
+		t.Fatalf("expected %s, got %s", expected, data)
+	}
+
+	restored := &Builder{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	request, err := restored.Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.Method != http.MethodPut || request.URL.String() != "https://api.example.com/v1/users/42?verbose=true" {
+		t.Fatalf("unexpected request %s %s", request.Method, request.URL)
+	}
+	if request.Header.Get("Authorization") != "Bearer secret" || request.Header.Get("User-Agent") != "worker/1.0" || request.Header.Get("Cookie") != "" {
+		t.Fatalf("unexpected headers %v", request.Header)
+	}
+
+	// switching credentials replaces their header
+	restored.Credentials(&Credentials{Token: "key", Header: "X-API-Key"})
+	if restored.headers.Get("Authorization") != "" || restored.headers.Get("X-API-Key") != "key" {
+		t.Fatalf("unexpected headers %v", restored.headers)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ErrHeaderConflict is returned by Make when a header has more than one value
+// and the conflict policy for it is ConflictError.
+var ErrHeaderConflict = errors.New("conflicting header values")
+
+// ConflictPolicy is what happens when a header ends up with more than one
+// value, e.g. because it was added both to a base builder and to one of its
+// children; the values are ordered from the least specific (the base builder)
+// to the most specific one. Headers replaced via SetHeader do not conflict.
+type ConflictPolicy int8
+
+const (
+	// ConflictAppend sends all the values, as separate header lines.
+	ConflictAppend ConflictPolicy = iota
+	// ConflictError makes Make fail with ErrHeaderConflict.
+	ConflictError
+	// ConflictFirstWins only sends the first (least specific) value.
+	ConflictFirstWins
+	// ConflictLastWins only sends the last (most specific) value.
+	ConflictLastWins
+	// ConflictMerge sends a single header line, with the values separated by
+	// commas (or semicolons, for Cookie).
+	ConflictMerge
+)
+
+// HeaderConflicts sets the policy applied to the given headers, or to all the
+// headers without a specific policy if none is given, when they have more than
+// one value; by default, all the values are sent.
+func (f *Builder) HeaderConflicts(policy ConflictPolicy, headers ...string) *Builder {
+	defer f.write()()
+	if len(headers) == 0 {
+		f.conflicts[""] = policy
+	}
+	for _, header := range headers {
+		f.conflicts[http.CanonicalHeaderKey(header)] = policy
+	}
+	return f
+}
+
+// resolve applies the conflict policies to the builder headers, and returns the
+// resulting set of headers, which is never shared with the builder; the builder
+// must be locked for reading.
+func (f *Builder) resolve() (http.Header, error) {
+	if len(f.conflicts) == 0 {
+		return f.headers.Clone(), nil
+	}
+	header := http.Header{}
+	conflicts := []string{}
+	for key, values := range f.headers {
+		policy, ok := f.conflicts[http.CanonicalHeaderKey(key)]
+		if !ok {
+			policy = f.conflicts[""]
+		}
+		if len(values) < 2 || policy == ConflictAppend {
+			header[key] = append([]string(nil), values...)
+			continue
+		}
+		switch policy {
+		case ConflictError:
+			conflicts = append(conflicts, fmt.Sprintf("%s (%s)", key, strings.Join(values, " | ")))
+		case ConflictFirstWins:
+			header[key] = []string{values[0]}
+		case ConflictLastWins:
+			header[key] = []string{values[len(values)-1]}
+		case ConflictMerge:
+			separator := ", "
+			if http.CanonicalHeaderKey(key) == "Cookie" {
+				separator = "; "
+			}
+			header[key] = []string{strings.Join(values, separator)}
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, fmt.Errorf("%w: %s", ErrHeaderConflict, strings.Join(conflicts, ", "))
+	}
+	return header, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeaderConflicts(t *testing.T) {
+	base := New("http://www.example.com/").
+		Add().Header("Content-Type", "application/json").
+		Add().Header("Cookie", "a=1")
+
+	testCases := []struct {
+		policy  ConflictPolicy
+		header  string
+		cookie  string
+		failing bool
+	}{
+		{ConflictAppend, "application/json|text/plain", "a=1|b=2", false},
+		{ConflictFirstWins, "application/json", "a=1", false},
+		{ConflictLastWins, "text/plain", "b=2", false},
+		{ConflictMerge, "application/json, text/plain", "a=1; b=2", false},
+		{ConflictError, "", "", true},
+	}
+	for _, test := range testCases {
+		f := base.New(http.MethodPost, "").
+			HeaderConflicts(test.policy).
+			Add().Header("Content-Type", "text/plain").
+			Add().Header("Cookie", "b=2")
+		request, err := f.Make()
+		if test.failing {
+			if !errors.Is(err, ErrHeaderConflict) {
+				t.Fatalf("policy %d: expected header conflict, got %v", test.policy, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("policy %d: unexpected error: %v", test.policy, err)
+		}
+		if actual := strings.Join(request.Header["Content-Type"], "|"); actual != test.header {
+			t.Fatalf("policy %d: expected Content-Type %q, got %q", test.policy, test.header, actual)
+		}
+		if actual := strings.Join(request.Header["Cookie"], "|"); actual != test.cookie {
+			t.Fatalf("policy %d: expected Cookie %q, got %q", test.policy, test.cookie, actual)
+		}
+		if len(f.headers["Content-Type"]) != 2 {
+			t.Fatalf("policy %d: builder headers were modified: %v", test.policy, f.headers)
+		}
+	}
+}
+
+func TestHeaderConflictsPerHeader(t *testing.T) {
+	f := New("http://www.example.com/").
+		HeaderConflicts(ConflictError).
+		HeaderConflicts(ConflictAppend, "accept").
+		Add().Header("Accept", "text/plain").
+		Add().Header("Accept", "application/json").
+		Set().Header("Content-Type", "application/json")
+	child := f.New(http.MethodGet, "").Set().Header("Content-Type", "text/plain")
+	request, err := child.Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(request.Header["Accept"], []string{"text/plain", "application/json"}) {
+		t.Fatalf("unexpected Accept header: %v", request.Header["Accept"])
+	}
+	if request.Header.Get("Content-Type") != "text/plain" {
+		t.Fatalf("unexpected Content-Type header: %v", request.Header["Content-Type"])
+	}
+	if _, err := child.Add().Header("Content-Type", "text/html").Make(); !errors.Is(err, ErrHeaderConflict) {
+		t.Fatalf("expected header conflict, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	// RequestIDHeader is the header carrying the ID of each request.
+	RequestIDHeader = "X-Request-ID"
+	// DefaultCorrelationHeader is the default header the correlation ID is
+	// propagated in.
+	DefaultCorrelationHeader = "X-Correlation-ID"
+)
+
+// correlationKey is the context key of the correlation ID.
+type correlationKey struct{}
+
+// ContextWithCorrelationID returns a copy of the given context carrying the
+// given correlation ID, e.g. as extracted from an incoming request by a server
+// middleware, so that the outgoing requests bound to it propagate the ID (see
+// PropagateCorrelationID).
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationIDFrom returns the correlation ID carried by the given context, or
+// an empty string if there is none.
+func CorrelationIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// WithRequestID makes each request generated by the builder carry a new ID in
+// the X-Request-ID header, unless the header is set explicitly; the ID is
+// generated by the given function (by default, a time-ordered UUIDv7) and
+// stays the same across the retries of a request.
+func (f *Builder) WithRequestID(generate func() string) *Builder {
+	if generate == nil {
+		generate = UUIDv7Generator{}.NewID
+	}
+	f.requestID = generate
+	return f
+}
+
+// PropagateCorrelationID makes the requests bound to a context carrying a
+// correlation ID copy it into the given header (by default, X-Correlation-ID);
+// the ID is obtained from the context by the given function, e.g. to integrate
+// with the key used by an existing server middleware, or by CorrelationIDFrom
+// if nil.
+func (f *Builder) PropagateCorrelationID(header string, extract func(ctx context.Context) string) *Builder {
+	if header == "" {
+		header = DefaultCorrelationHeader
+	}
+	if extract == nil {
+		extract = CorrelationIDFrom
+	}
+	f.correlation = header
+	f.correlate = extract
+	return f
+}
+
+// bind binds the given request, generated by the builder, to the given
+// context, propagating its correlation ID if so configured.
+func (f *Builder) bind(ctx context.Context, request *http.Request) *http.Request {
+	request = request.WithContext(ctx)
+	if f.correlation == "" || request.Header.Get(f.correlation) != "" {
+		return request
+	}
+	if id := f.correlate(ctx); id != "" {
+		request.Header.Set(f.correlation, id)
+	}
+	return request
+}
@@ -0,0 +1,80 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestID(t *testing.T) {
+	var ids []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get(RequestIDHeader))
+		if len(ids) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(nil).Retry(2, time.Millisecond)
+	f := New(server.URL).WithRequestID(nil)
+	if _, err := requestor.Do(context.Background(), f); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := requestor.Do(context.Background(), f); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ids) != 3 || ids[0] == "" || ids[0] != ids[1] || ids[1] == ids[2] {
+		t.Fatalf("expected an id stable across retries only, got %v", ids)
+	}
+
+	f = New(server.URL).WithRequestID(NewSequentialGenerator("req-").NewID)
+	f.Set().Header(RequestIDHeader, "explicit")
+	if request, _ := f.Make(); request.Header.Get(RequestIDHeader) != "explicit" {
+		t.Fatalf("expected the explicit id to be kept, got %q", request.Header.Get(RequestIDHeader))
+	}
+}
+
+func TestCorrelationID(t *testing.T) {
+	var correlation, custom string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		correlation = r.Header.Get(DefaultCorrelationHeader)
+		custom = r.Header.Get("X-Trace")
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(nil)
+	ctx := ContextWithCorrelationID(context.Background(), "abc")
+	if _, err := requestor.Do(ctx, New(server.URL).PropagateCorrelationID("", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if correlation != "abc" {
+		t.Fatalf("expected correlation id abc, got %q", correlation)
+	}
+
+	type key struct{}
+	ctx = context.WithValue(context.Background(), key{}, "xyz")
+	f := New(server.URL).PropagateCorrelationID("X-Trace", func(ctx context.Context) string {
+		id, _ := ctx.Value(key{}).(string)
+		return id
+	})
+	if exchange := requestor.Execute(ctx, f); exchange.Err != nil {
+		t.Fatalf("unexpected error: %v", exchange.Err)
+	}
+	if custom != "xyz" || correlation != "" {
+		t.Fatalf("unexpected headers %q and %q", custom, correlation)
+	}
+
+	if _, err := requestor.Do(context.Background(), f); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if custom != "" {
+		t.Fatalf("expected no correlation id, got %q", custom)
+	}
+}
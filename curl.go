@@ -0,0 +1,127 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// AsCurl returns the curl command line equivalent to the request generated by
+// the builder, e.g. to share a reproduction with an API vendor; credentials
+// (in the Authorization, Proxy-Authorization and Cookie headers, in the URL
+// user info and in the sensitive headers and query parameters, see
+// RegisterSensitiveHeaders) are replaced with "[REDACTED]" unless
+// includeCredentials is set. See Snapshot for how the body is read.
+func (f *Builder) AsCurl(includeCredentials bool) (string, error) {
+	request, err := f.Make()
+	if err != nil {
+		return "", err
+	}
+	body, err := f.peek(request)
+	if err != nil {
+		return "", err
+	}
+	u := *request.URL
+	header := request.Header.Clone()
+	if !includeCredentials {
+		if u.User != nil {
+			u.User = url.User(Redacted)
+		}
+		redactQuery(&u)
+		header = RedactHeader(header)
+	}
+
+	arguments := []string{"curl"}
+	switch {
+	case request.Method == http.MethodHead:
+		arguments = append(arguments, "--head")
+	case request.Method != http.MethodGet || body != nil:
+		arguments = append(arguments, "-X "+quote(request.Method))
+	}
+	arguments = append(arguments, quote(u.String()))
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range header[key] {
+			arguments = append(arguments, "-H "+quote(key+": "+value))
+		}
+	}
+	if body != nil {
+		arguments = append(arguments, "--data-binary "+quote(string(body)))
+	}
+	return strings.Join(arguments, " \\\n  "), nil
+}
+
+// peek returns the body of the given request, generated by the builder,
+// without consuming it; if the body cannot be re-read, it is read into memory
+// and the request body is replaced with an equivalent reader, whereas the
+// builder is left untouched.
+func (f *Builder) peek(request *http.Request) ([]byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return ioutil.ReadAll(body)
+	}
+	data, err := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(data))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	request.ContentLength = int64(len(data))
+	return data, nil
+}
+
+// quote quotes the given string for POSIX shells: printable text is enclosed in
+// single quotes, whereas text containing control characters (other than new
+// lines and tabs) or invalid UTF-8 is ANSI-C quoted ($'...').
+func quote(s string) string {
+	printable := utf8.ValidString(s)
+	for _, r := range s {
+		if !unicode.IsPrint(r) && r != '\n' && r != '\t' {
+			printable = false
+			break
+		}
+	}
+	if printable {
+		return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString("$'")
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'' || c == '\\':
+			buffer.WriteByte('\\')
+			buffer.WriteByte(c)
+		case c >= 0x20 && c < 0x7f:
+			buffer.WriteByte(c)
+		default:
+			fmt.Fprintf(&buffer, "\\x%02x", c)
+		}
+	}
+	buffer.WriteString("'")
+	return buffer.String()
+}
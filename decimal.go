@@ -0,0 +1,65 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+)
+
+// number matches the JSON number syntax.
+var number = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// Decimal is an exact decimal number, such as an amount in a financial API
+// response; it is decoded from JSON numbers or strings ("12.30" or 12.30)
+// keeping its textual representation, so that no float64 rounding occurs.
+type Decimal string
+
+// UnmarshalJSON decodes a JSON number or numeric string.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	value := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	}
+	if !number.MatchString(value) {
+		return fmt.Errorf("invalid decimal value %s", data)
+	}
+	*d = Decimal(value)
+	return nil
+}
+
+// MarshalJSON encodes the decimal as a JSON number.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	if d == "" {
+		return []byte("null"), nil
+	}
+	return []byte(d), nil
+}
+
+// String returns the textual representation of the decimal.
+func (d Decimal) String() string {
+	return string(d)
+}
+
+// Rat returns the decimal as an arbitrary precision rational number, for exact
+// arithmetic.
+func (d Decimal) Rat() (*big.Rat, bool) {
+	return new(big.Rat).SetString(string(d))
+}
+
+// Float64 returns the decimal as a (possibly rounded) float64.
+func (d Decimal) Float64() (float64, error) {
+	return strconv.ParseFloat(string(d), 64)
+}
@@ -0,0 +1,44 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecimal(t *testing.T) {
+	v := struct {
+		Amount Decimal `json:"amount"`
+		Fee    Decimal `json:"fee"`
+		Tax    Decimal `json:"tax"`
+	}{}
+	if err := decode("application/json", []byte(`{"amount": 12345678901234567.89, "fee": "0.10", "tax": null}`), &v, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Amount != "12345678901234567.89" || v.Fee != "0.10" || v.Tax != "" {
+		t.Fatalf("invalid decimals: %+v", v)
+	}
+	if rat, ok := v.Amount.Rat(); !ok || rat.FloatString(2) != "12345678901234567.89" {
+		t.Fatalf("invalid rational value: %v", rat)
+	}
+	data, _ := json.Marshal(v)
+	if string(data) != `{"amount":12345678901234567.89,"fee":0.10,"tax":null}` {
+		t.Fatalf("invalid encoding: %s", data)
+	}
+	if err := json.Unmarshal([]byte(`{"amount": "abc"}`), &v); err == nil {
+		t.Fatalf("expected an error decoding an invalid decimal")
+	}
+}
+
+func TestUseNumber(t *testing.T) {
+	v := map[string]interface{}{}
+	if err := decode("application/json", []byte(`{"id": 12345678901234567890}`), &v, UseNumber); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n, ok := v["id"].(json.Number); !ok || n.String() != "12345678901234567890" {
+		t.Fatalf("expected a json.Number, got %T %v", v["id"], v["id"])
+	}
+}
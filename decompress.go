@@ -0,0 +1,165 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Decompressor returns a reader decompressing the given response body.
+type Decompressor func(body io.Reader) (io.ReadCloser, error)
+
+var (
+	decompressorsLock sync.RWMutex
+	decompressors     = map[string]Decompressor{
+		"gzip":    gunzip,
+		"x-gzip":  gunzip,
+		"deflate": inflate,
+	}
+	// encodings lists the registered content codings, in order of preference.
+	encodings = []string{"gzip", "deflate"}
+)
+
+// RegisterDecompressor registers the decompressor of the given content coding
+// (e.g. "br"), so that Requestors decompressing responses (see Decompress)
+// support it; gzip and deflate are supported out of the box, while brotli and
+// zstd are provided by the decompress package.
+func RegisterDecompressor(encoding string, decompressor Decompressor) {
+	encoding = strings.ToLower(encoding)
+	decompressorsLock.Lock()
+	defer decompressorsLock.Unlock()
+	if _, ok := decompressors[encoding]; !ok {
+		encodings = append(encodings, encoding)
+	}
+	decompressors[encoding] = decompressor
+}
+
+// decompressor returns the decompressor of the given content coding, if any.
+func decompressor(encoding string) (Decompressor, bool) {
+	decompressorsLock.RLock()
+	defer decompressorsLock.RUnlock()
+	decompressor, ok := decompressors[strings.ToLower(strings.TrimSpace(encoding))]
+	return decompressor, ok
+}
+
+// Decompress makes the Requestor advertise the given content codings (by
+// default, all the registered ones) in the Accept-Encoding header of the
+// requests not setting it explicitly, and transparently decompress the
+// response bodies encoded with any registered coding; unlike the http.Transport
+// built-in support, which is limited to gzip, this also applies when the header
+// is set explicitly. The size of the response body as received is reported by
+// Response.CompressedSize.
+func (r *Requestor) Decompress(encodings ...string) *Requestor {
+	r.decompressing = true
+	r.encodings = encodings
+	return r
+}
+
+// acceptEncoding returns a copy of the given request advertising the supported
+// content codings, unless it sets Accept-Encoding explicitly.
+func (r *Requestor) acceptEncoding(request *http.Request) *http.Request {
+	if !r.decompressing || request.Header.Get("Accept-Encoding") != "" {
+		return request
+	}
+	accepted := r.encodings
+	if len(accepted) == 0 {
+		decompressorsLock.RLock()
+		accepted = append([]string(nil), encodings...)
+		decompressorsLock.RUnlock()
+	}
+	clone := request.Clone(request.Context())
+	clone.Header.Set("Accept-Encoding", strings.Join(accepted, ", "))
+	return clone
+}
+
+// decompress replaces the body of the given response with its decompressed
+// form, if its content codings are all supported, and returns the body as
+// received, to count its size.
+func (r *Requestor) decompress(response *http.Response) (*countingBody, error) {
+	encoding := response.Header.Get("Content-Encoding")
+	if !r.decompressing || encoding == "" || strings.EqualFold(encoding, "identity") {
+		return nil, nil
+	}
+	codings := strings.Split(encoding, ",")
+	chain := make([]Decompressor, len(codings))
+	for i, coding := range codings {
+		var ok bool
+		if chain[i], ok = decompressor(coding); !ok {
+			return nil, nil
+		}
+	}
+	raw := &countingBody{ReadCloser: response.Body, done: func(int64) {}}
+	var body io.Reader = raw
+	closers := []io.Closer{raw}
+	// codings are listed in the order they were applied
+	for i := len(chain) - 1; i >= 0; i-- {
+		decompressed, err := chain[i](body)
+		if err != nil {
+			raw.Close()
+			return nil, fmt.Errorf("error decompressing %s response body: %w", strings.TrimSpace(codings[i]), err)
+		}
+		body = decompressed
+		closers = append(closers, decompressed)
+	}
+	response.Body = &decompressedBody{Reader: body, closers: closers}
+	response.Header.Del("Content-Encoding")
+	response.Header.Del("Content-Length")
+	response.ContentLength = -1
+	response.Uncompressed = true
+	return raw, nil
+}
+
+// decompressedBody is a decompressed response body, closing the decompressors
+// and the original body when closed.
+type decompressedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes the decompressors and the original body.
+func (b *decompressedBody) Close() error {
+	var err error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if e := b.closers[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// CompressedSize returns the number of bytes of the response body read as
+// received from the server, if it was decompressed by the Requestor, or -1.
+func (r *Response) CompressedSize() int64 {
+	if r.compressed == nil {
+		return -1
+	}
+	return r.compressed.n
+}
+
+func gunzip(body io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(body)
+}
+
+// inflate decompresses deflate bodies, which should be zlib streams but are
+// sent as raw deflate streams by some servers.
+func inflate(body io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(body)
+	header, err := buffered.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
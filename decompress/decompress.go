@@ -0,0 +1,42 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package decompress provides the brotli ("br") and zstd decompressors of
+// response bodies, to be registered with the request package; it lives in its
+// own package so that the compression libraries are only pulled in by those
+// who need them. Registering both is as simple as:
+//
+//	decompress.Register()
+//	requestor := request.NewRequestor(nil).Decompress()
+package decompress
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/andybalholm/brotli"
+	request "github.com/dihedron/go-requestor"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Register registers the brotli and zstd decompressors.
+func Register() {
+	request.RegisterDecompressor("br", Brotli)
+	request.RegisterDecompressor("zstd", Zstd)
+}
+
+// Brotli returns a reader decompressing the given brotli body.
+func Brotli(body io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(brotli.NewReader(body)), nil
+}
+
+// Zstd returns a reader decompressing the given zstd body; the decoder is
+// released when the reader is closed.
+func Zstd(body io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package decompress
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	request "github.com/dihedron/go-requestor"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestDecompress(t *testing.T) {
+	payload := strings.Repeat("hello world ", 100)
+	var brotlied, zstded bytes.Buffer
+	writer := brotli.NewWriter(&brotlied)
+	writer.Write([]byte(payload))
+	writer.Close()
+	encoder, _ := zstd.NewWriter(&zstded)
+	encoder.Write([]byte(payload))
+	encoder.Close()
+
+	var accepted string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Encoding", r.URL.Query().Get("encoding"))
+		if r.URL.Query().Get("encoding") == "br" {
+			w.Write(brotlied.Bytes())
+		} else {
+			w.Write(zstded.Bytes())
+		}
+	}))
+	defer server.Close()
+
+	Register()
+	requestor := request.NewRequestor(nil).Decompress()
+	for _, encoding := range []string{"br", "zstd"} {
+		f := request.New(server.URL)
+		f.Set().QueryParameter("encoding", encoding)
+		response, err := requestor.Do(context.Background(), f)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if string(data) != payload {
+			t.Fatalf("unexpected %s body %q", encoding, data)
+		}
+		if !strings.Contains(accepted, "br") || !strings.Contains(accepted, "zstd") {
+			t.Fatalf("expected brotli and zstd to be accepted, got %q", accepted)
+		}
+	}
+}
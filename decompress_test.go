@@ -0,0 +1,101 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	payload := strings.Repeat("hello world ", 100)
+	compressed := map[string][]byte{}
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	gz.Write([]byte(payload))
+	gz.Close()
+	compressed["gzip"] = append([]byte(nil), buffer.Bytes()...)
+	buffer.Reset()
+	zl := zlib.NewWriter(&buffer)
+	zl.Write([]byte(payload))
+	zl.Close()
+	compressed["deflate"] = append([]byte(nil), buffer.Bytes()...)
+	buffer.Reset()
+	fl, _ := flate.NewWriter(&buffer, flate.BestCompression)
+	fl.Write([]byte(payload))
+	fl.Close()
+	compressed["raw"] = append([]byte(nil), buffer.Bytes()...)
+
+	var accepted string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted = r.Header.Get("Accept-Encoding")
+		encoding := r.URL.Query().Get("encoding")
+		if encoding == "raw" {
+			w.Header().Set("Content-Encoding", "deflate")
+		} else if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		if data, ok := compressed[encoding]; ok {
+			w.Write(data)
+		} else {
+			w.Write([]byte(payload))
+		}
+	}))
+	defer server.Close()
+
+	observer := &recordingObserver{}
+	requestor := NewRequestor(nil).Decompress().Observe(observer)
+	for _, encoding := range []string{"gzip", "deflate", "raw"} {
+		f := New(server.URL)
+		f.Set().QueryParameter("encoding", encoding)
+		response, err := requestor.Do(context.Background(), f)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var body []byte
+		if err := response.Decode(&body); err != nil || string(body) != payload {
+			t.Fatalf("unexpected %s body %q (%v)", encoding, body, err)
+		}
+		if response.Header.Get("Content-Encoding") != "" || !response.Uncompressed {
+			t.Fatalf("expected the %s response to be marked as decompressed", encoding)
+		}
+		if size := response.CompressedSize(); size != int64(len(compressed[encoding])) {
+			t.Fatalf("expected %s compressed size %d, got %d", encoding, len(compressed[encoding]), size)
+		}
+		if accepted != "gzip, deflate" {
+			t.Fatalf("unexpected Accept-Encoding %q", accepted)
+		}
+	}
+	if outcome := observer.outcomes[0]; outcome.Size != int64(len(payload)) || outcome.CompressedSize != int64(len(compressed["gzip"])) {
+		t.Fatalf("unexpected outcome %+v", outcome)
+	}
+
+	f := New(server.URL)
+	f.Set().QueryParameter("encoding", "br").Header("Accept-Encoding", "br")
+	response, err := requestor.Do(context.Background(), f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if accepted != "br" || response.Header.Get("Content-Encoding") != "br" || response.CompressedSize() != -1 {
+		t.Fatalf("expected an unsupported encoding to be left alone, got %q", response.Header.Get("Content-Encoding"))
+	}
+
+	response, err = NewRequestor(nil).Decompress("gzip").Do(context.Background(), New(server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if accepted != "gzip" || response.CompressedSize() != -1 {
+		t.Fatalf("unexpected Accept-Encoding %q", accepted)
+	}
+}
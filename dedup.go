@@ -0,0 +1,116 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/dihedron/go-log"
+)
+
+// significantHeaders are the request headers that can change the response, and
+// are therefore part of the default deduplication key.
+var significantHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language", "Authorization", "Cookie"}
+
+// DeduplicationKey is the default deduplication key: the method, the URL with
+// its query parameters sorted, and the values of the Accept, Accept-Encoding,
+// Accept-Language, Authorization and Cookie headers.
+func DeduplicationKey(request *http.Request) string {
+	u := *request.URL
+	u.RawQuery = u.Query().Encode()
+	u.Fragment = ""
+	key := []string{request.Method, u.String()}
+	for _, header := range significantHeaders {
+		key = append(key, header+": "+strings.Join(request.Header.Values(header), ", "))
+	}
+	return strings.Join(key, "\n")
+}
+
+// Deduplicate makes the Requestor collapse concurrent identical GET and HEAD
+// requests into a single upstream request, whose response is then fanned out to
+// all the callers, each getting its own copy of the body; requests are
+// identical if the given function (DeduplicationKey if nil) returns the same
+// key for them, and are never collapsed if it returns an empty key.
+func (r *Requestor) Deduplicate(key func(request *http.Request) string) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if key == nil {
+		key = DeduplicationKey
+	}
+	r.deduplicate = key
+	return r
+}
+
+// flight is an in-flight request shared by many callers; its outcome is
+// available once done is closed.
+type flight struct {
+	done     chan struct{}
+	response *Response
+	body     []byte
+	err      error
+}
+
+// result returns a copy of the outcome of the shared request.
+func (c *flight) result() (*Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	response := *c.response.Response
+	response.Header = c.response.Header.Clone()
+	response.Body = ioutil.NopCloser(bytes.NewReader(c.body))
+	return &Response{
+		Response:  &response,
+		Attempts:  c.response.Attempts,
+		FromCache: c.response.FromCache,
+		Timings:   c.response.Timings,
+	}, nil
+}
+
+// collapse executes the given request, sharing its outcome with the concurrent
+// identical requests if deduplication is enabled.
+func (r *Requestor) collapse(f *Builder, request *http.Request) (*Response, error) {
+	if r.deduplicate == nil || (request.Method != http.MethodGet && request.Method != http.MethodHead) {
+		return r.retry(f, request)
+	}
+	key := r.deduplicate(request)
+	if key == "" {
+		return r.retry(f, request)
+	}
+
+	r.lock.Lock()
+	if call, ok := r.flights[key]; ok {
+		r.lock.Unlock()
+		log.Debugf("joining in-flight %s request to %q", request.Method, RedactURL(request.URL))
+		select {
+		case <-call.done:
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		}
+		// the shared request was cancelled by its caller, but this one is not
+		if (errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) && request.Context().Err() == nil {
+			return r.retry(f, request)
+		}
+		return call.result()
+	}
+	call := &flight{done: make(chan struct{})}
+	r.flights[key] = call
+	r.lock.Unlock()
+
+	call.response, call.err = r.retry(f, request)
+	if call.err == nil {
+		call.body, call.err = ioutil.ReadAll(call.response.Body)
+		call.response.Body.Close()
+	}
+	r.lock.Lock()
+	delete(r.flights, key)
+	r.lock.Unlock()
+	close(call.done)
+	return call.result()
+}
@@ -0,0 +1,70 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDeduplicationKey(t *testing.T) {
+	a, _ := http.NewRequest(http.MethodGet, "http://example.com/path?b=2&a=1", nil)
+	b, _ := http.NewRequest(http.MethodGet, "http://example.com/path?a=1&b=2", nil)
+	if DeduplicationKey(a) != DeduplicationKey(b) {
+		t.Fatalf("keys should not depend on the order of query parameters")
+	}
+	b.Header.Set("Authorization", "Bearer other")
+	if DeduplicationKey(a) == DeduplicationKey(b) {
+		t.Fatalf("keys should depend on the Authorization header")
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	var count int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		<-release
+		w.Header().Set("X-Test", "yes")
+		w.Write([]byte("shared"))
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient()).Deduplicate(nil)
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			response, err := requestor.Do(context.Background(), New(server.URL))
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			defer response.Body.Close()
+			body, _ := ioutil.ReadAll(response.Body)
+			bodies[i] = string(body) + response.Header.Get("X-Test")
+		}(i)
+	}
+	// let all the requests join the first one
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&count); n != 1 {
+		t.Fatalf("expected a single upstream request, got %d", n)
+	}
+	for _, body := range bodies {
+		if body != "sharedyes" {
+			t.Fatalf("invalid fanned out response %q", body)
+		}
+	}
+}
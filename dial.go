@@ -0,0 +1,198 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// IPFamily is the IP version used to connect to target hosts.
+type IPFamily int8
+
+const (
+	// IPAny connects over either IPv4 or IPv6, as resolved.
+	IPAny IPFamily = iota
+	// IPv4 only connects over IPv4.
+	IPv4
+	// IPv6 only connects over IPv6.
+	IPv6
+)
+
+// String returns the name of the IP family.
+func (f IPFamily) String() string {
+	switch f {
+	case IPAny:
+		return "any"
+	case IPv4:
+		return "ipv4"
+	case IPv6:
+		return "ipv6"
+	}
+	return fmt.Sprintf("IPFamily(%d)", f)
+}
+
+// network returns the dial network for the family, given the requested one.
+func (f IPFamily) network(network string) string {
+	if network != "tcp" {
+		return network
+	}
+	switch f {
+	case IPv4:
+		return "tcp4"
+	case IPv6:
+		return "tcp6"
+	}
+	return network
+}
+
+// IPFamily makes the Requestor resolve and connect to target hosts only over
+// the given IP version.
+func (r *Requestor) IPFamily(family IPFamily) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.family = family
+	r.dialing()
+	return r
+}
+
+// LocalAddress binds the outgoing connections to the given local IP address,
+// e.g. the one allow-listed by a partner on a multi-homed host.
+func (r *Requestor) LocalAddress(address string) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	ip := net.ParseIP(address)
+	if ip == nil {
+		log.Errorf("invalid local address %q", address)
+		return r
+	}
+	r.localIP = ip
+	r.localInterface = ""
+	r.dialing()
+	return r
+}
+
+// LocalInterface binds the outgoing connections to an address of the given
+// network interface (e.g. "eth1"), of the same IP version as the target.
+func (r *Requestor) LocalInterface(name string) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.localIP = nil
+	r.localInterface = name
+	r.dialing()
+	return r
+}
+
+// dialing installs the custom dialer in the transport of the client, and
+// closes the pooled connections opened with the previous settings; it must be
+// called with the lock held.
+func (r *Requestor) dialing() {
+	if transport := r.ownTransport(); transport != nil {
+		transport.DialContext = r.dial
+		transport.CloseIdleConnections()
+	}
+}
+
+// ownTransport returns a copy of the transport of the client, private to this
+// Requestor, so that it can be customised without affecting other users of the
+// client; it returns nil if the client uses a custom http.RoundTripper. It must
+// be called with the lock held.
+func (r *Requestor) ownTransport() *http.Transport {
+	if r.transport != nil {
+		return r.transport
+	}
+	var transport *http.Transport
+	switch t := r.client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		log.Errorf("cannot customise transport of type %T", r.client.Transport)
+		return nil
+	}
+	client := *r.client
+	client.Transport = transport
+	r.client = &client
+	r.transport = transport
+	return transport
+}
+
+// dial opens connections as per the IP family, local address, keep-alive and
+// timeout settings.
+func (r *Requestor) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	r.lock.Lock()
+	family, localIP, localInterface, keepAlive, timeout := r.family, r.localIP, r.localInterface, r.keepAlive, r.dialTimeout
+	r.lock.Unlock()
+
+	if keepAlive == 0 {
+		keepAlive = 30 * time.Second
+	}
+	if timeout == 0 {
+		timeout = DefaultDialTimeout
+	}
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: keepAlive,
+	}
+	if localInterface != "" {
+		ip, err := interfaceAddress(localInterface, family)
+		if err != nil {
+			return nil, err
+		}
+		localIP = ip
+	}
+	if localIP != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: localIP}
+		if family == IPAny {
+			// the target must be reached over the same IP version
+			if localIP.To4() != nil {
+				family = IPv4
+			} else {
+				family = IPv6
+			}
+		}
+	}
+	return dialer.DialContext(ctx, family.network(network), address)
+}
+
+// interfaceAddress returns the first non link-local address of the given
+// interface of the given IP family, preferring IPv4 if any will do.
+func interfaceAddress(name string, family IPFamily) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var fallback net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		ip := ipnet.IP
+		switch {
+		case ip.To4() != nil && family != IPv6:
+			return ip, nil
+		case ip.To4() == nil && family == IPv6:
+			return ip, nil
+		case ip.To4() == nil && family == IPAny && fallback == nil:
+			fallback = ip
+		}
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, fmt.Errorf("no %v address on interface %q", strings.ToUpper(family.String()), name)
+}
@@ -0,0 +1,66 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPFamily(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := &http.Client{}
+	requestor := NewRequestor(client).IPFamily(IPv4)
+	if client.Transport != nil {
+		t.Fatalf("the original client should not be modified")
+	}
+	if _, err := requestor.Do(context.Background(), New(server.URL)); err != nil {
+		t.Fatalf("unexpected error over IPv4: %v", err)
+	}
+	requestor.IPFamily(IPv6)
+	if _, err := requestor.Do(context.Background(), New(server.URL)); err == nil {
+		t.Fatalf("expected an error connecting to an IPv4 address over IPv6")
+	}
+}
+
+func TestLocalAddress(t *testing.T) {
+	remote := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote <- r.RemoteAddr
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(nil).LocalAddress("127.0.0.1")
+	if _, err := requestor.Do(context.Background(), New(server.URL)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	host, _, _ := net.SplitHostPort(<-remote)
+	if host != "127.0.0.1" {
+		t.Fatalf("expected connection from 127.0.0.1, got %q", host)
+	}
+}
+
+func TestLocalInterface(t *testing.T) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("cannot list interfaces: %v", err)
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+		ip, err := interfaceAddress(iface.Name, IPv4)
+		if err != nil || !ip.IsLoopback() {
+			t.Fatalf("expected a loopback address on %q, got %v (%v)", iface.Name, ip, err)
+		}
+		return
+	}
+	t.Skip("no loopback interface")
+}
@@ -0,0 +1,192 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// DefaultDiscoveryTTL is how long the instances of a discovered service are
+// cached.
+const DefaultDiscoveryTTL = 30 * time.Second
+
+// ServiceResolver looks up the instances of a service, as SRV records; it can
+// be implemented on top of a service registry such as Consul.
+type ServiceResolver interface {
+	LookupService(ctx context.Context, service string) ([]*net.SRV, error)
+}
+
+// DNSServiceResolver looks up services via DNS SRV records.
+type DNSServiceResolver struct {
+	// Resolver is the resolver used; net.DefaultResolver if nil.
+	Resolver *net.Resolver
+}
+
+// LookupService returns the SRV records of the given service, e.g.
+// "_api._tcp.example.com".
+func (r DNSServiceResolver) LookupService(ctx context.Context, service string) ([]*net.SRV, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	_, records, err := resolver.LookupSRV(ctx, "", "", service)
+	return records, err
+}
+
+// discovery resolves the instances of a service at request time, caching them.
+type discovery struct {
+	resolver ServiceResolver
+	service  string
+	ttl      time.Duration
+
+	lock      sync.Mutex
+	instances []*net.SRV
+	expires   time.Time
+}
+
+// BaseFromSRV sets the base URL of the requests generated by this builder and
+// its children to the instances of the given service, e.g.
+// "_api._tcp.example.com", as discovered via DNS SRV records at request time;
+// the scheme is http for "_http._tcp" services, https otherwise. See
+// BaseFromService.
+func (f *Builder) BaseFromSRV(service string) *Builder {
+	return f.BaseFromService(DNSServiceResolver{}, service)
+}
+
+// BaseFromService sets the base URL of the requests generated by this builder
+// and its children to the instances of the given service, as discovered by the
+// given resolver at request time and cached for DefaultDiscoveryTTL, so that
+// the requests follow topology changes; the builder URL is set to
+// "https://<service>/", and its host is replaced in each request with the
+// host and port of an instance, picked by priority and weight as per RFC 2782.
+// If an instance cannot be reached, the request is sent to the next one.
+func (f *Builder) BaseFromService(resolver ServiceResolver, service string) *Builder {
+	f.discovery = &discovery{resolver: resolver, service: service, ttl: DefaultDiscoveryTTL}
+	f.endpoints = nil
+	scheme := "https"
+	if strings.HasPrefix(service, "_http.") {
+		scheme = "http"
+	}
+	f.url = scheme + "://" + service + "/"
+	return f
+}
+
+// lookup returns the instances of the service, from the cache if current; if
+// the resolver fails, the expired instances are used, if any.
+func (d *discovery) lookup(ctx context.Context) ([]*net.SRV, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.instances != nil && time.Now().Before(d.expires) {
+		return d.instances, nil
+	}
+	instances, err := d.resolver.LookupService(ctx, d.service)
+	if err == nil && len(instances) == 0 {
+		err = fmt.Errorf("no instances of service %s", d.service)
+	}
+	if err != nil {
+		if d.instances == nil {
+			return nil, err
+		}
+		log.Errorf("error looking up service %s, using stale instances: %v", d.service, err)
+		instances = d.instances
+	}
+	d.instances = instances
+	d.expires = time.Now().Add(d.ttl)
+	return instances, nil
+}
+
+// discover executes the given request against the instances of the service of
+// the given Builder, moving on to the next one when one cannot be reached.
+func (r *Requestor) discover(f *Builder, request *http.Request) (*Response, error) {
+	if !strings.EqualFold(request.URL.Hostname(), f.discovery.service) {
+		return r.execute(f, request)
+	}
+	instances, err := f.discovery.lookup(request.Context())
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", request.Method, RedactURL(request.URL), err)
+	}
+	instances = prioritise(instances)
+	for i, instance := range instances {
+		current := request
+		if i > 0 {
+			if current, err = rewind(request); err != nil {
+				return nil, err
+			}
+		}
+		host := net.JoinHostPort(strings.TrimSuffix(instance.Target, "."), strconv.Itoa(int(instance.Port)))
+		current = current.Clone(current.Context())
+		if current.Host == current.URL.Host {
+			current.Host = ""
+		}
+		current.URL.Host = host
+		var response *Response
+		if response, err = r.execute(f, current); err == nil {
+			response.Attempts += i
+			return response, nil
+		}
+		if request.Context().Err() != nil || !replayable(request) || !unreachable(request, err) {
+			return nil, err
+		}
+		log.Debugf("%s %s failed on %s (%v), trying next instance", request.Method, RedactURL(request.URL), host, err)
+	}
+	return nil, err
+}
+
+// unreachable returns whether the given error means that the server could not
+// be reached, so that the given request can be sent to another one.
+func unreachable(request *http.Request, err error) bool {
+	switch Classify(err) {
+	case ClassDNSNotFound, ClassDNSTimeout, ClassDNSFailure, ClassConnectionRefused, ClassConnectTimeout, ClassTLSHandshake:
+		// the request never reached the server
+		return true
+	case ClassConnectionReset:
+		return retriable(request)
+	}
+	return false
+}
+
+// prioritise returns the given SRV records in the order they should be tried:
+// by increasing priority, and randomly in proportion to their weights among
+// those of the same priority.
+func prioritise(records []*net.SRV) []*net.SRV {
+	sorted := append([]*net.SRV(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority < sorted[j].Priority })
+	ordered := make([]*net.SRV, 0, len(sorted))
+	for start := 0; start < len(sorted); {
+		end := start
+		for end < len(sorted) && sorted[end].Priority == sorted[start].Priority {
+			end++
+		}
+		group := sorted[start:end]
+		for len(group) > 0 {
+			total := 0
+			for _, record := range group {
+				total += int(record.Weight) + 1
+			}
+			pick, n := 0, rand.Intn(total)
+			for i, record := range group {
+				if n -= int(record.Weight) + 1; n < 0 {
+					pick = i
+					break
+				}
+			}
+			ordered = append(ordered, group[pick])
+			group = append(append([]*net.SRV(nil), group[:pick]...), group[pick+1:]...)
+		}
+		start = end
+	}
+	return ordered
+}
@@ -0,0 +1,96 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// staticServices resolves services to a fixed set of SRV records.
+type staticServices struct {
+	records []*net.SRV
+	err     error
+	lookups int
+}
+
+func (s *staticServices) LookupService(ctx context.Context, service string) ([]*net.SRV, error) {
+	s.lookups++
+	return s.records, s.err
+}
+
+func TestBaseFromService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + " " + r.URL.Path))
+	}))
+	defer server.Close()
+	address, _ := url.Parse(server.URL)
+	_, p, _ := net.SplitHostPort(address.Host)
+	port, _ := strconv.Atoi(p)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	address, _ = url.Parse(closed.URL)
+	_, p, _ = net.SplitHostPort(address.Host)
+	unreachable, _ := strconv.Atoi(p)
+
+	services := &staticServices{records: []*net.SRV{
+		{Target: "127.0.0.1.", Port: uint16(port), Priority: 20},
+		{Target: "127.0.0.1.", Port: uint16(unreachable), Priority: 10},
+	}}
+	requestor := NewRequestor(nil)
+	api := New("").BaseFromService(services, "_http._tcp.api.example.com")
+	for i := 0; i < 2; i++ {
+		response, err := requestor.Do(context.Background(), api.New(http.MethodGet, "users"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var body []byte
+		if err := response.Decode(&body); err != nil || string(body) != "127.0.0.1:"+strconv.Itoa(port)+" /users" {
+			t.Fatalf("unexpected body %q (%v)", body, err)
+		}
+		if response.Attempts != 2 {
+			t.Fatalf("expected 2 attempts, got %d", response.Attempts)
+		}
+	}
+	if services.lookups != 1 {
+		t.Fatalf("expected the instances to be cached, got %d lookups", services.lookups)
+	}
+
+	// stale instances are used if the lookup fails
+	api.discovery.expires = api.discovery.expires.Add(-DefaultDiscoveryTTL)
+	services.err = errors.New("registry unavailable")
+	if _, err := requestor.Do(context.Background(), api.New(http.MethodGet, "users")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := requestor.Do(context.Background(), New("").BaseFromService(services, "_api._tcp.example.com")); err == nil {
+		t.Fatalf("expected lookup error")
+	}
+}
+
+func TestPrioritise(t *testing.T) {
+	records := []*net.SRV{
+		{Target: "c", Priority: 20},
+		{Target: "a", Priority: 10, Weight: 100},
+		{Target: "b", Priority: 10, Weight: 0},
+	}
+	first := map[string]int{}
+	for i := 0; i < 100; i++ {
+		ordered := prioritise(records)
+		if len(ordered) != 3 || ordered[2].Target != "c" {
+			t.Fatalf("unexpected order %v", ordered)
+		}
+		first[ordered[0].Target]++
+	}
+	if first["a"] < 80 {
+		t.Fatalf("expected weights to be honoured, got %v", first)
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+const (
+	// DefaultDNSCacheTTL is how long a CachingResolver keeps the addresses
+	// returned by resolvers not reporting the TTL of their records.
+	DefaultDNSCacheTTL = 30 * time.Second
+	// DefaultDNSCacheNegativeTTL is how long a CachingResolver remembers that a
+	// lookup failed.
+	DefaultDNSCacheNegativeTTL = 5 * time.Second
+	// DefaultDNSCacheMaxEntries is the number of hosts a CachingResolver keeps
+	// by default.
+	DefaultDNSCacheMaxEntries = 1024
+)
+
+// TTLResolver is a Resolver that also reports how long the addresses of a host
+// can be cached, as per the TTL of its records; DoHResolver implements it.
+type TTLResolver interface {
+	Resolver
+	LookupHostTTL(ctx context.Context, host string) ([]string, time.Duration, error)
+}
+
+// CachingResolver is a Resolver caching the lookups of another one, to be
+// used via Builder.WithResolver:
+//
+//	api := request.New(url).WithResolver(request.NewCachingResolver(net.DefaultResolver))
+//
+// The addresses are kept for the TTL of their records if the resolver reports
+// it (see TTLResolver), and failed lookups for the negative TTL, unless the
+// failure is temporary or the lookup was cancelled by the caller; once
+// expired, the addresses are still used if refreshing them fails, so that a
+// DNS outage does not affect the hosts already known. Concurrent lookups of the same host
+// are collapsed into one, and the least recently used hosts are evicted when
+// the cache is full.
+type CachingResolver struct {
+	resolver    Resolver
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+
+	lock    sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// dnsEntry is the cached outcome of the lookup of a host.
+type dnsEntry struct {
+	host      string
+	addresses []string
+	err       error
+	expires   time.Time
+	// pending is closed when the ongoing lookup of the host completes.
+	pending chan struct{}
+}
+
+// NewCachingResolver returns a resolver caching the lookups of the given one.
+func NewCachingResolver(resolver Resolver) *CachingResolver {
+	return &CachingResolver{
+		resolver:    resolver,
+		ttl:         DefaultDNSCacheTTL,
+		negativeTTL: DefaultDNSCacheNegativeTTL,
+		maxEntries:  DefaultDNSCacheMaxEntries,
+		order:       list.New(),
+		entries:     map[string]*list.Element{},
+	}
+}
+
+// TTL sets how long the addresses are kept when the resolver does not report
+// the TTL of the records.
+func (r *CachingResolver) TTL(ttl time.Duration) *CachingResolver {
+	r.ttl = ttl
+	return r
+}
+
+// NegativeTTL sets how long failed lookups are remembered (0 to not cache
+// them).
+func (r *CachingResolver) NegativeTTL(ttl time.Duration) *CachingResolver {
+	r.negativeTTL = ttl
+	return r
+}
+
+// MaxEntries sets the maximum number of hosts kept in the cache.
+func (r *CachingResolver) MaxEntries(n int) *CachingResolver {
+	if n > 0 {
+		r.maxEntries = n
+	}
+	return r
+}
+
+// LookupHost returns the addresses of the given host, from the cache if
+// current.
+func (r *CachingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addresses, _, err := r.LookupHostTTL(ctx, host)
+	return addresses, err
+}
+
+// LookupHostTTL returns the addresses of the given host, and how long they stay
+// in the cache.
+func (r *CachingResolver) LookupHostTTL(ctx context.Context, host string) ([]string, time.Duration, error) {
+	host = strings.ToLower(host)
+	for {
+		r.lock.Lock()
+		element, ok := r.entries[host]
+		var entry *dnsEntry
+		if ok {
+			entry = element.Value.(*dnsEntry)
+			r.order.MoveToFront(element)
+			if entry.pending == nil && time.Now().Before(entry.expires) {
+				r.lock.Unlock()
+				return entry.addresses, time.Until(entry.expires), entry.err
+			}
+		}
+		if entry != nil && entry.pending != nil {
+			// another goroutine is looking the host up
+			pending := entry.pending
+			r.lock.Unlock()
+			select {
+			case <-pending:
+				continue
+			case <-ctx.Done():
+				return nil, 0, ctx.Err()
+			}
+		}
+		pending := make(chan struct{})
+		if entry == nil {
+			entry = &dnsEntry{host: host}
+			r.entries[host] = r.order.PushFront(entry)
+			r.evict()
+		}
+		entry.pending = pending
+		r.lock.Unlock()
+
+		addresses, ttl, err := r.lookup(ctx, host)
+
+		r.lock.Lock()
+		switch {
+		case err == nil:
+			entry.addresses, entry.err, entry.expires = addresses, nil, time.Now().Add(ttl)
+		case ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+			// the lookup was interrupted by the caller, the entry is left as
+			// it was for the others
+			ttl = 0
+		case len(entry.addresses) > 0 && entry.err == nil:
+			// keep using the stale addresses until the resolver recovers
+			log.Debugf("error resolving %s, using stale addresses: %v", host, err)
+			addresses, err = entry.addresses, nil
+			ttl = r.negativeTTL
+			entry.expires = time.Now().Add(ttl)
+		case !definitive(err):
+			// a transient failure, the next lookup tries again
+			ttl = 0
+		default:
+			ttl = r.negativeTTL
+			entry.addresses, entry.err, entry.expires = nil, err, time.Now().Add(ttl)
+		}
+		entry.pending = nil
+		close(pending)
+		r.lock.Unlock()
+		return addresses, ttl, err
+	}
+}
+
+// definitive returns whether the given lookup error is worth remembering for
+// the negative TTL: it is unless it is a temporary failure or a timeout (e.g.
+// a SERVFAIL), while a host that does not exist is never temporary.
+func definitive(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsNotFound || !(dnsErr.IsTemporary || dnsErr.IsTimeout)
+	}
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return false
+	}
+	var timeout interface{ Timeout() bool }
+	return !errors.As(err, &timeout) || !timeout.Timeout()
+}
+
+// Flush empties the cache.
+func (r *CachingResolver) Flush() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for host, element := range r.entries {
+		if element.Value.(*dnsEntry).pending == nil {
+			r.order.Remove(element)
+			delete(r.entries, host)
+		}
+	}
+}
+
+// lookup resolves the given host via the underlying resolver.
+func (r *CachingResolver) lookup(ctx context.Context, host string) ([]string, time.Duration, error) {
+	if resolver, ok := r.resolver.(TTLResolver); ok {
+		return resolver.LookupHostTTL(ctx, host)
+	}
+	addresses, err := r.resolver.LookupHost(ctx, host)
+	return addresses, r.ttl, err
+}
+
+// evict removes the least recently used entries beyond the maximum number,
+// except those being looked up; it must be called with the lock held.
+func (r *CachingResolver) evict() {
+	for element := r.order.Back(); element != nil && r.order.Len() > r.maxEntries; {
+		previous := element.Prev()
+		if entry := element.Value.(*dnsEntry); entry.pending == nil {
+			r.order.Remove(element)
+			delete(r.entries, entry.host)
+		}
+		element = previous
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingResolver resolves every host to 192.0.2.1, unless failing.
+type countingResolver struct {
+	lookups int32
+	failing atomic.Value
+	delay   time.Duration
+}
+
+func (r *countingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	atomic.AddInt32(&r.lookups, 1)
+	time.Sleep(r.delay)
+	if failing, _ := r.failing.Load().(bool); failing {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return []string{"192.0.2.1"}, nil
+}
+
+func TestCachingResolver(t *testing.T) {
+	ctx := context.Background()
+	resolver := &countingResolver{}
+	cache := NewCachingResolver(resolver).TTL(50 * time.Millisecond).NegativeTTL(50 * time.Millisecond).MaxEntries(2)
+
+	for i := 0; i < 3; i++ {
+		if addresses, err := cache.LookupHost(ctx, "api.example.com"); err != nil || len(addresses) != 1 {
+			t.Fatalf("unexpected addresses %v (%v)", addresses, err)
+		}
+	}
+	if resolver.lookups != 1 {
+		t.Fatalf("expected 1 lookup, got %d", resolver.lookups)
+	}
+
+	// expired addresses are kept if the resolver fails
+	time.Sleep(60 * time.Millisecond)
+	resolver.failing.Store(true)
+	if addresses, err := cache.LookupHost(ctx, "api.example.com"); err != nil || len(addresses) != 1 {
+		t.Fatalf("expected stale addresses, got %v (%v)", addresses, err)
+	}
+
+	// failures are cached too
+	for i := 0; i < 2; i++ {
+		if _, err := cache.LookupHost(ctx, "new.example.com"); err == nil {
+			t.Fatalf("expected error")
+		}
+	}
+	if resolver.lookups != 3 {
+		t.Fatalf("expected 3 lookups, got %d", resolver.lookups)
+	}
+
+	// the least recently used host is evicted
+	resolver.failing.Store(false)
+	cache.LookupHost(ctx, "other.example.com")
+	if len(cache.entries) != 2 || cache.entries["api.example.com"] != nil {
+		t.Fatalf("unexpected entries %v", cache.entries)
+	}
+
+	cache.Flush()
+	if len(cache.entries) != 0 {
+		t.Fatalf("expected empty cache")
+	}
+}
+
+// erroringResolver fails the lookups with the next error, if any.
+type erroringResolver struct {
+	lookups int
+	errs    []error
+}
+
+func (r *erroringResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.lookups++
+	if len(r.errs) > 0 {
+		err := r.errs[0]
+		r.errs = r.errs[1:]
+		return nil, err
+	}
+	return []string{"192.0.2.1"}, nil
+}
+
+func TestCachingResolverTransientFailures(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	resolver := &erroringResolver{errs: []error{
+		context.Canceled,
+		&net.DNSError{Err: "server misbehaving", Name: "api.example.com", IsTemporary: true},
+		&net.DNSError{Err: "i/o timeout", Name: "api.example.com", IsTimeout: true},
+	}}
+	cache := NewCachingResolver(resolver).NegativeTTL(time.Minute)
+	if _, err := cache.LookupHost(cancelled, "api.example.com"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancellation, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.LookupHost(context.Background(), "api.example.com"); err == nil {
+			t.Fatalf("expected a temporary error")
+		}
+	}
+	if addresses, err := cache.LookupHost(context.Background(), "api.example.com"); err != nil || len(addresses) != 1 {
+		t.Fatalf("expected the failures not to be cached, got %v (%v)", addresses, err)
+	}
+	if resolver.lookups != 4 {
+		t.Fatalf("expected 4 lookups, got %d", resolver.lookups)
+	}
+}
+
+func TestCachingResolverCollapsing(t *testing.T) {
+	resolver := &countingResolver{delay: 20 * time.Millisecond}
+	cache := NewCachingResolver(resolver)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.LookupHost(context.Background(), "api.example.com"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if resolver.lookups != 1 {
+		t.Fatalf("expected 1 lookup, got %d", resolver.lookups)
+	}
+}
+
+func TestCachingResolverTTL(t *testing.T) {
+	ctx := context.Background()
+	cache := NewCachingResolver(ttlResolver{ttl: time.Minute})
+	if _, ttl, err := cache.LookupHostTTL(ctx, "api.example.com"); err != nil || ttl <= 30*time.Second {
+		t.Fatalf("expected the record TTL, got %v (%v)", ttl, err)
+	}
+}
+
+type ttlResolver struct {
+	ttl time.Duration
+}
+
+func (r ttlResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return []string{"192.0.2.1"}, nil
+}
+
+func (r ttlResolver) LookupHostTTL(ctx context.Context, host string) ([]string, time.Duration, error) {
+	return []string{"192.0.2.1"}, r.ttl, nil
+}
@@ -0,0 +1,346 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dihedron/go-log"
+)
+
+var (
+	// ErrChecksumMismatch is returned when the checksum of a downloaded body
+	// does not match the expected one.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrNotResumable is returned when resuming a download that was not
+	// interrupted.
+	ErrNotResumable = errors.New("no interrupted download to resume")
+)
+
+// Download streams the response body of a request to a file or an io.Writer,
+// verifying its checksums along the way.
+type Download struct {
+	requestor *Requestor
+	builder   *Builder
+	expected  []byte
+	progress  func(written, total int64)
+
+	// path is the destination of the download to a file, and partial, if set,
+	// is the file holding the part received before the download was
+	// interrupted, as identified by validator (its ETag or Last-Modified).
+	path      string
+	partial   string
+	validator string
+}
+
+// Download creates a download of the response body of the request generated by
+// the given Builder, as follows:
+//
+//	n, err := requestor.Download(f).ExpectSHA256(sum).ToFile(ctx, "archive.tar.gz")
+//
+// The digests provided by the server via the Content-MD5, Digest,
+// Content-Digest or x-goog-hash headers are verified, unless the body was
+// decompressed while being received.
+func (r *Requestor) Download(f *Builder) *Download {
+	return &Download{requestor: r, builder: f}
+}
+
+// ExpectSHA256 sets the expected SHA-256 checksum of the body, in hexadecimal;
+// an invalid checksum makes the download fail.
+func (d *Download) ExpectSHA256(sum string) *Download {
+	d.expected, _ = hex.DecodeString(sum)
+	if d.expected == nil {
+		d.expected = []byte{}
+	}
+	return d
+}
+
+// Progress sets a callback invoked as the body is written, with the number of
+// bytes written so far and the size of the body (-1 if unknown).
+func (d *Download) Progress(callback func(written, total int64)) *Download {
+	d.progress = callback
+	return d
+}
+
+// To writes the body to the given writer, and returns the number of bytes
+// written; if the verification of the checksums fails, the body has been
+// written nonetheless.
+func (d *Download) To(ctx context.Context, w io.Writer) (int64, error) {
+	response, err := d.requestor.Do(ctx, d.builder)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	return d.copy(w, response, nil)
+}
+
+// ToFile writes the body to the file at the given path, and returns the number
+// of bytes written; the body is written to a temporary file in the same
+// directory, which is atomically renamed once complete and verified, so that
+// the file never has partial contents. If the transfer of the body is
+// interrupted, the part received so far is kept so that the download can be
+// resumed (see Resume) or discarded (see Discard).
+func (d *Download) ToFile(ctx context.Context, path string) (int64, error) {
+	if err := d.Discard(); err != nil {
+		return 0, err
+	}
+	d.path = path
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return 0, err
+	}
+	response, err := d.requestor.Do(ctx, d.builder)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return 0, err
+	}
+	defer response.Body.Close()
+	return d.complete(file, response, 0)
+}
+
+// Resume resumes the interrupted download to a file, by requesting the rest
+// of the body from where it was interrupted, provided the server supports
+// range requests and the body has not changed in the meantime, as checked via
+// its ETag or Last-Modified header; otherwise, the whole body is downloaded
+// again. It returns the size of the file, and ErrNotResumable if there is no
+// interrupted download.
+func (d *Download) Resume(ctx context.Context) (int64, error) {
+	if d.partial == "" {
+		return 0, ErrNotResumable
+	}
+	info, err := os.Stat(d.partial)
+	if err != nil {
+		d.partial = ""
+		return 0, err
+	}
+	offset := info.Size()
+	f := d.builder.New("", "")
+	f.SetHeader("Range", fmt.Sprintf("bytes=%d-", offset))
+	if d.validator != "" {
+		f.SetHeader("If-Range", d.validator)
+	}
+	response, err := d.requestor.Do(ctx, f)
+	restart := StatusCode(err) == http.StatusRequestedRangeNotSatisfiable
+	if err != nil && !restart {
+		return 0, err
+	}
+	if err == nil && response.StatusCode == http.StatusPartialContent && !resumes(response, offset) {
+		response.Body.Close()
+		restart = true
+	}
+	if restart {
+		// the server does not accept the range, start over
+		if response, err = d.requestor.Do(ctx, d.builder); err != nil {
+			return 0, err
+		}
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusPartialContent {
+		// the server does not support ranges, or the body has changed
+		offset = 0
+	}
+	file, err := os.OpenFile(d.partial, os.O_RDWR, 0600)
+	if err != nil {
+		return 0, err
+	}
+	if offset == 0 {
+		log.Debugf("download from %s cannot be resumed, starting over", RedactURL(response.Request.URL))
+		err = file.Truncate(0)
+	} else {
+		_, err = file.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return 0, err
+	}
+	n, err := d.complete(file, response, offset)
+	return offset + n, err
+}
+
+// Discard removes the part received by an interrupted download to a file, if
+// any.
+func (d *Download) Discard() error {
+	if d.partial == "" {
+		return nil
+	}
+	partial := d.partial
+	d.partial = ""
+	if err := os.Remove(partial); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// complete writes the given response body to the given temporary file from the
+// given offset, and moves the file to the destination path once done; if the
+// transfer is interrupted, the file is kept to resume the download.
+func (d *Download) complete(file *os.File, response *Response, offset int64) (int64, error) {
+	var first *io.SectionReader
+	if offset > 0 {
+		first = io.NewSectionReader(file, 0, offset)
+	}
+	n, err := d.copy(file, response, first)
+	if err != nil {
+		file.Close()
+		validator := response.Header.Get("ETag")
+		if strings.HasPrefix(validator, "W/") || validator == "" {
+			validator = response.Header.Get("Last-Modified")
+		}
+		if errors.Is(err, ErrChecksumMismatch) || offset+n == 0 || response.Header.Get("Accept-Ranges") == "none" || validator == "" {
+			d.partial = ""
+			os.Remove(file.Name())
+		} else {
+			d.partial = file.Name()
+			d.validator = validator
+		}
+		return n, err
+	}
+	d.partial = ""
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return n, err
+	}
+	if err := os.Rename(file.Name(), d.path); err != nil {
+		os.Remove(file.Name())
+		return n, err
+	}
+	return n, nil
+}
+
+// resumes returns whether the given partial response continues the body from
+// the given offset.
+func resumes(response *Response, offset int64) bool {
+	var start int64
+	_, err := fmt.Sscanf(response.Header.Get("Content-Range"), "bytes %d-", &start)
+	return err == nil && start == offset
+}
+
+// copy writes the body of the given response to the given writer, verifying
+// its checksums; if the body is the continuation of an interrupted download,
+// whose first part is given, only the expected SHA-256 checksum is verified.
+func (d *Download) copy(w io.Writer, response *Response, first *io.SectionReader) (int64, error) {
+	var offset int64
+	if first != nil {
+		offset = first.Size()
+	}
+	checksums := []*checksum{}
+	if d.expected != nil {
+		checksum := &checksum{name: "sha-256", hash: sha256.New(), expected: d.expected}
+		if first != nil {
+			if _, err := io.Copy(checksum.hash, first); err != nil {
+				return 0, err
+			}
+		}
+		checksums = append(checksums, checksum)
+	}
+	if !response.Uncompressed && offset == 0 {
+		checksums = append(checksums, digests(response.Header)...)
+	}
+	writers := []io.Writer{w}
+	for _, c := range checksums {
+		writers = append(writers, c.hash)
+	}
+	var out io.Writer = io.MultiWriter(writers...)
+	if d.progress != nil {
+		total := response.ContentLength
+		if total >= 0 {
+			total += offset
+		}
+		out = &progressWriter{Writer: out, written: offset, total: total, progress: d.progress}
+	}
+	n, err := io.Copy(out, response.Body)
+	if err != nil {
+		return n, err
+	}
+	for _, c := range checksums {
+		if sum := c.hash.Sum(nil); !bytes.Equal(sum, c.expected) {
+			return n, fmt.Errorf("%s %s: %w: %s is %x, expected %x", response.Request.Method, RedactURL(response.Request.URL), ErrChecksumMismatch, c.name, sum, c.expected)
+		}
+	}
+	return n, nil
+}
+
+// checksum is a checksum being computed, along with its expected value.
+type checksum struct {
+	name     string
+	hash     hash.Hash
+	expected []byte
+}
+
+// digests returns the checksums announced by the server in the given headers,
+// among those of supported algorithms.
+func digests(header http.Header) []*checksum {
+	checksums := []*checksum{}
+	add := func(algorithm, value string) {
+		algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+		expected, err := base64.StdEncoding.DecodeString(strings.Trim(strings.TrimSpace(value), ":"))
+		if err != nil {
+			return
+		}
+		var h hash.Hash
+		switch algorithm {
+		case "md5":
+			h = md5.New()
+		case "sha":
+			h = sha1.New()
+		case "sha-256":
+			h = sha256.New()
+		case "sha-512":
+			h = sha512.New()
+		case "crc32c":
+			h = crc32.New(crc32.MakeTable(crc32.Castagnoli))
+		default:
+			return
+		}
+		checksums = append(checksums, &checksum{name: algorithm, hash: h, expected: expected})
+	}
+	if value := header.Get("Content-MD5"); value != "" {
+		add("md5", value)
+	}
+	for _, name := range []string{"Digest", "Content-Digest", "X-Goog-Hash"} {
+		for _, values := range header.Values(name) {
+			for _, value := range strings.Split(values, ",") {
+				if i := strings.Index(value, "="); i > 0 {
+					add(value[:i], value[i+1:])
+				}
+			}
+		}
+	}
+	return checksums
+}
+
+// progressWriter is a writer reporting the progress of a download.
+type progressWriter struct {
+	io.Writer
+	written  int64
+	total    int64
+	progress func(written, total int64)
+}
+
+// Write writes the data, and reports the progress.
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.written += int64(n)
+	w.progress(w.written, w.total)
+	return n, err
+}
@@ -0,0 +1,179 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownload(t *testing.T) {
+	payload := []byte(strings.Repeat("0123456789", 1000))
+	md5sum := md5.Sum(payload)
+	sha256sum := sha256.Sum256(payload)
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.Checksum(payload, crc32.MakeTable(crc32.Castagnoli)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/md5":
+			w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(md5sum[:]))
+		case "/digest":
+			w.Header().Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sha256sum[:]))
+		case "/goog":
+			w.Header().Set("x-goog-hash", "crc32c="+base64.StdEncoding.EncodeToString(crc[:]))
+			w.Header().Add("x-goog-hash", "md5="+base64.StdEncoding.EncodeToString(md5sum[:]))
+		case "/corrupt":
+			w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(md5sum[:]))
+			w.Write(payload[1:])
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(payload)))
+		w.Write(payload)
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(nil)
+	api := New(server.URL + "/")
+	for _, path := range []string{"md5", "digest", "goog", "plain"} {
+		var buffer bytes.Buffer
+		n, err := requestor.Download(api.New("", path)).ExpectSHA256(fmt.Sprintf("%x", sha256sum)).To(context.Background(), &buffer)
+		if err != nil || n != int64(len(payload)) || !bytes.Equal(buffer.Bytes(), payload) {
+			t.Fatalf("unexpected %s download of %d bytes (%v)", path, n, err)
+		}
+	}
+
+	var buffer bytes.Buffer
+	if _, err := requestor.Download(api.New("", "corrupt")).To(context.Background(), &buffer); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if _, err := requestor.Download(api.New("", "plain")).ExpectSHA256("00").To(context.Background(), &buffer); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+
+	directory := t.TempDir()
+	path := filepath.Join(directory, "payload.txt")
+	var written, total int64
+	calls := 0
+	n, err := requestor.Download(api.New("", "md5")).Progress(func(w, t int64) {
+		written, total = w, t
+		calls++
+	}).ToFile(context.Background(), path)
+	if err != nil || n != int64(len(payload)) {
+		t.Fatalf("unexpected download of %d bytes (%v)", n, err)
+	}
+	if data, _ := ioutil.ReadFile(path); !bytes.Equal(data, payload) {
+		t.Fatalf("unexpected file contents")
+	}
+	if calls == 0 || written != int64(len(payload)) || total != int64(len(payload)) {
+		t.Fatalf("unexpected progress %d/%d (%d calls)", written, total, calls)
+	}
+
+	if _, err := requestor.Download(api.New("", "corrupt")).ToFile(context.Background(), filepath.Join(directory, "corrupt.txt")); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if entries, _ := os.ReadDir(directory); len(entries) != 1 {
+		t.Fatalf("expected only the complete file to be left, got %d entries", len(entries))
+	}
+}
+
+func TestResume(t *testing.T) {
+	payload := []byte(strings.Repeat("0123456789", 1000))
+	sum := sha256.Sum256(payload)
+	var interrupt, ranges bool
+	etag := `"v1"`
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.Header.Get("Range"))
+		if interrupt {
+			interrupt = false
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", fmt.Sprint(len(payload)))
+			w.Write(payload[:4000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		if !ranges {
+			r.Header.Del("Range")
+		}
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(payload))
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(nil)
+	path := filepath.Join(t.TempDir(), "payload.txt")
+	download := requestor.Download(New(server.URL)).ExpectSHA256(fmt.Sprintf("%x", sum))
+	if _, err := download.Resume(context.Background()); !errors.Is(err, ErrNotResumable) {
+		t.Fatalf("expected not resumable error, got %v", err)
+	}
+
+	interrupt, ranges = true, true
+	if _, err := download.ToFile(context.Background(), path); err == nil {
+		t.Fatalf("expected the download to be interrupted")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no file before the download completes")
+	}
+	var written int64 = -1
+	n, err := download.Progress(func(w, total int64) {
+		if written < 0 && total != int64(len(payload)) {
+			t.Errorf("unexpected total %d", total)
+		}
+		written = w
+	}).Resume(context.Background())
+	if err != nil || n != int64(len(payload)) || written != int64(len(payload)) {
+		t.Fatalf("unexpected resumed download of %d bytes (%v)", n, err)
+	}
+	if requested[len(requested)-1] != "bytes=4000-" {
+		t.Fatalf("expected a range request, got %q", requested[len(requested)-1])
+	}
+	if data, _ := ioutil.ReadFile(path); !bytes.Equal(data, payload) {
+		t.Fatalf("unexpected file contents")
+	}
+
+	// the server ignores ranges, so the download starts over
+	os.Remove(path)
+	interrupt, ranges = true, false
+	download.ToFile(context.Background(), path)
+	if n, err := download.Resume(context.Background()); err != nil || n != int64(len(payload)) {
+		t.Fatalf("unexpected restarted download of %d bytes (%v)", n, err)
+	}
+	if data, _ := ioutil.ReadFile(path); !bytes.Equal(data, payload) {
+		t.Fatalf("unexpected file contents")
+	}
+
+	// the body changes, so the download starts over
+	os.Remove(path)
+	interrupt, ranges = true, true
+	download.ToFile(context.Background(), path)
+	etag = `"v2"`
+	payload[0] = 'x'
+	sum = sha256.Sum256(payload)
+	if n, err := download.ExpectSHA256(fmt.Sprintf("%x", sum)).Resume(context.Background()); err != nil || n != int64(len(payload)) {
+		t.Fatalf("unexpected restarted download of %d bytes (%v)", n, err)
+	}
+	if data, _ := ioutil.ReadFile(path); !bytes.Equal(data, payload) {
+		t.Fatalf("unexpected file contents")
+	}
+	if _, err := download.Resume(context.Background()); !errors.Is(err, ErrNotResumable) {
+		t.Fatalf("expected not resumable error, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// EncodeOptions is a set of flags controlling how request bodies are encoded
+// to JSON (see WithJSONEntity).
+type EncodeOptions uint
+
+const (
+	// NoEscapeHTML leaves "<", ">" and "&" unescaped in JSON strings, instead
+	// of encoding them as "\u003c" and so on; it only applies to the standard
+	// encoder, not to a JSONMarshaler.
+	NoEscapeHTML EncodeOptions = 1 << iota
+	// IndentJSON indents the JSON bodies with two spaces, for debuggability.
+	IndentJSON
+)
+
+// JSONMarshaler encodes values to JSON, e.g. by means of an alternative JSON
+// library.
+type JSONMarshaler interface {
+	Marshal(v interface{}) ([]byte, error)
+}
+
+// JSONMarshalerFunc adapts a function, such as the Marshal function of most
+// JSON libraries, to a JSONMarshaler.
+type JSONMarshalerFunc func(v interface{}) ([]byte, error)
+
+// Marshal encodes the given value to JSON.
+func (m JSONMarshalerFunc) Marshal(v interface{}) ([]byte, error) {
+	return m(v)
+}
+
+// EncodeOptions sets the options used to encode the JSON bodies of the
+// requests generated by this builder and its children; they apply to the
+// bodies set afterwards.
+func (f *Builder) EncodeOptions(options EncodeOptions) *Builder {
+	f.encodeOptions = options
+	return f
+}
+
+// JSONMarshaler sets the JSONMarshaler used to encode the JSON bodies of the
+// requests generated by this builder and its children, instead of the
+// standard library, e.g.:
+//
+//	f.JSONMarshaler(request.JSONMarshalerFunc(jsoniter.Marshal))
+//
+// It applies to the bodies set afterwards; nil restores the standard library.
+func (f *Builder) JSONMarshaler(marshaler JSONMarshaler) *Builder {
+	f.marshaler = marshaler
+	return f
+}
+
+// marshalJSON encodes the given value to JSON according to the encoding
+// options and marshaler of the builder.
+func (f *Builder) marshalJSON(v interface{}) ([]byte, error) {
+	var data []byte
+	if f.marshaler != nil {
+		var err error
+		if data, err = f.marshaler.Marshal(v); err != nil {
+			return nil, err
+		}
+	} else {
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetEscapeHTML(f.encodeOptions&NoEscapeHTML == 0)
+		if err := encoder.Encode(v); err != nil {
+			return nil, err
+		}
+		data = bytes.TrimSuffix(buffer.Bytes(), []byte("\n"))
+	}
+	if f.encodeOptions&IndentJSON != 0 {
+		var buffer bytes.Buffer
+		if err := json.Indent(&buffer, data, "", "  "); err != nil {
+			return nil, err
+		}
+		data = buffer.Bytes()
+	}
+	return data, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestEncodeOptions(t *testing.T) {
+	type query struct {
+		Expression string `json:"expression"`
+	}
+	entity := query{Expression: "a < b && c > d"}
+	testCases := []struct {
+		options  EncodeOptions
+		expected string
+	}{
+		{0, `{"expression":"a \u003c b \u0026\u0026 c \u003e d"}`},
+		{NoEscapeHTML, `{"expression":"a < b && c > d"}`},
+		{NoEscapeHTML | IndentJSON, "{\n  \"expression\": \"a < b && c > d\"\n}"},
+	}
+	for _, test := range testCases {
+		request, err := New("http://www.example.com/").EncodeOptions(test.options).WithJSONEntity(entity).Make()
+		if err != nil {
+			t.Fatalf("options %d: unexpected error: %v", test.options, err)
+		}
+		if data, _ := ioutil.ReadAll(request.Body); string(data) != test.expected {
+			t.Fatalf("options %d: expected %q, got %q", test.options, test.expected, data)
+		}
+	}
+}
+
+func TestJSONMarshaler(t *testing.T) {
+	calls := 0
+	marshaler := JSONMarshalerFunc(func(v interface{}) ([]byte, error) {
+		calls++
+		return json.Marshal(v)
+	})
+	base := New("http://www.example.com/").JSONMarshaler(marshaler).EncodeOptions(IndentJSON)
+	request, err := base.New("", "").WithJSONEntity(Decimal("1.50")).Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := ioutil.ReadAll(request.Body); string(data) != "1.50" || calls != 1 {
+		t.Fatalf("expected the custom marshaler to encode 1.50, got %q (%d calls)", data, calls)
+	}
+	failing := JSONMarshalerFunc(func(v interface{}) ([]byte, error) {
+		return nil, errors.New("boom")
+	})
+	if _, err := base.New("", "").JSONMarshaler(failing).WithJSONEntity(struct{}{}).Make(); err == nil {
+		t.Fatalf("expected the encoding error")
+	}
+}
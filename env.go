@@ -0,0 +1,64 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FromEnv returns a new request builder configured from the environment
+// variables having the given prefix (e.g. "MYAPP_HTTP"), followed by:
+//
+//	_BASE_URL      the base URL
+//	_PROXY         the URL of the proxy the requests are sent through
+//	_TIMEOUT       the overall deadline of the requests (e.g. "30s")
+//	_TOKEN         a bearer token, sent in the Authorization header
+//	_HEADER_<NAME> a default header, whose name is spelled with underscores
+//	               instead of dashes (e.g. MYAPP_HTTP_HEADER_X_TEAM=core)
+//
+// Variables that are not set are ignored; if a value is invalid, Make fails.
+func FromEnv(prefix string) *Builder {
+	prefix = strings.TrimSuffix(prefix, "_") + "_"
+	f := New(os.Getenv(prefix + "BASE_URL"))
+	if value := os.Getenv(prefix + "PROXY"); value != "" {
+		proxy, err := url.Parse(value)
+		if err != nil {
+			f.err = fmt.Errorf("invalid %sPROXY: %w", prefix, err)
+			return f
+		}
+		f.customise(func(transport *http.Transport) {
+			transport.Proxy = http.ProxyURL(proxy)
+		})
+	}
+	if value := os.Getenv(prefix + "TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			f.err = fmt.Errorf("invalid %sTIMEOUT: %w", prefix, err)
+			return f
+		}
+		f.Timeout(timeout)
+	}
+	if token := os.Getenv(prefix + "TOKEN"); token != "" {
+		f.SetHeader("Authorization", "Bearer "+token)
+	}
+	variables := os.Environ()
+	sort.Strings(variables)
+	for _, variable := range variables {
+		if !strings.HasPrefix(variable, prefix+"HEADER_") {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(variable, prefix+"HEADER_"), "=", 2)
+		if len(parts) == 2 && parts[0] != "" {
+			f.SetHeader(strings.Replace(parts[0], "_", "-", -1), parts[1])
+		}
+	}
+	return f
+}
@@ -0,0 +1,53 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFromEnv(t *testing.T) {
+	for key, value := range map[string]string{
+		"TESTAPP_HTTP_BASE_URL":          "https://api.example.com/v1/",
+		"TESTAPP_HTTP_PROXY":             "http://proxy.example.com:3128",
+		"TESTAPP_HTTP_TIMEOUT":           "5s",
+		"TESTAPP_HTTP_TOKEN":             "secret",
+		"TESTAPP_HTTP_HEADER_X_TEAM":     "core",
+		"TESTAPP_HTTP_HEADER_USER_AGENT": "worker/1.0",
+	} {
+		os.Setenv(key, value)
+		defer os.Unsetenv(key)
+	}
+	api := FromEnv("TESTAPP_HTTP")
+	request, err := api.New("", "users").Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.URL.String() != "https://api.example.com/v1/users" {
+		t.Fatalf("unexpected URL %s", request.URL)
+	}
+	if request.Header.Get("X-Team") != "core" || request.Header.Get("User-Agent") != "worker/1.0" || request.Header.Get("Authorization") != "Bearer secret" {
+		t.Fatalf("unexpected headers %v", request.Header)
+	}
+	if api.timeout != 5*time.Second {
+		t.Fatalf("unexpected timeout %v", api.timeout)
+	}
+	transport, err := api.roundTripper(http.DefaultClient)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	proxy, err := transport.(*http.Transport).Proxy(request)
+	if err != nil || proxy.Host != "proxy.example.com:3128" {
+		t.Fatalf("unexpected proxy %v (%v)", proxy, err)
+	}
+
+	os.Setenv("TESTAPP_HTTP_TIMEOUT", "soon")
+	if _, err := FromEnv("TESTAPP_HTTP_").Make(); err == nil {
+		t.Fatalf("expected error for invalid timeout")
+	}
+}
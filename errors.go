@@ -0,0 +1,251 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultErrorBodyLimit is the default maximum number of bytes of the response
+// body that are retained in an HTTPError.
+const DefaultErrorBodyLimit = 4096
+
+// HTTPError is the error returned when the server responds with a non-2xx
+// status code; it captures enough information about the request and the
+// response to be logged or inspected without having to keep the response
+// around, since the response body is always consumed and closed.
+type HTTPError struct {
+	// StatusCode is the HTTP status code of the response, e.g. 404.
+	StatusCode int
+	// Status is the HTTP status line of the response, e.g. "404 Not Found".
+	Status string
+	// Header contains the response headers.
+	Header http.Header
+	// Body is a bounded snapshot of the response body.
+	Body []byte
+	// Truncated is set when the response body was longer than the snapshot.
+	Truncated bool
+	// Method is the HTTP method of the request that caused the error.
+	Method string
+	// URL is the URL of the request that caused the error, with its password
+	// and sensitive query parameters redacted (see RedactURL).
+	URL string
+	// Attempts is the number of times the request was sent to the server.
+	Attempts int
+}
+
+// NewHTTPError creates an HTTPError out of the given response, retaining at most
+// limit bytes of its body (or DefaultErrorBodyLimit if limit is not positive);
+// the response body is drained and closed.
+func NewHTTPError(response *http.Response, attempts int, limit int) *HTTPError {
+	if limit <= 0 {
+		limit = DefaultErrorBodyLimit
+	}
+	e := &HTTPError{
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Header:     response.Header,
+		Attempts:   attempts,
+	}
+	if response.Request != nil {
+		e.Method = response.Request.Method
+		if response.Request.URL != nil {
+			e.URL = RedactURL(response.Request.URL)
+		}
+	}
+	if response.Body != nil {
+		data, _ := io.ReadAll(io.LimitReader(response.Body, int64(limit)+1))
+		if len(data) > limit {
+			data = data[:limit]
+			e.Truncated = true
+		}
+		e.Body = data
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+	}
+	return e
+}
+
+// Error returns a description of the error, including the request method and
+// URL, the response status and the number of attempts.
+func (e *HTTPError) Error() string {
+	status := e.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	s := fmt.Sprintf("%s %s: unexpected status %s", e.Method, e.URL, status)
+	if e.Attempts > 1 {
+		s = fmt.Sprintf("%s (after %d attempts)", s, e.Attempts)
+	}
+	return s
+}
+
+// Is allows errors.Is to match an HTTPError against a target HTTPError having
+// the same status code, e.g. errors.Is(err, &HTTPError{StatusCode: 404}).
+func (e *HTTPError) Is(target error) bool {
+	t, ok := target.(*HTTPError)
+	if !ok {
+		return false
+	}
+	return t.StatusCode == 0 || t.StatusCode == e.StatusCode
+}
+
+// StatusCode returns the HTTP status code carried by the error (or by any error
+// in its chain), or 0 if the error is not an HTTPError.
+func StatusCode(err error) int {
+	var e *HTTPError
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// IsHTTPError returns whether the error (or any error in its chain) is an
+// HTTPError.
+func IsHTTPError(err error) bool {
+	return StatusCode(err) != 0
+}
+
+// IsBadRequest returns whether the error originates from a "400 Bad Request".
+func IsBadRequest(err error) bool {
+	return StatusCode(err) == http.StatusBadRequest
+}
+
+// IsUnauthorized returns whether the error originates from a "401 Unauthorized".
+func IsUnauthorized(err error) bool {
+	return StatusCode(err) == http.StatusUnauthorized
+}
+
+// IsForbidden returns whether the error originates from a "403 Forbidden".
+func IsForbidden(err error) bool {
+	return StatusCode(err) == http.StatusForbidden
+}
+
+// IsNotFound returns whether the error originates from a "404 Not Found".
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsConflict returns whether the error originates from a "409 Conflict".
+func IsConflict(err error) bool {
+	return StatusCode(err) == http.StatusConflict
+}
+
+// IsRateLimited returns whether the error originates from a "429 Too Many
+// Requests".
+func IsRateLimited(err error) bool {
+	return StatusCode(err) == http.StatusTooManyRequests
+}
+
+// IsClientError returns whether the error originates from a 4xx status code.
+func IsClientError(err error) bool {
+	code := StatusCode(err)
+	return code >= 400 && code < 500
+}
+
+// IsServerError returns whether the error originates from a 5xx status code.
+func IsServerError(err error) bool {
+	code := StatusCode(err)
+	return code >= 500 && code < 600
+}
+
+// ItemError is the failure of a single item in a batch execution.
+type ItemError struct {
+	// Index is the position of the failed item in the batch.
+	Index int
+	// Err is the error returned by the item.
+	Err error
+}
+
+// Error returns a description of the item failure.
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the underlying item error.
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError is the error returned when one or more items in a batch execution
+// fail; it lists the failures in item order, and supports errors.Is and
+// errors.As on the individual item errors, so that for instance
+// errors.As(err, &httpErr) extracts the first HTTPError in the batch.
+type BatchError struct {
+	// Total is the overall number of items in the batch.
+	Total int
+	// Failures contains the errors of the failed items, in item order.
+	Failures []*ItemError
+}
+
+// NewBatchError creates a BatchError out of the per-item outcomes of a batch,
+// where errs[i] is the error returned by the i-th item (or nil if it succeeded);
+// if all items succeeded, it returns nil.
+func NewBatchError(errs []error) error {
+	e := &BatchError{Total: len(errs)}
+	for i, err := range errs {
+		if err != nil {
+			e.Failures = append(e.Failures, &ItemError{Index: i, Err: err})
+		}
+	}
+	if len(e.Failures) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error returns a one-line description of the batch failure, mentioning the
+// first failed item.
+func (e *BatchError) Error() string {
+	if len(e.Failures) == 0 {
+		return fmt.Sprintf("0 of %d items failed", e.Total)
+	}
+	return fmt.Sprintf("%d of %d items failed (first: %v)", len(e.Failures), e.Total, e.Failures[0])
+}
+
+// Summary returns a multi-line, human readable description of the batch failure,
+// with one line per failed item.
+func (e *BatchError) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d items failed", len(e.Failures), e.Total)
+	for _, failure := range e.Failures {
+		fmt.Fprintf(&b, "\n  - %v", failure)
+	}
+	return b.String()
+}
+
+// Unwrap returns the item errors, so that errors.Is and errors.As can inspect
+// them.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		errs = append(errs, failure)
+	}
+	return errs
+}
+
+// Failed returns the indexes of the failed items.
+func (e *BatchError) Failed() []int {
+	indexes := make([]int, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		indexes = append(indexes, failure.Index)
+	}
+	return indexes
+}
+
+// Item returns the error of the item at the given index, or nil if it
+// succeeded.
+func (e *BatchError) Item(index int) error {
+	for _, failure := range e.Failures {
+		if failure.Index == index {
+			return failure.Err
+		}
+	}
+	return nil
+}
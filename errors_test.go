@@ -0,0 +1,119 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNewHTTPError(t *testing.T) {
+	u, _ := url.Parse("https://www.example.com/api/v2/users/42")
+	response := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Status:     "404 Not Found",
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader("0123456789")),
+		Request:    &http.Request{Method: http.MethodGet, URL: u},
+	}
+	e := NewHTTPError(response, 3, 4)
+	if e.StatusCode != http.StatusNotFound {
+		t.Fatalf("invalid status code: expected 404, got %d", e.StatusCode)
+	}
+	if string(e.Body) != "0123" || !e.Truncated {
+		t.Fatalf("invalid body snapshot: got %q (truncated: %t)", e.Body, e.Truncated)
+	}
+	if e.Method != http.MethodGet || e.URL != u.String() {
+		t.Fatalf("invalid request info: got %s %s", e.Method, e.URL)
+	}
+	expected := "GET https://www.example.com/api/v2/users/42: unexpected status 404 Not Found (after 3 attempts)"
+	if e.Error() != expected {
+		t.Fatalf("invalid error message: expected %q, got %q", expected, e.Error())
+	}
+}
+
+func TestHTTPErrorIs(t *testing.T) {
+	var err error = fmt.Errorf("wrapped: %w", &HTTPError{StatusCode: http.StatusTooManyRequests})
+	if !errors.Is(err, &HTTPError{StatusCode: http.StatusTooManyRequests}) {
+		t.Fatalf("error should match 429")
+	}
+	if errors.Is(err, &HTTPError{StatusCode: http.StatusNotFound}) {
+		t.Fatalf("error should not match 404")
+	}
+	if !errors.Is(err, &HTTPError{}) {
+		t.Fatalf("error should match any HTTPError")
+	}
+	if !IsRateLimited(err) || !IsClientError(err) || IsServerError(err) || IsNotFound(err) {
+		t.Fatalf("invalid classification of 429")
+	}
+	if StatusCode(errors.New("other")) != 0 || IsHTTPError(errors.New("other")) {
+		t.Fatalf("non-HTTP errors should have no status code")
+	}
+}
+
+func TestHTTPErrorHelpers(t *testing.T) {
+	tests := []struct {
+		code  int
+		check func(error) bool
+	}{
+		{http.StatusBadRequest, IsBadRequest},
+		{http.StatusUnauthorized, IsUnauthorized},
+		{http.StatusForbidden, IsForbidden},
+		{http.StatusNotFound, IsNotFound},
+		{http.StatusConflict, IsConflict},
+		{http.StatusTooManyRequests, IsRateLimited},
+		{http.StatusBadGateway, IsServerError},
+	}
+	for _, test := range tests {
+		if !test.check(&HTTPError{StatusCode: test.code}) {
+			t.Fatalf("invalid classification of status code %d", test.code)
+		}
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	if err := NewBatchError([]error{nil, nil}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	err := NewBatchError([]error{
+		nil,
+		&HTTPError{StatusCode: http.StatusNotFound, Method: http.MethodGet, URL: "https://www.example.com/1"},
+		nil,
+		errors.New("connection refused"),
+	})
+	var batch *BatchError
+	if !errors.As(err, &batch) {
+		t.Fatalf("expected a BatchError, got %T", err)
+	}
+	if len(batch.Failed()) != 2 || batch.Failed()[0] != 1 || batch.Failed()[1] != 3 {
+		t.Fatalf("invalid failed items: got %v", batch.Failed())
+	}
+	if batch.Item(0) != nil || batch.Item(3) == nil {
+		t.Fatalf("invalid per-item errors")
+	}
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected to extract the HTTPError from the batch")
+	}
+	if !IsNotFound(err) {
+		t.Fatalf("expected batch to contain a 404")
+	}
+	var item *ItemError
+	if !errors.As(err, &item) || item.Index != 1 {
+		t.Fatalf("expected to extract the first ItemError from the batch")
+	}
+	expected := "2 of 4 items failed (first: item 1: GET https://www.example.com/1: unexpected status 404 Not Found)"
+	if err.Error() != expected {
+		t.Fatalf("invalid error message: expected %q, got %q", expected, err.Error())
+	}
+	if lines := strings.Split(batch.Summary(), "\n"); len(lines) != 3 {
+		t.Fatalf("invalid summary: got %q", batch.Summary())
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// DefaultCacheSize is the number of entries of the in-memory caches created
+// when no Store is provided.
+const DefaultCacheSize = 1000
+
+// validatedHeaders are the headers of a "304 Not Modified" response that update
+// those of the cached response.
+var validatedHeaders = []string{"Cache-Control", "Content-Location", "Date", "ETag", "Expires", "Last-Modified", "Vary"}
+
+// validation is a cached response, along with the validators needed to check
+// whether it is still current.
+type validation struct {
+	StatusCode int         `json:"status_code"`
+	Status     string      `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	// Stored is the time at which the response was received or revalidated.
+	Stored time.Time `json:"stored"`
+	// Vary contains the values of the request headers listed in the Vary
+	// response header.
+	Vary http.Header `json:"vary,omitempty"`
+}
+
+// Revalidate enables the conditional request cache: the bodies of the 2xx
+// responses to GET requests carrying an ETag or Last-Modified header are kept
+// in the given Store (an LRUStore of DefaultCacheSize entries if nil), and the
+// following identical requests are sent with the If-None-Match and
+// If-Modified-Since headers; if the server answers "304 Not Modified", the
+// cached response is returned, with FromCache set. Requests already carrying
+// conditional headers are sent as they are, and range requests and 206 Partial
+// Content responses are never cached.
+func (r *Requestor) Revalidate(store Store) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if store == nil {
+		store = NewLRUStore(DefaultCacheSize)
+	}
+	r.cache = store
+	return r
+}
+
+// cacheKey returns the key under which the cached response to the given request
+// is stored; the key is hashed since it includes credentials.
+func cacheKey(request *http.Request) string {
+	hash := sha256.Sum256([]byte(DeduplicationKey(request)))
+	return "cache/" + hex.EncodeToString(hash[:])
+}
+
+// cacheable returns whether the cache applies to the given request, generated
+// by the given Builder; range requests are not cached, since the cache key does
+// not include the range.
+func (r *Requestor) cacheable(f *Builder, request *http.Request) bool {
+	if r.cache == nil || request.Method != http.MethodGet || f.caching == CacheBypass || request.Header.Get("Range") != "" {
+		return false
+	}
+	_, noStore := cacheControl(request.Header)["no-store"]
+	return !noStore
+}
+
+// lookup returns the cached response to the given request, if any.
+func (r *Requestor) lookup(request *http.Request) *validation {
+	data, ok, err := r.cache.Get(request.Context(), cacheKey(request))
+	if err != nil {
+		log.Errorf("error loading cached response for %q: %v", RedactURL(request.URL), err)
+		return nil
+	} else if !ok {
+		return nil
+	}
+	cached := &validation{}
+	if err := json.Unmarshal(data, cached); err != nil {
+		log.Errorf("invalid cached response for %q: %v", RedactURL(request.URL), err)
+		return nil
+	}
+	if cached.StatusCode == http.StatusPartialContent {
+		return nil
+	}
+	for name, values := range cached.Vary {
+		if strings.Join(request.Header.Values(name), ", ") != strings.Join(values, ", ") {
+			return nil
+		}
+	}
+	return cached
+}
+
+// precondition returns a copy of the given request carrying the conditional
+// headers, along with the cached response, if one is available; the given
+// request is left untouched, so that retries and requeued attempts revalidate
+// the cached response again.
+func (r *Requestor) precondition(f *Builder, request *http.Request) (*http.Request, *validation) {
+	if !r.cacheable(f, request) || request.Header.Get("If-None-Match") != "" || request.Header.Get("If-Modified-Since") != "" {
+		return request, nil
+	}
+	cached := r.lookup(request)
+	if cached == nil {
+		return request, nil
+	}
+	conditional := request.Clone(request.Context())
+	if etag := cached.Header.Get("ETag"); etag != "" {
+		conditional.Header.Set("If-None-Match", etag)
+	}
+	if modified := cached.Header.Get("Last-Modified"); modified != "" {
+		conditional.Header.Set("If-Modified-Since", modified)
+	}
+	return conditional, cached
+}
+
+// validate returns the cached response if the server confirmed it is still
+// current, and caches the new one if it can be; it returns whether the response
+// comes from the cache.
+func (r *Requestor) validate(f *Builder, request *http.Request, response *http.Response, cached *validation) (*http.Response, bool, error) {
+	if cached != nil && response.StatusCode == http.StatusNotModified {
+		log.Debugf("response for %q not modified, using cached body", RedactURL(request.URL))
+		r.count(func(stats *CacheStats) { stats.Revalidations++ })
+		ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		header := cached.Header.Clone()
+		for _, name := range validatedHeaders {
+			if values := response.Header.Values(name); len(values) > 0 {
+				header[name] = values
+			}
+		}
+		cached.Header = header
+		cached.Stored = time.Now()
+		r.store(request, cached)
+		validated := *response
+		validated.StatusCode = cached.StatusCode
+		validated.Status = cached.Status
+		validated.Header = header
+		validated.Body = ioutil.NopCloser(bytes.NewReader(cached.Body))
+		validated.ContentLength = int64(len(cached.Body))
+		return &validated, true, nil
+	}
+	if !r.cacheable(f, request) || response.StatusCode < 200 || response.StatusCode > 299 || response.StatusCode == http.StatusPartialContent {
+		return response, false, nil
+	}
+	r.count(func(stats *CacheStats) { stats.Misses++ })
+	directives := cacheControl(response.Header)
+	if _, noStore := directives["no-store"]; noStore || response.Header.Get("Vary") == "*" {
+		r.cache.Delete(request.Context(), cacheKey(request))
+		return response, false, nil
+	}
+	stale, _ := directiveSeconds(directives, "stale-while-revalidate")
+	if response.Header.Get("ETag") == "" && response.Header.Get("Last-Modified") == "" && (!r.freshness || lifetime(response.Header)+stale <= 0) {
+		return response, false, nil
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, false, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	entry := &validation{
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Header:     response.Header,
+		Body:       body,
+		Stored:     time.Now(),
+	}
+	for _, name := range strings.Split(strings.Join(response.Header.Values("Vary"), ","), ",") {
+		if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" {
+			if entry.Vary == nil {
+				entry.Vary = http.Header{}
+			}
+			entry.Vary[name] = request.Header.Values(name)
+		}
+	}
+	r.store(request, entry)
+	return response, false, nil
+}
+
+// store saves the given response in the cache.
+func (r *Requestor) store(request *http.Request, cached *validation) {
+	data, err := json.Marshal(cached)
+	if err == nil {
+		err = r.cache.Set(request.Context(), cacheKey(request), data, 0)
+	}
+	if err != nil {
+		log.Errorf("error caching response for %q: %v", RedactURL(request.URL), err)
+	}
+}
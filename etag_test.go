@@ -0,0 +1,137 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRevalidate(t *testing.T) {
+	var full, validated int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&validated, 1)
+			w.Header().Set("X-Fresh", "yes")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&full, 1)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient()).Revalidate(nil)
+	f := New(server.URL)
+	for i := 0; i < 3; i++ {
+		response, err := requestor.Do(context.Background(), f)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if string(body) != "content" || response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/plain" {
+			t.Fatalf("invalid response #%d: %d %q", i, response.StatusCode, body)
+		}
+		if response.FromCache != (i > 0) {
+			t.Fatalf("invalid cache flag on response #%d", i)
+		}
+	}
+	if full != 1 || validated != 2 {
+		t.Fatalf("expected 1 full and 2 conditional requests, got %d and %d", full, validated)
+	}
+	if f.headers.Get("If-None-Match") != "" {
+		t.Fatalf("conditional headers should not be added to the builder")
+	}
+
+	// requests with different credentials do not share the cache
+	response, err := requestor.Do(context.Background(), New(server.URL).Set().Header("Authorization", "Bearer other"))
+	if err != nil || response.FromCache {
+		t.Fatalf("expected a full response, got %v", err)
+	}
+	response.Body.Close()
+}
+
+func TestRevalidateRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.Write([]byte("content"))
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			if r.Header.Get("If-None-Match") != `"v1"` {
+				t.Errorf("expected the retried request to be conditional")
+			}
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient()).Revalidate(nil).Retry(3, time.Millisecond)
+	for i := 0; i < 2; i++ {
+		response, err := requestor.Do(context.Background(), New(server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error on request #%d: %v", i, err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if string(body) != "content" || response.FromCache != (i > 0) {
+			t.Fatalf("invalid response #%d: %q (cached: %t)", i, body, response.FromCache)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 requests, got %d", calls)
+	}
+}
+
+func TestRevalidateRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "content.txt", time.Unix(1500000000, 0), strings.NewReader("0123456789abcdefghij"))
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient()).Revalidate(nil)
+	response, err := requestor.Do(context.Background(), New(server.URL).SetHeader("Range", "bytes=0-9"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusPartialContent || string(body) != "0123456789" {
+		t.Fatalf("expected a partial response, got %d %q", response.StatusCode, body)
+	}
+	for i := 0; i < 2; i++ {
+		response, err = requestor.Do(context.Background(), New(server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		body, _ = ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK || string(body) != "0123456789abcdefghij" || response.FromCache != (i > 0) {
+			t.Fatalf("expected the full body (from cache: %t), got %d %q (from cache: %t)", i > 0, response.StatusCode, body, response.FromCache)
+		}
+	}
+	// range requests are not served from the cache either
+	response, err = requestor.Do(context.Background(), New(server.URL).SetHeader("Range", "bytes=10-"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ = ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusPartialContent || string(body) != "abcdefghij" || response.FromCache {
+		t.Fatalf("expected a partial response, got %d %q", response.StatusCode, body)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event is an event in the lifecycle of a request, published on an EventBus;
+// subscribers tell the events apart with a type switch:
+//
+//	bus.Subscribe(func(event request.Event) {
+//		switch event := event.(type) {
+//		case *request.RetryScheduled:
+//			log.Printf("retrying %s in %v: %v", event.URL, event.Delay, event.Err)
+//		case *request.CircuitOpened:
+//			log.Printf("circuit to %s opened", event.Host)
+//		}
+//	})
+type Event interface {
+	// When returns the time the event occurred.
+	When() time.Time
+}
+
+// BuildStarted is published when a Requestor starts building a request out of a
+// Builder.
+type BuildStarted struct {
+	At     time.Time
+	Method string
+	URL    string
+}
+
+// When returns the time the event occurred.
+func (e *BuildStarted) When() time.Time { return e.At }
+
+// RetryScheduled is published when a failed request is going to be sent again,
+// after the given delay.
+type RetryScheduled struct {
+	At      time.Time
+	Method  string
+	URL     string
+	Attempt int
+	Delay   time.Duration
+	Err     error
+}
+
+// When returns the time the event occurred.
+func (e *RetryScheduled) When() time.Time { return e.At }
+
+// RedirectFollowed is published when a redirect is followed.
+type RedirectFollowed struct {
+	At         time.Time
+	Method     string
+	From       string
+	To         string
+	StatusCode int
+}
+
+// When returns the time the event occurred.
+func (e *RedirectFollowed) When() time.Time { return e.At }
+
+// ResponseReceived is published when a response is received from the server,
+// for each attempt and whatever its status code.
+type ResponseReceived struct {
+	At         time.Time
+	Method     string
+	URL        string
+	StatusCode int
+	Elapsed    time.Duration
+}
+
+// When returns the time the event occurred.
+func (e *ResponseReceived) When() time.Time { return e.At }
+
+// CircuitOpened is published when a per-host circuit breaker of the Requestor
+// opens.
+type CircuitOpened struct {
+	At   time.Time
+	Host string
+}
+
+// When returns the time the event occurred.
+func (e *CircuitOpened) When() time.Time { return e.At }
+
+// EventBus dispatches the lifecycle events of the requests executed by the
+// Requestors publishing on it (see Requestor.Events) to its subscribers; URLs
+// in events are redacted (see RedactURL). It is safe for concurrent use.
+type EventBus struct {
+	lock        sync.RWMutex
+	next        int
+	subscribers map[int]func(event Event)
+	dropped     int64
+}
+
+// NewEventBus returns a new EventBus, with no subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: map[int]func(event Event){},
+	}
+}
+
+// Subscribe adds a callback invoked synchronously, by the goroutine executing
+// the request, for each event; it should therefore be fast. It returns the
+// function that removes the subscription.
+func (b *EventBus) Subscribe(callback func(event Event)) (unsubscribe func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	id := b.next
+	b.next++
+	b.subscribers[id] = callback
+	return func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// Channel returns a channel receiving the events, with the given buffer size;
+// events are dropped (see Dropped) rather than blocking the requests when the
+// buffer is full. It returns the function that removes the subscription and
+// closes the channel.
+func (b *EventBus) Channel(size int) (<-chan Event, func()) {
+	events := make(chan Event, size)
+	var once sync.Once
+	var lock sync.Mutex
+	closed := false
+	unsubscribe := b.Subscribe(func(event Event) {
+		lock.Lock()
+		defer lock.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case events <- event:
+		default:
+			atomic.AddInt64(&b.dropped, 1)
+		}
+	})
+	return events, func() {
+		once.Do(func() {
+			unsubscribe()
+			lock.Lock()
+			defer lock.Unlock()
+			closed = true
+			close(events)
+		})
+	}
+}
+
+// Dropped returns the number of events dropped because a channel subscriber
+// was not keeping up.
+func (b *EventBus) Dropped() int64 {
+	return atomic.LoadInt64(&b.dropped)
+}
+
+// Publish dispatches the given event to all the subscribers.
+func (b *EventBus) Publish(event Event) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	for _, subscriber := range b.subscribers {
+		subscriber(event)
+	}
+}
+
+// Events makes the Requestor publish the lifecycle events of the requests it
+// executes on the given bus.
+func (r *Requestor) Events(bus *EventBus) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = bus
+	return r
+}
+
+// publish publishes the given event, if the Requestor has an event bus.
+func (r *Requestor) publish(event Event) {
+	if r.events != nil {
+		r.events.Publish(event)
+	}
+}
+
+// build builds the request out of the given Builder, publishing BuildStarted.
+func (r *Requestor) build(f *Builder) (*http.Request, error) {
+	if r.events != nil {
+		unlock := f.read()
+		method, url := f.method, f.url
+		unlock()
+		r.publish(&BuildStarted{At: time.Now(), Method: method, URL: redactRawURL(url)})
+	}
+	return f.Make()
+}
+
+// redirecting returns the given client, with its redirect policy wrapped so as
+// to publish RedirectFollowed, if the Requestor has an event bus.
+func (r *Requestor) redirecting(client *http.Client) *http.Client {
+	if r.events == nil {
+		return client
+	}
+	wrapped := *client
+	wrapped.CheckRedirect = func(request *http.Request, via []*http.Request) error {
+		var err error
+		if client.CheckRedirect != nil {
+			err = client.CheckRedirect(request, via)
+		} else if len(via) >= 10 {
+			// the default policy of http.Client
+			err = errors.New("stopped after 10 redirects")
+		}
+		if err == nil {
+			event := &RedirectFollowed{At: time.Now(), Method: request.Method, To: RedactURL(request.URL)}
+			if previous := via[len(via)-1]; previous != nil {
+				event.From = RedactURL(previous.URL)
+			}
+			if request.Response != nil {
+				event.StatusCode = request.Response.StatusCode
+			}
+			r.publish(event)
+		}
+		return err
+	}
+	return &wrapped
+}
+
+// circuitChanged publishes CircuitOpened when a per-host circuit breaker opens,
+// and invokes the user callback.
+func (r *Requestor) circuitChanged(host string, from, to CircuitState) {
+	if to == CircuitOpen {
+		r.publish(&CircuitOpened{At: time.Now(), Host: host})
+	}
+	if r.breakerCallback != nil {
+		r.breakerCallback(host, from, to)
+	}
+}
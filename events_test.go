@@ -0,0 +1,99 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/flaky":
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/down":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	bus := NewEventBus()
+	events := []Event{}
+	unsubscribe := bus.Subscribe(func(event Event) {
+		events = append(events, event)
+	})
+	channel, stop := bus.Channel(1)
+	requestor := NewRequestor(nil).Events(bus).Retry(2, time.Millisecond)
+
+	response, err := requestor.Do(context.Background(), New(server.URL+"/old"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	if event, ok := events[0].(*BuildStarted); !ok || event.URL != server.URL+"/old" || event.When().IsZero() {
+		t.Fatalf("unexpected event %#v", events[0])
+	}
+	if event, ok := events[1].(*RedirectFollowed); !ok || event.From != server.URL+"/old" || event.To != server.URL+"/new" || event.StatusCode != http.StatusMovedPermanently {
+		t.Fatalf("unexpected event %#v", events[1])
+	}
+	if event, ok := events[2].(*ResponseReceived); !ok || event.StatusCode != http.StatusOK || event.URL != server.URL+"/old" {
+		t.Fatalf("unexpected event %#v", events[2])
+	}
+	if event := <-channel; event != events[0] {
+		t.Fatalf("expected the first event on the channel, got %#v", event)
+	}
+	if bus.Dropped() != 2 {
+		t.Fatalf("expected 2 dropped events, got %d", bus.Dropped())
+	}
+	stop()
+	if _, ok := <-channel; ok {
+		t.Fatalf("expected the channel to be closed")
+	}
+
+	events = events[:0]
+	response, err = requestor.Do(context.Background(), New(server.URL+"/flaky"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	if event, ok := events[2].(*RetryScheduled); !ok || event.Attempt != 2 || StatusCode(event.Err) != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected event %#v", events[2])
+	}
+
+	events = events[:0]
+	NewRequestor(nil).Events(bus).CircuitBreaker(1, time.Minute, nil).Do(context.Background(), New(server.URL+"/down"))
+	opened := false
+	for _, event := range events {
+		if event, ok := event.(*CircuitOpened); ok && event.Host == server.Listener.Addr().String() {
+			opened = true
+		}
+	}
+	if !opened {
+		t.Fatalf("expected the circuit to open, got %#v", events)
+	}
+
+	unsubscribe()
+	events = events[:0]
+	requestor.Do(context.Background(), New(server.URL+"/new"))
+	if len(events) != 0 {
+		t.Fatalf("expected no events after unsubscribing")
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// ErrUnexpectedResponse is returned (wrapped in an *ExpectationError) when a
+// response does not meet the expectations set on it.
+var ErrUnexpectedResponse = errors.New("unexpected response")
+
+// ExpectationError is returned when a response does not meet the expectations
+// set on it; it wraps ErrUnexpectedResponse.
+type ExpectationError struct {
+	// Method and URL identify the request, with any credentials redacted.
+	Method string
+	URL    string
+	// Failures are the assertions that did not hold.
+	Failures []AssertionResult
+}
+
+// Error describes the failed assertions.
+func (e *ExpectationError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		failures[i] = fmt.Sprintf("expected %s, but %s", failure.Name, failure.Message)
+	}
+	return fmt.Sprintf("%s %s: %v: %s", e.Method, e.URL, ErrUnexpectedResponse, strings.Join(failures, "; "))
+}
+
+// Unwrap returns ErrUnexpectedResponse.
+func (e *ExpectationError) Unwrap() error {
+	return ErrUnexpectedResponse
+}
+
+// Expect evaluates the given assertions against the response, and returns an
+// *ExpectationError listing those that did not hold, if any, e.g.
+//
+//	if err := response.Expect(StatusIn(200), JSONPathEquals("$.status", "ok")); err != nil {
+//		return err
+//	}
+//
+// The response body is buffered, so that it can still be read or decoded.
+func (r *Response) Expect(assertions ...Assertion) error {
+	body, err := r.buffer()
+	if err != nil {
+		return err
+	}
+	return r.expect(body, assertions)
+}
+
+// ExpectStatus returns an *ExpectationError unless the response status code is
+// one of the given ones; the response body is left untouched.
+func (r *Response) ExpectStatus(codes ...int) error {
+	return r.expect(nil, []Assertion{StatusIn(codes...)})
+}
+
+// ExpectHeader returns an *ExpectationError unless the response has the given
+// header value (see HeaderEquals); the response body is left untouched.
+func (r *Response) ExpectHeader(name, value string) error {
+	return r.expect(nil, []Assertion{HeaderEquals(name, value)})
+}
+
+// ExpectJSONPath returns an *ExpectationError unless the value at the given
+// path expression in the JSON response body is equal to the given one (see
+// JSONPathEquals); the response body is buffered, so that it can still be read
+// or decoded.
+func (r *Response) ExpectJSONPath(path string, expected interface{}) error {
+	return r.Expect(JSONPathEquals(path, expected))
+}
+
+// expect evaluates the given assertions against the response and the given
+// body.
+func (r *Response) expect(body []byte, assertions []Assertion) error {
+	exchange := &Exchange{Request: r.Request, Response: r, Body: body}
+	failures := []AssertionResult{}
+	for _, result := range evaluate(exchange, assertions) {
+		if !result.Passed {
+			failures = append(failures, result)
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	err := &ExpectationError{Failures: failures}
+	if r.Request != nil {
+		err.Method, err.URL = r.Request.Method, RedactURL(r.Request.URL)
+	}
+	return err
+}
+
+// buffer reads the whole response body, and replaces it with a copy that can
+// be read again.
+func (r *Response) buffer() ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
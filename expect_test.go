@@ -0,0 +1,53 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExpect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status": "ok", "count": 3}`))
+	}))
+	defer server.Close()
+	response, err := NewRequestor(nil).Do(context.Background(), New(server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := response.ExpectStatus(http.StatusOK, http.StatusCreated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := response.ExpectHeader("Content-Type", "application/json"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := response.ExpectJSONPath("$.status", "ok"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = response.Expect(StatusIn(http.StatusOK), JSONPathEquals("$.count", 3), JSONPathExists("$.missing"))
+	var expectationErr *ExpectationError
+	if !errors.As(err, &expectationErr) || !errors.Is(err, ErrUnexpectedResponse) {
+		t.Fatalf("expected an expectation error, got %v", err)
+	}
+	if len(expectationErr.Failures) != 2 {
+		t.Fatalf("expected 2 failures, got %+v", expectationErr.Failures)
+	}
+	if !strings.Contains(err.Error(), "status is 201") || !strings.Contains(err.Error(), "GET "+server.URL) {
+		t.Fatalf("unexpected error message %q", err)
+	}
+	var v struct {
+		Status string `json:"status"`
+	}
+	if err := response.Decode(&v); err != nil || v.Status != "ok" {
+		t.Fatalf("expected the body to be decoded after the expectations, got %+v (%v)", v, err)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrNoValue is returned when a path expression does not resolve to any value
+// in a JSON document.
+var ErrNoValue = errors.New("no value at path")
+
+// JSONValue is a value extracted from a JSON document via a path expression.
+type JSONValue struct {
+	value interface{}
+}
+
+// Interface returns the value as decoded into generic values: maps, slices,
+// strings, json.Number, bools or nil.
+func (v JSONValue) Interface() interface{} {
+	return v.value
+}
+
+// IsNull returns whether the value is null.
+func (v JSONValue) IsNull() bool {
+	return v.value == nil
+}
+
+// String returns the string representation of the value: strings are returned
+// as they are, null as an empty string and objects and arrays as JSON.
+func (v JSONValue) String() string {
+	return stringify(v.value)
+}
+
+// Int returns the value as an integer; it fails unless the value is an
+// integer number.
+func (v JSONValue) Int() (int64, error) {
+	number, ok := v.value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("value %s is not a number", render(v.value))
+	}
+	return strconv.ParseInt(number.String(), 10, 64)
+}
+
+// Float returns the value as a floating point number; it fails unless the
+// value is a number.
+func (v JSONValue) Float() (float64, error) {
+	number, ok := v.value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("value %s is not a number", render(v.value))
+	}
+	return number.Float64()
+}
+
+// Bool returns the value as a boolean; it fails unless the value is a boolean.
+func (v JSONValue) Bool() (bool, error) {
+	b, ok := v.value.(bool)
+	if !ok {
+		return false, fmt.Errorf("value %s is not a boolean", render(v.value))
+	}
+	return b, nil
+}
+
+// Decode unmarshals the value into the given one, as encoding/json would.
+func (v JSONValue) Decode(target interface{}) error {
+	data, err := json.Marshal(v.value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// Extract returns the value at the given path expression (e.g.
+// "$.items[0].id") in the JSON response body, or ErrNoValue if there is none,
+// e.g.
+//
+//	id, err := response.Extract("$.items[0].id")
+//	...
+//	n, err := id.Int()
+//
+// The response body is decoded once, and buffered so that it can still be
+// read, decoded or further extracted from.
+func (r *Response) Extract(path string) (JSONValue, error) {
+	if err := r.parse(); err != nil {
+		return JSONValue{}, err
+	}
+	value, found, err := lookupPath(r.document, path)
+	if err != nil {
+		return JSONValue{}, err
+	}
+	if !found {
+		return JSONValue{}, fmt.Errorf("%q: %w", path, ErrNoValue)
+	}
+	return JSONValue{value: value}, nil
+}
+
+// ExtractInto unmarshals the value at the given path expression in the JSON
+// response body into the given value, according to the decoding options, so
+// that only the fields of interest of a large body need a matching struct.
+func (r *Response) ExtractInto(path string, v interface{}) error {
+	value, err := r.Extract(path)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(value.value)
+	if err != nil {
+		return err
+	}
+	return decode("application/json", data, v, r.options)
+}
+
+// parse decodes the JSON response body, unless already done.
+func (r *Response) parse() error {
+	if r.parsed {
+		return nil
+	}
+	body, err := r.buffer()
+	if err != nil {
+		return err
+	}
+	if r.options&StripBOM != 0 {
+		body = bytes.TrimPrefix(body, bom)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&r.document); err != nil {
+		return err
+	}
+	r.parsed = true
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	body := `{"items": [{"id": 12345678901234, "name": "first", "price": 1.5, "active": true, "tags": ["a", "b"]}], "next": null}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	response, err := NewRequestor(nil).Do(context.Background(), New(server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	id, err := response.Extract("$.items[0].id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n, err := id.Int(); err != nil || n != 12345678901234 {
+		t.Fatalf("expected 12345678901234, got %d (%v)", n, err)
+	}
+	if price, err := response.Extract("items[0].price"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if f, err := price.Float(); err != nil || f != 1.5 {
+		t.Fatalf("expected 1.5, got %v (%v)", f, err)
+	} else if _, err := price.Int(); err == nil {
+		t.Fatalf("expected error converting 1.5 to an integer")
+	}
+	if active, err := response.Extract("$.items[0].active"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if b, err := active.Bool(); err != nil || !b {
+		t.Fatalf("expected true, got %v (%v)", b, err)
+	}
+	if name, err := response.Extract("$.items[0].name"); err != nil || name.String() != "first" {
+		t.Fatalf("expected \"first\", got %v (%v)", name, err)
+	} else if _, err := name.Int(); err == nil {
+		t.Fatalf("expected error converting a string to an integer")
+	}
+	if next, err := response.Extract("$.next"); err != nil || !next.IsNull() {
+		t.Fatalf("expected null, got %v (%v)", next, err)
+	}
+	if _, err := response.Extract("$.items[3]"); !errors.Is(err, ErrNoValue) {
+		t.Fatalf("expected ErrNoValue, got %v", err)
+	}
+	var item struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+	if err := response.ExtractInto("$.items[0]", &item); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if item.Name != "first" || len(item.Tags) != 2 {
+		t.Fatalf("unexpected item %+v", item)
+	}
+	var tags []string
+	if value, err := response.Extract("$.items[0].tags"); err != nil || value.Decode(&tags) != nil || len(tags) != 2 {
+		t.Fatalf("expected 2 tags, got %v (%v)", tags, err)
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil || string(data) != body {
+		t.Fatalf("expected the body to be readable after extraction, got %q (%v)", data, err)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// RequestBuilder generates HTTP requests; it is implemented by Builder, and
+// by FakeBuilder for tests, so that code generating or sending requests can
+// accept alternative implementations. Since Go has no covariant return types,
+// the fluent methods of Builder, which return a *Builder, cannot be part of
+// it: the code accepting a RequestBuilder should receive it fully configured.
+type RequestBuilder interface {
+	// Make generates a new request.
+	Make() (*http.Request, error)
+}
+
+var _ RequestBuilder = (*Builder)(nil)
+
+// Send sends the request generated by the given RequestBuilder; if it is a
+// *Builder, this is the same as Do, otherwise the request is executed with the
+// defaults of a blank Builder (no retries, no cache, and so on).
+func (r *Requestor) Send(ctx context.Context, b RequestBuilder) (*Response, error) {
+	if f, ok := b.(*Builder); ok {
+		return r.Do(ctx, f)
+	}
+	request, err := b.Make()
+	if err != nil {
+		return nil, err
+	}
+	if ctx != nil {
+		request = request.WithContext(ctx)
+	}
+	return r.do(New(request.URL.String()), request)
+}
+
+// FakeBuilder is a RequestBuilder for tests, making predefined requests and
+// recording them, e.g.
+//
+//	request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+//	fake := NewFakeBuilder(request)
+//	client.Refresh(fake)
+//	if len(fake.Made()) != 1 {
+//		t.Fatalf("expected a single request")
+//	}
+type FakeBuilder struct {
+	lock     sync.Mutex
+	requests []*http.Request
+	err      error
+	made     []*http.Request
+}
+
+// NewFakeBuilder returns a FakeBuilder making copies of the given requests in
+// turn, the last one repeatedly; the request bodies are replayed via GetBody,
+// as set by http.NewRequest for in-memory bodies.
+func NewFakeBuilder(requests ...*http.Request) *FakeBuilder {
+	return &FakeBuilder{requests: requests}
+}
+
+// MakeError makes Make fail with the given error, until reset with nil.
+func (b *FakeBuilder) MakeError(err error) *FakeBuilder {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.err = err
+	return b
+}
+
+// Make returns a copy of the next predefined request, and records it.
+func (b *FakeBuilder) Make() (*http.Request, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.requests) == 0 {
+		return nil, errors.New("no requests to make")
+	}
+	next := b.requests[0]
+	if len(b.requests) > 1 {
+		b.requests = b.requests[1:]
+	}
+	request := next.Clone(next.Context())
+	if next.GetBody != nil {
+		body, err := next.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		request.Body = body
+	}
+	b.made = append(b.made, request)
+	return request, nil
+}
+
+// Made returns the requests made so far.
+func (b *FakeBuilder) Made() []*http.Request {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return append([]*http.Request(nil), b.made...)
+}
@@ -0,0 +1,67 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFakeBuilder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + string(body)))
+	}))
+	defer server.Close()
+	first, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("first"))
+	second, _ := http.NewRequest(http.MethodPut, server.URL, strings.NewReader("second"))
+	fake := NewFakeBuilder(first, second)
+	requestor := NewRequestor(nil)
+	for _, expected := range []string{"POST first", "PUT second", "PUT second"} {
+		response, err := requestor.Send(context.Background(), fake)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if string(body) != expected {
+			t.Fatalf("expected %q, got %q", expected, body)
+		}
+	}
+	if made := fake.Made(); len(made) != 3 || made[0].Method != http.MethodPost || made[2].Method != http.MethodPut {
+		t.Fatalf("unexpected requests recorded: %v", made)
+	}
+	failure := errors.New("failure")
+	if _, err := requestor.Send(context.Background(), fake.MakeError(failure)); !errors.Is(err, failure) {
+		t.Fatalf("expected the configured error, got %v", err)
+	}
+	if len(fake.Made()) != 3 {
+		t.Fatalf("expected failed calls not to be recorded")
+	}
+	if _, err := NewFakeBuilder().Make(); err == nil {
+		t.Fatalf("expected error with no requests")
+	}
+}
+
+func TestSendBuilder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Test")))
+	}))
+	defer server.Close()
+	var b RequestBuilder = New(server.URL).SetHeader("X-Test", "value")
+	response, err := NewRequestor(nil).Send(context.Background(), b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer response.Body.Close()
+	if body, _ := ioutil.ReadAll(response.Body); string(body) != "value" {
+		t.Fatalf("expected the Builder to be used, got %q", body)
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// DefaultEndpointCooldown is how long an endpoint that failed is avoided.
+const DefaultEndpointCooldown = 30 * time.Second
+
+// Balancing is the strategy by which an EndpointPool picks the endpoint each
+// request is sent to first.
+type Balancing int8
+
+const (
+	// PrimaryBackup sends all requests to the first healthy endpoint, in the
+	// order they were given, falling back to the following ones.
+	PrimaryBackup Balancing = iota
+	// RoundRobin spreads the requests evenly across the healthy endpoints.
+	RoundRobin
+	// Weighted spreads the requests across the healthy endpoints in proportion
+	// to their weights (see EndpointPool.Weight).
+	Weighted
+)
+
+// EndpointPool is a set of equivalent base URLs (e.g. the same API in several
+// regions) the requests of a Builder are spread across, failing over to the
+// next endpoint when one cannot be reached or responds with one of the
+// failover status codes (by default 502, 503 and 504); endpoints that fail are
+// avoided for a cooldown period, unless all are failing. It is safe for
+// concurrent use, and can be shared by several Builders.
+type EndpointPool struct {
+	lock      sync.Mutex
+	strategy  Balancing
+	endpoints []*endpoint
+	next      int
+	statuses  map[int]bool
+	cooldown  time.Duration
+}
+
+// endpoint is a base URL of an EndpointPool, along with its health.
+type endpoint struct {
+	url      *url.URL
+	weight   int
+	current  int
+	failures int
+	failed   time.Time
+}
+
+// NewEndpointPool returns a pool of the given base URLs, balanced according to
+// the given strategy; the first URL is the primary one.
+func NewEndpointPool(strategy Balancing, urls ...string) (*EndpointPool, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no endpoints")
+	}
+	pool := &EndpointPool{
+		strategy: strategy,
+		statuses: map[int]bool{http.StatusBadGateway: true, http.StatusServiceUnavailable: true, http.StatusGatewayTimeout: true},
+		cooldown: DefaultEndpointCooldown,
+	}
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return nil, err
+		}
+		if parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint %q: absolute URL required", u)
+		}
+		parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+		parsed.RawPath = ""
+		pool.endpoints = append(pool.endpoints, &endpoint{url: parsed, weight: 1})
+	}
+	return pool, nil
+}
+
+// Weight sets the weight of the endpoint with the given base URL, used by the
+// Weighted strategy (1 by default).
+func (p *EndpointPool) Weight(u string, weight int) *EndpointPool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, e := range p.endpoints {
+		if strings.TrimSuffix(e.url.String(), "/") == strings.TrimSuffix(u, "/") && weight > 0 {
+			e.weight = weight
+		}
+	}
+	return p
+}
+
+// FailoverOn sets the status codes on which the request is sent to the next
+// endpoint; the request must be idempotent or carry an idempotency key.
+func (p *EndpointPool) FailoverOn(statuses ...int) *EndpointPool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.statuses = map[int]bool{}
+	for _, status := range statuses {
+		p.statuses[status] = true
+	}
+	return p
+}
+
+// Cooldown sets how long an endpoint that failed is avoided.
+func (p *EndpointPool) Cooldown(cooldown time.Duration) *EndpointPool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.cooldown = cooldown
+	return p
+}
+
+// Healthy returns the base URLs of the endpoints that are not being avoided.
+func (p *EndpointPool) Healthy() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	healthy := []string{}
+	for _, e := range p.endpoints {
+		if p.healthy(e) {
+			healthy = append(healthy, e.url.String())
+		}
+	}
+	return healthy
+}
+
+// Endpoints makes the requests generated by this builder and by its children
+// spread across the endpoints of the given pool: the builder URL is set to the
+// primary endpoint, and the requests whose URL lies under it have it replaced
+// with the endpoint they are sent to, e.g.
+//
+//	pool, _ := request.NewEndpointPool(request.RoundRobin, "https://eu.example.com/v1", "https://us.example.com/v1")
+//	api := request.New("").Endpoints(pool)
+//	users := api.New(http.MethodGet, "users")
+func (f *Builder) Endpoints(pool *EndpointPool) *Builder {
+	f.endpoints = pool
+	if pool != nil {
+		f.discovery = nil
+		f.url = pool.endpoints[0].url.String() + "/"
+	}
+	return f
+}
+
+// failover executes the given request against the endpoints of the pool of the
+// given Builder, if any, moving on to the next one when it fails.
+func (r *Requestor) failover(f *Builder, request *http.Request) (*Response, error) {
+	if f.discovery != nil {
+		return r.discover(f, request)
+	}
+	pool := f.endpoints
+	if pool == nil || !replayable(request) {
+		return r.execute(f, request)
+	}
+	primary := pool.endpoints[0].url
+	if request.URL.Scheme != primary.Scheme || request.URL.Host != primary.Host || !strings.HasPrefix(request.URL.Path, primary.Path) {
+		return r.execute(f, request)
+	}
+	order := pool.order()
+	var err error
+	for i, e := range order {
+		current := request
+		if i > 0 {
+			if current, err = rewind(request); err != nil {
+				return nil, err
+			}
+		}
+		current = rebase(current, primary, e.url)
+		var response *Response
+		response, err = r.execute(f, current)
+		if err == nil {
+			pool.report(e, false)
+			response.Attempts += i
+			return response, nil
+		}
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			httpErr.Attempts += i
+		}
+		if request.Context().Err() != nil || !pool.fails(request, err) {
+			return nil, err
+		}
+		pool.report(e, true)
+		if i < len(order)-1 {
+			log.Debugf("%s %s failed on %s (%v), failing over to %s", request.Method, RedactURL(request.URL), e.url.Host, err, order[i+1].url.Host)
+		}
+	}
+	return nil, err
+}
+
+// fails returns whether the given error is a failure of the endpoint, on which
+// the given request can be sent to the next one.
+func (p *EndpointPool) fails(request *http.Request, err error) bool {
+	if unreachable(request, err) {
+		return true
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.statuses[StatusCode(err)] && retriable(request)
+}
+
+// order returns the endpoints in the order they should be tried: the one picked
+// by the strategy first, then the other healthy ones, then the failing ones.
+func (p *EndpointPool) order() []*endpoint {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	healthy, failing := []*endpoint{}, []*endpoint{}
+	for _, e := range p.endpoints {
+		if p.healthy(e) {
+			healthy = append(healthy, e)
+		} else {
+			failing = append(failing, e)
+		}
+	}
+	if len(healthy) > 1 {
+		first := 0
+		switch p.strategy {
+		case RoundRobin:
+			first = p.next % len(healthy)
+			p.next++
+		case Weighted:
+			// smooth weighted round robin
+			total := 0
+			for i, e := range healthy {
+				e.current += e.weight
+				total += e.weight
+				if e.current > healthy[first].current {
+					first = i
+				}
+			}
+			healthy[first].current -= total
+		}
+		healthy = append(append([]*endpoint{healthy[first]}, healthy[:first]...), healthy[first+1:]...)
+	}
+	return append(healthy, failing...)
+}
+
+// healthy returns whether the given endpoint is not being avoided; it must be
+// called with the lock held.
+func (p *EndpointPool) healthy(e *endpoint) bool {
+	return e.failures == 0 || time.Since(e.failed) >= p.cooldown
+}
+
+// report records the outcome of a request sent to the given endpoint.
+func (p *EndpointPool) report(e *endpoint, failed bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !failed {
+		e.failures = 0
+		return
+	}
+	e.failures++
+	e.failed = time.Now()
+}
+
+// rebase returns a copy of the given request, whose URL lies under the given
+// base URL, moved under the given endpoint.
+func rebase(request *http.Request, base, endpoint *url.URL) *http.Request {
+	if endpoint == base {
+		return request
+	}
+	clone := request.Clone(request.Context())
+	clone.Body = request.Body
+	clone.URL.Scheme = endpoint.Scheme
+	clone.URL.Host = endpoint.Host
+	clone.URL.User = endpoint.User
+	clone.URL.Path = endpoint.Path + strings.TrimPrefix(request.URL.Path, base.Path)
+	clone.URL.RawPath = ""
+	if request.Host == request.URL.Host {
+		clone.Host = ""
+	}
+	return clone
+}
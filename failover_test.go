@@ -0,0 +1,104 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEndpointsFailover(t *testing.T) {
+	hits := map[string]int{}
+	handler := func(name string, status int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[name]++
+			w.WriteHeader(status)
+			w.Write([]byte(name + " " + r.URL.Path))
+		})
+	}
+	failing := httptest.NewServer(handler("failing", http.StatusServiceUnavailable))
+	defer failing.Close()
+	healthy := httptest.NewServer(handler("healthy", http.StatusOK))
+	defer healthy.Close()
+	closed := httptest.NewServer(handler("closed", http.StatusOK))
+	closed.Close()
+
+	pool, err := NewEndpointPool(PrimaryBackup, closed.URL+"/api", failing.URL+"/api", healthy.URL+"/v2/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	requestor := NewRequestor(nil)
+	api := New("").Endpoints(pool)
+	response, err := requestor.Do(context.Background(), api.New(http.MethodGet, "users"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var body []byte
+	if err := response.Decode(&body); err != nil || string(body) != "healthy /v2/users" {
+		t.Fatalf("unexpected body %q (%v)", body, err)
+	}
+	if response.Attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", response.Attempts)
+	}
+	if h := pool.Healthy(); len(h) != 1 || h[0] != healthy.URL+"/v2" {
+		t.Fatalf("unexpected healthy endpoints %v", h)
+	}
+
+	// failing endpoints are avoided while cooling down
+	if _, err := requestor.Do(context.Background(), api.New(http.MethodGet, "users")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hits["failing"] != 1 || hits["healthy"] != 2 {
+		t.Fatalf("unexpected hits %v", hits)
+	}
+
+	// non-idempotent requests are not failed over on status codes
+	pool.Cooldown(0)
+	if _, err := requestor.Do(context.Background(), New("").Endpoints(pool).New(http.MethodPost, "users")); StatusCode(err) != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %v", err)
+	}
+}
+
+func TestEndpointsBalancing(t *testing.T) {
+	hits := map[string]int{}
+	servers := []*httptest.Server{}
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[name]++
+		}))
+		defer server.Close()
+		servers = append(servers, server)
+	}
+	requestor := NewRequestor(nil)
+	for _, test := range []struct {
+		strategy Balancing
+		expected map[string]int
+	}{
+		{RoundRobin, map[string]int{"a": 4, "b": 4, "c": 4}},
+		{Weighted, map[string]int{"a": 6, "b": 4, "c": 2}},
+		{PrimaryBackup, map[string]int{"a": 12}},
+	} {
+		hits = map[string]int{}
+		pool, _ := NewEndpointPool(test.strategy, servers[0].URL, servers[1].URL, servers[2].URL)
+		pool.Weight(servers[0].URL, 3).Weight(servers[1].URL, 2).Cooldown(time.Minute)
+		api := New("").Endpoints(pool)
+		for i := 0; i < 12; i++ {
+			response, err := requestor.Do(context.Background(), api.New("", "/"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			response.Body.Close()
+		}
+		for name, n := range test.expected {
+			if hits[name] != n {
+				t.Fatalf("strategy %d: unexpected hits %v", test.strategy, hits)
+			}
+		}
+	}
+}
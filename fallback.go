@@ -0,0 +1,95 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/dihedron/go-log"
+)
+
+// Fetch is the outcome of sending a request and decoding its response into a
+// target value, as returned by Requestor.Fetch; OrElse and OrElseGet allow
+// non-critical lookups to fall back to a default value on failure:
+//
+//	flags := Flags{}
+//	requestor.Fetch(ctx, api.New(http.MethodGet, "/flags"), &flags).OrElse(DefaultFlags)
+type Fetch struct {
+	target   interface{}
+	err      error
+	degraded bool
+}
+
+// Fetch sends the request generated by the given Builder (applying all the
+// policies of the Requestor, e.g. retries) and decodes the response into the
+// given target, which must be a non-nil pointer.
+func (r *Requestor) Fetch(ctx context.Context, f *Builder, target interface{}) *Fetch {
+	fetch := &Fetch{target: target}
+	if value := reflect.ValueOf(target); value.Kind() != reflect.Ptr || value.IsNil() {
+		fetch.err = fmt.Errorf("invalid target of type %T, a non-nil pointer is required", target)
+		return fetch
+	}
+	response, err := r.Do(ctx, f)
+	if err != nil {
+		fetch.err = err
+		return fetch
+	}
+	fetch.err = response.Decode(target)
+	return fetch
+}
+
+// Err returns the error that prevented the response from being decoded into the
+// target, if any, even if a default value was used instead.
+func (f *Fetch) Err() error {
+	return f.err
+}
+
+// Degraded returns whether the target holds a default value.
+func (f *Fetch) Degraded() bool {
+	return f.degraded
+}
+
+// OrElse stores the given value (or the value it points to) into the target if
+// the request or the decoding failed; it panics if the value cannot be assigned
+// to the target.
+func (f *Fetch) OrElse(value interface{}) *Fetch {
+	if f.err == nil {
+		return f
+	}
+	return f.fallback(value)
+}
+
+// OrElseGet stores the value returned by the given function into the target if
+// the request or the decoding failed; the function is not invoked otherwise.
+func (f *Fetch) OrElseGet(get func() interface{}) *Fetch {
+	if f.err == nil {
+		return f
+	}
+	return f.fallback(get())
+}
+
+func (f *Fetch) fallback(value interface{}) *Fetch {
+	target := reflect.ValueOf(f.target)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return f
+	}
+	log.Debugf("falling back to default value: %v", f.err)
+	element := target.Elem()
+	source := reflect.ValueOf(value)
+	switch {
+	case !source.IsValid():
+		element.Set(reflect.Zero(element.Type()))
+	case source.Type().AssignableTo(element.Type()):
+		element.Set(source)
+	case source.Kind() == reflect.Ptr && source.Type().Elem().AssignableTo(element.Type()):
+		element.Set(source.Elem())
+	default:
+		panic(fmt.Sprintf("default value of type %T cannot be assigned to target of type %T", value, f.target))
+	}
+	f.degraded = true
+	return f
+}
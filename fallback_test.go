@@ -0,0 +1,61 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchOrElse(t *testing.T) {
+	type flags struct {
+		Beta bool `json:"beta"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flags":
+			w.Write([]byte(`{"beta": true}`))
+		case "/invalid":
+			w.Write([]byte(`{"beta": `))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	requestor := NewRequestor(nil)
+	api := New(server.URL)
+	defaults := flags{Beta: false}
+
+	actual := flags{}
+	fetch := requestor.Fetch(context.Background(), api.New(http.MethodGet, "/flags"), &actual).OrElse(defaults)
+	if fetch.Err() != nil || fetch.Degraded() || !actual.Beta {
+		t.Fatalf("unexpected outcome: %v %v %v", fetch.Err(), fetch.Degraded(), actual)
+	}
+
+	actual = flags{Beta: true}
+	fetch = requestor.Fetch(context.Background(), api.New(http.MethodGet, "/down"), &actual).OrElse(&defaults)
+	if StatusCode(fetch.Err()) != http.StatusServiceUnavailable || !fetch.Degraded() || actual.Beta {
+		t.Fatalf("unexpected outcome: %v %v %v", fetch.Err(), fetch.Degraded(), actual)
+	}
+
+	actual = flags{}
+	calls := 0
+	fetch = requestor.Fetch(context.Background(), api.New(http.MethodGet, "/invalid"), &actual).OrElseGet(func() interface{} {
+		calls++
+		return flags{Beta: true}
+	})
+	if fetch.Err() == nil || !fetch.Degraded() || !actual.Beta || calls != 1 {
+		t.Fatalf("unexpected outcome: %v %v %v", fetch.Err(), fetch.Degraded(), actual)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic on invalid default value")
+		}
+	}()
+	requestor.Fetch(context.Background(), api.New(http.MethodGet, "/down"), &actual).OrElse("invalid")
+}
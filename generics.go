@@ -0,0 +1,99 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+
+package request
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+)
+
+// Do sends the request generated by the given Builder via the given Requestor,
+// and decodes the response body into a value of type T, as Response.Decode
+// would; an empty body (e.g. 204 No Content) leaves the zero value. The
+// response is returned along with the value, with its body already read but
+// still readable, e.g.
+//
+//	user, response, err := request.Do[User](ctx, requestor, builder)
+func Do[T any](ctx context.Context, r *Requestor, f *Builder) (T, *Response, error) {
+	var v T
+	response, err := r.Do(ctx, f)
+	if err != nil {
+		return v, nil, err
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return v, response, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if len(bytes.TrimSpace(data)) > 0 {
+		err = decode(response.contentType(response.options), data, &v, response.options)
+	}
+	return v, response, err
+}
+
+// TypedPager is a Pager whose items are decoded into values of type T.
+type TypedPager[T any] struct {
+	*Pager
+}
+
+// DoPages returns a Pager that iterates over the pages of the resource that
+// the given Builder points to, decoding their items into values of type T, e.g.
+//
+//	pager := request.DoPages[Item](ctx, requestor, builder).Items("data")
+//	for pager.Next() {
+//		items, err := pager.PageItems()
+//		...
+//	}
+func DoPages[T any](ctx context.Context, r *Requestor, f *Builder) *TypedPager[T] {
+	return &TypedPager[T]{Pager: r.Paginate(ctx, f)}
+}
+
+// Limit sets the maximum number of pages that will be retrieved (see
+// Pager.Limit).
+func (p *TypedPager[T]) Limit(pages int) *TypedPager[T] {
+	p.Pager.Limit(pages)
+	return p
+}
+
+// Items sets the path expression locating the array of items in each page body
+// (see Pager.Items).
+func (p *TypedPager[T]) Items(path string) *TypedPager[T] {
+	p.Pager.Items(path)
+	return p
+}
+
+// PageItems returns the items of the current page; it is only valid after a
+// call to Next that returned true.
+func (p *TypedPager[T]) PageItems() ([]T, error) {
+	items, err := p.Page().Items()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]T, len(items))
+	for i, item := range items {
+		if err := json.Unmarshal(item, &result[i]); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// All iterates over all the remaining pages and returns their items.
+func (p *TypedPager[T]) All() ([]T, error) {
+	result := []T{}
+	for p.Next() {
+		items, err := p.PageItems()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+	}
+	return result, p.Err()
+}
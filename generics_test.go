@@ -0,0 +1,84 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+
+package request
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type typedItem struct {
+	ID int `json:"id"`
+}
+
+func TestDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id": 42}`))
+		}
+	}))
+	defer server.Close()
+	requestor := NewRequestor(nil)
+	v, response, err := Do[typedItem](context.Background(), requestor, New(server.URL+"/item"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.ID != 42 {
+		t.Fatalf("expected id 42, got %+v", v)
+	}
+	if body, _ := ioutil.ReadAll(response.Body); string(body) != `{"id": 42}` {
+		t.Fatalf("expected the body to be readable, got %q", body)
+	}
+	pointer, _, err := Do[*typedItem](context.Background(), requestor, New(server.URL+"/item"))
+	if err != nil || pointer == nil || pointer.ID != 42 {
+		t.Fatalf("expected id 42, got %+v (%v)", pointer, err)
+	}
+	if v, response, err = Do[typedItem](context.Background(), requestor, New(server.URL+"/empty")); err != nil || v.ID != 0 || response.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected the zero value, got %+v (%v)", v, err)
+	}
+	if _, response, err = Do[typedItem](context.Background(), requestor, New(server.URL+"/missing")); StatusCode(err) != http.StatusNotFound || response != nil {
+		t.Fatalf("expected a 404 error, got %v", err)
+	}
+}
+
+func TestDoPages(t *testing.T) {
+	server := newPagedServer(t, 3)
+	defer server.Close()
+
+	f := New(server.URL+"/items").SetHeader("X-Auth-Token", "secret").SetQueryParameter("per_page", "2")
+	items, err := DoPages[typedItem](context.Background(), NewRequestor(getClient()), f).Limit(2).All()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 4 || items[3].ID != 4 {
+		t.Fatalf("invalid items: got %v", items)
+	}
+	pager := DoPages[typedItem](context.Background(), NewRequestor(getClient()), f)
+	pages := 0
+	for pager.Next() {
+		items, err := pager.PageItems()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(items) != 2 || items[0].ID != pager.Page().Number*2-1 {
+			t.Fatalf("invalid items in page %d: got %v", pager.Page().Number, items)
+		}
+		pages++
+	}
+	if err := pager.Err(); err != nil || pages != 3 {
+		t.Fatalf("expected 3 pages, got %d (%v)", pages, err)
+	}
+}
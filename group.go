@@ -0,0 +1,115 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs a set of requests (and any dependent work) concurrently under a
+// shared context, in the style of golang.org/x/sync/errgroup: the first failure
+// cancels the context of all the others, and Wait returns it. Results are
+// decoded into the targets given to Go, so they are available in the order the
+// caller chooses once Wait returns:
+//
+//	group, ctx := requestor.Group(ctx, 4)
+//	users, orders := []User{}, []Order{}
+//	group.Go(api.New(http.MethodGet, "/users"), &users)
+//	group.Go(api.New(http.MethodGet, "/orders"), &orders)
+//	if err := group.Wait(); err != nil {
+//		...
+//	}
+type Group struct {
+	requestor *Requestor
+	ctx       context.Context
+	cancel    context.CancelFunc
+	semaphore chan struct{}
+	wg        sync.WaitGroup
+	once      sync.Once
+	err       error
+}
+
+// Group returns a new Group running at most limit functions concurrently
+// (unbounded if limit is not positive), along with the context derived from
+// the given one that is cancelled when any of them fails or Wait returns.
+func (r *Requestor) Group(ctx context.Context, limit int) (*Group, context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	g := &Group{
+		requestor: r,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	if limit > 0 {
+		g.semaphore = make(chan struct{}, limit)
+	}
+	return g, ctx
+}
+
+// Go sends the request generated by the given Builder under the group context
+// and decodes the response into the given target (see Response.Decode), which
+// can be nil to discard it; it blocks while the concurrency limit is reached.
+func (g *Group) Go(f *Builder, target interface{}) {
+	g.GoFunc(func(ctx context.Context) error {
+		response, err := g.requestor.Do(ctx, f)
+		if err != nil {
+			return err
+		}
+		return response.Decode(target)
+	})
+}
+
+// GoFunc runs the given function, e.g. a sequence of dependent requests, under
+// the group context; it blocks while the concurrency limit is reached. Once the
+// group context is done, functions are no longer started.
+func (g *Group) GoFunc(fn func(ctx context.Context) error) {
+	if g.semaphore != nil {
+		select {
+		case g.semaphore <- struct{}{}:
+		case <-g.ctx.Done():
+			g.fail(g.ctx.Err())
+			return
+		}
+	}
+	if err := g.ctx.Err(); err != nil {
+		g.release()
+		g.fail(err)
+		return
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer g.release()
+		if err := fn(g.ctx); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+// Wait waits for all the functions to complete, cancels the group context and
+// returns the first error, if any.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
+
+// fail records the first error and cancels the group context.
+func (g *Group) fail(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
+
+// release frees a concurrency slot.
+func (g *Group) release() {
+	if g.semaphore != nil {
+		<-g.semaphore
+	}
+}
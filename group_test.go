@@ -0,0 +1,82 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	var active, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		default:
+			time.Sleep(10 * time.Millisecond)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+		}
+	}))
+	defer server.Close()
+
+	api := New(server.URL)
+	requestor := NewRequestor(nil)
+
+	group, _ := requestor.Group(context.Background(), 2)
+	results := make([]struct{ Path string }, 4)
+	for i, path := range []string{"/a", "/b", "/c", "/d"} {
+		group.Go(api.New("", path), &results[i])
+	}
+	if err := group.Wait(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, path := range []string{"/a", "/b", "/c", "/d"} {
+		if results[i].Path != path {
+			t.Fatalf("expected %s at %d, got %+v", path, i, results[i])
+		}
+	}
+	if peak > 2 {
+		t.Fatalf("expected at most 2 concurrent requests, got %d", peak)
+	}
+
+	group, ctx := requestor.Group(context.Background(), 0)
+	started := time.Now()
+	group.Go(api.New("", "/slow"), nil)
+	group.Go(api.New("", "/fail"), nil)
+	err := group.Wait()
+	if StatusCode(err) != http.StatusInternalServerError {
+		t.Fatalf("expected the first failure, got %v", err)
+	}
+	if ctx.Err() == nil || time.Since(started) > 2*time.Second {
+		t.Fatalf("expected the slow request to be cancelled")
+	}
+	called := false
+	group.GoFunc(func(context.Context) error {
+		called = true
+		return nil
+	})
+	if called {
+		t.Fatalf("expected no functions to start after a failure")
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package h2c provides a transport speaking HTTP/2 over cleartext TCP with
+// prior knowledge, e.g. to internal services behind gRPC-gateway or Envoy
+// sidecars; it lives in its own package so that the HTTP/2 implementation is
+// only pulled in by those who need it:
+//
+//	api := request.New("http://sidecar:8080/").WithTransport(h2c.Transport(nil))
+//
+// Since all the requests to a host are multiplexed over a single connection,
+// health check pings (see Options) are the way to detect dead connections.
+package h2c
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// DefaultDialTimeout is the default maximum time to open a connection.
+const DefaultDialTimeout = 30 * time.Second
+
+// Options configures the transport; the zero value is usable.
+type Options struct {
+	// DialTimeout is the maximum time to open a connection (by default,
+	// DefaultDialTimeout).
+	DialTimeout time.Duration
+	// ReadIdleTimeout is the time after which a health check ping is sent if
+	// no frame is received on a connection (zero disables health checks).
+	ReadIdleTimeout time.Duration
+	// PingTimeout is the time after which a connection is closed if no
+	// response to a ping is received (by default, 15 seconds).
+	PingTimeout time.Duration
+	// WriteByteTimeout is the time after which a connection is closed if no
+	// data can be written to it (zero means no limit).
+	WriteByteTimeout time.Duration
+	// StrictMaxConcurrentStreams makes the transport respect the server limit
+	// on concurrent streams, instead of opening more connections.
+	StrictMaxConcurrentStreams bool
+}
+
+// Transport returns a transport sending the requests to http:// URLs over
+// HTTP/2 cleartext connections; the options can be nil.
+func Transport(options *Options) *http2.Transport {
+	if options == nil {
+		options = &Options{}
+	}
+	timeout := options.DialTimeout
+	if timeout == 0 {
+		timeout = DefaultDialTimeout
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, address string, config *tls.Config) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+		ReadIdleTimeout:            options.ReadIdleTimeout,
+		PingTimeout:                options.PingTimeout,
+		WriteByteTimeout:           options.WriteByteTimeout,
+		StrictMaxConcurrentStreams: options.StrictMaxConcurrentStreams,
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package h2c
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	request "github.com/dihedron/go-requestor"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), &http2.Server{}))
+	defer server.Close()
+
+	transport := Transport(&Options{ReadIdleTimeout: time.Second, PingTimeout: time.Second})
+	if transport.ReadIdleTimeout != time.Second || transport.PingTimeout != time.Second || !transport.AllowHTTP {
+		t.Fatalf("unexpected transport settings")
+	}
+	response, err := request.NewRequestor(nil).Do(context.Background(), request.New(server.URL).WithTransport(transport))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var body []byte
+	response.Decode(&body)
+	if string(body) != "HTTP/2.0" || response.ProtoMajor != 2 {
+		t.Fatalf("expected HTTP/2, got %q", body)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// Hedge enables request hedging for latency-sensitive requests: if no response
+// has been received after the given delay (e.g. the 95th percentile latency of
+// the target), a duplicate request is sent, and so on every delay up to max
+// duplicates; the first successful response wins and the other requests are
+// cancelled. Hedging only applies to idempotent methods, and to requests whose
+// body (if any) can be replayed; a zero max disables it.
+func (f *Builder) Hedge(delay time.Duration, max int) *Builder {
+	f.hedgeDelay = delay
+	f.hedges = max
+	return f
+}
+
+// isIdempotent returns whether the given method is idempotent as per RFC 7231,
+// and can therefore be sent more than once safely.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// replayable returns whether the given request can be sent more than once,
+// i.e. whether it has no body or its body can be recreated.
+func replayable(request *http.Request) bool {
+	return request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
+}
+
+// cancelOnClose releases the context of the winning hedged request when its
+// response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// hedged is the outcome of one of the hedged requests.
+type hedged struct {
+	index    int
+	response *Response
+	err      error
+}
+
+// hedge executes the given request, hedging it if the Builder asks for it; if
+// all the requests fail, the first error is returned.
+func (r *Requestor) hedge(f *Builder, request *http.Request) (*Response, error) {
+	if f.hedges <= 0 || !isIdempotent(request.Method) || !replayable(request) {
+		return r.requeue(f, request)
+	}
+
+	outcomes := make(chan hedged, f.hedges+1)
+	cancels := []context.CancelFunc{}
+	launch := func() error {
+		ctx, cancel := context.WithCancel(request.Context())
+		attempt := request.Clone(ctx)
+		if len(cancels) > 0 && request.GetBody != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				cancel()
+				return err
+			}
+			attempt.Body = body
+		}
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			response, err := r.requeue(f, attempt)
+			outcomes <- hedged{index: index, response: response, err: err}
+		}()
+		return nil
+	}
+
+	launch()
+	pending := 1
+	timer := time.NewTimer(f.hedgeDelay)
+	defer timer.Stop()
+	var failure error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if err := launch(); err != nil {
+				log.Errorf("error hedging request to %q: %v", RedactURL(request.URL), err)
+				continue
+			}
+			log.Debugf("no response from %q after %v, sent hedged request #%d", RedactURL(request.URL), f.hedgeDelay, len(cancels)-1)
+			pending++
+			if len(cancels) <= f.hedges {
+				timer.Reset(f.hedgeDelay)
+			}
+		case outcome := <-outcomes:
+			pending--
+			if outcome.err != nil {
+				if failure == nil {
+					failure = outcome.err
+				}
+				continue
+			}
+			for i, cancel := range cancels {
+				if i != outcome.index {
+					cancel()
+				}
+			}
+			// release the responses of the losers that complete anyway
+			go func(pending int) {
+				for ; pending > 0; pending-- {
+					if loser := <-outcomes; loser.err == nil {
+						loser.response.Body.Close()
+					}
+				}
+			}(pending)
+			outcome.response.Body = &cancelOnClose{ReadCloser: outcome.response.Body, cancel: cancels[outcome.index]}
+			outcome.response.Attempts = len(cancels)
+			return outcome.response, nil
+		}
+	}
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return nil, failure
+}
@@ -0,0 +1,86 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedge(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&count, 1)
+		if n == 1 {
+			// the first request is slow
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(2 * time.Second):
+			}
+		}
+		w.Write([]byte(strconv.Itoa(int(n))))
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient())
+	started := time.Now()
+	response, err := requestor.Do(context.Background(), New(server.URL).Hedge(50*time.Millisecond, 2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if string(body) != "2" || response.Attempts != 2 {
+		t.Fatalf("expected the hedged request to win, got %q after %d attempts", body, response.Attempts)
+	}
+	if time.Since(started) > time.Second {
+		t.Fatalf("hedged request took too long: %v", time.Since(started))
+	}
+}
+
+func TestHedgeMax(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient())
+	response, err := requestor.Do(context.Background(), New(server.URL).Hedge(20*time.Millisecond, 2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if n := atomic.LoadInt32(&count); n != 3 {
+		t.Fatalf("expected 3 requests, got %d", n)
+	}
+}
+
+func TestHedgeNonIdempotent(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient())
+	response, err := requestor.Do(context.Background(), New(server.URL).Post().Hedge(10*time.Millisecond, 2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if n := atomic.LoadInt32(&count); n != 1 || response.Attempts != 1 {
+		t.Fatalf("POST requests must not be hedged, got %d requests", n)
+	}
+}
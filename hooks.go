@@ -0,0 +1,97 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// RequestHook is called when the execution of a request starts.
+type RequestHook func(request *http.Request)
+
+// ResponseHook is called when a response to a request is received, along with
+// the time it took.
+type ResponseHook func(request *http.Request, response *Response, elapsed time.Duration)
+
+// ErrorHook is called when the execution of a request fails (including with a
+// non-2xx status code), along with the time it took.
+type ErrorHook func(request *http.Request, err error, elapsed time.Duration)
+
+// OnRequest adds hooks called when the execution of a request starts; hooks
+// are called synchronously and must not modify the request.
+func (r *Requestor) OnRequest(hooks ...RequestHook) *Requestor {
+	r.requestHooks = append(r.requestHooks, hooks...)
+	return r
+}
+
+// OnResponse adds hooks called when a response is received; hooks are called
+// synchronously and must not read the response body.
+func (r *Requestor) OnResponse(hooks ...ResponseHook) *Requestor {
+	r.responseHooks = append(r.responseHooks, hooks...)
+	return r
+}
+
+// OnError adds hooks called when the execution of a request fails; hooks are
+// called synchronously.
+func (r *Requestor) OnError(hooks ...ErrorHook) *Requestor {
+	r.errorHooks = append(r.errorHooks, hooks...)
+	return r
+}
+
+// hook calls the response or error hooks with the outcome of the given request.
+func (r *Requestor) hook(request *http.Request, response *Response, err error, started time.Time) {
+	elapsed := time.Since(started)
+	if err != nil {
+		for _, hook := range r.errorHooks {
+			hook(request, err, elapsed)
+		}
+		return
+	}
+	for _, hook := range r.responseHooks {
+		hook(request, response, elapsed)
+	}
+}
+
+// Log makes the Requestor log, via the given logger, each request (at debug
+// level), each response (at info level) and each failure (at warning level,
+// or error level if no response was received), along with its method, URL,
+// status code, duration and number of attempts; the credentials in the URL and
+// the values of the sensitive headers and query parameters (see
+// RegisterSensitiveHeaders and RegisterSensitiveQueryParameters) are redacted.
+func (r *Requestor) Log(logger *slog.Logger) *Requestor {
+	return r.OnRequest(func(request *http.Request) {
+		logger.LogAttrs(request.Context(), slog.LevelDebug, "sending request",
+			slog.String("method", request.Method),
+			slog.String("url", RedactURL(request.URL)),
+			slog.Any("header", RedactHeader(request.Header)),
+		)
+	}).OnResponse(func(request *http.Request, response *Response, elapsed time.Duration) {
+		logger.LogAttrs(request.Context(), slog.LevelInfo, "request completed",
+			slog.String("method", request.Method),
+			slog.String("url", RedactURL(request.URL)),
+			slog.Int("status", response.StatusCode),
+			slog.Duration("duration", elapsed),
+			slog.Int("attempts", response.Attempts),
+			slog.Bool("cached", response.FromCache),
+		)
+	}).OnError(func(request *http.Request, err error, elapsed time.Duration) {
+		attributes := []slog.Attr{
+			slog.String("method", request.Method),
+			slog.String("url", RedactURL(request.URL)),
+			slog.Duration("duration", elapsed),
+			slog.String("error", redactText(err.Error(), request.URL)),
+		}
+		level := slog.LevelError
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			level = slog.LevelWarn
+			attributes = append(attributes, slog.Int("status", httpErr.StatusCode), slog.Int("attempts", httpErr.Attempts))
+		}
+		logger.LogAttrs(request.Context(), level, "request failed", attributes...)
+	})
+}
@@ -0,0 +1,93 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	events := []string{}
+	requestor := NewRequestor(nil).OnRequest(func(request *http.Request) {
+		events = append(events, "request "+request.URL.Path)
+	}).OnResponse(func(request *http.Request, response *Response, elapsed time.Duration) {
+		events = append(events, "response "+response.Status)
+	}).OnError(func(request *http.Request, err error, elapsed time.Duration) {
+		events = append(events, "error "+err.Error())
+	})
+	response, err := requestor.Do(context.Background(), New(server.URL+"/found"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	requestor.Do(context.Background(), New(server.URL+"/missing"))
+	if len(events) != 4 || events[0] != "request /found" || events[1] != "response 200 OK" || events[2] != "request /missing" || !strings.HasPrefix(events[3], "error ") {
+		t.Fatalf("unexpected events %q", events)
+	}
+}
+
+func TestLog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	RegisterSensitiveHeaders("X-Log-Test-Token")
+	RegisterSensitiveQueryParameters("log_test_key")
+	var buffer bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	requestor := NewRequestor(nil).Log(logger)
+	api := New(server.URL).
+		Add().Header("Authorization", "Bearer secret").
+		Add().Header("X-Log-Test-Token", "secret").
+		Add().QueryParameter("log_test_key", "secret").
+		Add().QueryParameter("page", "2")
+	response, err := requestor.Do(context.Background(), api.New("", "/found"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	requestor.Do(context.Background(), api.New("", "/missing"))
+
+	if strings.Contains(buffer.String(), "secret") {
+		t.Fatalf("secrets leaked into the logs:\n%s", buffer.String())
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 log lines, got:\n%s", buffer.String())
+	}
+	entries := make([]map[string]interface{}, len(lines))
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &entries[i]); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+	}
+	if entries[0]["level"] != "DEBUG" || !strings.Contains(entries[0]["url"].(string), "log_test_key=[REDACTED]&page=2") {
+		t.Fatalf("unexpected request entry %v", entries[0])
+	}
+	if entries[1]["level"] != "INFO" || entries[1]["status"] != 200.0 || entries[1]["attempts"] != 1.0 || entries[1]["method"] != "GET" {
+		t.Fatalf("unexpected response entry %v", entries[1])
+	}
+	if entries[3]["level"] != "WARN" || entries[3]["status"] != 404.0 || entries[3]["msg"] != "request failed" {
+		t.Fatalf("unexpected error entry %v", entries[3])
+	}
+}
@@ -0,0 +1,14 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package http3 provides an experimental HTTP/3 (QUIC) transport, falling back
+// to HTTP/2 or HTTP/1.1 for the hosts it fails to reach, so that QUIC-only
+// edges can be tested with the same request building code:
+//
+//	api := request.New("https://edge.example.com/").WithTransport(http3.Transport(nil))
+//
+// It lives in its own package, and is only built with the "quic" build tag
+// (e.g. go build -tags quic), so that quic-go is only pulled in by those who
+// explicitly opt in.
+package http3
@@ -0,0 +1,116 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build quic
+
+package http3
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dihedron/go-log"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// DefaultFallbackPeriod is the default time the requests to a host that could
+// not be reached over HTTP/3 are sent via the fallback transport.
+const DefaultFallbackPeriod = 5 * time.Minute
+
+// Options configures the transport; the zero value is usable.
+type Options struct {
+	// TLSConfig is the TLS configuration of the QUIC connections.
+	TLSConfig *tls.Config
+	// QUICConfig configures the QUIC connections, e.g. their handshake and
+	// idle timeouts.
+	QUICConfig *quic.Config
+	// Fallback is the transport used for the hosts that cannot be reached
+	// over HTTP/3, and for http:// URLs (by default, http.DefaultTransport).
+	Fallback http.RoundTripper
+	// FallbackPeriod is the time after which HTTP/3 is attempted again for a
+	// host that could not be reached (by default, DefaultFallbackPeriod).
+	FallbackPeriod time.Duration
+}
+
+// RoundTripper sends the requests over HTTP/3, falling back to another
+// transport on failure.
+type RoundTripper struct {
+	quic     *http3.Transport
+	fallback http.RoundTripper
+	period   time.Duration
+
+	lock   sync.Mutex
+	broken map[string]time.Time
+}
+
+// Transport returns a new HTTP/3 transport; the options can be nil.
+func Transport(options *Options) *RoundTripper {
+	if options == nil {
+		options = &Options{}
+	}
+	t := &RoundTripper{
+		quic:     &http3.Transport{TLSClientConfig: options.TLSConfig, QUICConfig: options.QUICConfig},
+		fallback: options.Fallback,
+		period:   options.FallbackPeriod,
+		broken:   map[string]time.Time{},
+	}
+	if t.fallback == nil {
+		t.fallback = http.DefaultTransport
+	}
+	if t.period <= 0 {
+		t.period = DefaultFallbackPeriod
+	}
+	return t
+}
+
+// RoundTrip sends the request over HTTP/3, unless its host recently failed
+// to be reached that way; if it fails, the request is sent via the fallback
+// transport, provided its body can be replayed.
+func (t *RoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.URL.Scheme != "https" || t.failing(request.URL.Host) {
+		return t.fallback.RoundTrip(request)
+	}
+	response, err := t.quic.RoundTrip(request)
+	if err == nil || request.Context().Err() != nil {
+		return response, err
+	}
+	retry := request
+	if request.Body != nil && request.Body != http.NoBody {
+		if request.GetBody == nil {
+			return nil, err
+		}
+		body, e := request.GetBody()
+		if e != nil {
+			return nil, err
+		}
+		retry = request.Clone(request.Context())
+		retry.Body = body
+	}
+	log.Debugf("HTTP/3 request to %s failed (%v), falling back", request.URL.Host, err)
+	t.lock.Lock()
+	t.broken[request.URL.Host] = time.Now().Add(t.period)
+	t.lock.Unlock()
+	return t.fallback.RoundTrip(retry)
+}
+
+// failing returns whether the given host recently failed to be reached over
+// HTTP/3.
+func (t *RoundTripper) failing(host string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	until, ok := t.broken[host]
+	if ok && time.Now().After(until) {
+		delete(t.broken, host)
+		return false
+	}
+	return ok
+}
+
+// Close closes the QUIC connections.
+func (t *RoundTripper) Close() error {
+	return t.quic.Close()
+}
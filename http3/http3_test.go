@@ -0,0 +1,65 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build quic
+
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	request "github.com/dihedron/go-requestor"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestTransport(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	// the TLS server provides the certificate, and the fallback
+	fallback := httptest.NewTLSServer(handler)
+	defer fallback.Close()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server := &http3.Server{Handler: handler, TLSConfig: http3.ConfigureTLSConfig(fallback.TLS.Clone())}
+	go server.Serve(conn)
+	defer server.Close()
+
+	client := fallback.Client().Transport.(*http.Transport)
+	transport := Transport(&Options{
+		TLSConfig:  &tls.Config{RootCAs: client.TLSClientConfig.RootCAs},
+		QUICConfig: &quic.Config{HandshakeIdleTimeout: 500 * time.Millisecond},
+		Fallback:   client,
+	})
+	defer transport.Close()
+	requestor := request.NewRequestor(nil)
+
+	for url, expected := range map[string]string{
+		"https://" + conn.LocalAddr().String(): "HTTP/3.0",
+		fallback.URL:                           "HTTP/1.1",
+	} {
+		response, err := requestor.Do(context.Background(), request.New(url).WithTransport(transport))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var body []byte
+		response.Decode(&body)
+		if string(body) != expected {
+			t.Fatalf("expected %s from %s, got %q", expected, url, body)
+		}
+	}
+	if !transport.failing(fallback.Listener.Addr().String()) {
+		t.Fatalf("expected the fallback host to be remembered")
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ErrNoLink is returned when following a relation type the response has no
+// link for.
+var ErrNoLink = errors.New("no link")
+
+// HypermediaLinks returns the links of the response, keyed by relation type:
+// those in the "_links" section of HAL bodies, in the top-level (or else
+// primary data) "links" section of JSON:API bodies, and in the "Link" header,
+// in this order of precedence; relative link targets are resolved against the
+// request URL. The response body is buffered, so that it can still be read or
+// decoded.
+func (r *Response) HypermediaLinks() (map[string]string, error) {
+	result := map[string]string{}
+	var base *url.URL
+	if r.Request != nil {
+		base = r.Request.URL
+	}
+	if isJSON(r.Header.Get("Content-Type")) {
+		if err := r.parse(); err != nil {
+			return nil, err
+		}
+		if document, ok := r.document.(map[string]interface{}); ok {
+			sections := []interface{}{document["_links"], document["links"]}
+			if data, ok := document["data"].(map[string]interface{}); ok {
+				sections = append(sections, data["links"])
+			}
+			for _, section := range sections {
+				links, _ := section.(map[string]interface{})
+				for rel, link := range links {
+					if _, ok := result[rel]; ok {
+						continue
+					}
+					if href := hyperlink(link); href != "" {
+						result[rel] = resolveReference(base, href)
+					}
+				}
+			}
+		}
+	}
+	for rel, href := range Links(r.Response) {
+		if _, ok := result[rel]; !ok {
+			result[rel] = href
+		}
+	}
+	return result, nil
+}
+
+// hyperlink returns the target of a HAL or JSON:API link, which is either a
+// string or an object with an "href" member, or the first of an array of them.
+func hyperlink(link interface{}) string {
+	switch link := link.(type) {
+	case string:
+		return link
+	case map[string]interface{}:
+		href, _ := link["href"].(string)
+		return href
+	case []interface{}:
+		if len(link) > 0 {
+			return hyperlink(link[0])
+		}
+	}
+	return ""
+}
+
+// Follow returns a child of the Builder that generated the request, pointing
+// to the target of the response link with the given relation type (see
+// HypermediaLinks), e.g.
+//
+//	next, err := response.Follow("next")
+//
+// The child is a GET request without a body, inheriting the headers, the
+// credentials and the other settings of the Builder but not its query
+// parameters, since the link carries its own; the request being followed must
+// have been sent by a Requestor.
+func (r *Response) Follow(rel string) (*Builder, error) {
+	links, err := r.HypermediaLinks()
+	if err != nil {
+		return nil, err
+	}
+	href, ok := links[rel]
+	if !ok {
+		return nil, fmt.Errorf("%w with relation type %q", ErrNoLink, rel)
+	}
+	return r.follow(href)
+}
+
+// FollowLocation returns a child of the Builder that generated the request,
+// pointing to the target of the response "Location" header, e.g. the resource
+// created by a request answered with 201 Created, or the status monitor of a
+// request answered with 202 Accepted; a relative target is resolved against
+// the request URL. As with Follow, the child is a GET request without a body
+// or query parameters, inheriting the headers and the credentials.
+func (r *Response) FollowLocation() (*Builder, error) {
+	location, err := r.Location()
+	if err != nil {
+		return nil, fmt.Errorf("%w in the Location header: %v", ErrNoLink, err)
+	}
+	return r.follow(location.String())
+}
+
+// follow returns a child of the Builder that generated the request, for a GET
+// request to the given URL, which carries the full path and query; the query
+// and matrix parameters and the Content-Type of the original body are dropped.
+func (r *Response) follow(target string) (*Builder, error) {
+	if r.builder == nil {
+		return nil, errors.New("response was not received via a Requestor")
+	}
+	child := r.builder.New(http.MethodGet, target)
+	child.parameters = url.Values{}
+	child.raw = nil
+	child.order = nil
+	child.rawQuery = ""
+	child.matrix = nil
+	child.body = nil
+	child.headers.Del("Content-Type")
+	return child, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFollow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/orders":
+			w.Header().Set("Content-Type", "application/hal+json")
+			w.Header().Set("Link", `</orders?page=9>; rel="last"`)
+			w.Write([]byte(`{"_links": {"self": {"href": "/orders"}, "next": {"href": "/orders/page?page=2"}, "item": [{"href": "/orders/1"}, {"href": "/orders/2"}]}}`))
+		case "/articles":
+			w.Header().Set("Content-Type", "application/vnd.api+json")
+			w.Write([]byte(`{"links": {"self": "/articles", "next": {"href": "/articles?page[number]=2"}}, "data": {"links": {"related": "/authors/1"}}}`))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"path": "` + r.URL.RequestURI() + `"}`))
+		}
+	}))
+	defer server.Close()
+	requestor := NewRequestor(nil)
+	base := New(server.URL).SetHeader("Authorization", "Bearer token").SetQueryParameter("page", "1").RawQuery("token=abc")
+
+	response, err := requestor.Do(context.Background(), base.New("", "/orders"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	links, err := response.HypermediaLinks()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"self": server.URL + "/orders",
+		"next": server.URL + "/orders/page?page=2",
+		"item": server.URL + "/orders/1",
+		"last": server.URL + "/orders?page=9",
+	}
+	for rel, href := range expected {
+		if links[rel] != href {
+			t.Fatalf("expected %s link %q, got %q", rel, href, links[rel])
+		}
+	}
+	next, err := response.Follow("next")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	followed, err := requestor.Do(context.Background(), next)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path, err := followed.Extract("$.path"); err != nil || path.String() != "/orders/page?page=2" {
+		t.Fatalf("expected the next link to be followed with its own query, got %v (%v)", path, err)
+	}
+	if _, err := response.Follow("prev"); !errors.Is(err, ErrNoLink) {
+		t.Fatalf("expected ErrNoLink, got %v", err)
+	}
+
+	response, err = requestor.Do(context.Background(), base.New("", "/articles"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if links, err = response.HypermediaLinks(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if links["next"] != server.URL+"/articles?page[number]=2" || links["related"] != server.URL+"/authors/1" {
+		t.Fatalf("unexpected JSON:API links %v", links)
+	}
+	related, err := response.Follow("related")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := requestor.Do(context.Background(), related); err != nil {
+		t.Fatalf("expected the headers to be inherited, got %v", err)
+	}
+
+	if _, err := (&Response{Response: &http.Response{Header: http.Header{}}}).Follow("next"); err == nil {
+		t.Fatalf("expected error following a response not received via a Requestor")
+	}
+}
+
+func TestFollowLocation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/orders":
+			w.Header().Set("Location", "orders/42")
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"method": "` + r.Method + `", "path": "` + r.URL.RequestURI() + `"}`))
+		}
+	}))
+	defer server.Close()
+	requestor := NewRequestor(nil)
+	base := New(server.URL).SetHeader("Authorization", "Bearer token")
+
+	response, err := requestor.Do(context.Background(), base.New(http.MethodPost, "/v1/orders?dry=false").WithJSONEntity(map[string]string{"item": "book"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	created, err := response.FollowLocation()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	followed, err := requestor.Do(context.Background(), created)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if method, _ := followed.Extract("$.method"); method.String() != http.MethodGet {
+		t.Fatalf("expected a GET request, got %q", method)
+	}
+	if path, _ := followed.Extract("$.path"); path.String() != "/v1/orders/42" {
+		t.Fatalf("expected the location to be resolved against the request URL, got %q", path)
+	}
+
+	response, err = requestor.Do(context.Background(), base.New(http.MethodPost, "/v1/jobs"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if _, err := response.FollowLocation(); !errors.Is(err, ErrNoLink) {
+		t.Fatalf("expected ErrNoLink, got %v", err)
+	}
+}
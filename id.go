@@ -0,0 +1,124 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IDGenerator generates the random identifiers used by the package, e.g. as
+// idempotency keys or correlation ids; replacing it
+// allows a preferred entropy source to be enforced, or tests to produce
+// deterministic output. Implementations must be safe for concurrent use.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts a function to the IDGenerator interface.
+type IDGeneratorFunc func() string
+
+// NewID returns the identifier generated by the function.
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// UUIDGenerator generates random (version 4) UUIDs using crypto/rand.
+type UUIDGenerator struct{}
+
+// NewID returns a new random UUID.
+func (UUIDGenerator) NewID() string {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		panic(fmt.Sprintf("error reading random data: %v", err))
+	}
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+}
+
+// UUIDv7Generator generates time-ordered (version 7) UUIDs, whose first 48
+// bits are the Unix time in milliseconds and the rest is random, so that they
+// sort by creation time (e.g. in logs and indexes).
+type UUIDv7Generator struct{}
+
+// NewID returns a new time-ordered UUID.
+func (UUIDv7Generator) NewID() string {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[6:]); err != nil {
+		panic(fmt.Sprintf("error reading random data: %v", err))
+	}
+	binary.BigEndian.PutUint64(uuid[0:8], uint64(time.Now().UnixMilli())<<16|uint64(binary.BigEndian.Uint16(uuid[6:8])))
+	uuid[6] = (uuid[6] & 0x0f) | 0x70
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+}
+
+// SequentialGenerator generates the identifiers "<prefix>1", "<prefix>2" and so
+// on, e.g. for deterministic tests.
+type SequentialGenerator struct {
+	prefix  string
+	counter uint64
+}
+
+// NewSequentialGenerator returns a new SequentialGenerator using the given
+// prefix.
+func NewSequentialGenerator(prefix string) *SequentialGenerator {
+	return &SequentialGenerator{prefix: prefix}
+}
+
+// NewID returns the next identifier in the sequence.
+func (g *SequentialGenerator) NewID() string {
+	return fmt.Sprintf("%s%d", g.prefix, atomic.AddUint64(&g.counter, 1))
+}
+
+var (
+	idLock      sync.RWMutex
+	idGenerator IDGenerator = UUIDGenerator{}
+)
+
+// SetIDGenerator replaces the IDGenerator used by default throughout the
+// package (initially, a UUIDGenerator); nil restores the default.
+func SetIDGenerator(generator IDGenerator) {
+	if generator == nil {
+		generator = UUIDGenerator{}
+	}
+	idLock.Lock()
+	defer idLock.Unlock()
+	idGenerator = generator
+}
+
+// NewID returns a new identifier from the default IDGenerator.
+func NewID() string {
+	idLock.RLock()
+	generator := idGenerator
+	idLock.RUnlock()
+	return generator.NewID()
+}
+
+// IDGenerator sets the IDGenerator used for the identifiers generated by the
+// Requestor, in place of the package default.
+func (r *Requestor) IDGenerator(generator IDGenerator) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.ids = generator
+	return r
+}
+
+// newID returns a new identifier from the IDGenerator of the Requestor, or from
+// the default one.
+func (r *Requestor) newID() string {
+	r.lock.Lock()
+	generator := r.ids
+	r.lock.Unlock()
+	if generator == nil {
+		return NewID()
+	}
+	return generator.NewID()
+}
@@ -0,0 +1,44 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestIDGenerators(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first, second := NewID(), NewID()
+	if !uuid.MatchString(first) || first == second {
+		t.Fatalf("unexpected default ids %q and %q", first, second)
+	}
+
+	SetIDGenerator(NewSequentialGenerator("id-"))
+	defer SetIDGenerator(nil)
+	if id := NewID(); id != "id-1" {
+		t.Fatalf("expected id-1, got %q", id)
+	}
+	requestor := NewRequestor(nil)
+	if id := requestor.newID(); id != "id-2" {
+		t.Fatalf("expected id-2, got %q", id)
+	}
+	requestor.IDGenerator(IDGeneratorFunc(func() string { return "fixed" }))
+	if id := requestor.newID(); id != "fixed" {
+		t.Fatalf("expected fixed, got %q", id)
+	}
+}
+
+func TestUUIDv7Generator(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	generator := UUIDv7Generator{}
+	first := generator.NewID()
+	time.Sleep(2 * time.Millisecond)
+	second := generator.NewID()
+	if !uuid.MatchString(first) || !uuid.MatchString(second) || first >= second {
+		t.Fatalf("unexpected ids %q and %q", first, second)
+	}
+}
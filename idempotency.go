@@ -0,0 +1,29 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"net/http"
+)
+
+// IdempotencyKeyHeader is the header carrying the idempotency key of a request,
+// as used by Stripe-style APIs to process repeated requests only once.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Idempotent makes each request generated by the builder carry a new key in the
+// Idempotency-Key header (unless set explicitly), generated by the package
+// IDGenerator; the key stays the same across the retries of a request, which
+// are therefore allowed whatever its method, and changes between distinct
+// requests.
+func (f *Builder) Idempotent() *Builder {
+	f.idempotent = true
+	return f
+}
+
+// retriable returns whether the given request can be safely sent again, i.e.
+// whether its method is idempotent or it carries an idempotency key.
+func retriable(request *http.Request) bool {
+	return isIdempotent(request.Method) || request.Header.Get(IdempotencyKeyHeader) != ""
+}
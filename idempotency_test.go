@@ -0,0 +1,42 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdempotent(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(nil).Retry(3, time.Millisecond)
+	f := New(server.URL).Post().WithJSONEntity(struct{ Amount int }{10}).Idempotent()
+	if _, err := requestor.Do(context.Background(), f); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := requestor.Do(context.Background(), f); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 3 || keys[0] == "" || keys[0] != keys[1] || keys[1] == keys[2] {
+		t.Fatalf("expected a key stable across retries only, got %v", keys)
+	}
+
+	keys = nil
+	_, err := requestor.Do(context.Background(), New(server.URL).Post())
+	if StatusCode(err) != http.StatusServiceUnavailable || len(keys) != 1 || keys[0] != "" {
+		t.Fatalf("expected a POST without key not to be retried, got %v (%v)", err, keys)
+	}
+}
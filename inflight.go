@@ -0,0 +1,175 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// InFlight describes a request being executed.
+type InFlight struct {
+	// ID identifies the request in the Registry.
+	ID string
+	// Method and URL are those of the request.
+	Method string
+	URL    string
+	// Started is when the execution started.
+	Started time.Time
+	// Tags are the tags of the Builder that generated the request.
+	Tags map[string]string
+}
+
+// Registry keeps track of the requests being executed, from when they are
+// sent until their response body is closed, so that they can be listed (e.g.
+// by an admin endpoint) and cancelled (e.g. on shutdown). It is safe for
+// concurrent use.
+type Registry struct {
+	lock     sync.Mutex
+	requests map[string]*tracked
+}
+
+// tracked is an in-flight request, along with the function cancelling it.
+type tracked struct {
+	InFlight
+	cancel context.CancelFunc
+}
+
+// NewRegistry returns a new, empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		requests: map[string]*tracked{},
+	}
+}
+
+// Track makes the requests generated by this builder and its children be
+// tracked by the given Registry while in flight.
+func (f *Builder) Track(registry *Registry) *Builder {
+	f.registry = registry
+	return f
+}
+
+// Tag labels the requests generated by this builder and its children with the
+// given tag, as reported by the Registry.
+func (f *Builder) Tag(key, value string) *Builder {
+	f.tags[key] = value
+	return f
+}
+
+// List returns the requests currently in flight, oldest first.
+func (g *Registry) List() []InFlight {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	list := make([]InFlight, 0, len(g.requests))
+	for _, request := range g.requests {
+		list = append(list, request.InFlight)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Started.Equal(list[j].Started) {
+			return list[i].ID < list[j].ID
+		}
+		return list[i].Started.Before(list[j].Started)
+	})
+	return list
+}
+
+// Cancel cancels the in-flight request with the given ID, and returns whether
+// it was found.
+func (g *Registry) Cancel(id string) bool {
+	return g.CancelWhere(func(request InFlight) bool {
+		return request.ID == id
+	}) > 0
+}
+
+// CancelWhere cancels all the in-flight requests satisfying the given
+// predicate, and returns how many they were.
+func (g *Registry) CancelWhere(predicate func(request InFlight) bool) int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	n := 0
+	for id, request := range g.requests {
+		if predicate(request.InFlight) {
+			request.cancel()
+			delete(g.requests, id)
+			n++
+		}
+	}
+	return n
+}
+
+// CancelAll cancels all the in-flight requests, and returns how many they
+// were.
+func (g *Registry) CancelAll() int {
+	return g.CancelWhere(func(InFlight) bool { return true })
+}
+
+// add registers an in-flight request.
+func (g *Registry) add(request *tracked) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.requests[request.ID] = request
+}
+
+// remove unregisters an in-flight request.
+func (g *Registry) remove(id string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	delete(g.requests, id)
+}
+
+// track registers the given request, generated by the given Builder, in the
+// Builder registry (if any); it returns the request bound to a cancellable
+// context, and the function to call with the response (if any) once it is
+// available, which unregisters the request right away, or once its body is
+// closed.
+func (r *Requestor) track(f *Builder, request *http.Request, started time.Time) (*http.Request, func(*Response)) {
+	if f.registry == nil {
+		return request, func(*Response) {}
+	}
+	ctx, cancel := context.WithCancel(request.Context())
+	entry := &tracked{
+		InFlight: InFlight{
+			ID:      r.newID(),
+			Method:  request.Method,
+			URL:     RedactURL(request.URL),
+			Started: started,
+			Tags:    map[string]string{},
+		},
+		cancel: cancel,
+	}
+	for key, value := range f.tags {
+		entry.Tags[key] = value
+	}
+	f.registry.add(entry)
+	release := func() {
+		f.registry.remove(entry.ID)
+		cancel()
+	}
+	return request.WithContext(ctx), func(response *Response) {
+		if response == nil || response.Body == nil {
+			release()
+			return
+		}
+		response.Body = &releasingBody{ReadCloser: response.Body, release: release}
+	}
+}
+
+// releasingBody is a response body that unregisters its request when closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// Close closes the body and unregisters the request.
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,78 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	registry := NewRegistry()
+	api := New(server.URL).Track(registry).Tag("job", "sync")
+	requestor := NewRequestor(nil)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := requestor.Do(context.Background(), api.New("", "/slow").Tag("step", "users"))
+		errs <- err
+	}()
+	var list []InFlight
+	for deadline := time.Now().Add(time.Second); len(list) == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+		list = registry.List()
+	}
+	if len(list) != 1 || list[0].Method != http.MethodGet || list[0].URL != server.URL+"/slow" {
+		t.Fatalf("expected the slow request in flight, got %+v", list)
+	}
+	if list[0].Tags["job"] != "sync" || list[0].Tags["step"] != "users" || list[0].Started.IsZero() {
+		t.Fatalf("unexpected in-flight request %+v", list[0])
+	}
+	if api.tags["step"] != "" {
+		t.Fatalf("child tags leaked into the parent")
+	}
+	if !registry.Cancel(list[0].ID) || registry.Cancel(list[0].ID) {
+		t.Fatalf("expected the request to be cancelled once")
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected cancellation, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("request not cancelled")
+	}
+
+	response, err := requestor.Do(context.Background(), api)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(registry.List()) != 1 {
+		t.Fatalf("expected the request in flight until its body is closed")
+	}
+	var body []byte
+	if err := response.Decode(&body); err != nil || string(body) != "ok" {
+		t.Fatalf("unexpected body %q (%v)", body, err)
+	}
+	if len(registry.List()) != 0 {
+		t.Fatalf("expected no requests in flight, got %+v", registry.List())
+	}
+	if registry.CancelAll() != 0 {
+		t.Fatalf("expected nothing to cancel")
+	}
+}
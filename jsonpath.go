@@ -0,0 +1,127 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathToken is a single step in a path expression: either an object key or an
+// array index.
+type pathToken struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parsePath parses a simple JSONPath-like expression, e.g. "$.items[0].id",
+// "items[0].id", "meta.next_cursor" or "$['odd key'][-1]"; an empty path, "$"
+// and "." all refer to the whole document.
+func parsePath(path string) ([]pathToken, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	tokens := []pathToken{}
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			i++
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unterminated bracket", path)
+			}
+			inner := strings.TrimSpace(path[i+1 : i+end])
+			i += end + 1
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				tokens = append(tokens, pathToken{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: invalid index %q", path, inner)
+			}
+			tokens = append(tokens, pathToken{index: index, isIndex: true})
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			tokens = append(tokens, pathToken{key: path[i : i+end]})
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+// lookupPath evaluates the given path expression against a document decoded
+// into generic values (maps, slices and scalars); it returns whether the path
+// could be resolved.
+func lookupPath(document interface{}, path string) (interface{}, bool, error) {
+	tokens, err := parsePath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	current := document
+	for _, token := range tokens {
+		if token.isIndex {
+			array, ok := current.([]interface{})
+			if !ok {
+				return nil, false, nil
+			}
+			index := token.index
+			if index < 0 {
+				index += len(array)
+			}
+			if index < 0 || index >= len(array) {
+				return nil, false, nil
+			}
+			current = array[index]
+		} else {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false, nil
+			}
+			if current, ok = object[token.key]; !ok {
+				return nil, false, nil
+			}
+		}
+	}
+	return current, true, nil
+}
+
+// lookupJSONPath decodes the given JSON document and evaluates the path
+// expression against it; numbers are returned as json.Number.
+func lookupJSONPath(data []byte, path string) (interface{}, bool, error) {
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, false, err
+	}
+	return lookupPath(document, path)
+}
+
+// stringify returns the string representation of a value extracted via a path
+// expression; strings are returned as they are, null as an empty string and
+// objects and arrays as JSON.
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"testing"
+)
+
+func TestLookupJSONPath(t *testing.T) {
+	document := []byte(`{"meta": {"next_cursor": "abc", "count": 3, "more": true}, "items": [{"id": 1}, {"id": 2}, {"id": 3}], "odd key": null}`)
+	tests := []struct {
+		path     string
+		expected string
+		found    bool
+	}{
+		{"meta.next_cursor", "abc", true},
+		{"$.meta.count", "3", true},
+		{"$.meta.more", "true", true},
+		{"items[1].id", "2", true},
+		{"$.items[-1].id", "3", true},
+		{"$['odd key']", "", true},
+		{"items[5].id", "", false},
+		{"meta.missing", "", false},
+		{"meta.next_cursor.nested", "", false},
+		{"$.items[0]", `{"id":1}`, true},
+	}
+	for _, test := range tests {
+		value, found, err := lookupJSONPath(document, test.path)
+		if err != nil {
+			t.Fatalf("unexpected error evaluating %q: %v", test.path, err)
+		}
+		if found != test.found {
+			t.Fatalf("invalid result evaluating %q: expected found=%t, got %t", test.path, test.found, found)
+		}
+		if s := stringify(value); s != test.expected {
+			t.Fatalf("invalid value evaluating %q: expected %q, got %q", test.path, test.expected, s)
+		}
+	}
+	if _, _, err := lookupJSONPath(document, "items[x]"); err == nil {
+		t.Fatalf("expected error on invalid index")
+	}
+	if _, _, err := lookupJSONPath(document, "items[0"); err == nil {
+		t.Fatalf("expected error on unterminated bracket")
+	}
+}
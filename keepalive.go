@@ -0,0 +1,36 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"time"
+)
+
+// KeepAlive sets the interval between the TCP keep-alive probes sent on open
+// connections (30 seconds by default, negative to disable them); probing idle
+// pooled connections more often than the idle timeout of the load balancers and
+// NATs along the way keeps them from being silently dropped, and detects dead
+// peers before the connection is reused.
+func (r *Requestor) KeepAlive(interval time.Duration) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.keepAlive = interval
+	r.dialing()
+	return r
+}
+
+// MaxIdleTime sets the maximum time a pooled connection can stay idle before
+// being closed instead of reused; setting it below the idle timeout of the
+// server or of aggressive load balancers avoids the classic "connection reset"
+// failure of the first request after an idle period.
+func (r *Requestor) MaxIdleTime(timeout time.Duration) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if transport := r.ownTransport(); transport != nil {
+		transport.IdleConnTimeout = timeout
+		transport.CloseIdleConnections()
+	}
+	return r
+}
@@ -0,0 +1,48 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxIdleTime(t *testing.T) {
+	var connections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	requestor := NewRequestor(nil).KeepAlive(time.Second).MaxIdleTime(50 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		response, err := requestor.Do(context.Background(), New(server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response.Body.Close()
+	}
+	if n := atomic.LoadInt32(&connections); n != 1 {
+		t.Fatalf("expected the connection to be reused, got %d connections", n)
+	}
+	time.Sleep(100 * time.Millisecond)
+	response, err := requestor.Do(context.Background(), New(server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if n := atomic.LoadInt32(&connections); n != 2 {
+		t.Fatalf("expected the idle connection to be discarded, got %d connections", n)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Link is a web link as per RFC 5988 (and RFC 8288), as found in the "Link"
+// response header, e.g. `<https://api.example.com/items?page=2>; rel="next"`.
+type Link struct {
+	// URL is the target of the link, as it appears in the header.
+	URL string
+	// Rel is the relation type of the link, e.g. "next".
+	Rel string
+	// Params contains all the link parameters, including "rel".
+	Params map[string]string
+}
+
+// ParseLinks parses a set of "Link" header values into a list of links; links
+// having multiple space-separated relation types (e.g. rel="next last") are
+// returned once per relation type.
+func ParseLinks(values ...string) []Link {
+	result := []Link{}
+	for _, value := range values {
+		for _, token := range splitLinks(value) {
+			token = strings.TrimSpace(token)
+			if !strings.HasPrefix(token, "<") {
+				continue
+			}
+			end := strings.Index(token, ">")
+			if end < 0 {
+				continue
+			}
+			target := token[1:end]
+			params := map[string]string{}
+			for _, param := range strings.Split(token[end+1:], ";") {
+				param = strings.TrimSpace(param)
+				if param == "" {
+					continue
+				}
+				kv := strings.SplitN(param, "=", 2)
+				key := strings.ToLower(strings.TrimSpace(kv[0]))
+				if len(kv) == 2 {
+					params[key] = strings.Trim(strings.TrimSpace(kv[1]), "\"")
+				} else {
+					params[key] = ""
+				}
+			}
+			rels := strings.Fields(params["rel"])
+			if len(rels) == 0 {
+				rels = []string{""}
+			}
+			for _, rel := range rels {
+				result = append(result, Link{URL: target, Rel: rel, Params: params})
+			}
+		}
+	}
+	return result
+}
+
+// Links returns the links in the "Link" header of the given response, keyed by
+// relation type; relative link targets are resolved against the request URL.
+func Links(response *http.Response) map[string]string {
+	result := map[string]string{}
+	if response == nil {
+		return result
+	}
+	var base *url.URL
+	if response.Request != nil {
+		base = response.Request.URL
+	}
+	for _, link := range ParseLinks(response.Header.Values("Link")...) {
+		if _, ok := result[link.Rel]; ok {
+			// the first link wins
+			continue
+		}
+		result[link.Rel] = resolveReference(base, link.URL)
+	}
+	return result
+}
+
+// resolveReference resolves the given (possibly relative) reference against the
+// base URL, if available.
+func resolveReference(base *url.URL, reference string) string {
+	if base == nil {
+		return reference
+	}
+	u, err := url.Parse(reference)
+	if err != nil {
+		return reference
+	}
+	return base.ResolveReference(u).String()
+}
+
+// splitLinks splits a "Link" header value on the commas that separate links,
+// ignoring those within angle brackets and quoted strings.
+func splitLinks(value string) []string {
+	result := []string{}
+	inURL, inQuotes := false, false
+	start := 0
+	for i, c := range value {
+		switch {
+		case c == '<' && !inQuotes:
+			inURL = true
+		case c == '>' && !inQuotes:
+			inURL = false
+		case c == '"' && !inURL:
+			inQuotes = !inQuotes
+		case c == ',' && !inURL && !inQuotes:
+			result = append(result, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(result, value[start:])
+}
@@ -0,0 +1,47 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestParseLinks(t *testing.T) {
+	links := ParseLinks(`<https://api.example.com/items?page=2&a=b,c>; rel="next", <https://api.example.com/items?page=5>; rel="last"; title="a, b"`, `</items?page=1>; rel="first prev"`)
+	expected := []Link{
+		{URL: "https://api.example.com/items?page=2&a=b,c", Rel: "next"},
+		{URL: "https://api.example.com/items?page=5", Rel: "last"},
+		{URL: "/items?page=1", Rel: "first"},
+		{URL: "/items?page=1", Rel: "prev"},
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("invalid number of links: expected %d, got %d", len(expected), len(links))
+	}
+	for i := range expected {
+		if links[i].URL != expected[i].URL || links[i].Rel != expected[i].Rel {
+			t.Fatalf("invalid link %d: expected %v, got %v", i, expected[i], links[i])
+		}
+	}
+	if links[1].Params["title"] != "a, b" {
+		t.Fatalf("invalid title parameter: got %q", links[1].Params["title"])
+	}
+}
+
+func TestLinks(t *testing.T) {
+	u, _ := url.Parse("https://api.example.com/v1/items?page=1")
+	response := &http.Response{
+		Header:  http.Header{"Link": []string{`<?page=2>; rel="next", <https://other.example.com/x>; rel="next"`}},
+		Request: &http.Request{URL: u},
+	}
+	links := Links(response)
+	if links["next"] != "https://api.example.com/v1/items?page=2" {
+		t.Fatalf("invalid next link: got %q", links["next"])
+	}
+	if len(Links(nil)) != 0 {
+		t.Fatalf("expected no links on nil response")
+	}
+}
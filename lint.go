@@ -0,0 +1,147 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Severity is the severity of a Problem found by Lint.
+type Severity int8
+
+const (
+	// LintWarning marks a configuration that is most likely a mistake, but
+	// still generates a request.
+	LintWarning Severity = iota
+	// LintError marks a configuration that makes Make fail, or generates a
+	// request that cannot be sent as intended.
+	LintError
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	if s == LintError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Problem is a mistake found by Lint in the configuration of a builder.
+type Problem struct {
+	// Severity is the severity of the problem.
+	Severity Severity
+	// Message describes the problem.
+	Message string
+}
+
+// String returns the problem in human-readable form.
+func (p Problem) String() string {
+	return p.Severity.String() + ": " + p.Message
+}
+
+// Lint checks the request this builder would generate for common mistakes,
+// without sending it: besides the problems Strict rejects (e.g. a body on a
+// GET request, a body without Content-Type or unbound URL variables), it
+// reports the errors Make would fail with, the references to variables left
+// unresolved, the invalid characters in header names and values and the
+// conflicting authentication mechanisms. The builder is left untouched, and
+// bodies that cannot be read twice are not read.
+func (f *Builder) Lint() []Problem {
+	clone := f.New("", "")
+	clone.strict = false
+	switch clone.body.(type) {
+	case nil, *bytes.Reader, *strings.Reader, *templateBody, *lazyBody:
+	default:
+		clone.body = strings.NewReader("")
+	}
+	request, err := clone.Make()
+	if err != nil {
+		return []Problem{{Severity: LintError, Message: err.Error()}}
+	}
+	problems := clone.suspicious(request)
+	if location, err := url.PathUnescape(request.URL.String()); err == nil {
+		for _, reference := range unresolved(location) {
+			problems = append(problems, Problem{Severity: LintWarning, Message: fmt.Sprintf("unresolved variable reference %s in URL", reference)})
+		}
+	}
+	keys := make([]string, 0, len(request.Header))
+	for key := range request.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !validHeaderName(key) {
+			problems = append(problems, Problem{Severity: LintError, Message: fmt.Sprintf("invalid character in header name %q", key)})
+		}
+		for _, value := range request.Header[key] {
+			if !validHeaderValue(value) {
+				problems = append(problems, Problem{Severity: LintError, Message: fmt.Sprintf("invalid character in value of header %s", key)})
+			}
+			for _, reference := range unresolved(value) {
+				problems = append(problems, Problem{Severity: LintWarning, Message: fmt.Sprintf("unresolved variable reference %s in header %s", reference, key)})
+			}
+		}
+	}
+	return append(problems, clone.authentication(request)...)
+}
+
+// authentication returns the conflicts between the authentication mechanisms
+// of the given request, generated by this builder, which must not be shared.
+func (f *Builder) authentication(request *http.Request) []Problem {
+	problems := []Problem{}
+	authorization := request.Header.Values("Authorization")
+	if len(authorization) > 1 {
+		problems = append(problems, Problem{Severity: LintError, Message: fmt.Sprintf("%d Authorization headers", len(authorization))})
+	}
+	if request.URL.User != nil && len(authorization) > 0 {
+		problems = append(problems, Problem{Severity: LintError, Message: "credentials both in URL and in Authorization header"})
+	}
+	if f.credentials != nil && f.credentials.header() != "Authorization" && len(authorization) > 0 {
+		problems = append(problems, Problem{Severity: LintError, Message: fmt.Sprintf("credentials both in %s and in Authorization header", f.credentials.header())})
+	}
+	return problems
+}
+
+// unresolved returns the references to variables (see Var) in the given
+// string.
+func unresolved(s string) []string {
+	references := []string{}
+	for _, reference := range varReference.FindAllString(s, -1) {
+		if reference != "$$" {
+			references = append(references, reference)
+		}
+	}
+	return references
+}
+
+// validHeaderName returns whether the given header name is a valid token, as
+// per RFC 7230.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c >= 0x80 || !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// validHeaderValue returns whether the given header value has no control
+// characters other than horizontal tabs, as per RFC 7230.
+func validHeaderValue(value string) bool {
+	for _, c := range value {
+		if c == 0x7f || c < 0x20 && c != '\t' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// SetMatrixParameter replaces the current set of values for the given matrix
+// parameter of the given path segment, or of the last one if empty, as in
+// "/items;sort=asc"; the segment is identified by its name, and the parameter
+// is repeated for each value.
+func (f *Builder) SetMatrixParameter(segment, key string, values ...string) *Builder {
+	defer f.write()()
+	if f.matrix == nil {
+		f.matrix = map[string]map[string][]string{}
+	}
+	if f.matrix[segment] == nil {
+		f.matrix[segment] = url.Values{}
+	}
+	f.matrix[segment][key] = append([]string(nil), values...)
+	return f
+}
+
+// DelMatrixParameter removes the given matrix parameter of the given path
+// segment, or of the last one if empty.
+func (f *Builder) DelMatrixParameter(segment, key string) *Builder {
+	defer f.write()()
+	delete(f.matrix[segment], key)
+	if len(f.matrix[segment]) == 0 {
+		delete(f.matrix, segment)
+	}
+	return f
+}
+
+// addMatrixParameters appends the matrix parameters to the segments of the
+// path of the given URL, after those already there, if any; the parameters of
+// segments not in the path are ignored.
+func (f *Builder) addMatrixParameters(u *url.URL) {
+	segments := strings.Split(u.EscapedPath(), "/")
+	last := len(segments) - 1
+	if last > 0 && segments[last] == "" {
+		// trailing slash
+		last--
+	}
+	for name, parameters := range f.matrix {
+		index := last
+		if name != "" {
+			index = -1
+			for i, segment := range segments {
+				if n, err := url.PathUnescape(strings.SplitN(segment, ";", 2)[0]); err == nil && n == name {
+					index = i
+					break
+				}
+			}
+		}
+		if index < 0 {
+			continue
+		}
+		keys := make([]string, 0, len(parameters))
+		for key := range parameters {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			for _, value := range parameters[key] {
+				segments[index] += ";" + url.PathEscape(key) + "=" + url.PathEscape(value)
+			}
+		}
+	}
+	escaped := strings.Join(segments, "/")
+	if path, err := url.PathUnescape(escaped); err == nil {
+		u.Path, u.RawPath = path, escaped
+	}
+}
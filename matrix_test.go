@@ -0,0 +1,43 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"testing"
+)
+
+func TestMatrixParameters(t *testing.T) {
+	base := New("http://www.example.com/api/cars;make=fiat/models").
+		SetMatrixParameter("cars", "color", "red", "blue").
+		SetMatrixParameter("", "sort", "asc").
+		SetMatrixParameter("missing", "ignored", "true")
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{"", "/api/cars;make=fiat;color=red;color=blue/models;sort=asc"},
+		{"models/500/", "/api/cars;make=fiat;color=red;color=blue/models/500;sort=asc/"},
+		{"models/a b", "/api/cars;make=fiat;color=red;color=blue/models/a%20b;sort=asc"},
+	}
+	for _, test := range testCases {
+		request, err := base.New("", test.path).Make()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if path := request.URL.EscapedPath(); path != test.expected {
+			t.Fatalf("path %q: expected %q, got %q", test.path, test.expected, path)
+		}
+	}
+	request, err := base.New("", "").SetMatrixParameter("", "filter", "a;b").DelMatrixParameter("cars", "color").Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "http://www.example.com/api/cars;make=fiat/models;filter=a%3Bb;sort=asc"; request.URL.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, request.URL.String())
+	}
+	if len(base.matrix["cars"]) != 1 {
+		t.Fatalf("child matrix parameters leaked into the parent")
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Middleware wraps the transport used to send requests, e.g. to instrument or
+// decorate them; it is given the next transport in the chain.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// Use adds middlewares wrapping the transport that sends the requests generated
+// by this builder and its children; the first middleware is the outermost, and
+// each one is applied to every attempt (and redirect) of a request.
+func (f *Builder) Use(middlewares ...Middleware) *Builder {
+	f.middlewares = append(f.middlewares, middlewares...)
+	return f
+}
+
+// roundTripper returns the transport used to send the requests generated by
+// the given Builder, customised and wrapped in its middlewares, or nil if the
+// one of the given client is to be used as is; it fails if the transport must
+// be customised but is not an *http.Transport.
+func (f *Builder) roundTripper(client *http.Client) (http.RoundTripper, error) {
+	defer f.read()()
+	if f.transport == nil && len(f.customisations) == 0 && len(f.middlewares) == 0 {
+		return nil, nil
+	}
+	transport := f.transport
+	if transport == nil {
+		transport = client.Transport
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if len(f.customisations) > 0 {
+		base, ok := transport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("%w of type %T", ErrCustomTransport, transport)
+		}
+		transport = f.customised.get(base, f.customisations)
+	}
+	for i := len(f.middlewares) - 1; i >= 0; i-- {
+		transport = f.middlewares[i](transport)
+	}
+	return transport, nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type roundTripperFunc func(request *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func TestUse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Chain")))
+	}))
+	defer server.Close()
+
+	calls := []string{}
+	middleware := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+				calls = append(calls, name)
+				request = request.Clone(request.Context())
+				request.Header.Add("X-Chain", name)
+				return next.RoundTrip(request)
+			})
+		}
+	}
+	parent := New(server.URL).Use(middleware("outer"))
+	child := parent.New("", "").Use(middleware("inner"))
+	response, err := NewRequestor(nil).Do(context.Background(), child)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var body []byte
+	response.Decode(&body)
+	if !reflect.DeepEqual(calls, []string{"outer", "inner"}) || string(body) != "outer" {
+		t.Fatalf("unexpected middleware chain %v (body %q)", calls, body)
+	}
+	if len(parent.middlewares) != 1 {
+		t.Fatalf("child middlewares leaked into the parent")
+	}
+}
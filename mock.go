@@ -0,0 +1,312 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrUnexpectedRequest is returned by a Mock when a request does not match any
+// pending expectation.
+var ErrUnexpectedRequest = errors.New("unexpected request")
+
+// TestingT is the subset of testing.T used by Mock.AssertExpectations.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Mock is an http.RoundTripper that replies to requests according to a set of
+// expectations, so that the code building requests can be unit tested without a
+// server:
+//
+//	mock := request.NewMock()
+//	mock.Expect(http.MethodPost, "/users").WithJSONBody(user).Reply(http.StatusCreated, created)
+//	api := request.New("http://api.example.com").WithTransport(mock)
+//	...
+//	mock.AssertExpectations(t)
+//
+// By default, requests can match any pending expectation; in strict mode they
+// must match the expectations in the order they were declared. Requests not
+// matching any expectation fail with ErrUnexpectedRequest. It is safe for
+// concurrent use.
+type Mock struct {
+	lock         sync.Mutex
+	strict       bool
+	expectations []*Expectation
+	unexpected   []string
+}
+
+// NewMock returns a new Mock, with no expectations.
+func NewMock() *Mock {
+	return &Mock{}
+}
+
+// Strict makes the mock require that requests match the expectations in the
+// order they were declared.
+func (m *Mock) Strict() *Mock {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.strict = true
+	return m
+}
+
+// Expect adds an expectation for a request with the given method and URL path
+// (or full URL, without query, if it has a scheme); by default, it is expected
+// once and replied to with an empty 200 response.
+func (m *Mock) Expect(method, path string) *Expectation {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	expectation := &Expectation{
+		mock:   m,
+		method: method,
+		path:   path,
+		times:  1,
+		status: http.StatusOK,
+		header: http.Header{},
+	}
+	m.expectations = append(m.expectations, expectation)
+	return expectation
+}
+
+// RoundTrip replies to the given request according to the first matching
+// pending expectation.
+func (m *Mock) RoundTrip(request *http.Request) (*http.Response, error) {
+	var body []byte
+	if request.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	mismatches := []string{}
+	for _, expectation := range m.expectations {
+		if expectation.calls >= expectation.times {
+			continue
+		}
+		reason := expectation.mismatch(request, body)
+		if reason == "" {
+			expectation.calls++
+			return expectation.reply(request)
+		}
+		mismatches = append(mismatches, fmt.Sprintf("%s: %s", expectation, reason))
+		if m.strict {
+			break
+		}
+	}
+	description := fmt.Sprintf("%s %s", request.Method, request.URL)
+	m.unexpected = append(m.unexpected, description)
+	if len(mismatches) == 0 {
+		return nil, fmt.Errorf("%s: %w (no pending expectations)", description, ErrUnexpectedRequest)
+	}
+	return nil, fmt.Errorf("%s: %w (%s)", description, ErrUnexpectedRequest, strings.Join(mismatches, "; "))
+}
+
+// AssertExpectations reports, via the given testing.T, all the expectations
+// that were not met and all the unexpected requests; it returns whether there
+// were none.
+func (m *Mock) AssertExpectations(t TestingT) bool {
+	t.Helper()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	ok := true
+	for _, expectation := range m.expectations {
+		if expectation.calls < expectation.times {
+			t.Errorf("expectation %s not met: expected %d calls, got %d", expectation, expectation.times, expectation.calls)
+			ok = false
+		}
+	}
+	for _, request := range m.unexpected {
+		t.Errorf("unexpected request %s", request)
+		ok = false
+	}
+	return ok
+}
+
+// Expectation is a request expected by a Mock, along with its reply.
+type Expectation struct {
+	mock       *Mock
+	method     string
+	path       string
+	headers    http.Header
+	parameters map[string]string
+	body       []byte
+	json       interface{}
+	times      int
+	calls      int
+	status     int
+	header     http.Header
+	response   []byte
+	err        error
+}
+
+// String returns a description of the expected request.
+func (e *Expectation) String() string {
+	return fmt.Sprintf("%s %s", e.method, e.path)
+}
+
+// WithHeader requires the request to have the given header value.
+func (e *Expectation) WithHeader(key, value string) *Expectation {
+	e.mock.lock.Lock()
+	defer e.mock.lock.Unlock()
+	if e.headers == nil {
+		e.headers = http.Header{}
+	}
+	e.headers.Add(key, value)
+	return e
+}
+
+// WithQueryParameter requires the request to have the given query parameter
+// value.
+func (e *Expectation) WithQueryParameter(key, value string) *Expectation {
+	e.mock.lock.Lock()
+	defer e.mock.lock.Unlock()
+	if e.parameters == nil {
+		e.parameters = map[string]string{}
+	}
+	e.parameters[key] = value
+	return e
+}
+
+// WithBody requires the request to have exactly the given body.
+func (e *Expectation) WithBody(body string) *Expectation {
+	e.mock.lock.Lock()
+	defer e.mock.lock.Unlock()
+	e.body = []byte(body)
+	return e
+}
+
+// WithJSONBody requires the request to have a JSON body semantically equal to
+// the JSON encoding of the given value.
+func (e *Expectation) WithJSONBody(body interface{}) *Expectation {
+	e.mock.lock.Lock()
+	defer e.mock.lock.Unlock()
+	data, err := json.Marshal(body)
+	if err != nil {
+		panic(fmt.Sprintf("invalid JSON body for expectation %s: %v", e, err))
+	}
+	json.Unmarshal(data, &e.json)
+	return e
+}
+
+// Times sets the number of times the request is expected.
+func (e *Expectation) Times(n int) *Expectation {
+	e.mock.lock.Lock()
+	defer e.mock.lock.Unlock()
+	e.times = n
+	return e
+}
+
+// Reply sets the reply to the request: the body can be a string or a []byte,
+// sent as is, or any other value, sent JSON-encoded (with the corresponding
+// Content-Type, unless set via ReplyHeader).
+func (e *Expectation) Reply(status int, body interface{}) *Expectation {
+	e.mock.lock.Lock()
+	defer e.mock.lock.Unlock()
+	e.status = status
+	switch body := body.(type) {
+	case nil:
+		e.response = nil
+	case string:
+		e.response = []byte(body)
+	case []byte:
+		e.response = body
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			panic(fmt.Sprintf("invalid JSON reply for expectation %s: %v", e, err))
+		}
+		e.response = data
+		if e.header.Get("Content-Type") == "" {
+			e.header.Set("Content-Type", "application/json")
+		}
+	}
+	return e
+}
+
+// ReplyHeader adds a header to the reply.
+func (e *Expectation) ReplyHeader(key, value string) *Expectation {
+	e.mock.lock.Lock()
+	defer e.mock.lock.Unlock()
+	e.header.Add(key, value)
+	return e
+}
+
+// ReplyError makes the request fail with the given transport error.
+func (e *Expectation) ReplyError(err error) *Expectation {
+	e.mock.lock.Lock()
+	defer e.mock.lock.Unlock()
+	e.err = err
+	return e
+}
+
+// mismatch returns why the request does not match the expectation, or an empty
+// string if it does.
+func (e *Expectation) mismatch(request *http.Request, body []byte) string {
+	if e.method != request.Method {
+		return fmt.Sprintf("method is %s", request.Method)
+	}
+	path := request.URL.Path
+	if strings.Contains(e.path, "://") {
+		u := *request.URL
+		u.RawQuery = ""
+		u.Fragment = ""
+		path = u.String()
+	}
+	if path != e.path {
+		return fmt.Sprintf("path is %s", path)
+	}
+	for key, values := range e.headers {
+		if !reflect.DeepEqual(request.Header.Values(key), values) {
+			return fmt.Sprintf("header %s is %q", key, request.Header.Values(key))
+		}
+	}
+	query := request.URL.Query()
+	for key, value := range e.parameters {
+		if query.Get(key) != value {
+			return fmt.Sprintf("query parameter %s is %q", key, query.Get(key))
+		}
+	}
+	if e.body != nil && !bytes.Equal(e.body, body) {
+		return fmt.Sprintf("body is %q", body)
+	}
+	if e.json != nil {
+		var actual interface{}
+		if err := json.Unmarshal(body, &actual); err != nil || !reflect.DeepEqual(actual, e.json) {
+			return fmt.Sprintf("JSON body is %q", body)
+		}
+	}
+	return ""
+}
+
+// reply returns the reply to the given matching request.
+func (e *Expectation) reply(request *http.Request) (*http.Response, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.status, http.StatusText(e.status)),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(e.response)),
+		ContentLength: int64(len(e.response)),
+		Request:       request,
+	}, nil
+}
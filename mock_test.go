@@ -0,0 +1,91 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMock(t *testing.T) {
+	type user struct {
+		ID   int    `json:"id,omitempty"`
+		Name string `json:"name"`
+	}
+	mock := NewMock()
+	mock.Expect(http.MethodPost, "/users").
+		WithHeader("Content-Type", "application/json").
+		WithJSONBody(user{Name: "john"}).
+		Reply(http.StatusCreated, user{ID: 1, Name: "john"})
+	mock.Expect(http.MethodGet, "http://api.example.com/users").
+		WithQueryParameter("page", "2").
+		Reply(http.StatusNotFound, "not found").
+		Times(2)
+
+	api := New("http://api.example.com").WithTransport(mock)
+	requestor := NewRequestor(nil)
+
+	response, err := requestor.Do(context.Background(), api.New(http.MethodPost, "/users").WithJSONEntity(user{Name: "john"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	created := user{}
+	if err := response.Decode(&created); err != nil || created.ID != 1 || response.StatusCode != http.StatusCreated {
+		t.Fatalf("unexpected response: %d %v (%v)", response.StatusCode, created, err)
+	}
+	_, err = requestor.Do(context.Background(), api.New(http.MethodGet, "/users").Add().QueryParameter("page", "2"))
+	if StatusCode(err) != http.StatusNotFound {
+		t.Fatalf("expected 404, got %v", err)
+	}
+	_, err = requestor.Do(context.Background(), api.New(http.MethodDelete, "/users"))
+	if !errors.Is(err, ErrUnexpectedRequest) {
+		t.Fatalf("expected unexpected request, got %v", err)
+	}
+
+	recorder := &recordingT{}
+	if mock.AssertExpectations(recorder) || len(recorder.errors) != 2 {
+		t.Fatalf("expected unmet expectation and unexpected request, got %v", recorder.errors)
+	}
+	requestor.Do(context.Background(), api.New(http.MethodGet, "/users").Add().QueryParameter("page", "2"))
+	recorder = &recordingT{}
+	if mock.AssertExpectations(recorder) || len(recorder.errors) != 1 {
+		t.Fatalf("expected unexpected request only, got %v", recorder.errors)
+	}
+}
+
+func TestMockStrict(t *testing.T) {
+	mock := NewMock().Strict()
+	mock.Expect(http.MethodPost, "/login").Reply(http.StatusOK, `{"token": "abc"}`)
+	mock.Expect(http.MethodGet, "/me").WithHeader("Authorization", "Bearer abc")
+	api := New("http://api.example.com").WithTransport(mock)
+	requestor := NewRequestor(nil)
+
+	if _, err := requestor.Do(context.Background(), api.New(http.MethodGet, "/me").Set().Header("Authorization", "Bearer abc")); !errors.Is(err, ErrUnexpectedRequest) {
+		t.Fatalf("expected out of order request to fail, got %v", err)
+	}
+	if _, err := requestor.Do(context.Background(), api.New(http.MethodPost, "/login")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := requestor.Do(context.Background(), api.New(http.MethodGet, "/me").Set().Header("Authorization", "Bearer abc")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recorder := &recordingT{}
+	if mock.AssertExpectations(recorder) || len(recorder.errors) != 1 {
+		t.Fatalf("expected the out of order request to be reported, got %v", recorder.errors)
+	}
+}
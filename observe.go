@@ -0,0 +1,121 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Observer is notified about the execution of requests, e.g. to collect
+// metrics; its methods are called synchronously by the executing goroutine, so
+// they should be fast.
+type Observer interface {
+	// Started is called when the execution of a request starts.
+	Started(request *http.Request)
+	// Finished is called once the execution of a request is over: right away if
+	// it failed, once the response body is closed otherwise.
+	Finished(request *http.Request, outcome Outcome)
+}
+
+// Outcome describes how the execution of a request went.
+type Outcome struct {
+	// StatusCode is the status code of the response, or 0 if none was received.
+	StatusCode int
+	// Err is the error the execution failed with, if any.
+	Err error
+	// Attempts is the number of times the request was sent to the server (0 if
+	// it was not sent at all, e.g. when served from the cache).
+	Attempts int
+	// FromCache is set when the response was served from the cache.
+	FromCache bool
+	// Duration is the time it took to receive the response headers, or the
+	// execution to fail.
+	Duration time.Duration
+	// Size is the number of bytes read from the response body.
+	Size int64
+	// CompressedSize is the number of bytes read from the response body as
+	// received, if it was decompressed by the Requestor (see Decompress), or
+	// -1.
+	CompressedSize int64
+	// Timings is the breakdown of the time it took to get the response, if
+	// one was received.
+	Timings Timings
+}
+
+// Observe adds observers notified about the execution of requests.
+func (r *Requestor) Observe(observers ...Observer) *Requestor {
+	r.observers = append(r.observers, observers...)
+	return r
+}
+
+// started notifies the observers that the given request is starting.
+func (r *Requestor) started(request *http.Request) {
+	for _, observer := range r.observers {
+		observer.Started(request)
+	}
+}
+
+// finished notifies the observers of the outcome of the given request, once
+// the response body (if any) is closed.
+func (r *Requestor) finished(request *http.Request, response *Response, err error, started time.Time) {
+	if len(r.observers) == 0 {
+		return
+	}
+	outcome := Outcome{
+		StatusCode:     StatusCode(err),
+		Err:            err,
+		Duration:       time.Since(started),
+		CompressedSize: -1,
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		outcome.Attempts = httpErr.Attempts
+	}
+	notify := func() {
+		for _, observer := range r.observers {
+			observer.Finished(request, outcome)
+		}
+	}
+	if response == nil || response.Body == nil {
+		notify()
+		return
+	}
+	outcome.StatusCode = response.StatusCode
+	outcome.Attempts = response.Attempts
+	outcome.FromCache = response.FromCache
+	outcome.Timings = response.Timings
+	response.Body = &countingBody{ReadCloser: response.Body, done: func(n int64) {
+		outcome.Size = n
+		outcome.CompressedSize = response.CompressedSize()
+		notify()
+	}}
+}
+
+// countingBody is a response body that counts the bytes read from it, and
+// reports them when closed.
+type countingBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func(n int64)
+}
+
+// Read reads from the body, counting the bytes.
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// Close closes the body and reports the bytes read.
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.n) })
+	return err
+}
@@ -0,0 +1,61 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type recordingObserver struct {
+	started  int
+	outcomes []Outcome
+}
+
+func (o *recordingObserver) Started(request *http.Request) {
+	o.started++
+}
+
+func (o *recordingObserver) Finished(request *http.Request, outcome Outcome) {
+	o.outcomes = append(o.outcomes, outcome)
+}
+
+func TestObserve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("hello world"))
+	}))
+	defer server.Close()
+
+	observer := &recordingObserver{}
+	requestor := NewRequestor(nil).Observe(observer)
+	response, err := requestor.Do(context.Background(), New(server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if observer.started != 1 || len(observer.outcomes) != 0 {
+		t.Fatalf("expected the request to be finished once its body is closed")
+	}
+	var body []byte
+	response.Decode(&body)
+	if len(observer.outcomes) != 1 {
+		t.Fatalf("expected 1 outcome, got %d", len(observer.outcomes))
+	}
+	if outcome := observer.outcomes[0]; outcome.StatusCode != 200 || outcome.Size != 11 || outcome.Attempts != 1 || outcome.Err != nil {
+		t.Fatalf("unexpected outcome %+v", outcome)
+	}
+
+	if _, err := requestor.Do(context.Background(), New(server.URL+"/fail")); err == nil {
+		t.Fatalf("expected error")
+	}
+	if outcome := observer.outcomes[1]; outcome.StatusCode != http.StatusBadGateway || outcome.Err == nil || outcome.Attempts != 1 {
+		t.Fatalf("unexpected outcome %+v", outcome)
+	}
+}
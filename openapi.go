@@ -0,0 +1,443 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIOptions controls how an OpenAPI document is imported.
+type OpenAPIOptions struct {
+	// Unmarshal decodes the document, e.g. yaml.Unmarshal for YAML documents;
+	// if nil, the document is decoded as JSON.
+	Unmarshal func(data []byte, v interface{}) error
+	// Server is the base URL of the API; if empty, the first server in the
+	// document is used.
+	Server string
+	// Validate makes the builders check the requests they generate against the
+	// document before Make returns them.
+	Validate bool
+}
+
+type openAPIParameter struct {
+	Ref      string      `json:"$ref"`
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required"`
+	Schema   interface{} `json:"schema"`
+}
+
+type openAPIMediaType struct {
+	Schema interface{} `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Ref      string                      `json:"$ref"`
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Ref     string                      `json:"$ref"`
+	Content map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Parameters  []*openAPIParameter        `json:"parameters"`
+	RequestBody *openAPIRequestBody        `json:"requestBody"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIPathItem struct {
+	Parameters []*openAPIParameter `json:"parameters"`
+	Get        *openAPIOperation   `json:"get"`
+	Put        *openAPIOperation   `json:"put"`
+	Post       *openAPIOperation   `json:"post"`
+	Delete     *openAPIOperation   `json:"delete"`
+	Options    *openAPIOperation   `json:"options"`
+	Head       *openAPIOperation   `json:"head"`
+	Patch      *openAPIOperation   `json:"patch"`
+	Trace      *openAPIOperation   `json:"trace"`
+}
+
+func (p *openAPIPathItem) operations() map[string]*openAPIOperation {
+	return map[string]*openAPIOperation{
+		http.MethodGet:     p.Get,
+		http.MethodPut:     p.Put,
+		http.MethodPost:    p.Post,
+		http.MethodDelete:  p.Delete,
+		http.MethodOptions: p.Options,
+		http.MethodHead:    p.Head,
+		http.MethodPatch:   p.Patch,
+		http.MethodTrace:   p.Trace,
+	}
+}
+
+type openAPIDocument struct {
+	OpenAPI string `json:"openapi"`
+	Servers []struct {
+		URL       string `json:"url"`
+		Variables map[string]struct {
+			Default string `json:"default"`
+		} `json:"variables"`
+	} `json:"servers"`
+	Paths map[string]*openAPIPathItem `json:"paths"`
+}
+
+// ImportOpenAPI reads an OpenAPI 3 document and returns a Builder for each
+// operation having an operationId, keyed by it: the builders have the method
+// and URL of the operation (with its path parameters as URL variables, as in
+// "/users/{id}"), the default values of the query and header parameters, the
+// Content-Type of the request body (JSON if available) and an Accept header
+// listing the media types of the successful responses. If validation is
+// enabled, Make fails with ErrInvalidRequest if a required parameter or body is
+// missing, or if a parameter or JSON body does not match its schema.
+func ImportOpenAPI(document io.Reader, options *OpenAPIOptions) (map[string]*Builder, error) {
+	if options == nil {
+		options = &OpenAPIOptions{}
+	}
+	unmarshal := options.Unmarshal
+	if unmarshal == nil {
+		unmarshal = json.Unmarshal
+	}
+	data, err := ioutil.ReadAll(document)
+	if err != nil {
+		return nil, err
+	}
+	// decode generically first, so that any decoder can be used and references
+	// can be resolved against the raw document
+	var root interface{}
+	if err := unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	if data, err = json.Marshal(root); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	doc := openAPIDocument{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q", doc.OpenAPI)
+	}
+
+	server := options.Server
+	if server == "" && len(doc.Servers) > 0 {
+		server = doc.Servers[0].URL
+		for name, variable := range doc.Servers[0].Variables {
+			server = strings.Replace(server, "{"+name+"}", variable.Default, -1)
+		}
+	}
+	server = strings.TrimSuffix(server, "/")
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	builders := map[string]*Builder{}
+	for _, path := range paths {
+		item := doc.Paths[path]
+		for method, operation := range item.operations() {
+			if operation == nil || operation.OperationID == "" {
+				continue
+			}
+			if _, ok := builders[operation.OperationID]; ok {
+				return nil, fmt.Errorf("duplicate OpenAPI operation %q", operation.OperationID)
+			}
+			op, err := newOpenAPIOperation(root, method, path, item.Parameters, operation)
+			if err != nil {
+				return nil, fmt.Errorf("error importing OpenAPI operation %q: %w", operation.OperationID, err)
+			}
+			f := op.builder(server)
+			if options.Validate {
+				f.Validate(op.validate)
+			}
+			builders[operation.OperationID] = f
+		}
+	}
+	return builders, nil
+}
+
+// openAPI is an operation, with all its references resolved.
+type openAPI struct {
+	id          string
+	method      string
+	path        string
+	parameters  []*openAPIParameter
+	body        *openAPIRequestBody
+	accept      []string
+	contentType string
+	template    *regexp.Regexp
+	variables   []string
+	schemas     *schemaValidator
+}
+
+func newOpenAPIOperation(root interface{}, method, path string, shared []*openAPIParameter, operation *openAPIOperation) (*openAPI, error) {
+	op := &openAPI{
+		id:      operation.OperationID,
+		method:  method,
+		path:    path,
+		schemas: &schemaValidator{root: root},
+	}
+	// operation parameters override the path item ones with the same name and location
+	parameters := map[string]*openAPIParameter{}
+	keys := []string{}
+	for _, list := range [][]*openAPIParameter{shared, operation.Parameters} {
+		for _, parameter := range list {
+			if parameter.Ref != "" {
+				if err := resolveInto(root, parameter.Ref, &parameter); err != nil {
+					return nil, err
+				}
+			}
+			key := parameter.In + ":" + parameter.Name
+			if _, ok := parameters[key]; !ok {
+				keys = append(keys, key)
+			}
+			parameters[key] = parameter
+		}
+	}
+	for _, key := range keys {
+		op.parameters = append(op.parameters, parameters[key])
+	}
+
+	if body := operation.RequestBody; body != nil {
+		if body.Ref != "" {
+			if err := resolveInto(root, body.Ref, &body); err != nil {
+				return nil, err
+			}
+		}
+		op.body = body
+		types := make([]string, 0, len(body.Content))
+		for contentType := range body.Content {
+			types = append(types, contentType)
+		}
+		sort.Strings(types)
+		for _, contentType := range types {
+			if isJSON(contentType) {
+				op.contentType = contentType
+				break
+			}
+		}
+		if op.contentType == "" && len(types) > 0 {
+			op.contentType = types[0]
+		}
+	}
+
+	accept := map[string]bool{}
+	for status, response := range operation.Responses {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		if response.Ref != "" {
+			if err := resolveInto(root, response.Ref, &response); err != nil {
+				return nil, err
+			}
+		}
+		for contentType := range response.Content {
+			if !accept[contentType] {
+				accept[contentType] = true
+				op.accept = append(op.accept, contentType)
+			}
+		}
+	}
+	sort.Strings(op.accept)
+
+	expression := regexp.QuoteMeta(path)
+	for _, match := range regexp.MustCompile(`\{([^}]+)\}`).FindAllStringSubmatch(path, -1) {
+		op.variables = append(op.variables, match[1])
+		expression = strings.Replace(expression, regexp.QuoteMeta(match[0]), `([^/]*)`, 1)
+	}
+	template, err := regexp.Compile(expression + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template %q: %w", path, err)
+	}
+	op.template = template
+	return op, nil
+}
+
+// resolveInto resolves the given reference in the raw document into v.
+func resolveInto(root interface{}, ref string, v interface{}) error {
+	node, err := pointer(root, ref)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// builder returns a Builder pre-wired for the operation.
+func (op *openAPI) builder(server string) *Builder {
+	f := New(server + op.path).Method(op.method)
+	for _, parameter := range op.parameters {
+		schema, _ := op.resolve(parameter.Schema).(map[string]interface{})
+		defaultValue, ok := schema["default"]
+		if !ok {
+			continue
+		}
+		switch parameter.In {
+		case "query":
+			f.SetQueryParameter(parameter.Name, fmt.Sprintf("%v", defaultValue))
+		case "header":
+			f.SetHeader(parameter.Name, fmt.Sprintf("%v", defaultValue))
+		}
+	}
+	if len(op.accept) > 0 {
+		f.SetHeader("Accept", strings.Join(op.accept, ", "))
+	}
+	if op.contentType != "" {
+		f.ContentType(op.contentType)
+	}
+	return f
+}
+
+// resolve follows the references in the given schema.
+func (op *openAPI) resolve(schema interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		s, ok := schema.(map[string]interface{})
+		if !ok {
+			return schema
+		}
+		ref, ok := s["$ref"].(string)
+		if !ok {
+			return schema
+		}
+		resolved, err := pointer(op.schemas.root, ref)
+		if err != nil {
+			return nil
+		}
+		schema = resolved
+	}
+	return schema
+}
+
+// validate checks the given request against the operation.
+func (op *openAPI) validate(request *http.Request, body []byte) error {
+	violations := []string{}
+	path := map[string]string{}
+	if match := op.template.FindStringSubmatch(request.URL.Path); match != nil {
+		for i, name := range op.variables {
+			path[name] = match[i+1]
+		}
+	}
+	query := request.URL.Query()
+	for _, parameter := range op.parameters {
+		var values []string
+		required := parameter.Required
+		switch parameter.In {
+		case "path":
+			if value, ok := path[parameter.Name]; ok && value != "" && value != "{"+parameter.Name+"}" {
+				values = []string{value}
+			}
+			required = true
+		case "query":
+			values = query[parameter.Name]
+		case "header":
+			values = request.Header.Values(parameter.Name)
+		default:
+			continue
+		}
+		location := fmt.Sprintf("%s parameter %q", parameter.In, parameter.Name)
+		if len(values) == 0 {
+			if required {
+				violations = append(violations, location+": missing required value")
+			}
+			continue
+		}
+		schema := op.resolve(parameter.Schema)
+		if s, ok := schema.(map[string]interface{}); ok && s["type"] == "array" {
+			items := []interface{}{}
+			for _, value := range values {
+				for _, item := range strings.Split(value, ",") {
+					items = append(items, convert(item, op.resolve(s["items"])))
+				}
+			}
+			violations = append(violations, messages(op.schemas.validate(items, schema, location))...)
+			continue
+		}
+		for _, value := range values {
+			violations = append(violations, messages(op.schemas.validate(convert(value, schema), schema, location))...)
+		}
+	}
+
+	if op.body != nil {
+		if len(body) == 0 {
+			if op.body.Required {
+				violations = append(violations, "missing required body")
+			}
+		} else if media, ok := op.media(request.Header.Get("Content-Type")); !ok {
+			violations = append(violations, fmt.Sprintf("unexpected Content-Type %q", request.Header.Get("Content-Type")))
+		} else if media.Schema != nil && isJSON(request.Header.Get("Content-Type")) {
+			var value interface{}
+			if err := json.Unmarshal(body, &value); err != nil {
+				violations = append(violations, fmt.Sprintf("invalid JSON body: %v", err))
+			} else {
+				violations = append(violations, messages(op.schemas.validate(value, media.Schema, "$"))...)
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("%s %s: %w for operation %q: %s", request.Method, RedactURL(request.URL), ErrInvalidRequest, op.id, strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// media returns the media type of the request body matching the given
+// Content-Type, taking wildcards into account.
+func (op *openAPI) media(contentType string) (openAPIMediaType, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return openAPIMediaType{}, false
+	}
+	candidates := []string{mediaType, strings.SplitN(mediaType, "/", 2)[0] + "/*", "*/*"}
+	for _, candidate := range candidates {
+		for declared, media := range op.body.Content {
+			if declared, _, err := mime.ParseMediaType(declared); err == nil && declared == candidate {
+				return media, true
+			}
+		}
+	}
+	return openAPIMediaType{}, false
+}
+
+// convert converts a parameter value to the type required by its schema, so
+// that it can be validated.
+func convert(value string, schema interface{}) interface{} {
+	s, _ := schema.(map[string]interface{})
+	switch s["type"] {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// isJSON returns whether the given media type is JSON.
+func isJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,130 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const document = `{
+	"openapi": "3.0.3",
+	"servers": [{"url": "https://{region}.example.com/v1", "variables": {"region": {"default": "eu"}}}],
+	"paths": {
+		"/users/{id}": {
+			"parameters": [{"$ref": "#/components/parameters/UserID"}],
+			"get": {
+				"operationId": "getUser",
+				"parameters": [
+					{"name": "fields", "in": "query", "schema": {"type": "string", "enum": ["name", "email"], "default": "name"}},
+					{"name": "X-Tenant", "in": "header", "required": true, "schema": {"type": "string"}}
+				],
+				"responses": {
+					"200": {"content": {"application/json": {}, "application/xml": {}}},
+					"404": {"content": {"application/problem+json": {}}}
+				}
+			}
+		},
+		"/users": {
+			"post": {
+				"operationId": "createUser",
+				"requestBody": {
+					"required": true,
+					"content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}
+				},
+				"responses": {"201": {"content": {"application/json": {}}}}
+			}
+		}
+	},
+	"components": {
+		"parameters": {
+			"UserID": {"name": "id", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 1}}
+		},
+		"schemas": {
+			"User": {
+				"type": "object",
+				"required": ["name"],
+				"additionalProperties": false,
+				"properties": {
+					"name": {"type": "string", "minLength": 1},
+					"role": {"type": "string", "enum": ["admin", "user"]},
+					"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2}
+				}
+			}
+		}
+	}
+}`
+
+func TestImportOpenAPI(t *testing.T) {
+	builders, err := ImportOpenAPI(strings.NewReader(document), &OpenAPIOptions{Validate: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(builders) != 2 {
+		t.Fatalf("expected 2 builders, got %v", builders)
+	}
+
+	request, err := builders["getUser"].New("", "").
+		Set().Variable("id", 42).
+		Set().Header("X-Tenant", "acme").
+		Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.Method != http.MethodGet || request.URL.String() != "https://eu.example.com/v1/users/42?fields=name" {
+		t.Fatalf("unexpected request: %s %s", request.Method, request.URL)
+	}
+	if request.Header.Get("Accept") != "application/json, application/xml" {
+		t.Fatalf("unexpected Accept header: %v", request.Header)
+	}
+
+	invalid := []struct {
+		builder   *Builder
+		violation string
+	}{
+		{builders["getUser"].New("", "").Set().Header("X-Tenant", "acme"), `path parameter "id": missing required value`},
+		{builders["getUser"].New("", "").Set().Variable("id", 0).Set().Header("X-Tenant", "acme"), "value 0 less than 1"},
+		{builders["getUser"].New("", "").Set().Variable("id", "abc").Set().Header("X-Tenant", "acme"), "expected integer, got string"},
+		{builders["getUser"].New("", "").Set().Variable("id", 1), `header parameter "X-Tenant": missing required value`},
+		{builders["getUser"].New("", "").Set().Variable("id", 1).Set().Header("X-Tenant", "a").Set().QueryParameter("fields", "age"), `value "age" not in ["name","email"]`},
+		{builders["createUser"].New("", ""), "missing required body"},
+		{builders["createUser"].New("", "").WithEntity(strings.NewReader(`{"role": "root", "tags": ["a", "b", "c"], "x": 1}`)), `$: missing required property "name"`},
+		{builders["createUser"].New("", "").WithEntity(strings.NewReader(`{"name": "john", "role": "root"}`)), `$.role: value "root" not in`},
+		{builders["createUser"].New("", "").WithEntity(strings.NewReader(`{"name": "john", "x": 1}`)), `unexpected property "x"`},
+		{builders["createUser"].New("", "").WithEntity(strings.NewReader(`{"name": "john"}`)).ContentType("text/plain"), `unexpected Content-Type "text/plain"`},
+	}
+	for _, test := range invalid {
+		_, err := test.builder.Make()
+		if !errors.Is(err, ErrInvalidRequest) || !strings.Contains(err.Error(), test.violation) {
+			t.Fatalf("expected %q, got %v", test.violation, err)
+		}
+	}
+
+	request, err = builders["createUser"].New("", "").WithJSONEntity(struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}{"john", []string{"a"}}).Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.Header.Get("Content-Type") != "application/json" || request.GetBody == nil {
+		t.Fatalf("unexpected request: %v", request.Header)
+	}
+}
+
+func TestImportOpenAPIErrors(t *testing.T) {
+	if _, err := ImportOpenAPI(strings.NewReader(`{"swagger": "2.0"}`), nil); err == nil {
+		t.Fatalf("expected error on Swagger 2.0 document")
+	}
+	builders, err := ImportOpenAPI(strings.NewReader(document), &OpenAPIOptions{Server: "http://localhost:8080/"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request, err := builders["createUser"].Make(); err != nil || request.URL.String() != "http://localhost:8080/users" {
+		t.Fatalf("expected unvalidated request to the given server, got %v (%v)", request, err)
+	}
+}
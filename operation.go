@@ -0,0 +1,158 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// ErrUnexpectedStatus is returned when the status code of the response to an
+// operation is not among the expected ones.
+var ErrUnexpectedStatus = errors.New("unexpected status")
+
+// Operation describes an API operation (e.g. "CreateUser"), so that large API
+// clients get consistent behaviour and observability across operations: the
+// requests sent via Do carry the operation in their context (see
+// OperationFrom), which the metrics and tracing integrations use to label
+// them, and errors are mapped per operation. Operations are also what the
+// clients emitted by the code generator are built on.
+type Operation struct {
+	// Name identifies the operation, e.g. "CreateUser".
+	Name string
+	// Description documents the operation.
+	Description string
+	// Method and Path are the method and the path (with its variables, e.g.
+	// "/users/{id}") of the operation requests, relative to the base Builder.
+	Method string
+	Path   string
+	// Expect lists the expected 2xx status codes (any, if empty); the other
+	// ones make Do fail with ErrUnexpectedStatus.
+	Expect []int
+	// Response is a value of the type of the response body (e.g. User{}), used
+	// by Call to decode it.
+	Response interface{}
+	// Errors maps the status codes of the failed responses to the errors the
+	// operation should fail with (e.g. http.StatusNotFound to ErrUserNotFound).
+	Errors map[int]error
+}
+
+// OperationError is the error returned when an operation fails.
+type OperationError struct {
+	// Operation is the name of the operation.
+	Operation string
+	// Mapped is the error the status code is mapped to by the operation, if
+	// any.
+	Mapped error
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns a description of the error.
+func (e *OperationError) Error() string {
+	if e.Mapped != nil {
+		return fmt.Sprintf("%s: %v (%v)", e.Operation, e.Mapped, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Operation, e.Err)
+}
+
+// Unwrap returns the mapped and the underlying errors, so that errors.Is and
+// errors.As see both.
+func (e *OperationError) Unwrap() []error {
+	if e.Mapped != nil {
+		return []error{e.Mapped, e.Err}
+	}
+	return []error{e.Err}
+}
+
+// operationKey is the context key of the operation of a request.
+type operationKey struct{}
+
+// OperationFrom returns the operation the given context (e.g. that of a
+// request) belongs to, if any.
+func OperationFrom(ctx context.Context) (*Operation, bool) {
+	operation, ok := ctx.Value(operationKey{}).(*Operation)
+	return operation, ok
+}
+
+// Builder returns a child of the given base Builder, generating the requests of
+// the operation; it can then be customised, e.g. to set the path variables.
+func (o *Operation) Builder(base *Builder) *Builder {
+	return base.New(o.Method, o.Path).Tag("operation", o.Name)
+}
+
+// Do sends the request generated by the given Builder (see Builder) as the
+// operation, and decodes the response into the given target, which can be nil
+// to discard it; the response is returned with its body already closed.
+func (o *Operation) Do(ctx context.Context, r *Requestor, f *Builder, target interface{}) (*Response, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx = context.WithValue(ctx, operationKey{}, o)
+	response, err := r.Do(ctx, f)
+	if err != nil {
+		return nil, o.fail(err)
+	}
+	if !o.expected(response.StatusCode) {
+		response.Body.Close()
+		return nil, o.fail(fmt.Errorf("%w %s", ErrUnexpectedStatus, response.Status))
+	}
+	if err := response.Decode(target); err != nil {
+		return nil, o.fail(err)
+	}
+	return response, nil
+}
+
+// Call is like Do, but decodes the response into a new value of the type of
+// Response, and returns a pointer to it (nil if Response is not set).
+func (o *Operation) Call(ctx context.Context, r *Requestor, f *Builder) (interface{}, error) {
+	var target interface{}
+	if o.Response != nil {
+		target = reflect.New(reflect.TypeOf(o.Response)).Interface()
+	}
+	if _, err := o.Do(ctx, r, f, target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// String returns the operation name, method and path.
+func (o *Operation) String() string {
+	return fmt.Sprintf("%s (%s %s)", o.Name, o.Method, o.Path)
+}
+
+// expected returns whether the given status code is expected.
+func (o *Operation) expected(status int) bool {
+	if len(o.Expect) == 0 {
+		return true
+	}
+	for _, code := range o.Expect {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// fail wraps the given error into an OperationError, mapping its status code.
+func (o *Operation) fail(err error) error {
+	e := &OperationError{Operation: o.Name, Err: err}
+	if code := StatusCode(err); code != 0 {
+		e.Mapped = o.Errors[code]
+	}
+	return e
+}
+
+// OperationName returns the name of the operation of the given request, or an
+// empty string if it is not part of one.
+func OperationName(request *http.Request) string {
+	if operation, ok := OperationFrom(request.Context()); ok {
+		return operation.Name
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOperation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/1":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id": 1, "name": "alice"}`))
+		case "/users":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	type user struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	errNotFound := errors.New("user not found")
+	get := &Operation{
+		Name:     "GetUser",
+		Method:   http.MethodGet,
+		Path:     "/users/{id}",
+		Response: user{},
+		Errors:   map[int]error{http.StatusNotFound: errNotFound},
+	}
+
+	var operations []string
+	requestor := NewRequestor(nil).OnRequest(func(request *http.Request) {
+		operations = append(operations, OperationName(request))
+	})
+	api := New(server.URL)
+
+	f := get.Builder(api)
+	f.Set().Variable("id", "1")
+	result, err := get.Call(context.Background(), requestor, f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u, ok := result.(*user); !ok || u.ID != 1 || u.Name != "alice" {
+		t.Fatalf("unexpected result %#v", result)
+	}
+	if f.tags["operation"] != "GetUser" {
+		t.Fatalf("expected the builder to be tagged with the operation, got %v", f.tags)
+	}
+
+	f = get.Builder(api)
+	f.Set().Variable("id", "2")
+	_, err = get.Do(context.Background(), requestor, f, &user{})
+	if !errors.Is(err, errNotFound) || StatusCode(err) != http.StatusNotFound {
+		t.Fatalf("expected mapped not found error, got %v", err)
+	}
+	var operationErr *OperationError
+	if !errors.As(err, &operationErr) || operationErr.Operation != "GetUser" || !IsHTTPError(err) {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	create := &Operation{Name: "CreateUser", Method: http.MethodPost, Path: "/users", Expect: []int{http.StatusCreated}}
+	_, err = create.Do(context.Background(), requestor, create.Builder(api), nil)
+	if !errors.Is(err, ErrUnexpectedStatus) {
+		t.Fatalf("expected unexpected status error, got %v", err)
+	}
+
+	if len(operations) != 3 || operations[0] != "GetUser" || operations[2] != "CreateUser" {
+		t.Fatalf("unexpected operations %v", operations)
+	}
+	if _, err := requestor.Do(context.Background(), api.New(http.MethodGet, "/users/1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if operations[3] != "" {
+		t.Fatalf("expected no operation, got %q", operations[3])
+	}
+}
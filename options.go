@@ -0,0 +1,93 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+// When applies the given function to the builder if the condition holds, so
+// that optional settings can stay within the fluent chain:
+//
+//	f := api.New(http.MethodGet, "users").
+//		When(filter != "", func(f *request.Builder) { f.SetQueryParameter("filter", filter) })
+func (f *Builder) When(condition bool, apply func(f *Builder)) *Builder {
+	if condition {
+		apply(f)
+	}
+	return f
+}
+
+// Unless applies the given function to the builder if the condition does not
+// hold.
+func (f *Builder) Unless(condition bool, apply func(f *Builder)) *Builder {
+	return f.When(!condition, apply)
+}
+
+// Option is a reusable setting of a Builder, so that teams can compose their
+// standard request policies and share them across services, e.g.
+//
+//	func CompanyAuth() request.Option {
+//		return request.Options(
+//			request.BearerAuth("env:COMPANY_TOKEN"),
+//			request.Traced(),
+//		)
+//	}
+//
+//	api := request.New(url).Apply(request.JSONAccept(), CompanyAuth())
+type Option func(f *Builder)
+
+// Apply applies the given options to the builder, in order.
+func (f *Builder) Apply(options ...Option) *Builder {
+	for _, option := range options {
+		if option != nil {
+			option(f)
+		}
+	}
+	return f
+}
+
+// Options returns an Option applying the given options, in order.
+func Options(options ...Option) Option {
+	return func(f *Builder) {
+		f.Apply(options...)
+	}
+}
+
+// JSONAccept returns an Option asking for JSON responses.
+func JSONAccept() Option {
+	return func(f *Builder) {
+		f.SetHeader("Accept", "application/json")
+	}
+}
+
+// JSONContent returns an Option declaring JSON request bodies.
+func JSONContent() Option {
+	return func(f *Builder) {
+		f.ContentType("application/json")
+	}
+}
+
+// Traced returns an Option giving each request its own ID (see WithRequestID)
+// and propagating the correlation ID of its context in the default header (see
+// PropagateCorrelationID).
+func Traced() Option {
+	return func(f *Builder) {
+		f.WithRequestID(nil).PropagateCorrelationID("", nil)
+	}
+}
+
+// BearerAuth returns an Option authenticating the requests with the given
+// bearer token, which can be a reference such as "env:NAME" (see Credentials).
+func BearerAuth(token string) Option {
+	return func(f *Builder) {
+		f.Credentials(&Credentials{Token: token})
+	}
+}
+
+// BasicAuth returns an Option authenticating the requests with the given
+// username and password, which can be references such as "file:PATH" (see
+// Credentials).
+func BasicAuth(username, password string) Option {
+	return func(f *Builder) {
+		f.Credentials(&Credentials{Username: username, Password: password})
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"os"
+	"testing"
+)
+
+func TestWhenUnless(t *testing.T) {
+	for _, filter := range []string{"", "active"} {
+		request, err := New("https://api.example.com/users").
+			When(filter != "", func(f *Builder) { f.QueryParameter("filter", filter) }).
+			Unless(filter != "", func(f *Builder) { f.QueryParameter("all", "true") }).
+			Make()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		query := request.URL.Query()
+		if query.Get("filter") != filter || (query.Get("all") == "true") != (filter == "") {
+			t.Fatalf("unexpected query %v for filter %q", query, filter)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	os.Setenv("TEST_OPTIONS_TOKEN", "secret")
+	defer os.Unsetenv("TEST_OPTIONS_TOKEN")
+	companyAuth := func() Option {
+		return Options(BearerAuth("env:TEST_OPTIONS_TOKEN"), Traced())
+	}
+	api := New("https://api.example.com/").Apply(JSONAccept(), JSONContent(), companyAuth(), nil)
+	request, err := api.New("", "users").Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.Header.Get("Accept") != "application/json" || request.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected headers %v", request.Header)
+	}
+	if request.Header.Get("Authorization") != "Bearer secret" || request.Header.Get(RequestIDHeader) == "" {
+		t.Fatalf("unexpected headers %v", request.Header)
+	}
+	request, err = New("https://api.example.com/").Apply(BasicAuth("user", "password")).Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if username, password, ok := request.BasicAuth(); !ok || username != "user" || password != "password" {
+		t.Fatalf("unexpected credentials %v", request.Header)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package oteltrace traces the requests sent via the request package with
+// OpenTelemetry; it lives in its own package so that the OpenTelemetry API is
+// only pulled in by those who need it. The middleware is inherited by child
+// builders, so it is enough to install it on the root one:
+//
+//	api := request.New("https://api.example.com").Use(oteltrace.Middleware(nil))
+package oteltrace
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	request "github.com/dihedron/go-requestor"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation is the name of the tracer.
+const instrumentation = "github.com/dihedron/go-requestor/oteltrace"
+
+// Options configures the tracing; the zero value is usable.
+type Options struct {
+	// TracerProvider provides the tracer (default the global one).
+	TracerProvider trace.TracerProvider
+	// Propagator injects the trace context into the request headers (default
+	// the global one, which should include the W3C TraceContext propagator for
+	// traceparent and tracestate to be sent).
+	Propagator propagation.TextMapPropagator
+	// SpanName returns the name of the span of the given request (default the
+	// name of the request.Operation the request is part of, if any, or "HTTP"
+	// followed by the method).
+	SpanName func(req *http.Request) string
+	// Attributes are added to all the spans.
+	Attributes []attribute.KeyValue
+}
+
+// Middleware returns a request middleware starting a client span for each
+// request sent, as a child of the span in the request context (if any); the
+// span records the method, URL, status code and response body size, and ends
+// when the response body is closed. The options can be nil.
+func Middleware(options *Options) request.Middleware {
+	if options == nil {
+		options = &Options{}
+	}
+	provider := options.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	propagator := options.Propagator
+	if propagator == nil {
+		propagator = otel.GetTextMapPropagator()
+	}
+	name := options.SpanName
+	if name == nil {
+		name = func(req *http.Request) string {
+			if operation := request.OperationName(req); operation != "" {
+				return operation
+			}
+			return "HTTP " + req.Method
+		}
+	}
+	tracer := provider.Tracer(instrumentation)
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &transport{
+			next:       next,
+			tracer:     tracer,
+			propagator: propagator,
+			name:       name,
+			attributes: options.Attributes,
+		}
+	}
+}
+
+// transport is the tracing http.RoundTripper.
+type transport struct {
+	next       http.RoundTripper
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	name       func(req *http.Request) string
+	attributes []attribute.KeyValue
+}
+
+// RoundTrip sends the request within a client span.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attributes := append([]attribute.KeyValue{
+		attribute.String("http.request.method", req.Method),
+		attribute.String("url.full", redacted(req)),
+		attribute.String("server.address", req.URL.Hostname()),
+	}, t.attributes...)
+	if port := req.URL.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err == nil {
+			attributes = append(attributes, attribute.Int("server.port", n))
+		}
+	}
+	ctx, span := t.tracer.Start(req.Context(), t.name(req),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...))
+	// round trippers must not modify the request
+	req = req.Clone(ctx)
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	response, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", response.StatusCode))
+	if response.StatusCode >= 400 {
+		span.SetStatus(codes.Error, response.Status)
+	}
+	if response.Body == nil || response.Body == http.NoBody {
+		span.End()
+		return response, nil
+	}
+	response.Body = &body{ReadCloser: response.Body, span: span}
+	return response, nil
+}
+
+// redacted returns the URL of the request without credentials.
+func redacted(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+	return u.String()
+}
+
+// body is a response body ending its span when closed.
+type body struct {
+	io.ReadCloser
+	span trace.Span
+	n    int64
+	once sync.Once
+}
+
+// Read reads from the body, counting the bytes.
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err != nil && err != io.EOF {
+		b.span.RecordError(err)
+	}
+	return n, err
+}
+
+// Close closes the body and ends the span.
+func (b *body) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.span.SetAttributes(attribute.Int64("http.response.body.size", b.n))
+		b.span.End()
+	})
+	return err
+}
@@ -0,0 +1,81 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package oteltrace
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	request "github.com/dihedron/go-requestor"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMiddleware(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	api := request.New(server.URL).Use(Middleware(&Options{
+		TracerProvider: provider,
+		Propagator:     propagation.TraceContext{},
+	}))
+	requestor := request.NewRequestor(nil)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	response, err := requestor.Do(ctx, api.New("", "/hello"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recorder.Ended()) != 0 {
+		t.Fatalf("expected the span to end when the body is closed")
+	}
+	var data []byte
+	response.Decode(&data)
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "HTTP GET" || span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("unexpected span %q with parent %v", span.Name(), span.Parent())
+	}
+	if !strings.Contains(traceparent, parent.SpanContext().TraceID().String()) || !strings.Contains(traceparent, span.SpanContext().SpanID().String()) {
+		t.Fatalf("unexpected traceparent %q", traceparent)
+	}
+	attributes := map[attribute.Key]attribute.Value{}
+	for _, a := range span.Attributes() {
+		attributes[a.Key] = a.Value
+	}
+	if attributes["http.response.status_code"].AsInt64() != 200 || attributes["http.response.body.size"].AsInt64() != 5 || attributes["url.full"].AsString() != server.URL+"/hello" {
+		t.Fatalf("unexpected attributes %v", attributes)
+	}
+
+	// the middleware is inherited by child builders
+	if _, err := requestor.Do(context.Background(), api.New("", "/missing").New("", "")); err == nil {
+		t.Fatalf("expected error")
+	}
+	spans = recorder.Ended()
+	if len(spans) != 3 || spans[2].Status().Code != codes.Error {
+		t.Fatalf("expected a failed span, got %d spans", len(spans))
+	}
+}
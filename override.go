@@ -0,0 +1,245 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Override is a setting inherited by a builder and then changed or removed by
+// it or by one of its ancestors.
+type Override struct {
+	// Level is the depth of the builder that overrode the setting, 1 being the
+	// first child of the builder on which tracking was enabled.
+	Level int
+	// Setting is the overridden setting, e.g. "method", "url", "body",
+	// "header Accept", "query parameter page" or "variable id".
+	Setting string
+	// From is the inherited value.
+	From string
+	// To is the new value, or empty if the setting was removed.
+	To string
+	// Removed is whether the setting was removed.
+	Removed bool
+}
+
+// String returns a description of the override.
+func (o Override) String() string {
+	if o.Removed {
+		return fmt.Sprintf("level %d: %s removed (was %s)", o.Level, o.Setting, o.From)
+	}
+	return fmt.Sprintf("level %d: %s overridden: %s => %s", o.Level, o.Setting, o.From, o.To)
+}
+
+// lineage is the configuration of a builder at the time a child was created
+// from it, along with that of its ancestors.
+type lineage struct {
+	parent     *lineage
+	method     string
+	url        string
+	headers    http.Header
+	parameters url.Values
+	variables  map[string]string
+	body       io.Reader
+}
+
+// TrackOverrides makes the children created from this builder afterwards (and
+// their own children) keep track of which inherited settings they override, so
+// that deep builder hierarchies can be debugged via Overrides() and Describe().
+func (f *Builder) TrackOverrides() *Builder {
+	if f.lineage == nil {
+		f.lineage = &lineage{}
+	}
+	return f
+}
+
+// inherit returns the lineage of a child of this builder, which must be
+// read-locked.
+func (f *Builder) inherit() *lineage {
+	if f.lineage == nil {
+		return nil
+	}
+	return &lineage{
+		parent:     f.lineage,
+		method:     f.method,
+		url:        f.url,
+		headers:    f.headers.Clone(),
+		parameters: cloneValues(f.parameters),
+		variables:  cloneVariables(f.variables),
+		body:       f.body,
+	}
+}
+
+// Overrides returns, from the outermost to the innermost builder, the inherited
+// settings that were overridden; it is empty unless TrackOverrides was called
+// on an ancestor.
+func (f *Builder) Overrides() []Override {
+	defer f.read()()
+	return f.overrides()
+}
+
+// overrides returns the inherited settings that were overridden, as per
+// Overrides; the builder must be read-locked.
+func (f *Builder) overrides() []Override {
+	chain := []*lineage{}
+	for l := f.lineage; l != nil && l.parent != nil; l = l.parent {
+		chain = append([]*lineage{l}, chain...)
+	}
+	if len(chain) == 0 {
+		return nil
+	}
+	current := f.inherit()
+	chain = append(chain, current)
+	overrides := []Override{}
+	for i := 1; i < len(chain); i++ {
+		overrides = append(overrides, compare(i, chain[i-1], chain[i])...)
+	}
+	return overrides
+}
+
+// Describe returns a description of the request generated by the builder,
+// followed by the log of the inherited settings it overrides, if tracked; the
+// values of the sensitive headers and query parameters (see
+// RegisterSensitiveHeaders) are replaced with "[REDACTED]".
+func (f *Builder) Describe() string {
+	defer f.read()()
+	secret := func(kind, key string) bool {
+		switch kind {
+		case "header":
+			return sensitiveHeader(key) || (f.credentials != nil && http.CanonicalHeaderKey(key) == http.CanonicalHeaderKey(f.credentials.header()))
+		case "query parameter":
+			return sensitiveParameter(key)
+		}
+		return false
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(f.method + " " + redactRawURL(f.url) + "\n")
+	for _, values := range []struct {
+		kind   string
+		values map[string][]string
+	}{{"header", f.headers}, {"query parameter", f.parameters}} {
+		keys := make([]string, 0, len(values.values))
+		for key := range values.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := strings.Join(values.values[key], ", ")
+			if secret(values.kind, key) {
+				value = Redacted
+			}
+			buffer.WriteString(fmt.Sprintf("%s %s: %s\n", values.kind, key, value))
+		}
+	}
+	keys := make([]string, 0, len(f.variables))
+	for key := range f.variables {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		buffer.WriteString(fmt.Sprintf("variable %s: %s\n", key, f.variables[key]))
+	}
+	for _, override := range f.overrides() {
+		for _, kind := range []string{"header", "query parameter"} {
+			if strings.HasPrefix(override.Setting, kind+" ") && secret(kind, strings.TrimPrefix(override.Setting, kind+" ")) {
+				override.From, override.To = Redacted, Redacted
+			}
+		}
+		buffer.WriteString(override.String() + "\n")
+	}
+	return buffer.String()
+}
+
+// compare returns the settings of the parent configuration that were changed
+// or removed in the child one.
+func compare(level int, parent, child *lineage) []Override {
+	overrides := []Override{}
+	if parent.method != child.method {
+		overrides = append(overrides, Override{Level: level, Setting: "method", From: parent.method, To: child.method})
+	}
+	if parent.url != child.url {
+		overrides = append(overrides, Override{Level: level, Setting: "url", From: parent.url, To: child.url})
+	}
+	if !sameReader(parent.body, child.body) {
+		overrides = append(overrides, Override{Level: level, Setting: "body", From: describeReader(parent.body), To: describeReader(child.body), Removed: child.body == nil})
+	}
+	overrides = append(overrides, compareValues(level, "header", parent.headers, child.headers)...)
+	overrides = append(overrides, compareValues(level, "query parameter", parent.parameters, child.parameters)...)
+	keys := make([]string, 0, len(parent.variables))
+	for key := range parent.variables {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, ok := child.variables[key]
+		if !ok {
+			overrides = append(overrides, Override{Level: level, Setting: "variable " + key, From: parent.variables[key], Removed: true})
+		} else if value != parent.variables[key] {
+			overrides = append(overrides, Override{Level: level, Setting: "variable " + key, From: parent.variables[key], To: value})
+		}
+	}
+	return overrides
+}
+
+// compareValues returns the keys whose values were removed or replaced (rather
+// than extended) from parent to child.
+func compareValues(level int, kind string, parent, child map[string][]string) []Override {
+	keys := make([]string, 0, len(parent))
+	for key := range parent {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	overrides := []Override{}
+	for _, key := range keys {
+		from := parent[key]
+		to, ok := child[key]
+		if !ok {
+			overrides = append(overrides, Override{Level: level, Setting: kind + " " + key, From: strings.Join(from, ", "), Removed: true})
+		} else if len(to) < len(from) || !reflect.DeepEqual(to[:len(from)], from) {
+			overrides = append(overrides, Override{Level: level, Setting: kind + " " + key, From: strings.Join(from, ", "), To: strings.Join(to, ", ")})
+		}
+	}
+	return overrides
+}
+
+func sameReader(a, b io.Reader) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+func describeReader(r io.Reader) string {
+	if r == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%T", r)
+}
+
+func cloneValues(values url.Values) url.Values {
+	clone := url.Values{}
+	for key, list := range values {
+		clone[key] = append([]string(nil), list...)
+	}
+	return clone
+}
+
+func cloneVariables(variables map[string]string) map[string]string {
+	clone := map[string]string{}
+	for key, value := range variables {
+		clone[key] = value
+	}
+	return clone
+}
@@ -0,0 +1,73 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOverrides(t *testing.T) {
+	root := New("http://api.example.com/").
+		Set().Header("Accept", "application/json").
+		Set().Header("X-Team", "core").
+		Add().QueryParameter("version", "2").
+		TrackOverrides()
+	team := root.New("", "").
+		Set().Header("Accept", "text/plain").
+		Add().Header("X-Team", "payments")
+	child := team.New(http.MethodPost, "/charges").
+		Del().QueryParameter("version")
+
+	expected := []Override{
+		{Level: 1, Setting: "header Accept", From: "application/json", To: "text/plain"},
+		{Level: 2, Setting: "method", From: http.MethodGet, To: http.MethodPost},
+		{Level: 2, Setting: "url", From: "http://api.example.com/", To: "http://api.example.com/charges"},
+		{Level: 2, Setting: "query parameter version", From: "2", Removed: true},
+	}
+	if actual := child.Overrides(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+	description := child.Describe()
+	for _, line := range []string{
+		"POST http://api.example.com/charges\n",
+		"header X-Team: core, payments\n",
+		"level 1: header Accept overridden: application/json => text/plain\n",
+		"level 2: query parameter version removed (was 2)\n",
+	} {
+		if !strings.Contains(description, line) {
+			t.Fatalf("expected %q in description:\n%s", line, description)
+		}
+	}
+	if overrides := New("http://api.example.com/").New("", "/users").Overrides(); len(overrides) != 0 {
+		t.Fatalf("expected no overrides without tracking, got %v", overrides)
+	}
+}
+
+func TestDescribeRedacts(t *testing.T) {
+	root := New("http://api.example.com/").
+		SetHeader("Authorization", "Bearer s3cr3t").
+		APIKeyInQuery("describe_test_key", "s3cr3t").
+		Credentials(&Credentials{Token: "s3cr3t", Header: "X-Describe-Key"}).
+		TrackOverrides()
+	child := root.New("", "/items").SetHeader("Authorization", "Bearer 0th3r").SetQueryParameter("page", "2")
+	description := child.Describe()
+	if strings.Contains(description, "s3cr3t") || strings.Contains(description, "0th3r") {
+		t.Fatalf("secrets leaked into the description:\n%s", description)
+	}
+	for _, line := range []string{
+		"header Authorization: [REDACTED]\n",
+		"header X-Describe-Key: [REDACTED]\n",
+		"query parameter describe_test_key: [REDACTED]\n",
+		"query parameter page: 2\n",
+		"level 1: header Authorization overridden: [REDACTED] => [REDACTED]\n",
+	} {
+		if !strings.Contains(description, line) {
+			t.Fatalf("expected %q in description:\n%s", line, description)
+		}
+	}
+}
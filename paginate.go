@@ -0,0 +1,343 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"strconv"
+
+	"github.com/dihedron/go-log"
+)
+
+// Page is a single page of results retrieved by a Pager; its body has already
+// been read in full and the underlying response body closed.
+type Page struct {
+	// Number is the 1-based index of the page.
+	Number int
+	// Response is the response that carried the page.
+	Response *Response
+	// Body is the raw page body.
+	Body []byte
+
+	// items is the path expression locating the items in the page body.
+	items string
+}
+
+// Decode unmarshals the page body into the given value, using the response
+// Content-Type to choose between JSON (the default) and XML.
+func (p *Page) Decode(v interface{}) error {
+	return p.DecodeWith(v, p.Response.options)
+}
+
+// DecodeWith is like Decode, but uses the given options instead of those of
+// the Builder that generated the request.
+func (p *Page) DecodeWith(v interface{}, options DecodeOptions) error {
+	return decode(p.Response.contentType(options), p.Body, v, options)
+}
+
+// Items returns the items in the page, as located by the path expression set
+// on the Pager via Items(); the page body must be JSON and the selected value
+// must be an array.
+func (p *Page) Items() ([]json.RawMessage, error) {
+	document := json.RawMessage(p.Body)
+	if p.items != "" {
+		value, ok, err := lookupJSONPath(p.Body, p.items)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || value == nil {
+			return []json.RawMessage{}, nil
+		}
+		if document, err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+	items := []json.RawMessage{}
+	if err := json.Unmarshal(document, &items); err != nil {
+		return nil, fmt.Errorf("page items are not an array: %w", err)
+	}
+	return items, nil
+}
+
+// PaginationStrategy decides how a Pager moves from one page to the next.
+type PaginationStrategy interface {
+	// First returns the Builder for the first page, given the original one.
+	First(f *Builder) *Builder
+	// Next returns the Builder for the page following the given one, given the
+	// Builder that generated it, or nil if there are no more pages.
+	Next(f *Builder, page *Page) (*Builder, error)
+}
+
+// LinkPagination follows the rel="next" links in the response "Link" header, as
+// GitHub and many other APIs do; it is the default strategy.
+type LinkPagination struct{}
+
+// First returns the original Builder.
+func (LinkPagination) First(f *Builder) *Builder {
+	return f
+}
+
+// Next returns a Builder pointing to the rel="next" link, if any; since the link
+// carries the full query and path, the query parameters (including the raw
+// ones) and the matrix parameters of the Builder are dropped.
+func (LinkPagination) Next(f *Builder, page *Page) (*Builder, error) {
+	link, ok := Links(page.Response.Response)["next"]
+	if !ok || link == "" {
+		return nil, nil
+	}
+	log.Debugf("next page at %q", link)
+	child := f.New("", link)
+	child.parameters = url.Values{}
+	child.raw = nil
+	child.order = nil
+	child.rawQuery = ""
+	child.matrix = nil
+	return child, nil
+}
+
+// CursorPagination extracts an opaque cursor from the JSON body of each page,
+// via a path expression (e.g. "meta.next_cursor"), and sends it back as the
+// value of a query parameter; iteration stops when the cursor is missing, null
+// or empty.
+type CursorPagination struct {
+	// Path is the path expression locating the cursor in the page body.
+	Path string
+	// Parameter is the name of the query parameter carrying the cursor.
+	Parameter string
+}
+
+// First returns the original Builder.
+func (c CursorPagination) First(f *Builder) *Builder {
+	return f
+}
+
+// Next returns a Builder carrying the cursor extracted from the given page.
+func (c CursorPagination) Next(f *Builder, page *Page) (*Builder, error) {
+	value, ok, err := lookupJSONPath(page.Body, c.Path)
+	if err != nil {
+		return nil, err
+	}
+	cursor := stringify(value)
+	if !ok || cursor == "" {
+		return nil, nil
+	}
+	log.Debugf("next page with cursor %q", cursor)
+	return f.New("", "").SetQueryParameter(c.Parameter, cursor), nil
+}
+
+// OffsetPagination uses classic page number and page size query parameters
+// (e.g. "?page=2&per_page=50"); iteration stops at the first page holding fewer
+// items than the page size.
+type OffsetPagination struct {
+	// PageParameter is the name of the query parameter carrying the 1-based page
+	// number.
+	PageParameter string
+	// SizeParameter is the name of the query parameter carrying the page size;
+	// if empty, the page size is not sent.
+	SizeParameter string
+	// Size is the number of items per page.
+	Size int
+}
+
+// First returns a Builder for the first page.
+func (o OffsetPagination) First(f *Builder) *Builder {
+	return o.page(f, 1)
+}
+
+// Next returns a Builder for the page following the given one, unless it was
+// incomplete.
+func (o OffsetPagination) Next(f *Builder, page *Page) (*Builder, error) {
+	items, err := page.Items()
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 || (o.Size > 0 && len(items) < o.Size) {
+		return nil, nil
+	}
+	return o.page(f, page.Number+1), nil
+}
+
+func (o OffsetPagination) page(f *Builder, number int) *Builder {
+	child := f.New("", "").SetQueryParameter(o.PageParameter, strconv.Itoa(number))
+	if o.SizeParameter != "" && o.Size > 0 {
+		child.SetQueryParameter(o.SizeParameter, strconv.Itoa(o.Size))
+	}
+	return child
+}
+
+// Paginate sets the pagination strategy used by Pagers created from this
+// builder and its children.
+func (f *Builder) Paginate(strategy PaginationStrategy) *Builder {
+	f.pagination = strategy
+	return f
+}
+
+// PaginateByLink makes Pagers follow the rel="next" links in the "Link" header;
+// this is the default.
+func (f *Builder) PaginateByLink() *Builder {
+	return f.Paginate(LinkPagination{})
+}
+
+// PaginateByCursor makes Pagers extract the cursor for the next page from the
+// JSON body via the given path expression, and send it as the value of the
+// given query parameter, e.g. PaginateByCursor("next_cursor", "cursor").
+func (f *Builder) PaginateByCursor(path, parameter string) *Builder {
+	return f.Paginate(CursorPagination{Path: path, Parameter: parameter})
+}
+
+// PaginateByOffset makes Pagers request pages by number, via the given page
+// number and page size query parameters, e.g. PaginateByOffset("page",
+// "per_page", 50).
+func (f *Builder) PaginateByOffset(pageParameter, sizeParameter string, size int) *Builder {
+	return f.Paginate(OffsetPagination{PageParameter: pageParameter, SizeParameter: sizeParameter, Size: size})
+}
+
+// Pager iterates over the pages of a paginated resource, according to the
+// pagination strategy of the originating Builder; each page request is
+// generated from the original Builder, so it carries the same headers (and
+// credentials). Typical usage is as follows:
+//
+//	pager := requestor.Paginate(ctx, builder).Limit(10)
+//	for pager.Next() {
+//		var items []Item
+//		pager.Page().Decode(&items)
+//	}
+//	if err := pager.Err(); err != nil {
+//		// handle error
+//	}
+type Pager struct {
+	ctx       context.Context
+	requestor *Requestor
+	strategy  PaginationStrategy
+	next      *Builder
+	limit     int
+	items     string
+	page      *Page
+	err       error
+}
+
+// Paginate returns a Pager that iterates over the pages of the resource that
+// the given Builder points to.
+func (r *Requestor) Paginate(ctx context.Context, f *Builder) *Pager {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	strategy := f.pagination
+	if strategy == nil {
+		strategy = LinkPagination{}
+	}
+	return &Pager{
+		ctx:       ctx,
+		requestor: r,
+		strategy:  strategy,
+		next:      strategy.First(f),
+	}
+}
+
+// Limit sets the maximum number of pages that will be retrieved; if not
+// positive, all pages are retrieved.
+func (p *Pager) Limit(pages int) *Pager {
+	p.limit = pages
+	return p
+}
+
+// Items sets the path expression locating the array of items in each page body,
+// e.g. "data" or "$.result.items"; by default the whole body is expected to be
+// an array.
+func (p *Pager) Items(path string) *Pager {
+	p.items = path
+	return p
+}
+
+// Next retrieves the next page, and returns whether one was available; it
+// returns false when there are no more pages, when the page limit has been
+// reached, when the context is cancelled or when an error occurs, in which
+// case Err returns it. If the page is fetched but the following one cannot be
+// determined, the page is still returned and the error is reported by Err once
+// the next call to Next returns false.
+func (p *Pager) Next() bool {
+	if p.err != nil || p.next == nil {
+		return false
+	}
+	number := 1
+	if p.page != nil {
+		number = p.page.Number + 1
+	}
+	if p.limit > 0 && number > p.limit {
+		log.Debugf("page limit %d reached", p.limit)
+		p.next = nil
+		return false
+	}
+	if err := p.ctx.Err(); err != nil {
+		p.err = err
+		return false
+	}
+	current := p.next
+	response, err := p.requestor.Do(p.ctx, current)
+	if err != nil {
+		p.err = err
+		return false
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		p.err = err
+		return false
+	}
+	p.page = &Page{
+		Number:   number,
+		Response: response,
+		Body:     body,
+		items:    p.items,
+	}
+	if p.next, err = p.strategy.Next(current, p.page); err != nil {
+		// deliver the page anyway: the error stops the iteration on the next call
+		p.next = nil
+		p.err = err
+	}
+	return true
+}
+
+// Page returns the current page; it is only valid after a call to Next that
+// returned true.
+func (p *Pager) Page() *Page {
+	return p.page
+}
+
+// Err returns the error, if any, that stopped the iteration; reaching the last
+// page or the page limit is not an error.
+func (p *Pager) Err() error {
+	return p.err
+}
+
+// CollectAll iterates over all the remaining pages and appends their items (as
+// located via Items()) to the slice pointed to by target.
+func (p *Pager) CollectAll(target interface{}) error {
+	slice := reflect.ValueOf(target)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return errors.New("only pointers to slices can be passed as target for collected items")
+	}
+	slice = slice.Elem()
+	for p.Next() {
+		items, err := p.page.Items()
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			element := reflect.New(slice.Type().Elem())
+			if err := json.Unmarshal(item, element.Interface()); err != nil {
+				return err
+			}
+			slice.Set(reflect.Append(slice, element.Elem()))
+		}
+	}
+	return p.Err()
+}
@@ -0,0 +1,249 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func newPagedServer(t *testing.T, pages int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if page < pages {
+			w.Header().Set("Link", fmt.Sprintf(`<%s?page=%d&per_page=2>; rel="next"`, r.URL.Path, page+1))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `[{"id": %d}, {"id": %d}]`, page*2-1, page*2)
+	}))
+}
+
+func TestPaginate(t *testing.T) {
+	server := newPagedServer(t, 3)
+	defer server.Close()
+
+	f := New(server.URL+"/items").Set().Header("X-Auth-Token", "secret").QueryParameter("per_page", "2")
+	pager := NewRequestor(getClient()).Paginate(context.Background(), f)
+	ids := []int{}
+	for pager.Next() {
+		items := []struct {
+			ID int `json:"id"`
+		}{}
+		if err := pager.Page().Decode(&items); err != nil {
+			t.Fatalf("error decoding page %d: %v", pager.Page().Number, err)
+		}
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+	}
+	if err := pager.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ids) != 6 || ids[5] != 6 {
+		t.Fatalf("invalid items: got %v", ids)
+	}
+}
+
+func TestPaginateLimit(t *testing.T) {
+	server := newPagedServer(t, 10)
+	defer server.Close()
+
+	f := New(server.URL+"/items").Set().Header("X-Auth-Token", "secret")
+	pager := NewRequestor(getClient()).Paginate(context.Background(), f).Limit(2)
+	count := 0
+	for pager.Next() {
+		count++
+	}
+	if count != 2 || pager.Err() != nil {
+		t.Fatalf("expected 2 pages and no error, got %d (%v)", count, pager.Err())
+	}
+}
+
+func TestPaginateCancel(t *testing.T) {
+	server := newPagedServer(t, 10)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f := New(server.URL+"/items").Set().Header("X-Auth-Token", "secret")
+	pager := NewRequestor(getClient()).Paginate(ctx, f)
+	if !pager.Next() {
+		t.Fatalf("expected first page, got error %v", pager.Err())
+	}
+	cancel()
+	if pager.Next() {
+		t.Fatalf("expected iteration to stop on cancelled context")
+	}
+	if pager.Err() != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", pager.Err())
+	}
+}
+
+func TestPaginateRawQuery(t *testing.T) {
+	queries := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 3 {
+			// the link carries the full query, as GitHub does
+			w.Header().Set("Link", fmt.Sprintf(`<%s?token=abc&sig=a,b&page=%d>; rel="next"`, r.URL.Path, page+1))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	f := New(server.URL+"/items").RawQuery("token=abc").SetRawQueryParameter("sig", "a,b").SetQueryParameter("page", "1")
+	pager := NewRequestor(getClient()).Paginate(context.Background(), f)
+	for pager.Next() {
+	}
+	if err := pager.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(queries) != 3 {
+		t.Fatalf("expected 3 requests, got %v", queries)
+	}
+	for i, query := range queries {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			t.Fatalf("invalid query %q: %v", query, err)
+		}
+		if len(values["token"]) != 1 || len(values["sig"]) != 1 || values.Get("sig") != "a,b" || values.Get("page") != strconv.Itoa(i+1) {
+			t.Fatalf("expected a single token and signature on page %d, got %q", i+1, query)
+		}
+	}
+}
+
+func TestPaginateMatrixParameters(t *testing.T) {
+	paths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`<%s?page=%d>; rel="next"`, r.URL.EscapedPath(), page+1))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	f := New(server.URL+"/items").SetMatrixParameter("", "v", "1").SetQueryParameter("page", "1")
+	pager := NewRequestor(getClient()).Paginate(context.Background(), f)
+	for pager.Next() {
+	}
+	if err := pager.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(paths) != 3 {
+		t.Fatalf("expected 3 requests, got %v", paths)
+	}
+	for i, path := range paths {
+		if path != "/items;v=1" {
+			t.Fatalf("expected a single matrix parameter on page %d, got %q", i+1, path)
+		}
+	}
+}
+
+func TestPaginateByCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cursor := r.URL.Query().Get("cursor")
+		next := map[string]string{"": "c1", "c1": "c2", "c2": ""}[cursor]
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data": [{"id": %q}], "meta": {"next_cursor": %q}}`, "item"+cursor, next)
+	}))
+	defer server.Close()
+
+	f := New(server.URL).PaginateByCursor("meta.next_cursor", "cursor")
+	items := []struct {
+		ID string `json:"id"`
+	}{}
+	if err := NewRequestor(getClient()).Paginate(context.Background(), f.New("", "")).Items("data").CollectAll(&items); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 3 || items[0].ID != "item" || items[2].ID != "itemc2" {
+		t.Fatalf("invalid items: got %v", items)
+	}
+}
+
+func TestPaginateByOffset(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		ids := []int{}
+		for i := (page-1)*size + 1; i <= page*size && i <= 5; i++ {
+			ids = append(ids, i)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ids)
+	}))
+	defer server.Close()
+
+	f := New(server.URL).PaginateByOffset("page", "per_page", 2)
+	ids := []int{}
+	if err := NewRequestor(getClient()).Paginate(context.Background(), f).CollectAll(&ids); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ids) != 5 || ids[4] != 5 || requests != 3 {
+		t.Fatalf("invalid items: got %v after %d requests", ids, requests)
+	}
+
+	if err := NewRequestor(getClient()).Paginate(context.Background(), f).CollectAll(ids); err == nil {
+		t.Fatalf("expected error when target is not a pointer to slice")
+	}
+}
+
+// brokenPagination follows links but fails to determine the page after the
+// second one.
+type brokenPagination struct {
+	LinkPagination
+}
+
+func (b brokenPagination) Next(f *Builder, page *Page) (*Builder, error) {
+	if page.Number == 2 {
+		return nil, errors.New("invalid next link")
+	}
+	return b.LinkPagination.Next(f, page)
+}
+
+func TestPaginateStrategyError(t *testing.T) {
+	server := newPagedServer(t, 5)
+	defer server.Close()
+
+	f := New(server.URL+"/items").Set().Header("X-Auth-Token", "secret").Paginate(brokenPagination{})
+	pager := NewRequestor(getClient()).Paginate(context.Background(), f)
+	pages := 0
+	for pager.Next() {
+		pages++
+	}
+	if pages != 2 || pager.Err() == nil || pager.Err().Error() != "invalid next link" {
+		t.Fatalf("expected 2 pages and the strategy error, got %d (%v)", pages, pager.Err())
+	}
+	if pager.Next() {
+		t.Fatalf("expected the iteration to stay stopped after the error")
+	}
+
+	items := []struct {
+		ID int `json:"id"`
+	}{}
+	err := NewRequestor(getClient()).Paginate(context.Background(), f.New("", "")).CollectAll(&items)
+	if err == nil || len(items) != 4 || items[3].ID != 4 {
+		t.Fatalf("expected the items of the fetched pages and the error, got %v (%v)", items, err)
+	}
+}
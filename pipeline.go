@@ -0,0 +1,91 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dihedron/go-log"
+)
+
+// StepError is the error returned when a step of a Pipeline fails.
+type StepError struct {
+	// Step is the name of the failed step.
+	Step string
+	// Err is the reason why the step failed, e.g. an HTTPError.
+	Err error
+}
+
+// Error returns a description of the step failure.
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %q: %v", e.Step, e.Err)
+}
+
+// Unwrap returns the reason why the step failed.
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Pipeline is a sequence of named steps, each sending a request, where values
+// extracted from the responses of a step (via JSON path expressions, headers
+// or custom extractors) feed the path, query, headers or body of the following
+// ones, as in the following "create, then read" flow:
+//
+//	pipeline := NewPipeline()
+//	pipeline.Request("create", api.New(http.MethodPost, "/orders").WithJSONEntity(order)).
+//		Extract(CaptureHeader("location", "Location"))
+//	pipeline.Step("read", func(values Values) *Builder {
+//		return api.New(http.MethodGet, values["location"])
+//	}).Extract(Capture("status", "$.status"))
+//	values, err := pipeline.Run(ctx, requestor, nil)
+//
+// Unlike a Scenario, a Pipeline stops and returns an error at the first failed
+// step.
+type Pipeline struct {
+	steps []*Step
+}
+
+// NewPipeline returns a new, empty Pipeline.
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+// Step appends a new step to the pipeline; the given function is invoked when
+// the step is run to create the Builder for its request, given the values
+// extracted so far.
+func (p *Pipeline) Step(name string, build func(values Values) *Builder) *Step {
+	step := &Step{name: name, build: build}
+	p.steps = append(p.steps, step)
+	return step
+}
+
+// Request appends a new step to the pipeline, whose request is generated by a
+// child of the given Builder, to which the step injectors are applied.
+func (p *Pipeline) Request(name string, f *Builder) *Step {
+	return p.Step(name, func(values Values) *Builder {
+		return f.New("", "")
+	})
+}
+
+// Run runs the steps in order, using the given Requestor, starting with the
+// given initial values (which can be nil); it returns the values extracted so
+// far and, if a step failed, a *StepError.
+func (p *Pipeline) Run(ctx context.Context, requestor *Requestor, initial Values) (Values, error) {
+	values := Values{}
+	for key, value := range initial {
+		values[key] = value
+	}
+	for _, step := range p.steps {
+		if ctx != nil && ctx.Err() != nil {
+			return values, &StepError{Step: step.name, Err: ctx.Err()}
+		}
+		log.Debugf("pipeline: running step %q", step.name)
+		if _, err := step.run(ctx, requestor, values); err != nil {
+			return values, &StepError{Step: step.name, Err: err}
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/orders":
+			body, _ := ioutil.ReadAll(r.Body)
+			if string(body) != `{"item": "book"}` {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Location", "/orders/42")
+			w.WriteHeader(http.StatusCreated)
+		case r.URL.Path == "/orders/42":
+			w.Write([]byte(`{"status": "shipped"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	base := New(server.URL + "/")
+	pipeline := NewPipeline()
+	pipeline.Request("create", base.New(http.MethodPost, "orders")).
+		Inject(InjectBody(`{"item": "{{item}}"}`)).
+		Extract(CaptureHeader("location", "Location"), CaptureFunc("id", func(exchange *Exchange) (string, error) {
+			return strings.TrimPrefix(exchange.Header().Get("Location"), "/orders/"), nil
+		}))
+	pipeline.Step("read", func(values Values) *Builder {
+		return base.New(http.MethodGet, "orders/{id}")
+	}).Inject(InjectVariable("id", "{{id}}")).Extract(Capture("status", "$.status"))
+
+	values, err := pipeline.Run(context.Background(), NewRequestor(getClient()), Values{"item": "book"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if values["location"] != "/orders/42" || values["status"] != "shipped" {
+		t.Fatalf("invalid values: %v", values)
+	}
+
+	pipeline.Request("missing", base.New(http.MethodGet, "missing"))
+	pipeline.Request("never", base.New(http.MethodGet, "orders/42")).Extract(Capture("never", "$.status"))
+	values, err = pipeline.Run(context.Background(), NewRequestor(getClient()), Values{"item": "book"})
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != "missing" || !IsNotFound(err) {
+		t.Fatalf("expected the missing step to fail, got %v", err)
+	}
+	if _, ok := values["never"]; ok {
+		t.Fatalf("steps after a failure should not run")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// ErrPollTimeout is returned when a polled condition is not met before the
+// polling timeout expires.
+var ErrPollTimeout = errors.New("polling timed out")
+
+// Condition is a check on a completed exchange that tells whether polling can
+// stop; returning an error aborts polling.
+type Condition func(exchange *Exchange) (bool, error)
+
+// UntilStatus returns a Condition met when the response has any of the given
+// status codes, e.g. 200 or 404 when polling for a deletion.
+func UntilStatus(codes ...int) Condition {
+	return func(exchange *Exchange) (bool, error) {
+		for _, code := range codes {
+			if exchange.StatusCode() == code {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// UntilHeader returns a Condition met when the given response header has the
+// given value.
+func UntilHeader(header, value string) Condition {
+	return func(exchange *Exchange) (bool, error) {
+		return exchange.Header().Get(header) == value, nil
+	}
+}
+
+// UntilJSON returns a Condition met when the value at the given path in the
+// JSON response body equals any of the given values, e.g.
+// UntilJSON("$.status", "succeeded", "failed").
+func UntilJSON(path string, values ...string) Condition {
+	return func(exchange *Exchange) (bool, error) {
+		if exchange.Err != nil || len(exchange.Body) == 0 {
+			return false, nil
+		}
+		value, ok, err := lookupJSONPath(exchange.Body, path)
+		if err != nil || !ok {
+			return false, err
+		}
+		for _, expected := range values {
+			if stringify(value) == expected {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// PollUntil repeatedly sends the request generated by the given Builder until
+// the response satisfies the given condition, as needed by long-running
+// operations answered with "202 Accepted" and an operation URL; polls are
+// separated by an exponentially growing delay starting at interval, or by the
+// delay requested via the Retry-After header, if any. Failed polls are retried
+// if the Requestor retry decision (see RetryIf) allows it, otherwise polling is
+// aborted; once the timeout expires, ErrPollTimeout is returned along with the
+// last exchange.
+func (r *Requestor) PollUntil(ctx context.Context, f *Builder, condition Condition, interval, timeout time.Duration) (*Exchange, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	deadline := time.Now().Add(timeout)
+	classify, retryable := r.policy()
+	for poll := 1; ; poll++ {
+		exchange := r.Execute(ctx, f)
+		done, err := condition(exchange)
+		if err != nil {
+			return exchange, err
+		}
+		if done {
+			return exchange, nil
+		}
+		if exchange.Err != nil && !retryable(classify(exchange.Err), exchange.Err) {
+			return exchange, exchange.Err
+		}
+		delay := interval << uint(poll-1)
+		if delay > maxBackoff || delay <= 0 {
+			delay = maxBackoff
+		}
+		if after, ok := RetryAfter(exchange.Header(), time.Now()); ok {
+			delay = after
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return exchange, fmt.Errorf("condition not met after %d polls: %w", poll, ErrPollTimeout)
+		}
+		log.Debugf("condition not met after poll %d, polling again in %v", poll, delay)
+		if !sleep(ctx, delay) {
+			return exchange, ctx.Err()
+		}
+	}
+}
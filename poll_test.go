@@ -0,0 +1,71 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPollUntil(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&count, 1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"status": "running"}`))
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"status": "succeeded"}`))
+		}
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient())
+	exchange, err := requestor.PollUntil(context.Background(), New(server.URL), UntilJSON("$.status", "succeeded", "failed"), time.Millisecond, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 3 || string(exchange.Body) != `{"status": "succeeded"}` {
+		t.Fatalf("invalid outcome after %d polls: %q", count, exchange.Body)
+	}
+}
+
+func TestPollUntilTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient())
+	_, err := requestor.PollUntil(context.Background(), New(server.URL), UntilStatus(http.StatusOK), 10*time.Millisecond, 50*time.Millisecond)
+	if !errors.Is(err, ErrPollTimeout) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+}
+
+func TestPollUntilError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	// non retryable errors abort polling, unless they satisfy the condition
+	requestor := NewRequestor(getClient())
+	if _, err := requestor.PollUntil(context.Background(), New(server.URL), UntilStatus(http.StatusOK), time.Millisecond, time.Second); !IsNotFound(err) {
+		t.Fatalf("expected polling to abort, got %v", err)
+	}
+	if _, err := requestor.PollUntil(context.Background(), New(server.URL), UntilStatus(http.StatusNotFound), time.Millisecond, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// postmanPathVariable matches the ":name" path variables in Postman URLs.
+var postmanPathVariable = regexp.MustCompile(`/:([_a-zA-Z][\w-]*)`)
+
+type postmanValue struct {
+	Key      string      `json:"key"`
+	Value    interface{} `json:"value"`
+	Type     string      `json:"type"`
+	Disabled bool        `json:"disabled"`
+	Enabled  *bool       `json:"enabled"`
+}
+
+func (v postmanValue) active() bool {
+	return !v.Disabled && (v.Enabled == nil || *v.Enabled)
+}
+
+func (v postmanValue) String() string {
+	if v.Value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v.Value)
+}
+
+type postmanAuth struct {
+	Type   string         `json:"type"`
+	Bearer []postmanValue `json:"bearer"`
+	Basic  []postmanValue `json:"basic"`
+	APIKey []postmanValue `json:"apikey"`
+}
+
+type postmanURL struct {
+	Raw      string         `json:"raw"`
+	Query    []postmanValue `json:"query"`
+	Variable []postmanValue `json:"variable"`
+}
+
+// UnmarshalJSON decodes Postman URLs, given either as objects or as strings.
+func (u *postmanURL) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &u.Raw)
+	}
+	type plain postmanURL
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+type postmanBody struct {
+	Mode       string         `json:"mode"`
+	Raw        string         `json:"raw"`
+	URLEncoded []postmanValue `json:"urlencoded"`
+	FormData   []postmanValue `json:"formdata"`
+	GraphQL    *struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql"`
+	Options struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+	Disabled bool `json:"disabled"`
+}
+
+type postmanRequest struct {
+	Method string         `json:"method"`
+	Header []postmanValue `json:"header"`
+	URL    postmanURL     `json:"url"`
+	Body   *postmanBody   `json:"body"`
+	Auth   *postmanAuth   `json:"auth"`
+}
+
+type postmanItem struct {
+	Name     string          `json:"name"`
+	Item     []postmanItem   `json:"item"`
+	Request  *postmanRequest `json:"request"`
+	Auth     *postmanAuth    `json:"auth"`
+	Variable []postmanValue  `json:"variable"`
+}
+
+type postmanCollection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	postmanItem
+}
+
+type postmanEnvironment struct {
+	Name   string         `json:"name"`
+	Values []postmanValue `json:"values"`
+}
+
+// ImportPostmanEnvironment reads the enabled variables of a Postman environment
+// file, to be passed to ImportPostman.
+func ImportPostmanEnvironment(environment io.Reader) (Values, error) {
+	data, err := ioutil.ReadAll(environment)
+	if err != nil {
+		return nil, err
+	}
+	env := postmanEnvironment{}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("invalid Postman environment: %w", err)
+	}
+	values := Values{}
+	for _, value := range env.Values {
+		if value.active() {
+			values[value.Key] = value.String()
+		}
+	}
+	return values, nil
+}
+
+// ImportPostman reads a Postman collection (v2.1) and returns a Builder for each
+// of its requests, keyed by the request name prefixed by those of the folders
+// containing it, separated by "/" (e.g. "Users/Get user"). Collection variables
+// (overridden by folder variables and by the given environment values, which
+// can be nil) are set as URL variables, and the "{{name}}" and ":name"
+// placeholders in the request paths are turned into URL variables ("{name}"),
+// so that they can be overridden via SetVariable(); placeholders in the base URL,
+// headers, query parameters and bodies are replaced with their values when the
+// collection is imported, and left as they are if they have none. Bearer, basic
+// and API key authentication is supported, as are raw, URL-encoded, GraphQL and
+// (text only) form data bodies.
+func ImportPostman(collection io.Reader, environment Values) (map[string]*Builder, error) {
+	data, err := ioutil.ReadAll(collection)
+	if err != nil {
+		return nil, err
+	}
+	c := postmanCollection{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid Postman collection: %w", err)
+	}
+	if c.Info.Schema != "" && !strings.Contains(c.Info.Schema, "v2.1") {
+		return nil, fmt.Errorf("unsupported Postman collection schema %q", c.Info.Schema)
+	}
+	builders := map[string]*Builder{}
+	if err := importPostmanItems(builders, "", c.Item, c.Auth, scope(Values{}, c.Variable), environment); err != nil {
+		return nil, err
+	}
+	return builders, nil
+}
+
+// scope returns the given variables, extended with the active ones in values.
+func scope(variables Values, values []postmanValue) Values {
+	result := Values{}
+	for key, value := range variables {
+		result[key] = value
+	}
+	for _, value := range values {
+		if value.active() {
+			result[value.Key] = value.String()
+		}
+	}
+	return result
+}
+
+func importPostmanItems(builders map[string]*Builder, prefix string, items []postmanItem, auth *postmanAuth, variables, environment Values) error {
+	for _, item := range items {
+		name := prefix + item.Name
+		if item.Auth != nil {
+			auth = item.Auth
+		}
+		values := scope(variables, item.Variable)
+		if item.Request == nil {
+			if err := importPostmanItems(builders, name+"/", item.Item, auth, values, environment); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := builders[name]; ok {
+			return fmt.Errorf("duplicate Postman request %q", name)
+		}
+		for key, value := range environment {
+			values[key] = value
+		}
+		f, err := importPostmanRequest(item.Request, auth, values)
+		if err != nil {
+			return fmt.Errorf("error importing Postman request %q: %w", name, err)
+		}
+		builders[name] = f
+	}
+	return nil
+}
+
+// substitute replaces the "{{name}}" placeholders having a value.
+func substitute(template string, values Values) string {
+	return placeholder.ReplaceAllStringFunc(template, func(match string) string {
+		if value, ok := values[placeholder.FindStringSubmatch(match)[1]]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+func importPostmanRequest(request *postmanRequest, auth *postmanAuth, values Values) (*Builder, error) {
+	raw := request.URL.Raw
+	if index := strings.IndexAny(raw, "?#"); index >= 0 {
+		if request.URL.Query == nil && raw[index] == '?' {
+			query, err := url.ParseQuery(strings.SplitN(raw[index+1:], "#", 2)[0])
+			if err != nil {
+				return nil, err
+			}
+			for key, list := range query {
+				for _, value := range list {
+					request.URL.Query = append(request.URL.Query, postmanValue{Key: key, Value: value})
+				}
+			}
+		}
+		raw = raw[:index]
+	}
+	// the base URL (up to the path) is resolved straight away
+	if match := placeholder.FindStringIndex(raw); match != nil && match[0] == 0 {
+		raw = substitute(raw[:match[1]], values) + raw[match[1]:]
+	}
+	raw = placeholder.ReplaceAllString(raw, "{$1}")
+	raw = postmanPathVariable.ReplaceAllString(raw, "/{$1}")
+
+	method := request.Method
+	if method == "" {
+		method = "GET"
+	}
+	f := New(raw).Method(method)
+	for key, value := range values {
+		f.SetVariable(key, value)
+	}
+	for _, variable := range request.URL.Variable {
+		if variable.active() {
+			f.SetVariable(variable.Key, substitute(variable.String(), values))
+		}
+	}
+	for _, parameter := range request.URL.Query {
+		if parameter.active() {
+			f.AddQueryParameter(substitute(parameter.Key, values), substitute(parameter.String(), values))
+		}
+	}
+	for _, header := range request.Header {
+		if header.active() {
+			f.AddHeader(header.Key, substitute(header.String(), values))
+		}
+	}
+	if request.Auth != nil {
+		auth = request.Auth
+	}
+	if err := importPostmanAuth(f, auth, values); err != nil {
+		return nil, err
+	}
+	if err := importPostmanBody(f, request.Body, values); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func importPostmanAuth(f *Builder, auth *postmanAuth, values Values) error {
+	if auth == nil {
+		return nil
+	}
+	parameters := func(list []postmanValue) map[string]string {
+		result := map[string]string{}
+		for _, value := range list {
+			result[value.Key] = substitute(value.String(), values)
+		}
+		return result
+	}
+	switch auth.Type {
+	case "", "noauth":
+	case "bearer":
+		f.SetHeader("Authorization", "Bearer "+parameters(auth.Bearer)["token"])
+	case "basic":
+		p := parameters(auth.Basic)
+		f.SetHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(p["username"]+":"+p["password"])))
+	case "apikey":
+		p := parameters(auth.APIKey)
+		if p["in"] == "query" {
+			f.SetQueryParameter(p["key"], p["value"])
+		} else {
+			f.SetHeader(p["key"], p["value"])
+		}
+	default:
+		return fmt.Errorf("unsupported authentication type %q", auth.Type)
+	}
+	return nil
+}
+
+func importPostmanBody(f *Builder, body *postmanBody, values Values) error {
+	if body == nil || body.Disabled {
+		return nil
+	}
+	contentType := ""
+	var data []byte
+	switch body.Mode {
+	case "", "none":
+		return nil
+	case "raw":
+		data = []byte(substitute(body.Raw, values))
+		switch body.Options.Raw.Language {
+		case "json":
+			contentType = "application/json"
+		case "xml":
+			contentType = "application/xml"
+		case "html":
+			contentType = "text/html"
+		case "javascript":
+			contentType = "application/javascript"
+		default:
+			contentType = "text/plain"
+		}
+	case "urlencoded":
+		form := url.Values{}
+		for _, field := range body.URLEncoded {
+			if field.active() {
+				form.Add(substitute(field.Key, values), substitute(field.String(), values))
+			}
+		}
+		data = []byte(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	case "formdata":
+		var buffer bytes.Buffer
+		writer := multipart.NewWriter(&buffer)
+		if err := writer.SetBoundary(NewID()); err != nil {
+			return err
+		}
+		for _, field := range body.FormData {
+			if !field.active() {
+				continue
+			}
+			if field.Type == "file" {
+				return fmt.Errorf("unsupported file field %q in form data", field.Key)
+			}
+			if err := writer.WriteField(substitute(field.Key, values), substitute(field.String(), values)); err != nil {
+				return err
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		data = buffer.Bytes()
+		contentType = writer.FormDataContentType()
+	case "graphql":
+		if body.GraphQL == nil {
+			return nil
+		}
+		payload := map[string]interface{}{"query": substitute(body.GraphQL.Query, values)}
+		if variables := strings.TrimSpace(substitute(body.GraphQL.Variables, values)); variables != "" {
+			payload["variables"] = json.RawMessage(variables)
+		}
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("invalid GraphQL variables: %w", err)
+		}
+		contentType = "application/json"
+	default:
+		return fmt.Errorf("unsupported body mode %q", body.Mode)
+	}
+	unlock := f.read()
+	typed := f.headers.Get("Content-Type") != ""
+	unlock()
+	if !typed {
+		f.ContentType(contentType)
+	}
+	f.WithEntity(bytes.NewReader(data))
+	return nil
+}
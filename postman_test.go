@@ -0,0 +1,136 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const collection = `{
+	"info": {
+		"name": "Users API",
+		"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+	},
+	"auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}", "type": "string"}]},
+	"variable": [
+		{"key": "baseUrl", "value": "https://api.example.com/v1"},
+		{"key": "token", "value": "collection-token"}
+	],
+	"item": [
+		{
+			"name": "Users",
+			"item": [
+				{
+					"name": "Get user",
+					"request": {
+						"method": "GET",
+						"header": [
+							{"key": "Accept", "value": "application/json"},
+							{"key": "X-Debug", "value": "1", "disabled": true}
+						],
+						"url": {
+							"raw": "{{baseUrl}}/users/:id?fields=name",
+							"query": [
+								{"key": "fields", "value": "name"},
+								{"key": "verbose", "value": "true", "disabled": true}
+							],
+							"variable": [{"key": "id", "value": "42"}]
+						}
+					}
+				},
+				{
+					"name": "Create user",
+					"request": {
+						"method": "POST",
+						"auth": {"type": "basic", "basic": [
+							{"key": "username", "value": "admin"},
+							{"key": "password", "value": "{{password}}"}
+						]},
+						"url": "{{baseUrl}}/users",
+						"body": {
+							"mode": "raw",
+							"raw": "{\"name\": \"{{name}}\"}",
+							"options": {"raw": {"language": "json"}}
+						}
+					}
+				}
+			]
+		},
+		{
+			"name": "Login",
+			"request": {
+				"method": "POST",
+				"auth": {"type": "noauth"},
+				"url": "{{baseUrl}}/login",
+				"body": {"mode": "urlencoded", "urlencoded": [{"key": "user", "value": "john"}]}
+			}
+		}
+	]
+}`
+
+func TestImportPostman(t *testing.T) {
+	environment, err := ImportPostmanEnvironment(strings.NewReader(`{
+		"name": "staging",
+		"values": [
+			{"key": "token", "value": "env-token", "enabled": true},
+			{"key": "password", "value": "secret", "enabled": true},
+			{"key": "name", "value": "john", "enabled": false}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	builders, err := ImportPostman(strings.NewReader(collection), environment)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(builders) != 3 {
+		t.Fatalf("expected 3 builders, got %v", builders)
+	}
+
+	request, err := builders["Users/Get user"].Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.Method != http.MethodGet || request.URL.String() != "https://api.example.com/v1/users/42?fields=name" {
+		t.Fatalf("unexpected request: %s %s", request.Method, request.URL)
+	}
+	if request.Header.Get("Authorization") != "Bearer env-token" || request.Header.Get("Accept") != "application/json" || request.Header.Get("X-Debug") != "" {
+		t.Fatalf("unexpected headers: %v", request.Header)
+	}
+	request, _ = builders["Users/Get user"].New("", "").Set().Variable("id", "7").Make()
+	if request.URL.Path != "/v1/users/7" {
+		t.Fatalf("expected path variable to be overridden, got %s", request.URL)
+	}
+
+	request, _ = builders["Users/Create user"].Make()
+	body, _ := ioutil.ReadAll(request.Body)
+	if request.Header.Get("Authorization") != "Basic YWRtaW46c2VjcmV0" || request.Header.Get("Content-Type") != "application/json" || string(body) != `{"name": "{{name}}"}` {
+		t.Fatalf("unexpected request: %v %s", request.Header, body)
+	}
+
+	request, _ = builders["Login"].Make()
+	body, _ = ioutil.ReadAll(request.Body)
+	if request.Header.Get("Authorization") != "" || request.Header.Get("Content-Type") != "application/x-www-form-urlencoded" || string(body) != "user=john" {
+		t.Fatalf("unexpected request: %v %s", request.Header, body)
+	}
+}
+
+func TestImportPostmanErrors(t *testing.T) {
+	inputs := []string{
+		`{"info": {"schema": "https://schema.getpostman.com/json/collection/v1.0.0/collection.json"}}`,
+		`{"item": [{"name": "a", "request": {"url": "http://x"}}, {"name": "a", "request": {"url": "http://y"}}]}`,
+		`{"item": [{"name": "a", "request": {"url": "http://x", "auth": {"type": "oauth2"}}}]}`,
+		`{"item": [{"name": "a", "request": {"url": "http://x", "body": {"mode": "file"}}}]}`,
+	}
+	for _, input := range inputs {
+		if _, err := ImportPostman(strings.NewReader(input), nil); err == nil {
+			t.Fatalf("expected error importing %s", input)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Preference is a single preference as per RFC 7240, to be sent to the server
+// by means of the "Prefer" request header, e.g. "return=minimal".
+type Preference string
+
+const (
+	// PreferReturnMinimal asks the server to return a minimal response (usually
+	// with no body) to the request.
+	PreferReturnMinimal Preference = "return=minimal"
+	// PreferReturnRepresentation asks the server to return the full
+	// representation of the resource in the response body.
+	PreferReturnRepresentation Preference = "return=representation"
+	// PreferRespondAsync asks the server to process the request asynchronously,
+	// usually responding with a "202 Accepted".
+	PreferRespondAsync Preference = "respond-async"
+	// PreferHandlingStrict asks the server to apply strict validation and error
+	// handling rules to the request.
+	PreferHandlingStrict Preference = "handling=strict"
+	// PreferHandlingLenient asks the server to apply lenient validation and error
+	// handling rules to the request.
+	PreferHandlingLenient Preference = "handling=lenient"
+)
+
+// PreferWait returns a "wait" preference, by which the client states how long
+// it is willing to wait for the server to process the request synchronously;
+// the duration is expressed in seconds, rounded up.
+func PreferWait(d time.Duration) Preference {
+	seconds := int64((d + time.Second - 1) / time.Second)
+	if seconds < 0 {
+		seconds = 0
+	}
+	return Preference(fmt.Sprintf("wait=%d", seconds))
+}
+
+// Name returns the name of the preference, e.g. "return" for "return=minimal".
+func (p Preference) Name() string {
+	name, _ := splitPreference(string(p))
+	return name
+}
+
+// Value returns the value of the preference, e.g. "minimal" for "return=minimal";
+// preferences with no value (e.g. "respond-async") return an empty string.
+func (p Preference) Value() string {
+	_, value := splitPreference(string(p))
+	return value
+}
+
+// Prefer sets the "Prefer" header in the request builder to the given list of
+// preferences; the previous value is discarded; if no preference is given the
+// header is removed.
+func (f *Builder) Prefer(preferences ...Preference) *Builder {
+	if len(preferences) == 0 {
+		return f.DelHeader("Prefer")
+	}
+	values := make([]string, 0, len(preferences))
+	for _, preference := range preferences {
+		values = append(values, string(preference))
+	}
+	return f.SetHeader("Prefer", strings.Join(values, ", "))
+}
+
+// PreferencesApplied parses the "Preference-Applied" header of the given response
+// and returns the preferences the server claims to have honoured, in the order
+// in which they appear.
+func PreferencesApplied(response *http.Response) []Preference {
+	if response == nil {
+		return nil
+	}
+	return ParsePreferences(response.Header.Values("Preference-Applied")...)
+}
+
+// IsPreferenceApplied returns whether the server has applied the given preference,
+// as per the response "Preference-Applied" header; the comparison of preference
+// names is case-insensitive.
+func IsPreferenceApplied(response *http.Response, preference Preference) bool {
+	for _, applied := range PreferencesApplied(response) {
+		if strings.EqualFold(applied.Name(), preference.Name()) && applied.Value() == preference.Value() {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePreferences parses a set of "Prefer" or "Preference-Applied" header values
+// into a list of preferences; parameters following the preference (separated by
+// a semicolon) are dropped, and quoted values are unquoted.
+func ParsePreferences(values ...string) []Preference {
+	result := []Preference{}
+	for _, value := range values {
+		for _, token := range strings.Split(value, ",") {
+			// drop any parameter, e.g. "foo; bar" => "foo"
+			if index := strings.Index(token, ";"); index >= 0 {
+				token = token[:index]
+			}
+			token = strings.TrimSpace(token)
+			if token == "" {
+				continue
+			}
+			name, value := splitPreference(token)
+			if value != "" {
+				result = append(result, Preference(name+"="+value))
+			} else {
+				result = append(result, Preference(name))
+			}
+		}
+	}
+	return result
+}
+
+func splitPreference(preference string) (string, string) {
+	tokens := strings.SplitN(preference, "=", 2)
+	name := strings.TrimSpace(tokens[0])
+	if len(tokens) < 2 {
+		return name, ""
+	}
+	return name, strings.Trim(strings.TrimSpace(tokens[1]), "\"")
+}
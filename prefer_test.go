@@ -0,0 +1,73 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPrefer(t *testing.T) {
+	f := New("").Prefer(PreferReturnMinimal, PreferRespondAsync, PreferWait(1500*time.Millisecond))
+	if len(f.headers["Prefer"]) != 1 {
+		t.Fatalf("prefer header should have 1 value, got %d", len(f.headers["Prefer"]))
+	}
+	if value := f.headers.Get("Prefer"); value != "return=minimal, respond-async, wait=2" {
+		t.Fatalf("invalid prefer header: got %q", value)
+	}
+	f.Prefer()
+	if _, ok := f.headers["Prefer"]; ok {
+		t.Fatalf("prefer header should have been removed")
+	}
+}
+
+func TestPreferenceNameValue(t *testing.T) {
+	tests := []struct {
+		preference Preference
+		name       string
+		value      string
+	}{
+		{PreferReturnMinimal, "return", "minimal"},
+		{PreferRespondAsync, "respond-async", ""},
+		{PreferWait(10 * time.Second), "wait", "10"},
+		{Preference("foo=\"bar\""), "foo", "bar"},
+	}
+	for _, test := range tests {
+		if test.preference.Name() != test.name {
+			t.Fatalf("invalid name for %q: expected %q, got %q", test.preference, test.name, test.preference.Name())
+		}
+		if test.preference.Value() != test.value {
+			t.Fatalf("invalid value for %q: expected %q, got %q", test.preference, test.value, test.preference.Value())
+		}
+	}
+}
+
+func TestPreferencesApplied(t *testing.T) {
+	response := &http.Response{
+		Header: http.Header{
+			"Preference-Applied": []string{"return=minimal; foo=bar, respond-async", "wait=\"5\""},
+		},
+	}
+	applied := PreferencesApplied(response)
+	expected := []Preference{PreferReturnMinimal, PreferRespondAsync, PreferWait(5 * time.Second)}
+	if len(applied) != len(expected) {
+		t.Fatalf("invalid number of applied preferences: expected %d, got %d", len(expected), len(applied))
+	}
+	for i := range expected {
+		if applied[i] != expected[i] {
+			t.Fatalf("invalid applied preference %d: expected %q, got %q", i, expected[i], applied[i])
+		}
+	}
+	if !IsPreferenceApplied(response, PreferRespondAsync) {
+		t.Fatalf("respond-async should have been applied")
+	}
+	if IsPreferenceApplied(response, PreferReturnRepresentation) {
+		t.Fatalf("return=representation should not have been applied")
+	}
+	if len(PreferencesApplied(nil)) != 0 {
+		t.Fatalf("no preferences expected on nil response")
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// ErrNoCache is returned when prefetching with a Requestor that has no cache.
+var ErrNoCache = errors.New("no cache configured")
+
+const (
+	// DefaultPrefetchConcurrency is the maximum number of prefetch requests
+	// executed concurrently.
+	DefaultPrefetchConcurrency = 2
+	// DefaultPrefetchMaxDelay is the longest a prefetch request yields to the
+	// other requests of the Requestor before being sent anyway.
+	DefaultPrefetchMaxDelay = 5 * time.Second
+)
+
+// prefetching marks the contexts of prefetch requests.
+type prefetching struct{}
+
+// Prefetch is a set of requests warming the cache in the background; see
+// Requestor.Prefetch.
+type Prefetch struct {
+	done   chan struct{}
+	lock   sync.Mutex
+	errs   []error
+	warmed int
+}
+
+// Prefetch warms the cache (see Cache and Revalidate) with the responses to the
+// GET requests for the given URLs, relative to the given Builder, so that the
+// latency-sensitive requests issued later are served from the cache or only
+// need revalidating. Prefetching runs in the background at low priority: at
+// most DefaultPrefetchConcurrency requests at a time, each one waiting for the
+// other requests of the Requestor to complete (for up to
+// DefaultPrefetchMaxDelay) before being sent. Cancelling the context stops it.
+func (r *Requestor) Prefetch(ctx context.Context, f *Builder, urls ...string) *Prefetch {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	p := &Prefetch{
+		done: make(chan struct{}),
+		errs: make([]error, len(urls)),
+	}
+	r.lock.Lock()
+	cache := r.cache
+	r.lock.Unlock()
+	if cache == nil {
+		for i := range p.errs {
+			p.errs[i] = ErrNoCache
+		}
+		close(p.done)
+		return p
+	}
+	ctx = context.WithValue(ctx, prefetching{}, true)
+	go func() {
+		defer close(p.done)
+		semaphore := make(chan struct{}, DefaultPrefetchConcurrency)
+		var wg sync.WaitGroup
+		for i, u := range urls {
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				p.errs[i] = ctx.Err()
+				continue
+			}
+			wg.Add(1)
+			go func(i int, u string) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+				err := r.warm(ctx, f.New(http.MethodGet, u))
+				p.lock.Lock()
+				defer p.lock.Unlock()
+				if err != nil {
+					log.Errorf("error prefetching %q: %v", u, err)
+					p.errs[i] = err
+				} else {
+					p.warmed++
+				}
+			}(i, u)
+		}
+		wg.Wait()
+	}()
+	return p
+}
+
+// Done returns a channel that is closed once prefetching is over.
+func (p *Prefetch) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait waits for prefetching to be over, and returns a *BatchError listing the
+// URLs (by position) that could not be prefetched, if any.
+func (p *Prefetch) Wait() error {
+	<-p.done
+	return NewBatchError(p.errs)
+}
+
+// Warmed returns the number of responses prefetched so far.
+func (p *Prefetch) Warmed() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.warmed
+}
+
+// warm sends the request generated by the given Builder once the Requestor is
+// idle, and reads the response so that it is cached.
+func (r *Requestor) warm(ctx context.Context, f *Builder) error {
+	timer := time.NewTimer(DefaultPrefetchMaxDelay)
+	defer timer.Stop()
+	select {
+	case <-r.idle():
+	case <-timer.C:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	response, err := r.Do(ctx, f)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, err = io.Copy(ioutil.Discard, response.Body)
+	return err
+}
+
+// busy tracks the requests being executed, other than the prefetch ones; it
+// returns the function to call once the given request is over.
+func (r *Requestor) busy(request *http.Request) func() {
+	if request.Context().Value(prefetching{}) != nil {
+		return func() {}
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.active == 0 {
+		r.waiting = make(chan struct{})
+	}
+	r.active++
+	return func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.active--
+		if r.active == 0 {
+			close(r.waiting)
+		}
+	}
+}
+
+// idle returns a channel that is closed once no requests are being executed,
+// other than the prefetch ones.
+func (r *Requestor) idle() <-chan struct{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.active == 0 {
+		idle := make(chan struct{})
+		close(idle)
+		return idle
+	}
+	return r.waiting
+}
@@ -0,0 +1,81 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrefetch(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+			return
+		}
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	api := New(server.URL)
+	requestor := NewRequestor(nil).Cache(nil)
+
+	// a foreground request keeps the prefetch waiting
+	slow := make(chan error, 1)
+	go func() {
+		_, err := requestor.Do(context.Background(), api.New("", "/slow"))
+		slow <- err
+	}()
+	for deadline := time.Now().Add(time.Second); isClosed(requestor.idle()); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("foreground request not started")
+		}
+	}
+	prefetch := requestor.Prefetch(context.Background(), api, "/dashboard", "/users")
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Fatalf("expected prefetch to yield to the foreground request")
+	}
+	close(release)
+	if err := <-slow; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := prefetch.Wait(); err != nil || prefetch.Warmed() != 2 {
+		t.Fatalf("expected 2 prefetched responses, got %d (%v)", prefetch.Warmed(), err)
+	}
+
+	response, err := requestor.Do(context.Background(), api.New("", "/users"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var body []byte
+	response.Decode(&body)
+	if !response.FromCache || string(body) != "/users" || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("expected a warm cache, got %q (from cache: %v, calls: %d)", body, response.FromCache, calls)
+	}
+
+	err = NewRequestor(nil).Prefetch(context.Background(), api, "/users").Wait()
+	if !errors.Is(err, ErrNoCache) {
+		t.Fatalf("expected ErrNoCache, got %v", err)
+	}
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata describes a resource as reported by the headers of a HEAD or
+// OPTIONS response, without transferring its body.
+type Metadata struct {
+	// StatusCode is the status code of the response.
+	StatusCode int
+	// Header contains the response headers.
+	Header http.Header
+	// ContentLength is the size of the resource, or -1 if unknown.
+	ContentLength int64
+	// ContentType is the media type of the resource.
+	ContentType string
+	// Allow lists the methods supported by the resource, as per the Allow
+	// header or, for CORS preflight responses, Access-Control-Allow-Methods.
+	Allow []string
+	// AcceptRanges is true if the server supports byte range requests.
+	AcceptRanges bool
+	// ETag is the entity tag of the resource, if any.
+	ETag string
+	// LastModified is the last modification time of the resource, if known.
+	LastModified time.Time
+}
+
+// Allows returns whether the given method is listed among the allowed ones.
+func (m *Metadata) Allows(method string) bool {
+	for _, allowed := range m.Allow {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// newMetadata extracts the metadata from the given response status and headers.
+func newMetadata(statusCode int, header http.Header) *Metadata {
+	metadata := &Metadata{
+		StatusCode:    statusCode,
+		Header:        header,
+		ContentLength: -1,
+		ContentType:   header.Get("Content-Type"),
+		AcceptRanges:  strings.Contains(strings.ToLower(header.Get("Accept-Ranges")), "bytes"),
+		ETag:          header.Get("ETag"),
+	}
+	if length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		metadata.ContentLength = length
+	}
+	if modified, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		metadata.LastModified = modified
+	}
+	allow := header.Values("Allow")
+	if len(allow) == 0 {
+		allow = header.Values("Access-Control-Allow-Methods")
+	}
+	for _, value := range allow {
+		for _, method := range strings.Split(value, ",") {
+			if method = strings.ToUpper(strings.TrimSpace(method)); method != "" {
+				metadata.Allow = append(metadata.Allow, method)
+			}
+		}
+	}
+	return metadata
+}
+
+// Probe sends a HEAD request (or an OPTIONS request, if that is the method of
+// the given Builder) for the resource of the given Builder, and returns its
+// metadata, as a cheap way of discovering its size and capabilities; if the
+// server responds with a non-2xx status code, the metadata are returned along
+// with the *HTTPError.
+func (r *Requestor) Probe(ctx context.Context, f *Builder) (*Metadata, error) {
+	method := http.MethodHead
+	if f.method == http.MethodOptions {
+		method = http.MethodOptions
+	}
+	response, err := r.Do(ctx, f.New(method, "").WithEntity(nil))
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return newMetadata(httpErr.StatusCode, httpErr.Header), err
+		}
+		return nil, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+	metadata := newMetadata(response.StatusCode, response.Header)
+	if metadata.ContentLength < 0 && response.ContentLength >= 0 && method == http.MethodHead {
+		metadata.ContentLength = response.ContentLength
+	}
+	return metadata, nil
+}
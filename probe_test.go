@@ -0,0 +1,49 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			w.Header().Set("Content-Type", "application/zip")
+			w.Header().Set("Content-Length", "1048576")
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		case http.MethodOptions:
+			w.Header().Set("Allow", "GET, HEAD,options")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient())
+	metadata, err := requestor.Probe(context.Background(), New(server.URL).Post())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metadata.ContentLength != 1048576 || !metadata.AcceptRanges || metadata.ContentType != "application/zip" ||
+		metadata.ETag != `"v1"` || metadata.LastModified.Year() != 2006 {
+		t.Fatalf("invalid metadata: %+v", metadata)
+	}
+
+	metadata, err = requestor.Probe(context.Background(), New(server.URL).Method(http.MethodOptions))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(metadata.Allow) != 3 || !metadata.Allows("options") || metadata.Allows(http.MethodPost) || metadata.AcceptRanges {
+		t.Fatalf("invalid metadata: %+v", metadata)
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dihedron/go-log"
+)
+
+// ProfileEnv is the environment variable naming the profile applied by
+// Builder.Profiles and Builder.Profile("").
+const ProfileEnv = "REQUESTOR_PROFILE"
+
+// ErrUnknownProfile is returned when applying a profile that is not defined.
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// Profile is the configuration of an API in an environment (e.g. dev, staging
+// or prod).
+type Profile struct {
+	// BaseURL is the base URL of the API.
+	BaseURL string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	// Headers are the default headers of the requests.
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// Credentials are the credentials the requests are authenticated with.
+	Credentials *Credentials `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+	// TLS are the TLS settings of the connections.
+	TLS *TLSSettings `json:"tls,omitempty" yaml:"tls,omitempty"`
+}
+
+// Credentials refer to the credentials of a profile; each value can be given
+// literally, or as a reference resolved when the profile is applied, so that
+// secrets are kept out of the profile definitions: "env:NAME" is the value of
+// an environment variable and "file:PATH" the trimmed contents of a file.
+type Credentials struct {
+	// Token is a bearer token, sent in the Authorization header unless Header
+	// is set.
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+	// Header is the header carrying the token verbatim, e.g. "X-API-Key".
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+	// Username and Password are sent via HTTP basic authentication.
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+}
+
+// TLSSettings are the TLS settings of a profile; relative paths are resolved
+// against the working directory.
+type TLSSettings struct {
+	// CAFile is a PEM file with the certificate authorities trusted in addition
+	// to the system ones.
+	CAFile string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+	// CertFile and KeyFile are PEM files with the client certificate and key.
+	CertFile string `json:"cert_file,omitempty" yaml:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	// ServerName overrides the name the server certificate is verified for.
+	ServerName string `json:"server_name,omitempty" yaml:"server_name,omitempty"`
+	// InsecureSkipVerify disables the verification of the server certificate.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+}
+
+// Profiles are named profiles, e.g. "dev", "staging" and "prod".
+type Profiles map[string]*Profile
+
+// LoadProfiles loads the profiles defined in the file at the given path, as a
+// map of profiles by name; the codec can be nil for JSON files, whereas other
+// formats need their own codec, e.g. yamlcassette.Codec for YAML.
+func LoadProfiles(path string, codec CassetteCodec) (Profiles, error) {
+	if codec == nil {
+		if ext := strings.ToLower(filepath.Ext(path)); ext != ".json" {
+			return nil, fmt.Errorf("no codec for profiles %q", path)
+		}
+		codec = JSONCodec{}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profiles := Profiles{}
+	if err := codec.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("invalid profiles %q: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// Profiles sets the profiles of this builder and its children, and applies the
+// one named by the ProfileEnv environment variable, if set.
+func (f *Builder) Profiles(profiles Profiles) *Builder {
+	f.profiles = profiles
+	if name := os.Getenv(ProfileEnv); name != "" {
+		f.Profile(name)
+	}
+	return f
+}
+
+// Profile applies the profile with the given name, or the one named by the
+// ProfileEnv environment variable if empty, among those set via Profiles: the
+// builder gets its base URL, headers, credentials and TLS settings, replacing
+// those of the profile previously applied, if any. If the profile cannot be
+// applied (e.g. it does not exist, or a secret or certificate cannot be read),
+// Make fails.
+func (f *Builder) Profile(name string) *Builder {
+	if name == "" {
+		name = os.Getenv(ProfileEnv)
+	}
+	profile, ok := f.profiles[name]
+	if !ok || profile == nil {
+		f.err = fmt.Errorf("%w %q", ErrUnknownProfile, name)
+		return f
+	}
+	if err := f.apply(profile); err != nil {
+		log.Errorf("error applying profile %q: %v", name, err)
+		f.err = fmt.Errorf("profile %q: %w", name, err)
+		return f
+	}
+	f.err = nil
+	f.profile = name
+	return f
+}
+
+// apply applies the given profile, undoing the previous one.
+func (f *Builder) apply(profile *Profile) error {
+	if _, _, err := profile.Credentials.authorization(); err != nil {
+		return err
+	}
+	config, err := profile.TLS.config()
+	if err != nil {
+		return err
+	}
+	var previous *Profile
+	if f.profile != "" {
+		previous = f.profiles[f.profile]
+	}
+	if previous != nil {
+		for key := range previous.Headers {
+			f.DelHeader(key)
+		}
+	}
+	if profile.BaseURL != "" {
+		f.url = profile.BaseURL
+	}
+	for key, value := range profile.Headers {
+		f.SetHeader(key, value)
+	}
+	if profile.Credentials != nil || (previous != nil && previous.Credentials != nil) {
+		f.Credentials(profile.Credentials)
+	}
+	if config != nil || (previous != nil && previous.TLS != nil) {
+		f.customise(func(transport *http.Transport) {
+			transport.TLSClientConfig = config
+		})
+	}
+	return nil
+}
+
+// Credentials makes the requests generated by this builder and its children
+// carry the given credentials, whose references are resolved straight away,
+// replacing those previously set, if any (nil just removes them); unlike the
+// headers carrying them, the references are kept in the configuration of the
+// builder (see Config). If a reference cannot be resolved, Make fails.
+func (f *Builder) Credentials(credentials *Credentials) *Builder {
+	if f.credentials != nil {
+		f.DelHeader(f.credentials.header())
+	}
+	f.credentials = credentials
+	header, value, err := credentials.authorization()
+	if err != nil {
+		f.err = fmt.Errorf("invalid credentials: %w", err)
+		return f
+	}
+	if header != "" {
+		f.SetHeader(header, value)
+	}
+	return f
+}
+
+// header returns the name of the header carrying the credentials.
+func (c *Credentials) header() string {
+	if c.Token != "" && c.Header != "" {
+		return c.Header
+	}
+	return "Authorization"
+}
+
+// authorization returns the header carrying the credentials, and its value.
+func (c *Credentials) authorization() (string, string, error) {
+	switch {
+	case c == nil:
+		return "", "", nil
+	case c.Token != "":
+		token, err := secret(c.Token)
+		if err != nil {
+			return "", "", err
+		}
+		if c.Header != "" {
+			return c.header(), token, nil
+		}
+		return c.header(), "Bearer " + token, nil
+	case c.Username != "":
+		username, err := secret(c.Username)
+		if err != nil {
+			return "", "", err
+		}
+		password, err := secret(c.Password)
+		if err != nil {
+			return "", "", err
+		}
+		return "Authorization", "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	}
+	return "", "", nil
+}
+
+// secret resolves the given credential reference.
+func secret(reference string) (string, error) {
+	switch {
+	case strings.HasPrefix(reference, "env:"):
+		value, ok := os.LookupEnv(strings.TrimPrefix(reference, "env:"))
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", strings.TrimPrefix(reference, "env:"))
+		}
+		return value, nil
+	case strings.HasPrefix(reference, "file:"):
+		data, err := ioutil.ReadFile(strings.TrimPrefix(reference, "file:"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return reference, nil
+}
+
+// config returns the TLS configuration of the settings, or nil if none.
+func (s *TLSSettings) config() (*tls.Config, error) {
+	if s == nil {
+		return nil, nil
+	}
+	config := &tls.Config{ServerName: s.ServerName, InsecureSkipVerify: s.InsecureSkipVerify}
+	if s.CAFile != "" {
+		data, err := ioutil.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		if config.RootCAs, err = x509.SystemCertPool(); err != nil || config.RootCAs == nil {
+			config.RootCAs = x509.NewCertPool()
+		}
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates in %s", s.CAFile)
+		}
+	}
+	if s.CertFile != "" || s.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
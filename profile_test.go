@@ -0,0 +1,81 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Environment") + "|" + r.Header.Get("X-Debug")))
+	}))
+	defer server.Close()
+
+	directory := t.TempDir()
+	ca := filepath.Join(directory, "ca.pem")
+	if err := ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path := filepath.Join(directory, "profiles.json")
+	if err := ioutil.WriteFile(path, []byte(`{
+		"dev": {"base_url": "http://localhost:1/", "headers": {"X-Environment": "dev", "X-Debug": "1"}},
+		"staging": {
+			"base_url": "`+server.URL+`/",
+			"headers": {"X-Environment": "staging"},
+			"credentials": {"token": "env:TEST_PROFILE_TOKEN"},
+			"tls": {"ca_file": "`+ca+`", "server_name": "example.com"}
+		}
+	}`), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	profiles, err := LoadProfiles(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := LoadProfiles(filepath.Join(directory, "profiles.yaml"), nil); err == nil {
+		t.Fatalf("expected error for missing codec")
+	}
+
+	os.Setenv(ProfileEnv, "dev")
+	defer os.Unsetenv(ProfileEnv)
+	api := New("").Profiles(profiles)
+	request, err := api.New("", "users").Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.URL.String() != "http://localhost:1/users" || request.Header.Get("X-Debug") != "1" {
+		t.Fatalf("unexpected request %s %v", request.URL, request.Header)
+	}
+
+	if _, err := api.Profile("staging").Make(); err == nil {
+		t.Fatalf("expected error for unset credentials")
+	}
+	os.Setenv("TEST_PROFILE_TOKEN", "secret")
+	defer os.Unsetenv("TEST_PROFILE_TOKEN")
+	response, err := NewRequestor(nil).Do(context.Background(), api.Profile("staging").New("", "users"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var body []byte
+	if err := response.Decode(&body); err != nil || string(body) != "Bearer secret|staging|" {
+		t.Fatalf("unexpected body %q (%v)", body, err)
+	}
+
+	if _, err := api.Profile("prod").Make(); !errors.Is(err, ErrUnknownProfile) {
+		t.Fatalf("expected unknown profile, got %v", err)
+	}
+	if _, err := api.Profile("").Make(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultProgressInterval is the default minimum interval between two progress
+// reports.
+const DefaultProgressInterval = 100 * time.Millisecond
+
+// ProgressFunc is called as a body is transferred, with the number of bytes
+// transferred so far and the size of the body (-1 if unknown).
+type ProgressFunc func(done, total int64)
+
+// OnUploadProgress sets the callback reporting the progress of the transfer of
+// the request bodies, e.g. to render a progress bar; it is called at most once
+// per progress interval (see ProgressInterval) and once the body is completely
+// sent, restarting from zero if the request is sent again.
+func (f *Builder) OnUploadProgress(callback ProgressFunc) *Builder {
+	f.uploadProgress = callback
+	return f
+}
+
+// OnDownloadProgress sets the callback reporting the progress of the transfer
+// of the response bodies, as they are read; it is called at most once per
+// progress interval (see ProgressInterval) and once the body is completely
+// read or closed.
+func (f *Builder) OnDownloadProgress(callback ProgressFunc) *Builder {
+	f.downloadProgress = callback
+	return f
+}
+
+// ProgressInterval sets the minimum interval between two progress reports (by
+// default, DefaultProgressInterval); zero reports each read.
+func (f *Builder) ProgressInterval(interval time.Duration) *Builder {
+	if interval >= 0 {
+		f.progressInterval = &interval
+	}
+	return f
+}
+
+// interval returns the minimum interval between two progress reports.
+func (f *Builder) interval() time.Duration {
+	if f.progressInterval == nil {
+		return DefaultProgressInterval
+	}
+	return *f.progressInterval
+}
+
+// uploading returns a copy of the given request whose body reports the
+// progress of its transfer, if so configured.
+func (f *Builder) uploading(request *http.Request) *http.Request {
+	if f.uploadProgress == nil || request.Body == nil || request.Body == http.NoBody {
+		return request
+	}
+	clone := request.WithContext(request.Context())
+	clone.Body = &progressReader{ReadCloser: request.Body, total: request.ContentLength, interval: f.interval(), callback: f.uploadProgress}
+	return clone
+}
+
+// downloading makes the body of the given response report the progress of its
+// transfer, if so configured.
+func (f *Builder) downloading(response *http.Response) {
+	if f.downloadProgress == nil || response.Body == nil {
+		return
+	}
+	response.Body = &progressReader{ReadCloser: response.Body, total: response.ContentLength, interval: f.interval(), callback: f.downloadProgress}
+}
+
+// progressReader is a body reporting the progress of its transfer.
+type progressReader struct {
+	io.ReadCloser
+	done     int64
+	total    int64
+	interval time.Duration
+	callback ProgressFunc
+	reported time.Time
+	finished bool
+}
+
+// Read reads from the body, and reports the progress if the interval since the
+// previous report has elapsed or the body is over.
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.done += int64(n)
+	if err == io.EOF {
+		r.finish()
+	} else if n > 0 && time.Since(r.reported) >= r.interval {
+		r.reported = time.Now()
+		r.callback(r.done, r.total)
+	}
+	return n, err
+}
+
+// Close closes the body, and reports the final progress.
+func (r *progressReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.finish()
+	return err
+}
+
+// finish reports the final progress, once.
+func (r *progressReader) finish() {
+	if !r.finished {
+		r.finished = true
+		r.callback(r.done, r.total)
+	}
+}
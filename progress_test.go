@@ -0,0 +1,65 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	payload := strings.Repeat("0123456789", 10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Length", fmt.Sprint(len(payload)))
+		w.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	type report struct{ done, total int64 }
+	var uploaded, downloaded []report
+	f := New(server.URL).Post().WithEntity(bytes.NewReader([]byte(payload))).
+		OnUploadProgress(func(done, total int64) { uploaded = append(uploaded, report{done, total}) }).
+		OnDownloadProgress(func(done, total int64) { downloaded = append(downloaded, report{done, total}) }).
+		ProgressInterval(0)
+	response, err := NewRequestor(nil).Do(context.Background(), f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var body []byte
+	response.Decode(&body)
+
+	for name, reports := range map[string][]report{"upload": uploaded, "download": downloaded} {
+		if len(reports) < 2 {
+			t.Fatalf("expected several %s reports, got %v", name, reports)
+		}
+		last := reports[len(reports)-1]
+		if last.done != int64(len(payload)) || last.total != int64(len(payload)) {
+			t.Fatalf("unexpected final %s report %+v", name, last)
+		}
+		for i := 1; i < len(reports); i++ {
+			if reports[i].done < reports[i-1].done {
+				t.Fatalf("expected %s progress to grow, got %v", name, reports)
+			}
+		}
+	}
+
+	downloaded = nil
+	response, err = NewRequestor(nil).Do(context.Background(), f.New("", "").ProgressInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Decode(&body)
+	if len(downloaded) != 2 || downloaded[1].done != int64(len(payload)) {
+		t.Fatalf("expected the first and final reports only, got %v", downloaded)
+	}
+}
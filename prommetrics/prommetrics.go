@@ -0,0 +1,233 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package prommetrics exposes Prometheus metrics about the requests executed by
+// a request Requestor; it lives in its own package so that the Prometheus
+// client is only pulled in by those who need it:
+//
+//	metrics, err := prommetrics.New(prometheus.DefaultRegisterer, nil)
+//	...
+//	requestor := request.NewRequestor(nil).Observe(metrics)
+package prommetrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	request "github.com/dihedron/go-requestor"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Label names.
+const (
+	Method    = "method"
+	Host      = "host"
+	Path      = "path"
+	Status    = "status"
+	Operation = "operation"
+)
+
+// Options configures the metrics; the zero value is usable.
+type Options struct {
+	// Namespace and Subsystem prefix the metric names (e.g. "myapp" gives
+	// "myapp_http_client_requests_total").
+	Namespace string
+	Subsystem string
+	// Labels are the labels of the metrics, among Method, Host, Path, Status and
+	// Operation (the name of the request.Operation the request is part of, if
+	// any); Status is not applied to the in-flight gauge. By default, Method,
+	// Host and Status are used. The phase duration histogram has an additional
+	// "phase" label (dns, connect, tls or ttfb).
+	Labels []string
+	// ConstLabels are added to all the metrics.
+	ConstLabels prometheus.Labels
+	// DurationBuckets are the buckets of the request duration histogram, in
+	// seconds (default prometheus.DefBuckets).
+	DurationBuckets []float64
+	// SizeBuckets are the buckets of the response size histogram, in bytes
+	// (default exponential, from 100 bytes to 100 MB).
+	SizeBuckets []float64
+	// NormalizePath returns the value of the Path label for the given request,
+	// so that high-cardinality paths (e.g. "/users/42") can be collapsed (e.g.
+	// into "/users/{id}"); by default, the URL path is used as is.
+	NormalizePath func(request *http.Request) string
+}
+
+// Metrics is a request Observer updating Prometheus metrics.
+type Metrics struct {
+	labels     []string
+	normalize  func(request *http.Request) string
+	requests   *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	retries    *prometheus.CounterVec
+	inflight   *prometheus.GaugeVec
+	size       *prometheus.HistogramVec
+	compressed *prometheus.HistogramVec
+	phases     *prometheus.HistogramVec
+}
+
+// New creates the metrics and registers them with the given registerer; the
+// options can be nil.
+func New(registerer prometheus.Registerer, options *Options) (*Metrics, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	m := &Metrics{
+		labels:    options.Labels,
+		normalize: options.NormalizePath,
+	}
+	if len(m.labels) == 0 {
+		m.labels = []string{Method, Host, Status}
+	}
+	gauge := []string{}
+	for _, label := range m.labels {
+		switch label {
+		case Method, Host, Path, Operation:
+			gauge = append(gauge, label)
+		case Status:
+		default:
+			return nil, errors.New("unsupported label " + strconv.Quote(label))
+		}
+	}
+	if m.normalize == nil {
+		m.normalize = func(request *http.Request) string { return request.URL.Path }
+	}
+	durations := options.DurationBuckets
+	if len(durations) == 0 {
+		durations = prometheus.DefBuckets
+	}
+	sizes := options.SizeBuckets
+	if len(sizes) == 0 {
+		sizes = prometheus.ExponentialBuckets(100, 10, 7)
+	}
+	m.requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   options.Namespace,
+		Subsystem:   options.Subsystem,
+		Name:        "http_client_requests_total",
+		Help:        "Total number of HTTP requests executed.",
+		ConstLabels: options.ConstLabels,
+	}, m.labels)
+	m.duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   options.Namespace,
+		Subsystem:   options.Subsystem,
+		Name:        "http_client_request_duration_seconds",
+		Help:        "Time taken to receive the response headers, in seconds.",
+		ConstLabels: options.ConstLabels,
+		Buckets:     durations,
+	}, m.labels)
+	m.retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   options.Namespace,
+		Subsystem:   options.Subsystem,
+		Name:        "http_client_retries_total",
+		Help:        "Total number of times HTTP requests were sent again.",
+		ConstLabels: options.ConstLabels,
+	}, m.labels)
+	m.inflight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   options.Namespace,
+		Subsystem:   options.Subsystem,
+		Name:        "http_client_requests_in_flight",
+		Help:        "Number of HTTP requests being executed.",
+		ConstLabels: options.ConstLabels,
+	}, gauge)
+	m.size = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   options.Namespace,
+		Subsystem:   options.Subsystem,
+		Name:        "http_client_response_size_bytes",
+		Help:        "Size of the HTTP response bodies, in bytes.",
+		ConstLabels: options.ConstLabels,
+		Buckets:     sizes,
+	}, m.labels)
+	m.compressed = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   options.Namespace,
+		Subsystem:   options.Subsystem,
+		Name:        "http_client_response_compressed_size_bytes",
+		Help:        "Size of the compressed HTTP response bodies as received, in bytes.",
+		ConstLabels: options.ConstLabels,
+		Buckets:     sizes,
+	}, m.labels)
+	m.phases = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   options.Namespace,
+		Subsystem:   options.Subsystem,
+		Name:        "http_client_request_phase_duration_seconds",
+		Help:        "Time spent in each phase (dns, connect, tls, ttfb) of the HTTP requests, in seconds.",
+		ConstLabels: options.ConstLabels,
+		Buckets:     durations,
+	}, append(append([]string{}, m.labels...), "phase"))
+	if registerer != nil {
+		for _, collector := range []prometheus.Collector{m.requests, m.duration, m.retries, m.inflight, m.size, m.compressed, m.phases} {
+			if err := registerer.Register(collector); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+// Started increments the in-flight gauge.
+func (m *Metrics) Started(req *http.Request) {
+	m.inflight.With(m.values(req, nil)).Inc()
+}
+
+// Finished updates the metrics with the outcome of the request.
+func (m *Metrics) Finished(req *http.Request, outcome request.Outcome) {
+	m.inflight.With(m.values(req, nil)).Dec()
+	labels := m.values(req, &outcome)
+	m.requests.With(labels).Inc()
+	m.duration.With(labels).Observe(outcome.Duration.Seconds())
+	if outcome.Attempts > 1 {
+		m.retries.With(labels).Add(float64(outcome.Attempts - 1))
+	}
+	if outcome.Err == nil {
+		m.size.With(labels).Observe(float64(outcome.Size))
+		if outcome.CompressedSize >= 0 {
+			m.compressed.With(labels).Observe(float64(outcome.CompressedSize))
+		}
+	}
+	if outcome.Attempts > 0 {
+		for phase, duration := range map[string]time.Duration{
+			"dns":     outcome.Timings.DNS,
+			"connect": outcome.Timings.Connect,
+			"tls":     outcome.Timings.TLS,
+			"ttfb":    outcome.Timings.TTFB,
+		} {
+			if duration > 0 {
+				phased := prometheus.Labels{"phase": phase}
+				for key, value := range labels {
+					phased[key] = value
+				}
+				m.phases.With(phased).Observe(duration.Seconds())
+			}
+		}
+	}
+}
+
+// values returns the label values for the given request and outcome (nil for
+// the in-flight gauge, which has no status).
+func (m *Metrics) values(req *http.Request, outcome *request.Outcome) prometheus.Labels {
+	labels := prometheus.Labels{}
+	for _, label := range m.labels {
+		switch label {
+		case Method:
+			labels[label] = req.Method
+		case Host:
+			labels[label] = req.URL.Host
+		case Path:
+			labels[label] = m.normalize(req)
+		case Operation:
+			labels[label] = request.OperationName(req)
+		case Status:
+			if outcome == nil {
+				continue
+			}
+			if outcome.StatusCode == 0 {
+				labels[label] = "error"
+			} else {
+				labels[label] = strconv.Itoa(outcome.StatusCode)
+			}
+		}
+	}
+	return labels
+}
@@ -0,0 +1,90 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package prommetrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	request "github.com/dihedron/go-requestor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	metrics, err := New(registry, &Options{
+		Namespace: "test",
+		Labels:    []string{Method, Path, Status},
+		NormalizePath: func(r *http.Request) string {
+			if strings.HasPrefix(r.URL.Path, "/users/") {
+				return "/users/{id}"
+			}
+			return r.URL.Path
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	requestor := request.NewRequestor(nil).Observe(metrics)
+	api := request.New(server.URL)
+	for _, path := range []string{"/users/1", "/users/2"} {
+		response, err := requestor.Do(context.Background(), api.New("", path))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if testutil.ToFloat64(metrics.inflight.WithLabelValues("GET", "/users/{id}")) != 1 {
+			t.Fatalf("expected the request in flight until its body is closed")
+		}
+		var body []byte
+		response.Decode(&body)
+	}
+	if _, err := requestor.Do(context.Background(), api.New("", "/missing")); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if n := testutil.ToFloat64(metrics.requests.WithLabelValues("GET", "/users/{id}", "200")); n != 2 {
+		t.Fatalf("expected 2 requests, got %v", n)
+	}
+	if n := testutil.ToFloat64(metrics.requests.WithLabelValues("GET", "/missing", "404")); n != 1 {
+		t.Fatalf("expected 1 failed request, got %v", n)
+	}
+	if n := testutil.ToFloat64(metrics.inflight.WithLabelValues("GET", "/users/{id}")); n != 0 {
+		t.Fatalf("expected no requests in flight, got %v", n)
+	}
+	expected := `
+# HELP test_http_client_response_size_bytes Size of the HTTP response bodies, in bytes.
+# TYPE test_http_client_response_size_bytes histogram
+test_http_client_response_size_bytes_bucket{method="GET",path="/users/{id}",status="200",le="100"} 2
+test_http_client_response_size_bytes_bucket{method="GET",path="/users/{id}",status="200",le="1000"} 2
+test_http_client_response_size_bytes_bucket{method="GET",path="/users/{id}",status="200",le="10000"} 2
+test_http_client_response_size_bytes_bucket{method="GET",path="/users/{id}",status="200",le="100000"} 2
+test_http_client_response_size_bytes_bucket{method="GET",path="/users/{id}",status="200",le="1e+06"} 2
+test_http_client_response_size_bytes_bucket{method="GET",path="/users/{id}",status="200",le="1e+07"} 2
+test_http_client_response_size_bytes_bucket{method="GET",path="/users/{id}",status="200",le="1e+08"} 2
+test_http_client_response_size_bytes_bucket{method="GET",path="/users/{id}",status="200",le="+Inf"} 2
+test_http_client_response_size_bytes_sum{method="GET",path="/users/{id}",status="200"} 10
+test_http_client_response_size_bytes_count{method="GET",path="/users/{id}",status="200"} 2
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "test_http_client_response_size_bytes"); err != nil {
+		t.Fatalf("unexpected size metrics: %v", err)
+	}
+
+	if _, err := New(nil, &Options{Labels: []string{"url"}}); err == nil {
+		t.Fatalf("expected error on unsupported label")
+	}
+}
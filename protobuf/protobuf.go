@@ -0,0 +1,150 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package protobuf provides encoding and decoding of Protocol Buffers request
+// and response bodies, in binary or JSON form, including google.protobuf.Any
+// payloads resolved through a type registry; it lives in its own package so
+// that the protobuf runtime is only pulled in by those who need it.
+package protobuf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// ContentType is the media type of binary Protocol Buffers bodies.
+const ContentType = "application/x-protobuf"
+
+// Entity returns the binary encoding of the given message, to be used as a
+// request body along with ContentType, as follows:
+//
+//	body, err := protobuf.Entity(message)
+//	f.WithEntity(body).ContentType(protobuf.ContentType)
+func Entity(message proto.Message) (io.Reader, error) {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// Registry resolves the type URLs of google.protobuf.Any payloads to message
+// types; it is safe for concurrent use once populated.
+type Registry struct {
+	types *protoregistry.Types
+}
+
+// NewRegistry returns a new Registry knowing the types of the given messages.
+func NewRegistry(messages ...proto.Message) (*Registry, error) {
+	registry := &Registry{types: new(protoregistry.Types)}
+	if err := registry.Register(messages...); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// Global is the Registry of all the message types linked into the program.
+var Global = &Registry{types: protoregistry.GlobalTypes}
+
+// Register adds the types of the given messages to the registry.
+func (r *Registry) Register(messages ...proto.Message) error {
+	for _, message := range messages {
+		if err := r.types.RegisterMessage(message.ProtoReflect().Type()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve returns a new, empty message of the type identified by the given type
+// URL (e.g. "type.googleapis.com/google.protobuf.StringValue").
+func (r *Registry) Resolve(url string) (proto.Message, error) {
+	messageType, err := r.types.FindMessageByURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("unknown message type %q: %w", url, err)
+	}
+	return messageType.New().Interface(), nil
+}
+
+// Unpack returns the message held by the given Any, resolving its type.
+func (r *Registry) Unpack(envelope *anypb.Any) (proto.Message, error) {
+	message, err := r.Resolve(envelope.GetTypeUrl())
+	if err != nil {
+		return nil, err
+	}
+	if err := (proto.UnmarshalOptions{Resolver: r.types}).Unmarshal(envelope.GetValue(), message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// Decode unmarshals the given body into the given message, as binary Protocol
+// Buffers or, if the content type is JSON, as protojson; Any fields are
+// resolved through the registry.
+func (r *Registry) Decode(contentType string, data []byte, message proto.Message) error {
+	if isJSON(contentType) {
+		return (protojson.UnmarshalOptions{Resolver: r.types, DiscardUnknown: true}).Unmarshal(data, message)
+	}
+	return (proto.UnmarshalOptions{Resolver: r.types}).Unmarshal(data, message)
+}
+
+// DecodeAny unmarshals the given body as a google.protobuf.Any and returns the
+// message it holds.
+func (r *Registry) DecodeAny(contentType string, data []byte) (proto.Message, error) {
+	envelope := &anypb.Any{}
+	if err := r.Decode(contentType, data, envelope); err != nil {
+		return nil, err
+	}
+	return r.Unpack(envelope)
+}
+
+// DecodeResponse reads the response body and unmarshals it into the given
+// message, according to the response Content-Type; the body is closed.
+func (r *Registry) DecodeResponse(response *http.Response, message proto.Message) error {
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	return r.Decode(response.Header.Get("Content-Type"), data, message)
+}
+
+// DecodeAnyResponse reads the response body as a google.protobuf.Any and
+// returns the message it holds; the body is closed.
+func (r *Registry) DecodeAnyResponse(response *http.Response) (proto.Message, error) {
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	return r.DecodeAny(response.Header.Get("Content-Type"), data)
+}
+
+// Decode unmarshals the given body into the given message, resolving Any
+// fields through the global registry.
+func Decode(contentType string, data []byte, message proto.Message) error {
+	return Global.Decode(contentType, data, message)
+}
+
+// DecodeResponse reads the response body and unmarshals it into the given
+// message, resolving Any fields through the global registry.
+func DecodeResponse(response *http.Response, message proto.Message) error {
+	return Global.DecodeResponse(response, message)
+}
+
+// isJSON returns whether the given content type denotes JSON.
+func isJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,63 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package protobuf
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestDecodeAny(t *testing.T) {
+	envelope, err := anypb.New(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	binary, _ := proto.Marshal(envelope)
+
+	registry, err := NewRegistry(&wrapperspb.StringValue{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	message, err := registry.DecodeAny(ContentType, binary)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value, ok := message.(*wrapperspb.StringValue); !ok || value.GetValue() != "hello" {
+		t.Fatalf("invalid message: %v", message)
+	}
+
+	json, _ := protojson.Marshal(envelope)
+	response := &http.Response{
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   ioutil.NopCloser(strings.NewReader(string(json))),
+	}
+	if message, err = registry.DecodeAnyResponse(response); err != nil || message.(*wrapperspb.StringValue).GetValue() != "hello" {
+		t.Fatalf("invalid message from JSON: %v (%v)", message, err)
+	}
+
+	empty, _ := NewRegistry()
+	if _, err := empty.DecodeAny(ContentType, binary); err == nil {
+		t.Fatalf("expected an error resolving an unregistered type")
+	}
+}
+
+func TestEntity(t *testing.T) {
+	body, err := Entity(wrapperspb.Int64(42))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := ioutil.ReadAll(body)
+	value := &wrapperspb.Int64Value{}
+	if err := Decode(ContentType, data, value); err != nil || value.GetValue() != 42 {
+		t.Fatalf("invalid round trip: %v (%v)", value, err)
+	}
+}
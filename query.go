@@ -0,0 +1,327 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// QueryEncoding is a set of flags controlling how query parameters are
+// serialised, for the servers and signature schemes that are sensitive to the
+// exact encoding; by default, as in url.Values.Encode(), spaces are encoded as
+// "+" and slashes as "%2F".
+type QueryEncoding int
+
+const (
+	// SpacesAsPercent encodes spaces as "%20" rather than "+".
+	SpacesAsPercent QueryEncoding = 1 << iota
+	// PreserveSlashes leaves slashes unescaped.
+	PreserveSlashes
+	// PreserveOrder serialises the query parameters in the order they were
+	// first set, after those in the URL, instead of sorted by key.
+	PreserveOrder
+	// SemicolonSeparators separates the query parameters with semicolons
+	// rather than ampersands, for legacy servers.
+	SemicolonSeparators
+)
+
+// ArrayEncoding is the way a query parameter having more than one value is
+// serialised, since APIs are often strict about the style they accept.
+type ArrayEncoding int8
+
+const (
+	// RepeatedKeys repeats the key for each value, as in "ids=1&ids=2".
+	RepeatedKeys ArrayEncoding = iota
+	// CommaSeparated sends the values in a single parameter, separated by
+	// commas, as in "ids=1,2".
+	CommaSeparated
+	// PipeSeparated sends the values in a single parameter, separated by
+	// pipes, as in "ids=1|2".
+	PipeSeparated
+	// BracketKeys repeats the key for each value, with a PHP/Rails style
+	// suffix, as in "ids[]=1&ids[]=2".
+	BracketKeys
+)
+
+// MapEncoding is the way the entries of a map query parameter (see
+// SetQueryMap) are serialised.
+type MapEncoding int8
+
+const (
+	// BracketMap puts the map keys in brackets, as in "filter[status]=open".
+	BracketMap MapEncoding = iota
+	// DottedMap appends the map keys after a dot, as in "filter.status=open".
+	DottedMap
+)
+
+// QueryEncoding sets how the query parameters of the requests generated by this
+// builder and its children are serialised.
+func (f *Builder) QueryEncoding(encoding QueryEncoding) *Builder {
+	f.encoding = encoding
+	return f
+}
+
+// ArrayEncoding sets how the given query parameters are serialised, even if
+// they have a single value, or how all the query parameters without a specific
+// encoding are if none is given, when they have more than one value; by
+// default, the key is repeated for each value.
+func (f *Builder) ArrayEncoding(encoding ArrayEncoding, keys ...string) *Builder {
+	if f.arrays == nil {
+		f.arrays = map[string]ArrayEncoding{}
+	}
+	if len(keys) == 0 {
+		f.arrays[""] = encoding
+	}
+	for _, key := range keys {
+		f.arrays[key] = encoding
+	}
+	return f
+}
+
+// MapEncoding sets how the entries of the given map query parameters, or of
+// all the map query parameters without a specific encoding if none is given,
+// are serialised; by default, the map keys are put in brackets.
+func (f *Builder) MapEncoding(encoding MapEncoding, keys ...string) *Builder {
+	if f.maps == nil {
+		f.maps = map[string]MapEncoding{}
+	}
+	if len(keys) == 0 {
+		f.maps[""] = encoding
+	}
+	for _, key := range keys {
+		f.maps[key] = encoding
+	}
+	return f
+}
+
+// SetRawQueryParameter replaces the current set of values for the given query
+// parameter with the given values, which are sent verbatim, without being
+// escaped (e.g. pre-signed signatures); they must therefore be valid in a URL
+// query.
+func (f *Builder) SetRawQueryParameter(key string, values ...string) *Builder {
+	f.SetQueryParameter(key, values...)
+	defer f.write()()
+	if f.raw == nil {
+		f.raw = map[string]bool{}
+	}
+	f.raw[key] = true
+	return f
+}
+
+// RawQuery sets a query string that is sent verbatim, without being parsed nor
+// re-encoded, before the query parameters set otherwise, if any.
+func (f *Builder) RawQuery(query string) *Builder {
+	defer f.write()()
+	f.rawQuery = strings.TrimPrefix(query, "?")
+	return f
+}
+
+// track records the given query parameter keys, in the order they are first
+// set.
+func (f *Builder) track(keys ...string) {
+	for _, key := range keys {
+		tracked := false
+		for _, existing := range f.order {
+			if existing == key {
+				tracked = true
+				break
+			}
+		}
+		if !tracked {
+			f.order = append(f.order, key)
+		}
+	}
+}
+
+// SetQueryMap replaces the given map query parameter, and all its entries,
+// with the entries of the given map; nested maps are flattened into nested
+// keys (e.g. "filter[date][from]"), and slices into multiple values, which are
+// serialised according to the ArrayEncoding.
+func (f *Builder) SetQueryMap(key string, value map[string]interface{}) *Builder {
+	defer f.write()()
+	for existing := range f.parameters {
+		if existing == key || strings.HasPrefix(existing, key+"[") {
+			delete(f.parameters, existing)
+		}
+	}
+	flatten(f.parameters, key, value)
+	keys := []string{}
+	for existing := range f.parameters {
+		if strings.HasPrefix(existing, key+"[") {
+			keys = append(keys, existing)
+		}
+	}
+	sort.Strings(keys)
+	f.track(keys...)
+	return f
+}
+
+// flatten adds the given value to the given query parameters under the given
+// key, recursively flattening maps and slices.
+func flatten(parameters url.Values, key string, value interface{}) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			flatten(parameters, fmt.Sprintf("%s[%v]", key, k.Interface()), v.MapIndex(k).Interface())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			parameters.Add(key, fmt.Sprintf("%v", v.Index(i).Interface()))
+		}
+	case reflect.Invalid:
+		parameters.Add(key, "")
+	default:
+		parameters.Add(key, fmt.Sprintf("%v", value))
+	}
+}
+
+// Encode serialises the given values, sorted by key, according to the encoding.
+func (e QueryEncoding) Encode(values url.Values) string {
+	return queryEncoder{flags: e}.encode(values)
+}
+
+// queryEncoder serialises query parameters according to the encoding flags and
+// to the array and map encodings of a builder.
+type queryEncoder struct {
+	flags  QueryEncoding
+	arrays map[string]ArrayEncoding
+	maps   map[string]MapEncoding
+	// raw contains the keys of the query parameters whose values are not
+	// escaped.
+	raw map[string]bool
+	// order contains the keys of the query parameters to serialise first, in
+	// order.
+	order []string
+}
+
+// queryEncoder returns the encoder of the query parameters of the requests
+// generated by the builder, whose URL has the given query, or nil if
+// url.Values.Encode() is enough.
+func (f *Builder) queryEncoder(query string) *queryEncoder {
+	if f.encoding == 0 && len(f.arrays) == 0 && len(f.maps) == 0 && len(f.raw) == 0 {
+		return nil
+	}
+	encoder := &queryEncoder{flags: f.encoding, arrays: f.arrays, maps: f.maps, raw: f.raw}
+	if f.encoding&PreserveOrder != 0 {
+		for _, pair := range strings.Split(query, "&") {
+			if i := strings.IndexByte(pair, '='); i >= 0 {
+				pair = pair[:i]
+			}
+			if key, err := url.QueryUnescape(pair); err == nil && key != "" {
+				encoder.order = append(encoder.order, key)
+			}
+		}
+		encoder.order = append(encoder.order, f.order...)
+	}
+	return encoder
+}
+
+// encode serialises the given values, in order or sorted by key.
+func (e queryEncoder) encode(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	seen := map[string]bool{}
+	for _, key := range e.order {
+		if _, ok := values[key]; ok && !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+	rest := []string{}
+	for key := range values {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+	var buffer strings.Builder
+	write := func(key, value string) {
+		if buffer.Len() > 0 {
+			buffer.WriteByte(e.flags.separator())
+		}
+		buffer.WriteString(key)
+		buffer.WriteByte('=')
+		buffer.WriteString(value)
+	}
+	for _, key := range keys {
+		base := key
+		if i := strings.IndexByte(key, '['); i > 0 && strings.HasSuffix(key, "]") {
+			base = key[:i]
+		}
+		name := e.flags.escape(e.mapKey(key, base))
+		encoding, ok := e.arrays[key]
+		if !ok {
+			encoding, ok = e.arrays[base]
+		}
+		if !ok && len(values[key]) > 1 {
+			encoding = e.arrays[""]
+		}
+		escaped := make([]string, len(values[key]))
+		for i, value := range values[key] {
+			if e.raw[key] {
+				escaped[i] = value
+			} else {
+				escaped[i] = e.flags.escape(value)
+			}
+		}
+		switch encoding {
+		case CommaSeparated:
+			write(name, strings.Join(escaped, ","))
+		case PipeSeparated:
+			write(name, strings.Join(escaped, "|"))
+		case BracketKeys:
+			name = e.flags.escape(e.mapKey(key, base) + "[]")
+			fallthrough
+		default:
+			for _, value := range escaped {
+				write(name, value)
+			}
+		}
+	}
+	return buffer.String()
+}
+
+// mapKey returns the given key of a map query parameter, with the given base
+// key, according to its MapEncoding.
+func (e queryEncoder) mapKey(key, base string) string {
+	if key == base {
+		return key
+	}
+	encoding, ok := e.maps[base]
+	if !ok {
+		encoding = e.maps[""]
+	}
+	if encoding == DottedMap {
+		return base + strings.Replace(strings.Replace(strings.TrimSuffix(key[len(base):], "]"), "][", ".", -1), "[", ".", 1)
+	}
+	return key
+}
+
+// separator returns the separator of query parameters according to the
+// encoding.
+func (e QueryEncoding) separator() byte {
+	if e&SemicolonSeparators != 0 {
+		return ';'
+	}
+	return '&'
+}
+
+// escape escapes a query key or value according to the encoding; since
+// url.QueryEscape escapes literal plus signs, those left are all spaces.
+func (e QueryEncoding) escape(s string) string {
+	s = url.QueryEscape(s)
+	if e&SpacesAsPercent != 0 {
+		s = strings.Replace(s, "+", "%20", -1)
+	}
+	if e&PreserveSlashes != 0 {
+		s = strings.Replace(s, "%2F", "/", -1)
+	}
+	return s
+}
@@ -0,0 +1,169 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"testing"
+)
+
+func TestQueryEncoding(t *testing.T) {
+	base := New("http://www.example.com/search").
+		Add().QueryParameter("q", "a b+c").
+		Add().QueryParameter("path", "/usr/local")
+	testCases := []struct {
+		encoding QueryEncoding
+		expected string
+	}{
+		{0, "path=%2Fusr%2Flocal&q=a+b%2Bc"},
+		{SpacesAsPercent, "path=%2Fusr%2Flocal&q=a%20b%2Bc"},
+		{PreserveSlashes, "path=/usr/local&q=a+b%2Bc"},
+		{SpacesAsPercent | PreserveSlashes, "path=/usr/local&q=a%20b%2Bc"},
+	}
+	for _, test := range testCases {
+		request, err := base.New("", "").QueryEncoding(test.encoding).Make()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if request.URL.RawQuery != test.expected {
+			t.Fatalf("encoding %d: expected %q, got %q", test.encoding, test.expected, request.URL.RawQuery)
+		}
+		if request.URL.Query().Get("q") != "a b+c" {
+			t.Fatalf("encoding %d: query does not decode back: %q", test.encoding, request.URL.Query().Get("q"))
+		}
+	}
+}
+
+func TestArrayEncoding(t *testing.T) {
+	base := New("http://www.example.com/search").
+		SetQueryParameter("ids", "1", "2").
+		SetQueryParameter("q", "a b")
+	testCases := []struct {
+		setup    func(f *Builder) *Builder
+		expected string
+	}{
+		{func(f *Builder) *Builder { return f }, "ids=1&ids=2&q=a+b"},
+		{func(f *Builder) *Builder { return f.ArrayEncoding(CommaSeparated) }, "ids=1,2&q=a+b"},
+		{func(f *Builder) *Builder { return f.ArrayEncoding(PipeSeparated) }, "ids=1|2&q=a+b"},
+		{func(f *Builder) *Builder { return f.ArrayEncoding(BracketKeys) }, "ids%5B%5D=1&ids%5B%5D=2&q=a+b"},
+		{func(f *Builder) *Builder { return f.ArrayEncoding(BracketKeys, "q") }, "ids=1&ids=2&q%5B%5D=a+b"},
+		{func(f *Builder) *Builder { return f.ArrayEncoding(CommaSeparated).ArrayEncoding(RepeatedKeys, "ids") }, "ids=1&ids=2&q=a+b"},
+		{func(f *Builder) *Builder { return f.QueryEncoding(SpacesAsPercent).ArrayEncoding(CommaSeparated) }, "ids=1,2&q=a%20b"},
+	}
+	for i, test := range testCases {
+		request, err := test.setup(base.New("", "")).Make()
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if request.URL.RawQuery != test.expected {
+			t.Fatalf("test %d: expected %q, got %q", i, test.expected, request.URL.RawQuery)
+		}
+	}
+	if _, ok := base.arrays[""]; ok {
+		t.Fatalf("child encoding leaked into the parent")
+	}
+}
+
+func TestMapEncoding(t *testing.T) {
+	base := New("http://www.example.com/search").
+		SetQueryParameter("filter", "stale").
+		SetQueryParameter("filter[stale]", "stale").
+		SetQueryMap("filter", map[string]interface{}{
+			"status": "open",
+			"date":   map[string]string{"from": "2020"},
+			"tags":   []string{"a", "b"},
+		})
+	testCases := []struct {
+		setup    func(f *Builder) *Builder
+		expected string
+	}{
+		{func(f *Builder) *Builder { return f }, "filter%5Bdate%5D%5Bfrom%5D=2020&filter%5Bstatus%5D=open&filter%5Btags%5D=a&filter%5Btags%5D=b"},
+		{func(f *Builder) *Builder { return f.MapEncoding(DottedMap) }, "filter.date.from=2020&filter.status=open&filter.tags=a&filter.tags=b"},
+		{func(f *Builder) *Builder { return f.MapEncoding(DottedMap, "filter").ArrayEncoding(BracketKeys) }, "filter.date.from=2020&filter.status=open&filter.tags%5B%5D=a&filter.tags%5B%5D=b"},
+		{func(f *Builder) *Builder {
+			return f.MapEncoding(DottedMap, "other").ArrayEncoding(CommaSeparated, "filter")
+		}, "filter%5Bdate%5D%5Bfrom%5D=2020&filter%5Bstatus%5D=open&filter%5Btags%5D=a,b"},
+	}
+	for i, test := range testCases {
+		request, err := test.setup(base.New("", "")).Make()
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if request.URL.RawQuery != test.expected {
+			t.Fatalf("test %d: expected %q, got %q", i, test.expected, request.URL.RawQuery)
+		}
+	}
+}
+
+func TestPreserveOrder(t *testing.T) {
+	f := New("http://www.example.com/search?z=1&a=2").
+		SetQueryParameter("y", "3").
+		AddQueryParameter("b", "4").
+		SetQueryParameter("y", "5").
+		QueryEncoding(PreserveOrder)
+	request, err := f.Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "z=1&a=2&y=5&b=4"; request.URL.RawQuery != expected {
+		t.Fatalf("expected %q, got %q", expected, request.URL.RawQuery)
+	}
+	request, err = f.New("", "").AddQueryParameter("c", "6").QueryEncoding(0).Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "a=2&b=4&c=6&y=5&z=1"; request.URL.RawQuery != expected {
+		t.Fatalf("expected %q, got %q", expected, request.URL.RawQuery)
+	}
+}
+
+func TestRawQuery(t *testing.T) {
+	signature := "abc%2Bdef%3D"
+	f := New("http://www.example.com/object").
+		SetQueryParameter("q", "a b").
+		SetRawQueryParameter("X-Signature", signature)
+	request, err := f.Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "X-Signature=abc%2Bdef%3D&q=a+b"; request.URL.RawQuery != expected {
+		t.Fatalf("expected %q, got %q", expected, request.URL.RawQuery)
+	}
+	request, err = f.New("", "").SetQueryParameter("X-Signature", "a+b").Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "X-Signature=a%2Bb&q=a+b"; request.URL.RawQuery != expected {
+		t.Fatalf("expected %q, got %q", expected, request.URL.RawQuery)
+	}
+	request, err = New("http://www.example.com/object").RawQuery("?b=%7E;a=1").Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "b=%7E;a=1"; request.URL.RawQuery != expected {
+		t.Fatalf("expected %q, got %q", expected, request.URL.RawQuery)
+	}
+	request, err = f.New("", "").RawQuery("b=%7E").Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "b=%7E&X-Signature=abc%2Bdef%3D&q=a+b"; request.URL.RawQuery != expected {
+		t.Fatalf("expected %q, got %q", expected, request.URL.RawQuery)
+	}
+}
+
+func TestSemicolonSeparators(t *testing.T) {
+	request, err := New("http://www.example.com/search").
+		SetQueryParameter("a", "1").
+		SetQueryParameter("b", "2").
+		RawQuery("c=3").
+		QueryEncoding(SemicolonSeparators).
+		Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "c=3;a=1;b=2"; request.URL.RawQuery != expected {
+		t.Fatalf("expected %q, got %q", expected, request.URL.RawQuery)
+	}
+}
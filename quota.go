@@ -0,0 +1,210 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// Quota is the server-side rate limit quota of a target host, as advertised by
+// rate limit response headers.
+type Quota struct {
+	// Limit is the maximum number of requests in the current window, or -1 if not
+	// advertised.
+	Limit int
+	// Remaining is the number of requests left in the current window.
+	Remaining int
+	// Reset is the time at which the quota will be replenished.
+	Reset time.Time
+	// Updated is the time at which the quota was last observed.
+	Updated time.Time
+}
+
+// ParseQuota extracts the rate limit quota from the response headers; it
+// supports the de-facto standard X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset headers (the latter either as a Unix timestamp or as a
+// number of seconds), the IETF draft RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers, and the structured "RateLimit: limit=100,
+// remaining=50, reset=30" header of later drafts. It returns false if the
+// headers do not advertise any quota.
+func ParseQuota(header http.Header, now time.Time) (Quota, bool) {
+	quota := Quota{Limit: -1, Updated: now}
+	var remaining, reset string
+	if value := header.Get("RateLimit"); value != "" {
+		for _, token := range strings.Split(value, ",") {
+			kv := strings.SplitN(strings.TrimSpace(token), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch strings.ToLower(strings.TrimSpace(kv[0])) {
+			case "limit":
+				quota.Limit = atoi(kv[1], -1)
+			case "remaining", "r":
+				remaining = kv[1]
+			case "reset", "t":
+				reset = kv[1]
+			}
+		}
+	}
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-"} {
+		if remaining == "" {
+			remaining = header.Get(prefix + "Remaining")
+			if quota.Limit < 0 {
+				quota.Limit = atoi(header.Get(prefix+"Limit"), -1)
+			}
+			if reset == "" {
+				reset = header.Get(prefix + "Reset")
+			}
+		}
+	}
+	if remaining == "" {
+		return quota, false
+	}
+	quota.Remaining = atoi(remaining, 0)
+	if seconds, err := strconv.ParseInt(strings.TrimSpace(reset), 10, 64); err == nil {
+		// values larger than any sensible window are Unix timestamps
+		if seconds > 10*365*24*3600 {
+			quota.Reset = time.Unix(seconds, 0)
+		} else {
+			quota.Reset = now.Add(time.Duration(seconds) * time.Second)
+		}
+	}
+	return quota, true
+}
+
+// RetryAfter parses the "Retry-After" response header, expressed either as a
+// number of seconds or as an HTTP date, and returns how long to wait before
+// retrying.
+func RetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			seconds = 0
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// RetryAfter returns how long the server asked to wait before retrying, as per
+// the response "Retry-After" header, if any.
+func (e *HTTPError) RetryAfter() (time.Duration, bool) {
+	return RetryAfter(e.Header, time.Now())
+}
+
+func atoi(s string, fallback int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
+// AdaptiveThrottle makes the Requestor honour the server-side rate limit quotas
+// advertised by rate limit response headers (see ParseQuota) and by the
+// Retry-After header of "429 Too Many Requests" responses: when the quota of a
+// host is exhausted, subsequent requests to it are paused until the quota is
+// reset; if pace is set, requests are also spread evenly over the remaining
+// window, so that the quota lasts until the reset.
+func (r *Requestor) AdaptiveThrottle(pace bool) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.adaptive = true
+	r.pace = pace
+	return r
+}
+
+// Quota returns the last observed rate limit quota for the given target host
+// (as in URL.Host); quotas are observed whether adaptive throttling is enabled
+// or not.
+func (r *Requestor) Quota(host string) (Quota, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	quota, ok := r.quotas[strings.ToLower(host)]
+	return quota, ok
+}
+
+// observe updates the quota of the given host from the response headers.
+func (r *Requestor) observe(host string, response *http.Response) {
+	now := time.Now()
+	quota, ok := ParseQuota(response.Header, now)
+	if response.StatusCode == http.StatusTooManyRequests {
+		if delay, found := RetryAfter(response.Header, now); found {
+			if !ok {
+				quota = Quota{Limit: -1, Updated: now}
+			}
+			quota.Remaining = 0
+			quota.Reset = now.Add(delay)
+			ok = true
+		}
+	}
+	if !ok {
+		return
+	}
+	log.Debugf("quota for %q: %d/%d, reset at %v", host, quota.Remaining, quota.Limit, quota.Reset)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.quotas[strings.ToLower(host)] = quota
+}
+
+// pause waits, if adaptive throttling is enabled, until the quota of the host
+// the given request is directed to allows sending it.
+func (r *Requestor) pause(request *http.Request) error {
+	host := strings.ToLower(request.URL.Host)
+	r.lock.Lock()
+	if !r.adaptive {
+		r.lock.Unlock()
+		return nil
+	}
+	quota, ok := r.quotas[host]
+	now := time.Now()
+	var delay time.Duration
+	if ok && quota.Reset.After(now) {
+		if quota.Remaining <= 0 {
+			delay = quota.Reset.Sub(now)
+		} else if r.pace {
+			delay = quota.Reset.Sub(now) / time.Duration(quota.Remaining+1)
+		}
+		// account for this request until the server tells otherwise
+		quota.Remaining--
+		r.quotas[host] = quota
+	}
+	failFast := r.failFast
+	r.lock.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	if failFast {
+		return ErrRateLimitExceeded
+	}
+	if deadline, ok := request.Context().Deadline(); ok && deadline.Before(now.Add(delay)) {
+		return context.DeadlineExceeded
+	}
+	log.Debugf("quota for %q requires waiting %v", host, delay)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-request.Context().Done():
+		return request.Context().Err()
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseQuota(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		header    http.Header
+		limit     int
+		remaining int
+		reset     time.Time
+	}{
+		{
+			header:    http.Header{"X-Ratelimit-Limit": {"5000"}, "X-Ratelimit-Remaining": {"4999"}, "X-Ratelimit-Reset": {"1700003600"}},
+			limit:     5000,
+			remaining: 4999,
+			reset:     time.Unix(1700003600, 0),
+		},
+		{
+			header:    http.Header{"Ratelimit-Limit": {"100"}, "Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"30"}},
+			limit:     100,
+			remaining: 0,
+			reset:     now.Add(30 * time.Second),
+		},
+		{
+			header:    http.Header{"Ratelimit": {"limit=10, remaining=3, reset=5"}},
+			limit:     10,
+			remaining: 3,
+			reset:     now.Add(5 * time.Second),
+		},
+	}
+	for i, test := range tests {
+		quota, ok := ParseQuota(test.header, now)
+		if !ok {
+			t.Fatalf("test %d: expected quota", i)
+		}
+		if quota.Limit != test.limit || quota.Remaining != test.remaining || !quota.Reset.Equal(test.reset) {
+			t.Fatalf("test %d: invalid quota %+v", i, quota)
+		}
+	}
+	if _, ok := ParseQuota(http.Header{}, now); ok {
+		t.Fatalf("expected no quota")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if d, ok := RetryAfter(http.Header{"Retry-After": {"120"}}, now); !ok || d != 2*time.Minute {
+		t.Fatalf("invalid delay in seconds: %v", d)
+	}
+	if d, ok := RetryAfter(http.Header{"Retry-After": {"Wed, 01 Jan 2020 00:00:30 GMT"}}, now); !ok || d != 30*time.Second {
+		t.Fatalf("invalid delay as date: %v", d)
+	}
+	if _, ok := RetryAfter(http.Header{"Retry-After": {"soon"}}, now); ok {
+		t.Fatalf("expected invalid value to be ignored")
+	}
+	e := &HTTPError{Header: http.Header{"Retry-After": {"5"}}}
+	if d, ok := e.RetryAfter(); !ok || d != 5*time.Second {
+		t.Fatalf("invalid delay from error: %v", d)
+	}
+}
+
+func TestAdaptiveThrottle(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("RateLimit-Remaining", "0")
+			w.Header().Set("RateLimit-Reset", "1")
+		} else {
+			w.Header().Set("RateLimit-Remaining", "10")
+		}
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	requestor := NewRequestor(getClient()).AdaptiveThrottle(false)
+	response, err := requestor.Do(context.Background(), New(server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if quota, ok := requestor.Quota(u.Host); !ok || quota.Remaining != 0 {
+		t.Fatalf("invalid quota: %+v", quota)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := requestor.Do(ctx, New(server.URL)); err != context.DeadlineExceeded {
+		t.Fatalf("expected request to be paused beyond deadline, got %v", err)
+	}
+
+	requestor.RateLimitFailFast(true)
+	if _, err := requestor.Do(context.Background(), New(server.URL)); err != ErrRateLimitExceeded {
+		t.Fatalf("expected fail fast on exhausted quota, got %v", err)
+	}
+
+	requestor.RateLimitFailFast(false)
+	started := time.Now()
+	response, err = requestor.Do(context.Background(), New(server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if elapsed := time.Since(started); elapsed < 500*time.Millisecond {
+		t.Fatalf("expected request to wait for quota reset, waited %v", elapsed)
+	}
+	if quota, _ := requestor.Quota(u.Host); quota.Remaining != 10 {
+		t.Fatalf("invalid quota after reset: %+v", quota)
+	}
+}
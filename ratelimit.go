@@ -0,0 +1,234 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// ErrRateLimitExceeded is returned when a request cannot be sent immediately
+// because of a client-side rate limit, and the limit is configured to fail fast
+// instead of waiting.
+var ErrRateLimitExceeded = errors.New("client-side rate limit exceeded")
+
+// Limiter is a client-side rate limiter; implementations may keep their state
+// locally (as RateLimiter does) or share it among processes, so that a quota
+// is respected globally.
+type Limiter interface {
+	// Wait blocks until the request can be sent or the context is done.
+	Wait(ctx context.Context) error
+	// TryAcquire lets the request through if it can be sent immediately, and
+	// returns whether it did.
+	TryAcquire(ctx context.Context) (bool, error)
+}
+
+// RateLimiter is a token bucket rate limiter: the bucket holds up to burst
+// tokens and is refilled at a rate of rps tokens per second; each request
+// consumes one token. It is safe for concurrent use.
+type RateLimiter struct {
+	lock     sync.Mutex
+	rate     float64
+	burst    int
+	tokens   float64
+	last     time.Time
+	failFast bool
+}
+
+// NewRateLimiter returns a new RateLimiter allowing rps requests per second on
+// average, with bursts of up to burst requests; the bucket is initially full.
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rps,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// FailFast makes the limiter return ErrRateLimitExceeded instead of waiting
+// when no token is available.
+func (l *RateLimiter) FailFast(failFast bool) *RateLimiter {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.failFast = failFast
+	return l
+}
+
+// Allow consumes a token if one is immediately available, and returns whether
+// it did.
+func (l *RateLimiter) Allow() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.refill(time.Now())
+	if l.tokens >= 1 {
+		l.tokens--
+		return true
+	}
+	return false
+}
+
+// TryAcquire consumes a token if one is immediately available, and returns
+// whether it did.
+func (l *RateLimiter) TryAcquire(ctx context.Context) (bool, error) {
+	return l.Allow(), nil
+}
+
+// Wait blocks until a token is available or the context is done; if the limiter
+// fails fast, or the context deadline would expire before a token becomes
+// available, it returns immediately with an error.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	l.lock.Lock()
+	now := time.Now()
+	l.refill(now)
+	if l.tokens >= 1 {
+		l.tokens--
+		l.lock.Unlock()
+		return nil
+	}
+	if l.failFast || l.rate <= 0 {
+		l.lock.Unlock()
+		return ErrRateLimitExceeded
+	}
+	delay := time.Duration(math.Ceil((1 - l.tokens) / l.rate * float64(time.Second)))
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(delay)) {
+		l.lock.Unlock()
+		return context.DeadlineExceeded
+	}
+	// reserve the token, so that concurrent waiters queue up behind this one
+	l.tokens--
+	l.lock.Unlock()
+
+	log.Debugf("rate limit reached, waiting %v", delay)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// give the reserved token back
+		l.lock.Lock()
+		l.tokens++
+		l.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+// refill adds the tokens accumulated since the last refill; it must be called
+// with the lock held.
+func (l *RateLimiter) refill(now time.Time) {
+	elapsed := now.Sub(l.last).Seconds()
+	l.last = now
+	if elapsed > 0 {
+		l.tokens = math.Min(float64(l.burst), l.tokens+elapsed*l.rate)
+	}
+}
+
+// RateLimit sets a client-side rate limit, shared by this builder and by all
+// the children created from it afterwards, by which the Requestor throttles the
+// requests generated by them to rps requests per second on average, with bursts
+// of up to burst requests.
+func (f *Builder) RateLimit(rps float64, burst int) *Builder {
+	return f.Limiter(NewRateLimiter(rps, burst))
+}
+
+// Limiter sets a custom client-side rate limiter (e.g. a distributed one),
+// shared by this builder and by all the children created from it afterwards.
+func (f *Builder) Limiter(limiter Limiter) *Builder {
+	f.limiter = limiter
+	return f
+}
+
+// RateLimit sets the same rate limit on each target host; each host has its own
+// token bucket, created as soon as the first request is sent to it.
+func (r *Requestor) RateLimit(rps float64, burst int) *Requestor {
+	return r.HostLimiters(func(host string) Limiter {
+		return NewRateLimiter(rps, burst)
+	})
+}
+
+// HostLimiters sets a function that creates the rate limiter of each target
+// host, as soon as the first request is sent to it; this allows for instance to
+// use distributed limiters keyed by host.
+func (r *Requestor) HostLimiters(factory func(host string) Limiter) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.limiterFactory = factory
+	return r
+}
+
+// HostRateLimit sets a rate limit on the given target host (as in URL.Host,
+// i.e. including the port if not the default one), overriding the one set via
+// RateLimit().
+func (r *Requestor) HostRateLimit(host string, rps float64, burst int) *Requestor {
+	return r.HostLimiter(host, NewRateLimiter(rps, burst))
+}
+
+// HostLimiter sets a custom rate limiter on the given target host, overriding
+// the one created via RateLimit() or HostLimiters().
+func (r *Requestor) HostLimiter(host string, limiter Limiter) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.limiters[strings.ToLower(host)] = limiter
+	return r
+}
+
+// RateLimitFailFast makes the Requestor fail with ErrRateLimitExceeded instead of
+// waiting when a request exceeds a builder or per-host rate limit.
+func (r *Requestor) RateLimitFailFast(failFast bool) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.failFast = failFast
+	return r
+}
+
+// throttle waits until the given request can be sent according to the builder
+// and per-host rate limits.
+func (r *Requestor) throttle(f *Builder, request *http.Request) error {
+	for _, limiter := range []Limiter{f.limiter, r.hostLimiter(request.URL.Host)} {
+		if limiter == nil {
+			continue
+		}
+		if r.failFast {
+			ok, err := limiter.TryAcquire(request.Context())
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return ErrRateLimitExceeded
+			}
+		} else if err := limiter.Wait(request.Context()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hostLimiter returns the rate limiter for the given host, creating it if a
+// per-host limiter factory is set.
+func (r *Requestor) hostLimiter(host string) Limiter {
+	host = strings.ToLower(host)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	limiter, ok := r.limiters[host]
+	if !ok && r.limiterFactory != nil {
+		limiter = r.limiterFactory(host)
+		r.limiters[host] = limiter
+	}
+	return limiter
+}
@@ -0,0 +1,91 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(100, 2)
+	if !limiter.Allow() || !limiter.Allow() {
+		t.Fatalf("expected burst of 2 to be allowed")
+	}
+	if limiter.Allow() {
+		t.Fatalf("expected third request to be throttled")
+	}
+	started := time.Now()
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 5*time.Millisecond {
+		t.Fatalf("expected Wait to block for about 10ms, blocked %v", elapsed)
+	}
+
+	limiter = NewRateLimiter(0.1, 1)
+	limiter.Allow()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if err := limiter.FailFast(true).Wait(context.Background()); err != ErrRateLimitExceeded {
+		t.Fatalf("expected fail fast, got %v", err)
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	limiter := NewRateLimiter(1, 1)
+	limiter.Allow()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err := limiter.Wait(ctx); err != context.Canceled {
+		t.Fatalf("expected context cancelled, got %v", err)
+	}
+}
+
+func TestRequestorRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient()).RateLimit(1, 1).RateLimitFailFast(true)
+	f := New(server.URL)
+	response, err := requestor.Do(context.Background(), f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if _, err := requestor.Do(context.Background(), f); err != ErrRateLimitExceeded {
+		t.Fatalf("expected per-host rate limit to be exceeded, got %v", err)
+	}
+
+	requestor = NewRequestor(getClient()).RateLimitFailFast(true)
+	f = New(server.URL).RateLimit(1, 1)
+	child := f.New("", "/child")
+	response, err = requestor.Do(context.Background(), f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if _, err := requestor.Do(context.Background(), child); err != ErrRateLimitExceeded {
+		t.Fatalf("expected builder rate limit to be shared with child, got %v", err)
+	}
+
+	requestor = NewRequestor(getClient()).HostRateLimit("other.example.com", 1, 1).RateLimitFailFast(true)
+	for i := 0; i < 3; i++ {
+		response, err := requestor.Do(context.Background(), New(server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error on unlimited host: %v", err)
+		}
+		response.Body.Close()
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// sensitive holds the names of the headers and query parameters whose values
+// are redacted when requests are logged, exported or recorded.
+var sensitive = struct {
+	lock       sync.RWMutex
+	headers    map[string]bool
+	parameters map[string]bool
+}{
+	headers: map[string]bool{
+		"Authorization":       true,
+		"Proxy-Authorization": true,
+		"Cookie":              true,
+		"Set-Cookie":          true,
+	},
+	parameters: map[string]bool{},
+}
+
+// RegisterSensitiveHeaders marks the given headers (in addition to
+// Authorization, Proxy-Authorization, Cookie and Set-Cookie) as carrying
+// secrets, so that their values are redacted in logs, curl commands (unless
+// credentials are included), VCR cassettes, results and errors.
+func RegisterSensitiveHeaders(names ...string) {
+	sensitive.lock.Lock()
+	defer sensitive.lock.Unlock()
+	for _, name := range names {
+		sensitive.headers[http.CanonicalHeaderKey(name)] = true
+	}
+}
+
+// RegisterSensitiveQueryParameters marks the given query parameters as carrying
+// secrets, so that their values are redacted in logs, curl commands (unless
+// credentials are included), VCR cassettes, results and errors.
+func RegisterSensitiveQueryParameters(names ...string) {
+	sensitive.lock.Lock()
+	defer sensitive.lock.Unlock()
+	for _, name := range names {
+		sensitive.parameters[name] = true
+	}
+}
+
+// RedactHeader returns a copy of the given header, with the values of the
+// sensitive headers replaced with "[REDACTED]".
+func RedactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	sensitive.lock.RLock()
+	defer sensitive.lock.RUnlock()
+	for name := range sensitive.headers {
+		redact(redacted, name)
+	}
+	return redacted
+}
+
+// RedactURL returns the given URL, with the password and the values of the
+// sensitive query parameters replaced with "[REDACTED]".
+func RedactURL(u *url.URL) string {
+	redacted := *u
+	if _, ok := redacted.User.Password(); ok {
+		redacted.User = url.UserPassword(redacted.User.Username(), Redacted)
+	}
+	redactQuery(&redacted)
+	// keep the marker readable, rather than percent-encoded
+	return strings.Replace(redacted.String(), url.QueryEscape(Redacted), Redacted, -1)
+}
+
+// sensitiveHeader returns whether the given header carries secrets.
+func sensitiveHeader(name string) bool {
+	sensitive.lock.RLock()
+	defer sensitive.lock.RUnlock()
+	return sensitive.headers[http.CanonicalHeaderKey(name)]
+}
+
+// sensitiveParameter returns whether the given query parameter carries secrets.
+func sensitiveParameter(name string) bool {
+	sensitive.lock.RLock()
+	defer sensitive.lock.RUnlock()
+	return sensitive.parameters[name]
+}
+
+// redactRawURL returns the given URL as in RedactURL, or as is if it does not
+// parse (e.g. a template with unbound variables).
+func redactRawURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return RedactURL(u)
+}
+
+// redactQuery replaces the values of the sensitive query parameters in the
+// given URL with "[REDACTED]".
+func redactQuery(u *url.URL) {
+	sensitive.lock.RLock()
+	defer sensitive.lock.RUnlock()
+	if len(sensitive.parameters) == 0 || u.RawQuery == "" {
+		return
+	}
+	query := u.Query()
+	changed := false
+	for name, values := range query {
+		if sensitive.parameters[name] {
+			for i := range values {
+				values[i] = Redacted
+			}
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = query.Encode()
+	}
+}
+
+// APIKeyInQuery sets the given query parameter to the given API key, for the
+// providers requiring credentials in the URL, and registers the parameter as
+// sensitive (see RegisterSensitiveQueryParameters), so that its value is
+// redacted in logs, curl commands, VCR cassettes, results and errors.
+func (f *Builder) APIKeyInQuery(name, value string) *Builder {
+	RegisterSensitiveQueryParameters(name)
+	return f.SetQueryParameter(name, value)
+}
+
+// redactText redacts the given URL, as per RedactURL, wherever it appears in
+// the given text (e.g. an error message).
+func redactText(text string, u *url.URL) string {
+	redacted := RedactURL(u)
+	text = strings.Replace(text, u.String(), redacted, -1)
+	if u.RawQuery != "" {
+		if index := strings.IndexByte(redacted, '?'); index >= 0 {
+			text = strings.Replace(text, "?"+u.RawQuery, redacted[index:], -1)
+		}
+	}
+	return text
+}
@@ -0,0 +1,119 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redisstore
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	request "github.com/dihedron/go-requestor"
+)
+
+// bucket is the Lua script implementing the token bucket atomically on the
+// server; it uses the server clock, so that all clients agree on time, and
+// returns the number of microseconds to wait before a token is available (0 if
+// one was consumed, -1 if the bucket is never refilled).
+var bucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1]) or burst
+local updated = tonumber(state[2]) or now
+if rate > 0 then
+	tokens = math.min(burst, tokens + math.max(0, now - updated) * rate / 1000000)
+end
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+elseif rate > 0 then
+	wait = math.ceil((1 - tokens) * 1000000 / rate)
+else
+	wait = -1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+if rate > 0 then
+	redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+end
+return wait
+`)
+
+// Limiter is a token bucket rate limiter whose state is kept in Redis, so that
+// the same quota is enforced across all the processes sharing the key; it
+// satisfies the request package Limiter interface.
+type Limiter struct {
+	client redis.UniversalClient
+	key    string
+	rate   float64
+	burst  int
+}
+
+// NewLimiter returns a new Limiter allowing rps requests per second on
+// average, with bursts of up to burst requests, across all the clients using
+// the same key; if rps is not positive, the bucket is never refilled, and Wait
+// fails with request.ErrRateLimitExceeded once the burst is consumed, as with
+// request.RateLimiter.
+func NewLimiter(client redis.UniversalClient, key string, rps float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	if rps < 0 || math.IsNaN(rps) {
+		rps = 0
+	}
+	return &Limiter{
+		client: client,
+		key:    key,
+		rate:   rps,
+		burst:  burst,
+	}
+}
+
+// take tries to consume a token, and returns how long to wait for the next one
+// if none is available; it fails with request.ErrRateLimitExceeded if no token
+// will ever be available.
+func (l *Limiter) take(ctx context.Context) (time.Duration, error) {
+	wait, err := bucket.Run(ctx, l.client, []string{l.key}, l.rate, l.burst).Int64()
+	if err != nil {
+		return 0, err
+	} else if wait < 0 {
+		return 0, request.ErrRateLimitExceeded
+	}
+	return time.Duration(wait) * time.Microsecond, nil
+}
+
+// TryAcquire consumes a token if one is immediately available, and returns
+// whether it did.
+func (l *Limiter) TryAcquire(ctx context.Context) (bool, error) {
+	wait, err := l.take(ctx)
+	if err == request.ErrRateLimitExceeded {
+		return false, nil
+	}
+	return err == nil && wait == 0, err
+}
+
+// Wait blocks until a token is available or the context is done; since other
+// clients compete for the same tokens, it retries until it gets one.
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		wait, err := l.take(ctx)
+		if err != nil || wait == 0 {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(time.Now().Add(wait)) {
+			return context.DeadlineExceeded
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
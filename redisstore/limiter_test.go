@@ -0,0 +1,151 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redisstore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	request "github.com/dihedron/go-requestor"
+)
+
+var _ request.Limiter = (*Limiter)(nil)
+
+func newLimiter(t *testing.T, server *miniredis.Miniredis, rps float64, burst int) *Limiter {
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewLimiter(client, "limiter/api", rps, burst)
+}
+
+// acquire returns how many tokens can be consumed right away.
+func acquire(t *testing.T, limiter *Limiter) int {
+	for n := 0; ; n++ {
+		ok, err := limiter.TryAcquire(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !ok {
+			return n
+		}
+	}
+}
+
+func TestLimiterBurstAndRefill(t *testing.T) {
+	server := miniredis.RunT(t)
+	now := time.Now()
+	server.SetTime(now)
+	limiter := newLimiter(t, server, 10, 3)
+	if n := acquire(t, limiter); n != 3 {
+		t.Fatalf("expected a burst of 3 tokens, got %d", n)
+	}
+	if wait, err := limiter.take(context.Background()); err != nil || wait != 100*time.Millisecond {
+		t.Fatalf("expected to wait 100ms for the next token, got %v (%v)", wait, err)
+	}
+	server.SetTime(now.Add(100 * time.Millisecond))
+	if n := acquire(t, limiter); n != 1 {
+		t.Fatalf("expected 1 token to be refilled, got %d", n)
+	}
+	server.SetTime(now.Add(time.Minute))
+	if n := acquire(t, limiter); n != 3 {
+		t.Fatalf("expected the refill to be capped at the burst, got %d", n)
+	}
+	if ttl := server.TTL("limiter/api"); ttl <= 0 || ttl > 2*time.Second {
+		t.Fatalf("expected the bucket to expire once full, got %v", ttl)
+	}
+}
+
+func TestLimiterConcurrentAcquire(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.SetTime(time.Now())
+	limiters := []*Limiter{newLimiter(t, server, 1, 5), newLimiter(t, server, 1, 5)}
+	var acquired int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(limiter *Limiter) {
+			defer wg.Done()
+			ok, err := limiter.TryAcquire(context.Background())
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if ok {
+				atomic.AddInt32(&acquired, 1)
+			}
+		}(limiters[i%2])
+	}
+	wg.Wait()
+	if acquired != 5 {
+		t.Fatalf("expected the clients to share 5 tokens, got %d", acquired)
+	}
+}
+
+func TestLimiterWait(t *testing.T) {
+	server := miniredis.RunT(t)
+	limiter := newLimiter(t, server, 50, 1)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("expected to wait for the tokens to be refilled, got %v", elapsed)
+	}
+
+	server = miniredis.RunT(t)
+	server.SetTime(time.Now())
+	limiter = newLimiter(t, server, 1, 1)
+	if ok, err := limiter.TryAcquire(context.Background()); !ok || err != nil {
+		t.Fatalf("expected the token to be acquired, got %t (%v)", ok, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+}
+
+func TestSharedCircuitBreaker(t *testing.T) {
+	server := miniredis.RunT(t)
+	first := request.NewCircuitBreaker("api", 2, time.Minute).Share(newStore(t, server))
+	second := request.NewCircuitBreaker("api", 2, time.Minute).Share(newStore(t, server))
+	first.Allow()
+	first.Failure()
+	second.Allow()
+	second.Failure()
+	if first.State() != request.CircuitOpen || second.State() != request.CircuitOpen {
+		t.Fatalf("shared breakers should both be open, got %v and %v", first.State(), second.State())
+	}
+	if err := first.Allow(); err != request.ErrCircuitOpen {
+		t.Fatalf("expected open circuit, got %v", err)
+	}
+	if !server.Exists("requestor:circuit/api") || server.TTL("requestor:circuit/api") <= 0 {
+		t.Fatalf("expected the shared state to be stored with a TTL, got %v", server.Keys())
+	}
+	server.Close()
+	if err := second.Allow(); err != request.ErrCircuitOpen {
+		t.Fatalf("expected the local state to be used when Redis is down, got %v", err)
+	}
+}
+
+func TestLimiterZeroRate(t *testing.T) {
+	server := miniredis.RunT(t)
+	limiter := newLimiter(t, server, 0, 2)
+	if n := acquire(t, limiter); n != 2 {
+		t.Fatalf("expected the burst to be available, got %d", n)
+	}
+	if err := limiter.Wait(context.Background()); err != request.ErrRateLimitExceeded {
+		t.Fatalf("expected the rate limit to be exceeded, got %v", err)
+	}
+	if n := acquire(t, newLimiter(t, server, -1, 2)); n != 0 {
+		t.Fatalf("expected a negative rate never to refill the bucket, got %d", n)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package redisstore provides a Redis-backed implementation of the request
+// package Store interface; it lives in its own package so that the Redis client
+// is only pulled in by those who need it.
+package redisstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store is a Store backed by Redis; all keys are namespaced under a common
+// prefix, so that the same Redis instance can be shared safely.
+type Store struct {
+	client    redis.UniversalClient
+	namespace string
+}
+
+// New returns a new Store using the given Redis client and key namespace (e.g.
+// "requestor:").
+func New(client redis.UniversalClient, namespace string) *Store {
+	return &Store{
+		client:    client,
+		namespace: namespace,
+	}
+}
+
+// Get returns the value stored under the given key.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := s.client.Get(ctx, s.namespace+key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set stores the value under the given key, with the given TTL; Redis takes
+// care of expiring the entry.
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return s.client.Set(ctx, s.namespace+key, value, ttl).Err()
+}
+
+// Delete removes the given key from the store.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.namespace+key).Err()
+}
+
+// List returns the sorted list of keys having the given prefix, scanning the key space
+// incrementally so as not to block the server.
+func (s *Store) List(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	iterator := s.client.Scan(ctx, 0, s.namespace+escape(prefix)+"*", 100).Iterator()
+	for iterator.Next(ctx) {
+		keys = append(keys, iterator.Val()[len(s.namespace):])
+	}
+	if err := iterator.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// escape escapes the glob-style pattern characters in the given string.
+func escape(s string) string {
+	result := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			result = append(result, '\\')
+		}
+		result = append(result, s[i])
+	}
+	return string(result)
+}
@@ -0,0 +1,84 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	request "github.com/dihedron/go-requestor"
+)
+
+var _ request.Store = (*Store)(nil)
+
+func newStore(t *testing.T, server *miniredis.Miniredis) *Store {
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return New(client, "requestor:")
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	store := newStore(t, server)
+	if _, ok, err := store.Get(ctx, "missing"); ok || err != nil {
+		t.Fatalf("expected missing key, got %t (%v)", ok, err)
+	}
+	if err := store.Set(ctx, "etag/https://example.com/a", []byte("value-a"), 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Set(ctx, "etag/https://example.com/b", []byte("value-b"), 20*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store.Set(ctx, "cache/https://example.com/a", []byte("value-c"), time.Hour)
+	if value, ok, err := store.Get(ctx, "etag/https://example.com/a"); !ok || err != nil || string(value) != "value-a" {
+		t.Fatalf("invalid value: got %q, %t (%v)", value, ok, err)
+	}
+	if !server.Exists("requestor:etag/https://example.com/a") {
+		t.Fatalf("expected the key to be namespaced, got %v", server.Keys())
+	}
+	keys, err := store.List(ctx, "etag/")
+	if err != nil || len(keys) != 2 || keys[0] != "etag/https://example.com/a" {
+		t.Fatalf("invalid keys: got %v (%v)", keys, err)
+	}
+	server.FastForward(30 * time.Millisecond)
+	if _, ok, _ := store.Get(ctx, "etag/https://example.com/b"); ok {
+		t.Fatalf("expected key to have expired")
+	}
+	if keys, _ := store.List(ctx, "etag/"); len(keys) != 1 {
+		t.Fatalf("expected expired key not to be listed, got %v", keys)
+	}
+	if ttl := server.TTL("requestor:etag/https://example.com/a"); ttl != 0 {
+		t.Fatalf("expected a zero TTL not to expire the key, got %v", ttl)
+	}
+	if err := store.Delete(ctx, "etag/https://example.com/a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Delete(ctx, "etag/https://example.com/a"); err != nil {
+		t.Fatalf("deleting a missing key should not fail: %v", err)
+	}
+	if keys, _ := store.List(ctx, ""); len(keys) != 1 || keys[0] != "cache/https://example.com/a" {
+		t.Fatalf("invalid keys after delete: %v", keys)
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	store := newStore(t, server)
+	server.Set("other:etag/a", "foreign")
+	store.Set(ctx, "etag/[a]*", []byte("x"), 0)
+	store.Set(ctx, "etag/ab", []byte("x"), 0)
+	if keys, err := store.List(ctx, "etag/[a]*"); err != nil || len(keys) != 1 || keys[0] != "etag/[a]*" {
+		t.Fatalf("expected the pattern characters to be escaped, got %v (%v)", keys, err)
+	}
+	if keys, _ := store.List(ctx, ""); len(keys) != 2 {
+		t.Fatalf("expected the keys outside the namespace not to be listed, got %v", keys)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AssertionResult is the outcome of a single assertion evaluated against the
+// response to a request.
+type AssertionResult struct {
+	// Name is a short description of the assertion, e.g. "status in [200]".
+	Name string `json:"name"`
+	// Passed is whether the assertion held.
+	Passed bool `json:"passed"`
+	// Message explains why the assertion failed.
+	Message string `json:"message,omitempty"`
+}
+
+// Result is the outcome of a single request execution, as collected by a
+// ResultRecorder and written out by reporters.
+type Result struct {
+	// Name identifies the request in reports; it defaults to "METHOD URL".
+	Name string `json:"name"`
+	// Method is the HTTP method of the request.
+	Method string `json:"method"`
+	// URL is the URL of the request.
+	URL string `json:"url"`
+	// StatusCode is the status code of the response, if one was received.
+	StatusCode int `json:"status,omitempty"`
+	// Started is the time at which the execution started.
+	Started time.Time `json:"started"`
+	// Latency is the overall duration of the execution.
+	Latency time.Duration `json:"-"`
+	// Error is the description of the error that made the execution fail, if
+	// any.
+	Error string `json:"error,omitempty"`
+	// Assertions contains the outcome of the assertions evaluated against the
+	// response, if any.
+	Assertions []AssertionResult `json:"assertions,omitempty"`
+	// Skipped is set when the request was not executed at all, e.g. because a
+	// previous step in a scenario failed.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// Passed returns whether the request was executed successfully and all
+// assertions held.
+func (r Result) Passed() bool {
+	if r.Skipped || r.Error != "" {
+		return false
+	}
+	for _, assertion := range r.Assertions {
+		if !assertion.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// newResult creates the Result of the execution of the given request.
+func newResult(request *http.Request, response *Response, err error, started time.Time) Result {
+	result := Result{
+		Name:    request.Method + " " + RedactURL(request.URL),
+		Method:  request.Method,
+		URL:     RedactURL(request.URL),
+		Started: started,
+		Latency: time.Since(started),
+	}
+	if response != nil {
+		result.StatusCode = response.StatusCode
+	}
+	if err != nil {
+		result.Error = redactText(err.Error(), request.URL)
+		result.StatusCode = StatusCode(err)
+	}
+	return result
+}
+
+// MarshalJSON renders the result as JSON, with the latency expressed in
+// milliseconds and the overall outcome.
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	return json.Marshal(struct {
+		result
+		Latency float64 `json:"latency_ms"`
+		Passed  bool    `json:"passed"`
+	}{
+		result:  result(r),
+		Latency: float64(r.Latency) / float64(time.Millisecond),
+		Passed:  r.Passed(),
+	})
+}
+
+// Failed returns whether the request was executed, and either failed or some
+// assertion did not hold.
+func (r Result) Failed() bool {
+	return !r.Skipped && !r.Passed()
+}
+
+// failure returns a description of the reasons why the execution failed.
+func (r Result) failure() string {
+	reasons := []string{}
+	if r.Error != "" {
+		reasons = append(reasons, r.Error)
+	}
+	for _, assertion := range r.Assertions {
+		if !assertion.Passed {
+			if assertion.Message != "" {
+				reasons = append(reasons, fmt.Sprintf("%s: %s", assertion.Name, assertion.Message))
+			} else {
+				reasons = append(reasons, assertion.Name)
+			}
+		}
+	}
+	return strings.Join(reasons, "\n")
+}
+
+// ResultRecorder collects the results of request executions; it is safe for
+// concurrent use, and can be attached to a Requestor via RecordResults().
+type ResultRecorder struct {
+	lock    sync.Mutex
+	results []Result
+}
+
+// NewResultRecorder returns a new, empty ResultRecorder.
+func NewResultRecorder() *ResultRecorder {
+	return &ResultRecorder{}
+}
+
+// Record adds a result to the recorder.
+func (r *ResultRecorder) Record(result Result) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.results = append(r.results, result)
+}
+
+// Results returns a copy of the results collected so far, in order of
+// completion.
+func (r *ResultRecorder) Results() []Result {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	results := make([]Result, len(r.results))
+	copy(results, r.results)
+	return results
+}
+
+// Reset discards all the results collected so far.
+func (r *ResultRecorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.results = nil
+}
+
+// Reporter writes a set of execution results in some report format.
+type Reporter interface {
+	Report(w io.Writer, results []Result) error
+}
+
+// JSONReporter writes execution results as a JSON document, with a summary and
+// the list of results; latencies are expressed in milliseconds.
+type JSONReporter struct {
+	// Indent enables pretty-printing of the JSON document.
+	Indent bool
+}
+
+// Report writes the results to the given writer as JSON.
+func (j JSONReporter) Report(w io.Writer, results []Result) error {
+	report := struct {
+		Tests    int      `json:"tests"`
+		Failures int      `json:"failures"`
+		Skipped  int      `json:"skipped"`
+		Results  []Result `json:"results"`
+	}{
+		Tests:   len(results),
+		Results: results,
+	}
+	if report.Results == nil {
+		report.Results = []Result{}
+	}
+	for _, result := range results {
+		if result.Skipped {
+			report.Skipped++
+		} else if result.Failed() {
+			report.Failures++
+		}
+	}
+	encoder := json.NewEncoder(w)
+	if j.Indent {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(report)
+}
+
+// JUnitReporter writes execution results as a JUnit XML report, with one test
+// case per result, so that CI systems can consume them.
+type JUnitReporter struct {
+	// Suite is the name of the test suite; it defaults to "requests".
+	Suite string
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// Report writes the results to the given writer as JUnit XML.
+func (j JUnitReporter) Report(w io.Writer, results []Result) error {
+	suite := junitTestSuite{
+		Name:  j.Suite,
+		Tests: len(results),
+	}
+	if suite.Name == "" {
+		suite.Name = "requests"
+	}
+	var total time.Duration
+	for i, result := range results {
+		if i == 0 && !result.Started.IsZero() {
+			suite.Timestamp = result.Started.UTC().Format("2006-01-02T15:04:05")
+		}
+		total += result.Latency
+		testCase := junitTestCase{
+			Name:      result.Name,
+			ClassName: suite.Name,
+			Time:      seconds(result.Latency),
+		}
+		if result.Skipped {
+			suite.Skipped++
+			testCase.Skipped = &struct{}{}
+		} else if result.Failed() {
+			suite.Failures++
+			kind := "assertion"
+			if result.Error != "" {
+				kind = "error"
+			}
+			message := result.failure()
+			testCase.Failure = &junitFailure{
+				Message: strings.SplitN(message, "\n", 2)[0],
+				Type:    kind,
+				Text:    message,
+			}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Time = seconds(total)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,127 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getResults() []Result {
+	return []Result{
+		{
+			Name:    "login",
+			Method:  http.MethodPost,
+			URL:     "https://www.example.com/login",
+			Latency: 150 * time.Millisecond,
+			Assertions: []AssertionResult{
+				{Name: "status in [200]", Passed: true},
+			},
+		},
+		{
+			Name:    "profile",
+			Method:  http.MethodGet,
+			URL:     "https://www.example.com/me",
+			Latency: 50 * time.Millisecond,
+			Assertions: []AssertionResult{
+				{Name: "status in [200]", Passed: false, Message: "got 500"},
+			},
+		},
+		{
+			Name:   "logout",
+			Method: http.MethodPost,
+			URL:    "https://www.example.com/logout",
+			Error:  "connection refused",
+		},
+	}
+}
+
+func TestJSONReporter(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := (JSONReporter{Indent: true}).Report(&buffer, getResults()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report := struct {
+		Tests    int `json:"tests"`
+		Failures int `json:"failures"`
+		Results  []struct {
+			Name    string  `json:"name"`
+			Latency float64 `json:"latency_ms"`
+			Passed  bool    `json:"passed"`
+		} `json:"results"`
+	}{}
+	if err := json.Unmarshal(buffer.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON report: %v", err)
+	}
+	if report.Tests != 3 || report.Failures != 2 {
+		t.Fatalf("invalid summary: got %d tests and %d failures", report.Tests, report.Failures)
+	}
+	if report.Results[0].Latency != 150 || !report.Results[0].Passed || report.Results[1].Passed {
+		t.Fatalf("invalid results: got %+v", report.Results)
+	}
+}
+
+func TestJUnitReporter(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := (JUnitReporter{Suite: "smoke"}).Report(&buffer, getResults()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Logf("report:\n%s", buffer.String())
+	report := junitTestSuites{}
+	if err := xml.Unmarshal(buffer.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JUnit report: %v", err)
+	}
+	suite := report.Suites[0]
+	if suite.Name != "smoke" || suite.Tests != 3 || suite.Failures != 2 || suite.Time != "0.200" {
+		t.Fatalf("invalid suite: got %+v", suite)
+	}
+	if suite.TestCases[0].Failure != nil {
+		t.Fatalf("first test case should have passed")
+	}
+	if f := suite.TestCases[1].Failure; f == nil || f.Type != "assertion" || !strings.Contains(f.Message, "got 500") {
+		t.Fatalf("invalid failure for second test case: %+v", f)
+	}
+	if f := suite.TestCases[2].Failure; f == nil || f.Type != "error" {
+		t.Fatalf("invalid failure for third test case: %+v", f)
+	}
+}
+
+func TestRecordResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	recorder := NewResultRecorder()
+	requestor := NewRequestor(getClient()).RecordResults(recorder)
+	if response, err := requestor.Do(context.Background(), New(server.URL+"/ok")); err == nil {
+		response.Body.Close()
+	}
+	requestor.Do(context.Background(), New(server.URL+"/missing"))
+
+	results := recorder.Results()
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if !results[0].Passed() || results[0].StatusCode != http.StatusOK {
+		t.Fatalf("invalid first result: %+v", results[0])
+	}
+	if results[1].Passed() || results[1].StatusCode != http.StatusNotFound {
+		t.Fatalf("invalid second result: %+v", results[1])
+	}
+	recorder.Reset()
+	if len(recorder.Results()) != 0 {
+		t.Fatalf("expected no results after reset")
+	}
+}
@@ -6,6 +6,7 @@ package request
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -14,7 +15,10 @@ import (
 	"net/url"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dihedron/go-log"
 	"github.com/fatih/structs"
@@ -31,10 +35,22 @@ const (
 
 // Builder is the HTTP request builder; it can be used to create child request
 // factories, with specialised BaseURLs or other parameters; when a sub-builder
-// is generated, it will share the Method and BaseURL by value and all other
-// firlds by pointer, so any change in sub-factories will affect the parent too.
+// is generated, it gets its own copy of the headers, query parameters and
+// variables, so that changes to either do not affect the other.
+//
+// A Builder can be shared by goroutines once configured: New and Make only read
+// it, and the requests they generate have their own copy of the headers and of
+// in-memory bodies; the setters of headers, query parameters, variables and
+// body are guarded by a lock, so that changing a shared builder never corrupts
+// it, but the other settings are not, and neither is the deprecated
+// Add/Set/Del/Remove mode, which is shared by all the goroutines. Hence,
+// configure a base builder first, then share it and derive per-request
+// children from it via New.
 type Builder struct {
 
+	// lock guards the headers, query parameters, variables and body.
+	lock *sync.RWMutex
+
 	// method is the HTTP method to be used for requests generated by this
 	// builder.
 	method string
@@ -42,8 +58,8 @@ type Builder struct {
 	// url is the base URL for generating HTTP requests.
 	url string
 
-	// op is used internally to provide a flowing API to header and query parameters
-	// maipulation methods.
+	// op is the mode of the deprecated Header, QueryParameter and Variable
+	// methods.
 	op operation
 
 	// headers is a set of header values for HTTP request headers; special headers
@@ -63,30 +79,241 @@ type Builder struct {
 	// entity as an io.Reader. Moreover, it will be queried to set the request
 	// content type.
 	body io.Reader
+
+	// pagination is the strategy used by Pagers to move from one page to the
+	// next; if nil, rel="next" links in the Link header are followed.
+	pagination PaginationStrategy
+
+	// limiter is the client-side rate limiter shared by this builder and its
+	// children.
+	limiter Limiter
+
+	// breaker is the circuit breaker shared by this builder and its children.
+	breaker *CircuitBreaker
+
+	// hedgeDelay is the delay after which a hedged duplicate request is sent,
+	// up to hedges times.
+	hedgeDelay time.Duration
+	hedges     int
+
+	// decoding are the options used to decode the responses.
+	decoding DecodeOptions
+
+	// encodeOptions and marshaler control how JSON bodies are encoded.
+	encodeOptions EncodeOptions
+	marshaler     JSONMarshaler
+
+	// encoders format the values bound from struct fields, by type.
+	encoders map[reflect.Type]ValueEncoder
+
+	// caching controls how requests use the cache of the Requestor.
+	caching CacheMode
+
+	// conflicts contains the policies applied to headers having more than one
+	// value, by header name ("" for the default one).
+	conflicts map[string]ConflictPolicy
+
+	// transport, if set, overrides the transport of the Requestor.
+	transport http.RoundTripper
+	// customisations are applied to a private copy of the transport.
+	customisations []func(*http.Transport)
+	// customised caches the customised copies of the transports.
+	customised *customisedTransports
+
+	// strict makes Make fail on suspicious configurations.
+	strict bool
+
+	// lineage, if set, tracks the configuration inherited from the ancestors.
+	lineage *lineage
+
+	// validators check the generated requests.
+	validators []Validator
+
+	// responseSchema, if set, is the JSON Schema of the response bodies.
+	responseSchema *Schema
+
+	// encoding controls how query parameters are serialised.
+	encoding QueryEncoding
+
+	// arrays and maps contain the encodings of query parameters having more
+	// than one value and of map query parameters, by key; the default ones
+	// are under the empty key.
+	arrays map[string]ArrayEncoding
+	maps   map[string]MapEncoding
+
+	// order contains the keys of the query parameters in the order they were
+	// first set, raw those of the parameters not to be escaped, and rawQuery
+	// the query sent verbatim.
+	order    []string
+	raw      map[string]bool
+	rawQuery string
+
+	// matrix contains the matrix parameters, by path segment.
+	matrix map[string]map[string][]string
+
+	// length, if set, is the declared size of the request body, or -1 to send
+	// it chunked.
+	length *int64
+
+	// registry, if set, tracks the in-flight requests, labelled with tags.
+	registry *Registry
+	tags     map[string]string
+
+	// middlewares wrap the transport sending the requests.
+	middlewares []Middleware
+
+	// requestID, if set, generates the ID of each request; correlation, if
+	// set, is the header the correlation ID returned by correlate for the
+	// request context is copied into.
+	requestID   func() string
+	correlation string
+	correlate   func(ctx context.Context) string
+
+	// idempotent makes each request carry a new idempotency key.
+	idempotent bool
+
+	// uploadProgress and downloadProgress, if set, report the progress of the
+	// transfer of the request and response bodies, at most once per
+	// progressInterval (if nil, DefaultProgressInterval).
+	uploadProgress   ProgressFunc
+	downloadProgress ProgressFunc
+	progressInterval *time.Duration
+
+	// maxResponseBytes, if positive, is the maximum size of the response
+	// bodies.
+	maxResponseBytes int64
+
+	// timeout, if positive, is the overall deadline of the requests.
+	timeout time.Duration
+
+	// host, if set, overrides the Host header of the requests.
+	host string
+
+	// endpoints, if set, is the pool of base URLs the requests are spread
+	// across.
+	endpoints *EndpointPool
+
+	// discovery, if set, resolves the base URL of the requests at request
+	// time.
+	discovery *discovery
+
+	// profiles are the named profiles of the builder, and profile is the name
+	// of the one applied, if any.
+	profiles Profiles
+	profile  string
+
+	// credentials are the references to the credentials of the requests.
+	credentials *Credentials
+
+	// vars are the variables replaced in the URL, headers, query parameters
+	// and bodies of the requests.
+	vars map[string]string
+
+	// err, if set, is the configuration error Make fails with.
+	err error
 }
 
 // New returns a new request builder; the URL can be omitted and specified
 // later via Base() or Path().
 func New(url string) *Builder {
 	return &Builder{
+		lock:       &sync.RWMutex{},
 		method:     http.MethodGet,
 		url:        url,
 		headers:    map[string][]string{},
 		parameters: map[string][]string{},
 		variables:  map[string]string{},
+		conflicts:  map[string]ConflictPolicy{},
+		tags:       map[string]string{},
 	}
 }
 
 // New clones the current builder and can optionally specify the request method
 // and/or the request URL.
 func (f *Builder) New(method, url string) *Builder {
+	defer f.read()()
 	clone := &Builder{
-		method:     f.method,
-		url:        f.url,
-		headers:    map[string][]string{},
-		parameters: map[string][]string{},
-		variables:  map[string]string{},
-		body:       f.body,
+		lock:             &sync.RWMutex{},
+		method:           f.method,
+		url:              f.url,
+		headers:          map[string][]string{},
+		parameters:       map[string][]string{},
+		variables:        map[string]string{},
+		conflicts:        map[string]ConflictPolicy{},
+		body:             f.body,
+		pagination:       f.pagination,
+		limiter:          f.limiter,
+		breaker:          f.breaker,
+		hedgeDelay:       f.hedgeDelay,
+		hedges:           f.hedges,
+		decoding:         f.decoding,
+		encodeOptions:    f.encodeOptions,
+		marshaler:        f.marshaler,
+		encoders:         f.encoders,
+		caching:          f.caching,
+		transport:        f.transport,
+		customisations:   f.customisations,
+		customised:       f.customised,
+		strict:           f.strict,
+		lineage:          f.inherit(),
+		validators:       append([]Validator(nil), f.validators...),
+		responseSchema:   f.responseSchema,
+		encoding:         f.encoding,
+		order:            append([]string(nil), f.order...),
+		rawQuery:         f.rawQuery,
+		length:           f.length,
+		registry:         f.registry,
+		tags:             map[string]string{},
+		middlewares:      append([]Middleware(nil), f.middlewares...),
+		requestID:        f.requestID,
+		correlation:      f.correlation,
+		correlate:        f.correlate,
+		idempotent:       f.idempotent,
+		uploadProgress:   f.uploadProgress,
+		downloadProgress: f.downloadProgress,
+		progressInterval: f.progressInterval,
+		maxResponseBytes: f.maxResponseBytes,
+		timeout:          f.timeout,
+		host:             f.host,
+		endpoints:        f.endpoints,
+		discovery:        f.discovery,
+		profiles:         f.profiles,
+		profile:          f.profile,
+		credentials:      f.credentials,
+		err:              f.err,
+	}
+	if f.arrays != nil {
+		clone.arrays = map[string]ArrayEncoding{}
+		for key, encoding := range f.arrays {
+			clone.arrays[key] = encoding
+		}
+	}
+	if f.matrix != nil {
+		clone.matrix = map[string]map[string][]string{}
+		for segment, parameters := range f.matrix {
+			clone.matrix[segment] = map[string][]string{}
+			for key, values := range parameters {
+				clone.matrix[segment][key] = append([]string(nil), values...)
+			}
+		}
+	}
+	if f.raw != nil {
+		clone.raw = map[string]bool{}
+		for key := range f.raw {
+			clone.raw[key] = true
+		}
+	}
+	if f.maps != nil {
+		clone.maps = map[string]MapEncoding{}
+		for key, encoding := range f.maps {
+			clone.maps[key] = encoding
+		}
+	}
+	if f.vars != nil {
+		clone.vars = map[string]string{}
+		for key, value := range f.vars {
+			clone.vars[key] = value
+		}
 	}
 	if method != "" {
 		clone.method = strings.ToUpper(method)
@@ -109,10 +336,34 @@ func (f *Builder) New(method, url string) *Builder {
 	for key, value := range f.variables {
 		clone.variables[key] = value
 	}
+	for key, policy := range f.conflicts {
+		clone.conflicts[key] = policy
+	}
+	for key, value := range f.tags {
+		clone.tags[key] = value
+	}
 
 	return clone
 }
 
+// read locks the builder for reading, and returns the function unlocking it.
+func (f *Builder) read() func() {
+	if f.lock == nil {
+		return func() {}
+	}
+	f.lock.RLock()
+	return f.lock.RUnlock
+}
+
+// write locks the builder for writing, and returns the function unlocking it.
+func (f *Builder) write() func() {
+	if f.lock == nil {
+		return func() {}
+	}
+	f.lock.Lock()
+	return f.lock.Unlock
+}
+
 // Base sets the base URL. If you intend to extend the url with Path, the URL
 // should be specified with a trailing slash.
 func (f *Builder) Base(url string) *Builder {
@@ -142,162 +393,353 @@ func (f *Builder) Method(method string) *Builder {
 // UserAgent sets the user agent information in the request builder; the previous
 // value is discarded.
 func (f *Builder) UserAgent(userAgent string) *Builder {
-	return f.Set().Header("User-Agent", userAgent)
+	return f.SetHeader("User-Agent", userAgent)
 }
 
 // ContentType sets the content type information in the request builder; the
 // previous value is discarded.
 func (f *Builder) ContentType(contentType string) *Builder {
-	return f.Set().Header("Content-Type", contentType)
+	return f.SetHeader("Content-Type", contentType)
 }
 
-// Add is used to provide a fluent API by which it is possible to add query
-// parameters and headers without having many different methods or intermediate
-// objects; this method relies on an internal Builder field (named op), which
-// will be set to the "add" value and will instruct the following QueryParameter()
-// and Header() methods to add the passed values to the current set for the given
-// key.
+// AddHeader adds the given values to the current set for the given header.
+func (f *Builder) AddHeader(key string, values ...string) *Builder {
+	defer f.write()()
+	for _, value := range values {
+		f.headers.Add(key, value)
+	}
+	return f
+}
+
+// SetHeader replaces the current set of values for the given header with the
+// given values.
+func (f *Builder) SetHeader(key string, values ...string) *Builder {
+	defer f.write()()
+	f.headers.Del(key)
+	for _, value := range values {
+		f.headers.Add(key, value)
+	}
+	return f
+}
+
+// DelHeader removes all the values of the given header.
+func (f *Builder) DelHeader(key string) *Builder {
+	defer f.write()()
+	f.headers.Del(key)
+	return f
+}
+
+// RemoveHeadersMatching removes all the values of the headers whose keys match
+// the given regular expression.
+func (f *Builder) RemoveHeadersMatching(pattern string) *Builder {
+	defer f.write()()
+	removeMatching(regexp.MustCompile(pattern), f.headers)
+	return f
+}
+
+// AddRawHeader adds the given values to the current set for the given header,
+// whose key is sent byte-for-byte as given, instead of being canonicalised
+// (e.g. "x-api-key" rather than "X-Api-Key"), for the appliances and signing
+// schemes that are case-sensitive; keys differing only in case are distinct
+// headers, all sent. The values of a header are sent as separate lines, in the
+// order they were added, but the headers are sorted by key by net/http, and
+// HTTP/2 lower-cases all the keys.
+func (f *Builder) AddRawHeader(key string, values ...string) *Builder {
+	defer f.write()()
+	f.headers[key] = append(f.headers[key], values...)
+	return f
+}
+
+// SetRawHeader replaces the current set of values for the given header, whose
+// key is sent byte-for-byte as given (see AddRawHeader), with the given values.
+func (f *Builder) SetRawHeader(key string, values ...string) *Builder {
+	defer f.write()()
+	f.headers[key] = append([]string(nil), values...)
+	return f
+}
+
+// DelRawHeader removes all the values of the given header, whose key is
+// matched byte-for-byte (see AddRawHeader).
+func (f *Builder) DelRawHeader(key string) *Builder {
+	defer f.write()()
+	delete(f.headers, key)
+	return f
+}
+
+// AddQueryParameter adds the given values to the current set for the given
+// query parameter.
+func (f *Builder) AddQueryParameter(key string, values ...string) *Builder {
+	defer f.write()()
+	f.track(key)
+	for _, value := range values {
+		f.parameters.Add(key, value)
+	}
+	return f
+}
+
+// SetQueryParameter replaces the current set of values for the given query
+// parameter with the given values.
+func (f *Builder) SetQueryParameter(key string, values ...string) *Builder {
+	defer f.write()()
+	f.track(key)
+	delete(f.raw, key)
+	f.parameters.Del(key)
+	for _, value := range values {
+		f.parameters.Add(key, value)
+	}
+	return f
+}
+
+// DelQueryParameter removes all the values of the given query parameter.
+func (f *Builder) DelQueryParameter(key string) *Builder {
+	defer f.write()()
+	f.parameters.Del(key)
+	delete(f.raw, key)
+	return f
+}
+
+// RemoveQueryParametersMatching removes all the values of the query parameters
+// whose keys match the given regular expression.
+func (f *Builder) RemoveQueryParametersMatching(pattern string) *Builder {
+	defer f.write()()
+	removeMatching(regexp.MustCompile(pattern), f.parameters)
+	return f
+}
+
+// SetVariable sets the value of the given URL variable.
+func (f *Builder) SetVariable(key string, value interface{}) *Builder {
+	defer f.write()()
+	f.variables[key] = fmt.Sprintf("%v", value)
+	return f
+}
+
+// DelVariable removes the given URL variable.
+func (f *Builder) DelVariable(key string) *Builder {
+	defer f.write()()
+	delete(f.variables, key)
+	return f
+}
+
+// RemoveVariablesMatching removes the URL variables whose keys match the given
+// regular expression.
+func (f *Builder) RemoveVariablesMatching(pattern string) *Builder {
+	defer f.write()()
+	re := regexp.MustCompile(pattern)
+	for key := range f.variables {
+		if re.MatchString(key) {
+			delete(f.variables, key)
+		}
+	}
+	return f
+}
+
+// removeMatching removes the keys matching the given regular expression from
+// the given set of headers or query parameters.
+func removeMatching(re *regexp.Regexp, values map[string][]string) {
+	for key := range values {
+		if re.MatchString(key) {
+			delete(values, key)
+		}
+	}
+}
+
+// MapHeaders transforms the headers in bulk: the given function is called with
+// the key and values of each header, and returns the key and values to replace
+// them with, or false to drop the header; the returned key is used as is, e.g.
+// to send it in lower case, and the values of headers renamed to the same key
+// are merged.
+//
+//	f.MapHeaders(func(key string, values []string) (string, []string, bool) {
+//		return key, values, len(values) > 0 && values[0] != ""
+//	})
+func (f *Builder) MapHeaders(mapping func(key string, values []string) (string, []string, bool)) *Builder {
+	defer f.write()()
+	mapValues(f.headers, mapping)
+	return f
+}
+
+// MapQueryParameters transforms the query parameters in bulk, as MapHeaders
+// does for headers.
+func (f *Builder) MapQueryParameters(mapping func(key string, values []string) (string, []string, bool)) *Builder {
+	defer f.write()()
+	mapValues(f.parameters, mapping)
+	keys := []string{}
+	for key := range f.parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	f.track(keys...)
+	return f
+}
+
+// mapValues replaces the given set of headers or query parameters in place
+// with the result of the given mapping.
+func mapValues(values map[string][]string, mapping func(key string, values []string) (string, []string, bool)) {
+	mapped := map[string][]string{}
+	for key, v := range values {
+		if key, v, ok := mapping(key, append([]string(nil), v...)); ok {
+			mapped[key] = append(mapped[key], v...)
+		}
+	}
+	for key := range values {
+		delete(values, key)
+	}
+	for key, v := range mapped {
+		values[key] = v
+	}
+}
+
+// Add makes the following QueryParameter, Variable and Header calls add the
+// passed values to the current set for the given key.
+//
+// Deprecated: the mode is shared by all the users of the builder; use
+// AddHeader, AddQueryParameter and SetVariable instead.
 func (f *Builder) Add() *Builder {
+	defer f.write()()
 	f.op = add
 	return f
 }
 
-// Set is used to provide a fluent API by which it is possible to replace query
-// parameters and headers without having many different methods or intermediate
-// objects; this method relies on an internal Builder field (named op), which
-// will be set to the "set" value and will instruct the following QueryParameter()
-// and Header() methods to replace the current set of values for the given key
-// with the passed values.
+// Set makes the following QueryParameter, Variable and Header calls replace
+// the current set of values for the given key with the passed values.
+//
+// Deprecated: the mode is shared by all the users of the builder; use
+// SetHeader, SetQueryParameter and SetVariable instead.
 func (f *Builder) Set() *Builder {
+	defer f.write()()
 	f.op = set
 	return f
 }
 
-// Del is used to provide a fluent API by which it is possible to replace query
-// parameters and headers without having many different methods or intermediate
-// objects; this method relies on an internal Builder field (named op), which
-// will be set to the "set" value and will instruct the following QueryParameter()
-// and Header() methods to replace the current set of values for the given key
-// with the passed values.
+// Del makes the following QueryParameter, Variable and Header calls remove
+// all the values for the given key.
+//
+// Deprecated: the mode is shared by all the users of the builder; use
+// DelHeader, DelQueryParameter and DelVariable instead.
 func (f *Builder) Del() *Builder {
+	defer f.write()()
 	f.op = del
 	return f
 }
 
-// Remove is used to provide a fluent API by which it is possible to remove the
-// values of query parameters and headers whose keys match a regular exception.
+// Remove makes the following QueryParameter, Variable and Header calls
+// remove the values for the keys matching the given regular expression.
+//
+// Deprecated: the mode is shared by all the users of the builder; use
+// RemoveHeadersMatching, RemoveQueryParametersMatching and
+// RemoveVariablesMatching instead.
 func (f *Builder) Remove() *Builder {
+	defer f.write()()
 	f.op = rem
 	return f
 }
 
-// QueryParameter adds, sets or removes the given set of values to the URL's query
-// parameters; if the query parameter is being removed, there is no need to specify
-// any value; if the query parameter is being reset, the key is regarded as a
-// regular expression.
+// mode returns the current Add/Set/Del/Remove mode.
+func (f *Builder) mode() operation {
+	defer f.read()()
+	return f.op
+}
+
+// QueryParameter adds, sets or removes the given set of values to the URL's
+// query parameters, according to the current Add/Set/Del/Remove mode.
+//
+// Deprecated: use AddQueryParameter, SetQueryParameter, DelQueryParameter or
+// RemoveQueryParametersMatching instead.
 func (f *Builder) QueryParameter(key string, values ...string) *Builder {
-	if f.op == add {
-		for _, value := range values {
-			f.parameters.Add(key, value)
-		}
-	} else if f.op == set {
-		f.parameters.Del(key)
-		for _, value := range values {
-			f.parameters.Add(key, value)
-		}
-	} else if f.op == del {
-		f.parameters.Del(key)
-	} else if f.op == rem {
-		re := regexp.MustCompile(key)
-		for key := range f.parameters {
-			if re.MatchString(key) {
-				defer f.parameters.Del(key)
-			}
-		}
+	switch f.mode() {
+	case add:
+		return f.AddQueryParameter(key, values...)
+	case set:
+		return f.SetQueryParameter(key, values...)
+	case del:
+		return f.DelQueryParameter(key)
+	default:
+		return f.RemoveQueryParametersMatching(key)
 	}
-	return f
 }
 
-// QueryParametersFrom adds, sets or removes values extracted from a struct (and
-// tagged with "parameter") or from a map[string][]string to the URL's query
-// parameters; if the query parameters are being removed, there is no need to
-// specify any value in the input struct/map; if the query parameters are being
-// reset, the keys are regarded as regular expressions.
+// QueryParametersFrom sets the URL's query parameters to the values extracted
+// from a struct (and tagged with "parameter") or from a map[string][]string,
+// replacing any previous values of the same keys.
 func (f *Builder) QueryParametersFrom(source interface{}) *Builder {
-	for key, values := range getValuesFrom("parameter", source) {
-		f.QueryParameter(key, values...)
+	for key, values := range f.valuesFrom("parameter", source) {
+		f.SetQueryParameter(key, values...)
 	}
 	return f
 }
 
-// Variable adds, sets or removes the given value to the URL's variables; if the
-// variable is being removed, there is no need to specify the value; both setting
-// and adding a value for a given variable effectively replace its value.
-func (f *Builder) Variable(key string, value interface{}) *Builder {
-	if f.op == add || f.op == set {
-		f.variables[key] = fmt.Sprintf("%v", value)
-	} else if f.op == del {
-		delete(f.variables, key)
-	} else if f.op == rem {
-		re := regexp.MustCompile(key)
-		for key := range f.variables {
-			if re.MatchString(key) {
-				defer delete(f.variables, key)
-			}
-		}
+// AddQueryParametersFrom adds the values extracted from a struct (and tagged
+// with "parameter") or from a map[string][]string to the URL's query
+// parameters, keeping any previous values of the same keys.
+func (f *Builder) AddQueryParametersFrom(source interface{}) *Builder {
+	for key, values := range f.valuesFrom("parameter", source) {
+		f.AddQueryParameter(key, values...)
 	}
 	return f
 }
 
-// VariablesFrom adds/sets or removes values extracted from a struct (and
-// tagged with "variable") or from a map[string]string to the URL's variables; if
-// the variables are being removed, there is no need to specify any value in the
-// input struct/map; if the variables are being reset, the keys are regarded as
-// regular expressions.
+// Variable adds, sets or removes the given value to the URL's variables,
+// according to the current Add/Set/Del/Remove mode; both setting and adding a
+// value for a given variable effectively replace its value.
+//
+// Deprecated: use SetVariable, DelVariable or RemoveVariablesMatching instead.
+func (f *Builder) Variable(key string, value interface{}) *Builder {
+	switch f.mode() {
+	case add, set:
+		return f.SetVariable(key, value)
+	case del:
+		return f.DelVariable(key)
+	default:
+		return f.RemoveVariablesMatching(key)
+	}
+}
+
+// VariablesFrom sets the URL's variables to the values extracted from a struct
+// (and tagged with "variable") or from a map[string]string; if a key has more
+// than one value, the last one wins.
 func (f *Builder) VariablesFrom(source interface{}) *Builder {
-	for key, values := range getValuesFrom("variable", source) {
+	for key, values := range f.valuesFrom("variable", source) {
 		if len(values) > 0 {
-			// the last value wins
-			f.Variable(key, values[len(values)-1])
+			f.SetVariable(key, values[len(values)-1])
 		}
 	}
 	return f
 }
 
-// Header adds, sets or removes the given set of values to the URL's headers; if
-// the header is being removed, there is no need to specify any value; if the
-// header is being reset, the key is regarded as a regular expression.
+// Header adds, sets or removes the given set of values to the URL's headers,
+// according to the current Add/Set/Del/Remove mode.
+//
+// Deprecated: use AddHeader, SetHeader, DelHeader or RemoveHeadersMatching
+// instead.
 func (f *Builder) Header(key string, values ...string) *Builder {
-	if f.op == add {
-		for _, value := range values {
-			f.headers.Add(key, value)
-		}
-	} else if f.op == set {
-		f.headers.Del(key)
-		for _, value := range values {
-			f.headers.Add(key, value)
-		}
-	} else if f.op == del {
-		f.headers.Del(key)
-	} else if f.op == rem {
-		re := regexp.MustCompile(key)
-		for key := range f.headers {
-			if re.MatchString(key) {
-				defer f.headers.Del(key)
-			}
-		}
+	switch f.mode() {
+	case add:
+		return f.AddHeader(key, values...)
+	case set:
+		return f.SetHeader(key, values...)
+	case del:
+		return f.DelHeader(key)
+	default:
+		return f.RemoveHeadersMatching(key)
 	}
-	return f
 }
 
-// HeadersFrom adds, sets or removes values extracted from a struct (and tagged
-// with "header") or from a map[string][]string to the URL's headers; if the
-// headers are being removed, there is no need to  specify any value in the input
-// struct/map; if the headers are being reset, the keys are regarded as regular
-// expressions.
+// HeadersFrom sets the request headers to the values extracted from a struct
+// (and tagged with "header") or from a map[string][]string, replacing any
+// previous values of the same keys.
 func (f *Builder) HeadersFrom(source interface{}) *Builder {
-	for key, values := range getValuesFrom("header", source) {
-		f.Header(key, values...)
+	for key, values := range f.valuesFrom("header", source) {
+		f.SetHeader(key, values...)
+	}
+	return f
+}
+
+// AddHeadersFrom adds the values extracted from a struct (and tagged with
+// "header") or from a map[string][]string to the request headers, keeping any
+// previous values of the same keys.
+func (f *Builder) AddHeadersFrom(source interface{}) *Builder {
+	for key, values := range f.valuesFrom("header", source) {
+		f.AddHeader(key, values...)
 	}
 	return f
 }
@@ -306,40 +748,43 @@ func (f *Builder) HeadersFrom(source interface{}) *Builder {
 // read; if nil is passed, the request will have no payload; the Content-Type
 // MUST be provoded separately.
 func (f *Builder) WithEntity(entity io.Reader) *Builder {
+	defer f.write()()
 	f.body = entity
 	return f
 }
 
+// WithTransport sets the transport used to send the requests generated by this
+// builder and by the children created from it afterwards, in place of the one
+// of the Requestor's HTTP client, e.g. one built by a TransportBuilder to tune
+// the connection pooling for an API, or a Mock in tests.
+func (f *Builder) WithTransport(transport http.RoundTripper) *Builder {
+	defer f.write()()
+	f.transport = transport
+	return f
+}
+
 // WithJSONEntity sets an io.Reader that returns a JSON fragment as per the
-// input struct; if no Content-Type has been set already, the method will
-// automatically set it to "application/json".
+// input value, which can be anything json.Marshal accepts (structs, maps,
+// slices, primitives, json.Marshaler implementations); if no Content-Type has
+// been set already, the method will automatically set it to
+// "application/json". The encoding is controlled by EncodeOptions and
+// JSONMarshaler, and an encoding error makes Make fail.
 func (f *Builder) WithJSONEntity(entity interface{}) *Builder {
-
-	switch reflect.ValueOf(entity).Kind() {
-	case reflect.Struct:
-		// do nothing, entity is already a struct, thus it's ok
-	case reflect.Ptr:
-		// override entity by the value it points to if it's a struct
-		if reflect.ValueOf(entity).Elem().Kind() == reflect.Struct {
-			entity = reflect.ValueOf(entity).Elem().Interface()
-		} else {
-			panic("only structs can be passed as source for JSON entities")
-		}
-	default:
-		panic("only structs can be passed as source for JSON entities")
-	}
-
-	data, err := json.Marshal(entity)
+	unlock := f.read()
+	data, err := f.marshalJSON(entity)
+	typed := f.headers.Get("Content-Type") != ""
+	unlock()
 	if err != nil {
-		return nil
+		defer f.write()()
+		f.err = fmt.Errorf("error encoding JSON entity: %w", err)
+		return f
 	}
 
-	if f.headers.Get("Content-Type") == "" {
+	if !typed {
 		f.ContentType("application/json")
 	}
 
-	f.body = bytes.NewReader(data)
-	return f
+	return f.WithEntity(bytes.NewReader(data))
 }
 
 // WithXMLEntity sets an io.Reader that returns an XML fragment as per the
@@ -366,12 +811,14 @@ func (f *Builder) WithXMLEntity(entity interface{}) *Builder {
 		return nil
 	}
 
-	if f.headers.Get("Content-Type") == "" {
+	unlock := f.read()
+	typed := f.headers.Get("Content-Type") != ""
+	unlock()
+	if !typed {
 		f.ContentType("text/xml")
 	}
 
-	f.body = bytes.NewReader(data)
-	return f
+	return f.WithEntity(bytes.NewReader(data))
 }
 
 // Get sets the builder method to "GET" and returns an http.Request.
@@ -421,15 +868,33 @@ func (f *Builder) Connect() *Builder {
 
 // Make creates a new http.Request from the information available in the Builder.
 func (f *Builder) Make() (*http.Request, error) {
+	defer f.read()()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	if _, ok := f.transport.(*http.Transport); f.transport != nil && !ok && len(f.customisations) > 0 {
+		return nil, fmt.Errorf("%w of type %T", ErrCustomTransport, f.transport)
+	}
+
+	// replace the builder variables
+	raw, parameters := f.url, f.parameters
+	if len(f.vars) > 0 {
+		var err error
+		if raw, parameters, err = f.expandURL(); err != nil {
+			return nil, err
+		}
+	}
 
 	// parse URL to validate
-	url, err := url.Parse(f.url)
+	url, err := url.Parse(raw)
 	if err != nil {
 		return nil, err
 	}
+	query := url.RawQuery
 
 	// augment URL with additional query parameters
-	url, err = addQueryParameters(url, f.parameters)
+	url, err = addQueryParameters(url, parameters)
 	if err != nil {
 		return nil, err
 	}
@@ -437,19 +902,93 @@ func (f *Builder) Make() (*http.Request, error) {
 	// replace variables
 	u := bindVariables(url, f.variables)
 
-	request, err := http.NewRequest(f.method, u, f.body)
+	// give each request its own copy of in-memory bodies
+	body := f.body
+	switch b := body.(type) {
+	case *bytes.Reader:
+		snapshot := *b
+		body = &snapshot
+	case *strings.Reader:
+		snapshot := *b
+		body = &snapshot
+	case *templateBody:
+		if body, err = b.render(); err != nil {
+			return nil, err
+		}
+	case *lazyBody:
+		body = b.reader()
+	}
+	if len(f.vars) > 0 && body != nil {
+		if body, err = f.expandBody(body, f.headers.Get("Content-Type")); err != nil {
+			return nil, err
+		}
+	}
+
+	request, err := http.NewRequest(f.method, u, body)
 	if err != nil {
 		return nil, err
 	}
+	if err := f.size(request, body); err != nil {
+		return nil, err
+	}
+
+	if encoder := f.queryEncoder(query); encoder != nil {
+		request.URL.RawQuery = encoder.encode(request.URL.Query())
+	}
+	if len(f.matrix) > 0 {
+		f.addMatrixParameters(request.URL)
+	}
+	if f.rawQuery != "" {
+		if request.URL.RawQuery != "" {
+			request.URL.RawQuery = f.rawQuery + string(f.encoding.separator()) + request.URL.RawQuery
+		} else {
+			request.URL.RawQuery = f.rawQuery
+		}
+	}
+
+	if request.Header, err = f.resolve(); err != nil {
+		return nil, err
+	}
+
+	if len(f.vars) > 0 {
+		if request.Header, err = f.expandHeader(request.Header); err != nil {
+			return nil, err
+		}
+	}
+
+	if f.requestID != nil && request.Header.Get(RequestIDHeader) == "" {
+		request.Header.Set(RequestIDHeader, f.requestID())
+	}
+
+	if f.idempotent && request.Header.Get(IdempotencyKeyHeader) == "" {
+		request.Header.Set(IdempotencyKeyHeader, NewID())
+	}
+
+	if f.host != "" {
+		request.Host = f.host
+	}
 
-	request.Header = f.headers
+	if f.strict {
+		if problems := f.suspicious(request); len(problems) > 0 {
+			descriptions := make([]string, len(problems))
+			for i, problem := range problems {
+				descriptions[i] = problem.Message
+			}
+			return nil, fmt.Errorf("%s %s: %w: %s", request.Method, RedactURL(request.URL), ErrSuspiciousRequest, strings.Join(descriptions, "; "))
+		}
+	}
+
+	if err := f.validate(request); err != nil {
+		return nil, err
+	}
 
 	return request, nil
 }
 
 // String prints the current request builder internal state as a string.
-func (f Builder) String() string {
+func (f *Builder) String() string {
 
+	unlock := f.read()
 	data := struct {
 		Method     string      `json:"method,omitempty"`
 		URL        string      `json:"url,omitempty"`
@@ -460,19 +999,19 @@ func (f Builder) String() string {
 	}{
 		Method:     f.method,
 		URL:        f.url,
-		Headers:    f.headers,
-		Parameters: f.parameters,
-	}
-
-	if req, err := f.Make(); err == nil {
-		data.Request = req.URL.String()
+		Headers:    f.headers.Clone(),
+		Parameters: cloneValues(f.parameters),
 	}
-
 	if f.body != nil {
 		data.Body = fmt.Sprintf("%q (%T)", f.body, f.body)
 	} else {
 		data.Body = "nil"
 	}
+	unlock()
+
+	if req, err := f.Make(); err == nil {
+		data.Request = req.URL.String()
+	}
 
 	b, _ := json.MarshalIndent(data, "", "  ")
 	b = bytes.Replace(b, []byte("\\u003c"), []byte("<"), -1)
@@ -483,10 +1022,14 @@ func (f Builder) String() string {
 }
 
 func getValuesFrom(tag string, source interface{}) map[string][]string {
+	return getValuesFromWith(tag, source, nil)
+}
+
+func getValuesFromWith(tag string, source interface{}, encoders map[reflect.Type]ValueEncoder) map[string][]string {
 	var m map[string][]string
 	switch reflect.ValueOf(source).Kind() {
 	case reflect.Struct:
-		m = getValuesFromStruct(tag, source)
+		m = getValuesFromStructWith(tag, source, encoders)
 	case reflect.Map:
 		var ok bool
 		if m, ok = source.(map[string][]string); !ok {
@@ -495,7 +1038,7 @@ func getValuesFrom(tag string, source interface{}) map[string][]string {
 	case reflect.Ptr:
 		if reflect.ValueOf(source).Elem().Kind() == reflect.Struct {
 			source = reflect.ValueOf(source).Elem().Interface()
-			m = getValuesFromStruct(tag, source)
+			m = getValuesFromStructWith(tag, source, encoders)
 		} else if reflect.ValueOf(source).Elem().Kind() == reflect.Map {
 			source = reflect.ValueOf(source).Elem().Interface()
 			var ok bool
@@ -512,20 +1055,18 @@ func getValuesFrom(tag string, source interface{}) map[string][]string {
 }
 
 func getValuesFromStruct(tag string, source interface{}) map[string][]string {
+	return getValuesFromStructWith(tag, source, nil)
+}
+
+func getValuesFromStructWith(tag string, source interface{}, encoders map[reflect.Type]ValueEncoder) map[string][]string {
 	result := map[string][]string{}
 	for key, values := range scan(tag, source) {
 		// log.Debugf("tag is %q", key)
 		for _, value := range values {
-			s := ""
-			if reflect.ValueOf(value).Kind() == reflect.Ptr && !reflect.ValueOf(value).IsNil() {
-				s = fmt.Sprintf("%v", reflect.ValueOf(value).Elem().Interface())
-			} else {
-				s = fmt.Sprintf("%v", value)
-			}
 			if _, ok := result[key]; !ok {
 				result[key] = []string{}
 			}
-			result[key] = append(result[key], s)
+			result[key] = append(result[key], format(value, encoders)...)
 		}
 	}
 	return result
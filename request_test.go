@@ -5,6 +5,7 @@
 package request
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -550,6 +551,155 @@ func TestRemHeader(t *testing.T) {
 	}
 }
 
+func TestHeaderMethods(t *testing.T) {
+	f := New("").
+		AddHeader("key1", "value1").
+		AddHeader("key1", "value2").
+		SetHeader("key2", "value1").
+		SetHeader("key2", "value2").
+		AddHeader("key3", "value1").
+		AddHeader("another_key", "value1")
+	if values := f.headers["Key1"]; len(values) != 2 || values[0] != "value1" || values[1] != "value2" {
+		t.Fatalf("error adding headers: expected [value1 value2], got %v", values)
+	}
+	if values := f.headers["Key2"]; len(values) != 1 || values[0] != "value2" {
+		t.Fatalf("error setting headers: expected [value2], got %v", values)
+	}
+	f.DelHeader("key1")
+	if _, ok := f.headers["Key1"]; ok {
+		t.Fatalf("error deleting headers: key1 still present")
+	}
+	f.RemoveHeadersMatching("^Key\\d$")
+	if len(f.headers) != 1 || f.headers.Get("another_key") != "value1" {
+		t.Fatalf("error removing multiple headers: expected only another_key, got %v", f.headers)
+	}
+}
+
+func TestQueryParameterMethods(t *testing.T) {
+	f := New("").
+		AddQueryParameter("key1", "value1").
+		AddQueryParameter("key1", "value2").
+		SetQueryParameter("key2", "value1").
+		SetQueryParameter("key2", "value2").
+		AddQueryParameter("key3", "value1").
+		AddQueryParameter("another_key", "value1")
+	if values := f.parameters["key1"]; len(values) != 2 || values[0] != "value1" || values[1] != "value2" {
+		t.Fatalf("error adding query parameters: expected [value1 value2], got %v", values)
+	}
+	if values := f.parameters["key2"]; len(values) != 1 || values[0] != "value2" {
+		t.Fatalf("error setting query parameters: expected [value2], got %v", values)
+	}
+	f.DelQueryParameter("key1")
+	if _, ok := f.parameters["key1"]; ok {
+		t.Fatalf("error deleting query parameters: key1 still present")
+	}
+	f.RemoveQueryParametersMatching("^key\\d$")
+	if len(f.parameters) != 1 || f.parameters.Get("another_key") != "value1" {
+		t.Fatalf("error removing multiple query parameters: expected only another_key, got %v", f.parameters)
+	}
+}
+
+func TestVariableMethods(t *testing.T) {
+	f := New("").
+		SetVariable("key1", 1).
+		SetVariable("key2", "value2").
+		SetVariable("another_key", true)
+	if f.variables["key1"] != "1" || f.variables["another_key"] != "true" {
+		t.Fatalf("error setting variables: got %v", f.variables)
+	}
+	f.DelVariable("key1")
+	if _, ok := f.variables["key1"]; ok {
+		t.Fatalf("error deleting variables: key1 still present")
+	}
+	f.RemoveVariablesMatching("^key\\d$")
+	if len(f.variables) != 1 || f.variables["another_key"] != "true" {
+		t.Fatalf("error removing multiple variables: expected only another_key, got %v", f.variables)
+	}
+}
+
+func TestExplicitMethodsIgnoreMode(t *testing.T) {
+	f := New("").Del()
+	f.SetHeader("key", "value")
+	f.AddQueryParameter("key", "value")
+	if f.headers.Get("key") != "value" || f.parameters.Get("key") != "value" {
+		t.Fatalf("error: explicit methods affected by the mode")
+	}
+}
+
+func TestRawHeaders(t *testing.T) {
+	f := New("http://www.example.com/").
+		AddRawHeader("x-api-key", "secret").
+		AddRawHeader("X-DUP", "first").
+		AddRawHeader("X-DUP", "second").
+		SetHeader("X-Dup", "canonical")
+	request, err := f.Make()
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	var buffer bytes.Buffer
+	if err := request.Write(&buffer); err != nil {
+		t.Fatalf("error writing request: %v", err)
+	}
+	wire := buffer.String()
+	for _, line := range []string{"x-api-key: secret\r\n", "X-DUP: first\r\nX-DUP: second\r\n", "X-Dup: canonical\r\n"} {
+		if !strings.Contains(wire, line) {
+			t.Fatalf("expected %q in request, got %q", line, wire)
+		}
+	}
+	f.SetRawHeader("X-DUP", "only").DelRawHeader("x-api-key")
+	if values := f.headers["X-DUP"]; len(values) != 1 || values[0] != "only" {
+		t.Fatalf("error setting raw header: got %v", values)
+	}
+	if _, ok := f.headers["x-api-key"]; ok {
+		t.Fatalf("error deleting raw header: still present")
+	}
+}
+
+func TestMapHeaders(t *testing.T) {
+	f := New("").
+		SetHeader("X-Custom", "value1").
+		SetHeader("X-Empty", "").
+		SetHeader("Accept", "application/json")
+	f.MapHeaders(func(key string, values []string) (string, []string, bool) {
+		if len(values) == 0 || values[0] == "" {
+			return "", nil, false
+		}
+		if strings.HasPrefix(key, "X-") {
+			return strings.ToLower(key), values, true
+		}
+		return key, values, true
+	})
+	if len(f.headers) != 2 {
+		t.Fatalf("error mapping headers: expected 2, got %v", f.headers)
+	}
+	if values := f.headers["x-custom"]; len(values) != 1 || values[0] != "value1" {
+		t.Fatalf("error mapping headers: expected lower-case x-custom, got %v", f.headers)
+	}
+	request, err := f.Make()
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if _, ok := request.Header["x-custom"]; !ok || request.Header.Get("Accept") != "application/json" {
+		t.Fatalf("error mapping headers: got %v", request.Header)
+	}
+}
+
+func TestMapQueryParameters(t *testing.T) {
+	f := New("").
+		SetQueryParameter("a", "1").
+		SetQueryParameter("b", "2").
+		SetQueryParameter("empty", "")
+	f.MapQueryParameters(func(key string, values []string) (string, []string, bool) {
+		if key == "b" {
+			key = "a"
+		}
+		return key, values, values[0] != ""
+	})
+	if len(f.parameters) != 1 || len(f.parameters["a"]) != 2 {
+		t.Fatalf("error mapping query parameters: expected a with 2 values, got %v", f.parameters)
+	}
+}
+
 func TestHeadersFrom(t *testing.T) {
 
 	type Nested struct {
@@ -615,6 +765,30 @@ func TestHeadersFrom(t *testing.T) {
 	}
 }
 
+func TestBindingFromIgnoresMode(t *testing.T) {
+	source := map[string][]string{"key": {"new"}}
+	f := New("https://example.com/{key}").SetHeader("Key", "old").SetQueryParameter("key", "old").SetVariable("key", "old").Del()
+	f.HeadersFrom(source).QueryParametersFrom(source).VariablesFrom(source)
+	request, err := f.Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if values := request.Header.Values("Key"); len(values) != 1 || values[0] != "new" {
+		t.Fatalf("expected the header to be set, got %v", values)
+	}
+	if request.URL.String() != "https://example.com/new?key=new" {
+		t.Fatalf("expected the parameter and variable to be set, got %s", request.URL)
+	}
+	request, _ = New("https://example.com").SetHeader("Key", "old").SetQueryParameter("key", "old").Remove().
+		AddHeadersFrom(source).AddQueryParametersFrom(source).Make()
+	if values := request.Header.Values("Key"); len(values) != 2 || values[1] != "new" {
+		t.Fatalf("expected the header to be added, got %v", values)
+	}
+	if values := request.URL.Query()["key"]; len(values) != 2 || values[1] != "new" {
+		t.Fatalf("expected the parameter to be added, got %v", values)
+	}
+}
+
 func TestWithEntity(t *testing.T) {
 	expected := "some text to send along"
 	f := New("").ContentType("text/plain").WithEntity(strings.NewReader(expected))
@@ -665,15 +839,34 @@ func TestWithJSONEntity(t *testing.T) {
 }
 
 func TestWithJSONEntityNoStruct(t *testing.T) {
-	defer handler("only structs can be passed as source for JSON entities", t)
 	s := "a string"
-	New("").WithJSONEntity(s)
+	testCases := []struct {
+		entity   interface{}
+		expected string
+	}{
+		{s, `"a string"`},
+		{&s, `"a string"`},
+		{42, `42`},
+		{nil, `null`},
+		{[]int{1, 2}, `[1,2]`},
+		{map[string]interface{}{"key": []string{"value"}}, `{"key":["value"]}`},
+	}
+	for _, test := range testCases {
+		f := New("").WithJSONEntity(test.entity)
+		data, _ := ioutil.ReadAll(f.body)
+		if string(data) != test.expected {
+			t.Fatalf("error adding JSON entity: expected %s, got %s", test.expected, data)
+		}
+		if f.headers.Get("Content-Type") != "application/json" {
+			t.Fatalf("error adding JSON entity: expected application/json, got %q", f.headers.Get("Content-Type"))
+		}
+	}
 }
 
-func TestWithJSONEntityNoStructPtr(t *testing.T) {
-	defer handler("only structs can be passed as source for JSON entities", t)
-	s := "a string"
-	New("").WithJSONEntity(&s)
+func TestWithJSONEntityUnsupported(t *testing.T) {
+	if _, err := New("http://www.example.com/").WithJSONEntity(make(chan int)).Make(); err == nil {
+		t.Fatalf("expected an encoding error")
+	}
 }
 
 func TestWithXMLEntity(t *testing.T) {
@@ -0,0 +1,273 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// Requestor executes the requests generated by a Builder; it wraps an
+// http.Client and turns non-2xx responses into HTTPErrors. A Requestor can be
+// shared by many builders and is safe for concurrent use, provided it is not
+// reconfigured while requests are being executed.
+type Requestor struct {
+
+	// client is the HTTP client used to send requests; transport, if set, is
+	// the private copy of its transport customised by the Requestor.
+	client    *http.Client
+	transport *http.Transport
+
+	// limit is the maximum number of bytes of the response body retained in
+	// HTTPErrors.
+	limit int
+
+	// recorder, if set, collects the results of all executions.
+	recorder *ResultRecorder
+
+	// lock protects the lazily populated per-host state.
+	lock sync.Mutex
+
+	// limiters contains the per-host rate limiters, created on demand by the
+	// limiter factory.
+	limiters       map[string]Limiter
+	limiterFactory func(host string) Limiter
+
+	// failFast makes rate limited requests fail instead of waiting.
+	failFast bool
+
+	// quotas contains the last observed server-side quota of each host.
+	quotas map[string]Quota
+
+	// adaptive enables throttling based on the observed quotas, and pace makes
+	// it spread requests evenly over the quota window.
+	adaptive bool
+	pace     bool
+
+	// breakers contains the per-host circuit breakers, created according to the
+	// breaker threshold, timeout and callback.
+	breakers         map[string]*CircuitBreaker
+	breakerThreshold int
+	breakerTimeout   time.Duration
+	breakerCallback  func(host string, from, to CircuitState)
+	breakerStore     Store
+
+	// attempts is the maximum number of attempts per request, with backoff as
+	// the base delay between them; retryable and classifier, if set, override
+	// the default retry decision and error classification.
+	attempts   int
+	backoff    time.Duration
+	retryable  func(class ErrorClass, err error) bool
+	classifier func(err error) ErrorClass
+
+	// deduplicate, if set, returns the key by which identical in-flight
+	// requests, tracked in flights, are collapsed.
+	deduplicate func(request *http.Request) string
+	flights     map[string]*flight
+
+	// family, localIP, localInterface, keepAlive and dialTimeout control how
+	// outgoing connections are opened.
+	family         IPFamily
+	localIP        net.IP
+	localInterface string
+	keepAlive      time.Duration
+	dialTimeout    time.Duration
+
+	// cache, if set, is the response cache; freshness enables serving fresh
+	// responses without revalidating them, offline serves all the responses
+	// from the cache, refreshing tracks the responses being revalidated in the
+	// background and stats is updated as the cache is used.
+	cache      Store
+	freshness  bool
+	offline    bool
+	refreshing map[string]bool
+	stats      CacheStats
+
+	// ids, if set, generates the identifiers used by the Requestor.
+	ids IDGenerator
+
+	// requeues is the maximum number of times a request rejected with a 429
+	// status code re-enters the queue.
+	requeues int
+
+	// observers are notified about the execution of requests.
+	observers []Observer
+
+	// requestHooks, responseHooks and errorHooks are called as requests are
+	// executed.
+	requestHooks  []RequestHook
+	responseHooks []ResponseHook
+	errorHooks    []ErrorHook
+
+	// events, if set, is the bus the lifecycle events are published on.
+	events *EventBus
+
+	// active is the number of requests being executed, other than prefetch
+	// ones, and waiting is closed when it drops to zero.
+	active  int
+	waiting chan struct{}
+
+	// decompressing makes the Requestor decompress the responses, advertising
+	// the given encodings (all the registered ones, if empty).
+	decompressing bool
+	encodings     []string
+}
+
+// NewRequestor returns a new Requestor using the given HTTP client; if no
+// client is provided, http.DefaultClient is used.
+func NewRequestor(client *http.Client) *Requestor {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Requestor{
+		client:     client,
+		limit:      DefaultErrorBodyLimit,
+		limiters:   map[string]Limiter{},
+		quotas:     map[string]Quota{},
+		breakers:   map[string]*CircuitBreaker{},
+		flights:    map[string]*flight{},
+		refreshing: map[string]bool{},
+	}
+}
+
+// ErrorBodyLimit sets the maximum number of bytes of the response body that
+// are retained in HTTPErrors.
+func (r *Requestor) ErrorBodyLimit(limit int) *Requestor {
+	if limit > 0 {
+		r.limit = limit
+	}
+	return r
+}
+
+// RecordResults attaches a ResultRecorder to the Requestor, so that the outcome
+// of each execution is collected for reporting.
+func (r *Requestor) RecordResults(recorder *ResultRecorder) *Requestor {
+	r.recorder = recorder
+	return r
+}
+
+// Do creates a new http.Request from the given Builder, binds it to the given
+// context and sends it; if the server responds with a non-2xx status code, the
+// response body is consumed and closed and an *HTTPError is returned.
+func (r *Requestor) Do(ctx context.Context, f *Builder) (*Response, error) {
+	request, err := r.build(f)
+	if err != nil {
+		return nil, err
+	}
+	if ctx != nil {
+		request = f.bind(ctx, request)
+	}
+	return r.do(f, request)
+}
+
+// do executes the given request, generated by the given Builder, and records
+// its outcome.
+func (r *Requestor) do(f *Builder, request *http.Request) (*Response, error) {
+	started := time.Now()
+	request, done := r.track(f, request, started)
+	request, expire := f.deadline(request)
+	defer r.busy(request)()
+	r.started(request)
+	for _, hook := range r.requestHooks {
+		hook(request)
+	}
+	var err error
+	response := r.cached(f, request)
+	if response == nil && r.offline {
+		err = fmt.Errorf("%s %s: %w", request.Method, RedactURL(request.URL), ErrOffline)
+	} else if response == nil {
+		response, err = r.collapse(f, request)
+	}
+	if response != nil {
+		response.options = f.decoding
+		response.builder = f
+		response.Timings.Total = time.Since(started)
+	}
+	r.hook(request, response, err, started)
+	r.finished(request, response, err, started)
+	done(response)
+	expire(response)
+	if r.recorder != nil {
+		r.recorder.Record(newResult(request, response, err, started))
+	}
+	return response, err
+}
+
+// execute applies the Requestor policies to the given request, generated by the
+// given Builder, and sends it.
+func (r *Requestor) execute(f *Builder, request *http.Request) (*Response, error) {
+	breakers, err := r.admit(f, request)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.throttle(f, request); err != nil {
+		settle(breakers, request, false, err)
+		return nil, err
+	}
+	if err := r.pause(request); err != nil {
+		settle(breakers, request, false, err)
+		return nil, err
+	}
+	response, err := r.send(f, request)
+	settle(breakers, request, true, err)
+	return response, err
+}
+
+func (r *Requestor) send(f *Builder, request *http.Request) (*Response, error) {
+	log.Debugf("sending %s request to %q", request.Method, RedactURL(request.URL))
+	request, cached := r.precondition(f, request)
+	client := r.redirecting(r.client)
+	transport, err := f.roundTripper(r.client)
+	if err != nil {
+		return nil, err
+	} else if transport != nil {
+		override := *client
+		override.Transport = transport
+		client = &override
+	}
+	traced, timings := trace(r.acceptEncoding(f.uploading(request)))
+	started := time.Now()
+	response, err := client.Do(traced)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := r.decompress(response)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.limit(request, response); err != nil {
+		return nil, err
+	}
+	f.downloading(response)
+	if r.events != nil {
+		r.publish(&ResponseReceived{At: time.Now(), Method: request.Method, URL: RedactURL(request.URL), StatusCode: response.StatusCode, Elapsed: time.Since(started)})
+	}
+	r.observe(request.URL.Host, response)
+	response, fromCache, err := r.validate(f, request, response, cached)
+	if err != nil {
+		return nil, err
+	}
+	attempts := 1
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		log.Debugf("request to %q failed with status %q", RedactURL(request.URL), response.Status)
+		return nil, NewHTTPError(response, attempts, r.limit)
+	}
+	if err := f.conform(request, response); err != nil {
+		return nil, err
+	}
+	return &Response{
+		Response:   response,
+		Attempts:   attempts,
+		FromCache:  fromCache,
+		Timings:    timings(),
+		compressed: compressed,
+	}, nil
+}
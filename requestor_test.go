@@ -0,0 +1,46 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestorDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(strings.Repeat("x", 100)))
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient()).ErrorBodyLimit(10)
+
+	response, err := requestor.Do(context.Background(), New(server.URL).Set().Header("X-Auth-Token", "secret"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if string(body) != "hello" || response.Attempts != 1 {
+		t.Fatalf("invalid response: got %q after %d attempts", body, response.Attempts)
+	}
+
+	_, err = requestor.Do(context.Background(), New(server.URL))
+	if !IsUnauthorized(err) {
+		t.Fatalf("expected 401 error, got %v", err)
+	}
+	if e := err.(*HTTPError); len(e.Body) != 10 || !e.Truncated || e.Method != http.MethodGet {
+		t.Fatalf("invalid error: %#v", e)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// DefaultRequeueDelay is how long a request rejected with a 429 status code
+// waits before re-entering the queue, if the server does not send Retry-After.
+const DefaultRequeueDelay = time.Second
+
+// Requeue makes the Requestor handle 429 (Too Many Requests) responses by
+// waiting for as long as the server asks via Retry-After (DefaultRequeueDelay
+// if it does not), and then sending the request again, through circuit breakers,
+// rate limiters and quotas as if it were new, up to max times; since the server
+// did not process the request, this also applies to non-idempotent requests,
+// provided their body can be replayed.
+func (r *Requestor) Requeue(max int) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.requeues = max
+	return r
+}
+
+// requeue executes the given request, re-entering the queue when the server
+// rejects it with a 429 status code.
+func (r *Requestor) requeue(f *Builder, request *http.Request) (*Response, error) {
+	if r.requeues <= 0 || !replayable(request) {
+		return r.failover(f, request)
+	}
+	current := request
+	for reentry := 0; ; reentry++ {
+		response, err := r.failover(f, current)
+		if err == nil {
+			response.Attempts += reentry
+			return response, nil
+		}
+		var httpErr *HTTPError
+		if !errors.As(err, &httpErr) {
+			return nil, err
+		}
+		httpErr.Attempts += reentry
+		if httpErr.StatusCode != http.StatusTooManyRequests || reentry >= r.requeues {
+			return nil, err
+		}
+		delay, ok := httpErr.RetryAfter()
+		if !ok {
+			delay = DefaultRequeueDelay
+		}
+		if deadline, ok := request.Context().Deadline(); ok && deadline.Before(time.Now().Add(delay)) {
+			return nil, err
+		}
+		log.Debugf("%s %s rate limited by server, re-entering queue in %v", request.Method, RedactURL(request.URL), delay)
+		r.publish(&RetryScheduled{At: time.Now(), Method: request.Method, URL: RedactURL(request.URL), Attempt: reentry + 2, Delay: delay, Err: err})
+		if !sleep(request.Context(), delay) {
+			return nil, err
+		}
+		if current, err = rewind(request); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequeue(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(nil).Requeue(2)
+	started := time.Now()
+	response, err := requestor.Do(context.Background(), New(server.URL).Post().WithEntity(strings.NewReader("payload")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if string(body) != "payload" || response.Attempts != 3 {
+		t.Fatalf("expected payload after 3 attempts, got %q after %d", body, response.Attempts)
+	}
+	if time.Since(started) > DefaultRequeueDelay {
+		t.Fatalf("Retry-After was not honoured")
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	_, err = NewRequestor(nil).Requeue(1).Do(context.Background(), New(server.URL).Post())
+	if StatusCode(err) != http.StatusTooManyRequests || calls != 2 {
+		t.Fatalf("expected 429 after 2 calls, got %v after %d", err, calls)
+	}
+	if httpErr, ok := err.(*HTTPError); !ok || httpErr.Attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// Resolver resolves host names to IP addresses; *net.Resolver implements it.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// StaticResolver resolves the host names it maps (in lower case) to the given
+// IP addresses, and the others via the default resolver, like a hosts file.
+type StaticResolver map[string][]string
+
+// LookupHost returns the addresses of the given host.
+func (r StaticResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addresses, ok := r[strings.ToLower(host)]; ok {
+		return addresses, nil
+	}
+	return net.DefaultResolver.LookupHost(ctx, host)
+}
+
+// DNSResolver returns a resolver querying the given DNS server (e.g.
+// "10.0.0.53:5353", port 53 if omitted) instead of the system ones.
+func DNSResolver(server string) *net.Resolver {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// DoHResolver resolves host names via DNS over HTTPS, using the JSON API
+// offered by the public resolvers (e.g. "https://cloudflare-dns.com/dns-query"
+// or "https://dns.google/resolve").
+type DoHResolver struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewDoHResolver returns a resolver querying the given DNS over HTTPS endpoint
+// with the given HTTP client (http.DefaultClient if nil), which must not
+// itself depend on the resolver.
+func NewDoHResolver(endpoint string, client *http.Client) *DoHResolver {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &DoHResolver{endpoint: endpoint, client: client}
+}
+
+// LookupHost returns the IPv4 and IPv6 addresses of the given host.
+func (r *DoHResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addresses, _, err := r.LookupHostTTL(ctx, host)
+	return addresses, err
+}
+
+// LookupHostTTL returns the IPv4 and IPv6 addresses of the given host, and the
+// lowest TTL of their records.
+func (r *DoHResolver) LookupHostTTL(ctx context.Context, host string) ([]string, time.Duration, error) {
+	addresses := []string{}
+	ttl := time.Duration(-1)
+	var failure error
+	for _, t := range []string{"A", "AAAA"} {
+		found, expiry, err := r.query(ctx, host, t)
+		if err != nil {
+			failure = err
+		}
+		if len(found) > 0 && (ttl < 0 || expiry < ttl) {
+			ttl = expiry
+		}
+		addresses = append(addresses, found...)
+	}
+	if len(addresses) == 0 {
+		if failure == nil {
+			failure = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return nil, 0, failure
+	}
+	return addresses, ttl, nil
+}
+
+// query returns the addresses of the given record type of the given host, and
+// the lowest TTL of their records.
+func (r *DoHResolver) query(ctx context.Context, host string, recordType string) ([]string, time.Duration, error) {
+	request, err := http.NewRequest(http.MethodGet, r.endpoint+"?"+url.Values{"name": {host}, "type": {recordType}}.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	request.Header.Set("Accept", "application/dns-json")
+	response, err := r.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("DNS over HTTPS query for %s failed with status %q", host, response.Status)
+	}
+	var answer struct {
+		Status int
+		Answer []struct {
+			Type int    `json:"type"`
+			TTL  int64  `json:"TTL"`
+			Data string `json:"data"`
+		}
+	}
+	if err := json.NewDecoder(response.Body).Decode(&answer); err != nil {
+		return nil, 0, err
+	}
+	addresses := []string{}
+	var ttl time.Duration
+	for _, record := range answer.Answer {
+		// only A and AAAA records, skipping CNAMEs
+		if (record.Type == 1 || record.Type == 28) && net.ParseIP(record.Data) != nil {
+			if expiry := time.Duration(record.TTL) * time.Second; len(addresses) == 0 || expiry < ttl {
+				ttl = expiry
+			}
+			addresses = append(addresses, record.Data)
+		}
+	}
+	return addresses, ttl, nil
+}
+
+// WithResolver makes the requests generated by this builder and by the
+// children created from it afterwards resolve host names via the given
+// resolver, e.g. a StaticResolver to hit a specific instance, a DNSResolver
+// for an internal DNS server or a DoHResolver; the connections are opened
+// through a transport private to the builder, derived from the one the
+// requests are sent with (the one set via WithTransport or the one of the
+// Requestor), which must be an *http.Transport.
+func (f *Builder) WithResolver(resolver Resolver) *Builder {
+	dialer := &net.Dialer{Timeout: DefaultDialTimeout, KeepAlive: 30 * time.Second}
+	return f.customise(func(transport *http.Transport) {
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(address)
+			if err != nil || net.ParseIP(host) != nil {
+				return dialer.DialContext(ctx, network, address)
+			}
+			addresses, err := resolver.LookupHost(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+			for _, ip := range addresses {
+				var conn net.Conn
+				if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip, port)); err == nil {
+					return conn, nil
+				}
+				log.Debugf("error connecting to %s at %s: %v", host, ip, err)
+			}
+			return nil, err
+		}
+	})
+}
+
+// HostOverride makes the requests generated by this builder and by the
+// children created from it afterwards carry the given host in the Host header
+// and in the TLS server name indication, whatever the host of their URL; this
+// allows hitting a specific backend instance by address while preserving
+// virtual host routing and certificate validation.
+func (f *Builder) HostOverride(host string) *Builder {
+	serverName := host
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		serverName = name
+	}
+	f.customise(func(transport *http.Transport) {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.ServerName = serverName
+	})
+	defer f.write()()
+	f.host = host
+	return f
+}
+
+// ErrCustomTransport is returned when the settings of a builder require a
+// private copy of its transport, but the transport is a custom
+// http.RoundTripper rather than an *http.Transport.
+var ErrCustomTransport = errors.New("cannot customise transport")
+
+// customise makes the requests generated by this builder and its children be
+// sent through a private copy of their transport, modified by the given
+// function after the previous ones; since the transport may be the one of the
+// Requestor, the copy is made when the requests are sent.
+func (f *Builder) customise(customisation func(*http.Transport)) *Builder {
+	defer f.write()()
+	// never append in place, the slice is shared with the children
+	f.customisations = append(f.customisations[:len(f.customisations):len(f.customisations)], customisation)
+	f.customised = &customisedTransports{copies: map[*http.Transport]*http.Transport{}}
+	return f
+}
+
+// customisedTransports caches the customised copies of the transports, so
+// that their connections are pooled across requests.
+type customisedTransports struct {
+	lock   sync.Mutex
+	copies map[*http.Transport]*http.Transport
+}
+
+// get returns the copy of the given transport modified by the given
+// customisations, making it on first use.
+func (c *customisedTransports) get(transport *http.Transport, customisations []func(*http.Transport)) *http.Transport {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if customised, ok := c.copies[transport]; ok {
+		return customised
+	}
+	customised := transport.Clone()
+	for _, customisation := range customisations {
+		customisation(customised)
+	}
+	c.copies[transport] = customised
+	return customised
+}
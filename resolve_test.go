@@ -0,0 +1,119 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestWithResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+	address, _ := url.Parse(server.URL)
+	_, port, _ := net.SplitHostPort(address.Host)
+
+	requestor := NewRequestor(nil)
+	api := New("http://backend.internal:" + port).WithResolver(StaticResolver{"backend.internal": {"127.0.0.1"}})
+	response, err := requestor.Do(context.Background(), api.New("", "/"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var body []byte
+	if err := response.Decode(&body); err != nil || string(body) != "backend.internal:"+port {
+		t.Fatalf("unexpected body %q (%v)", body, err)
+	}
+
+	api = New("http://unknown.invalid:" + port).WithResolver(StaticResolver{"unknown.invalid": {}})
+	if _, err := requestor.Do(context.Background(), api); err == nil {
+		t.Fatalf("expected resolution error")
+	}
+}
+
+func TestDoHResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/dns-json" || r.URL.Query().Get("name") != "api.example.com" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/dns-json")
+		switch r.URL.Query().Get("type") {
+		case "A":
+			w.Write([]byte(`{"Status":0,"Answer":[{"name":"api.example.com","type":5,"data":"lb.example.com."},{"name":"lb.example.com","type":1,"TTL":300,"data":"192.0.2.1"}]}`))
+		case "AAAA":
+			w.Write([]byte(`{"Status":0,"Answer":[{"name":"lb.example.com","type":28,"TTL":60,"data":"2001:db8::1"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	addresses, ttl, err := NewDoHResolver(server.URL, nil).LookupHostTTL(context.Background(), "api.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(addresses) != 2 || addresses[0] != "192.0.2.1" || addresses[1] != "2001:db8::1" || ttl != time.Minute {
+		t.Fatalf("unexpected addresses %v (TTL %v)", addresses, ttl)
+	}
+	if _, err := NewDoHResolver(server.URL, nil).LookupHost(context.Background(), "other.example.com"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestHostOverride(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + " " + r.TLS.ServerName))
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(nil)
+	api := New(server.URL).WithTransport(server.Client().Transport).HostOverride("example.com")
+	response, err := requestor.Do(context.Background(), api.New("", "/"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var body []byte
+	if err := response.Decode(&body); err != nil || string(body) != "example.com example.com" {
+		t.Fatalf("unexpected body %q (%v)", body, err)
+	}
+}
+
+func TestCustomisedTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+
+	// the transport of the Requestor, trusting the server, is customised
+	requestor := NewRequestor(server.Client())
+	api := New(server.URL).HostOverride("example.com")
+	for i := 0; i < 2; i++ {
+		response, err := requestor.Do(context.Background(), api.New("", "/"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var body []byte
+		if err := response.Decode(&body); err != nil || string(body) != "example.com" {
+			t.Fatalf("unexpected body %q (%v)", body, err)
+		}
+	}
+	if len(api.customised.copies) != 1 {
+		t.Fatalf("expected a single copy of the transport, got %d", len(api.customised.copies))
+	}
+
+	mocked := NewRequestor(&http.Client{Transport: NewMock()})
+	if _, err := mocked.Do(context.Background(), api.New("", "/")); !errors.Is(err, ErrCustomTransport) {
+		t.Fatalf("expected an error customising the mock, got %v", err)
+	}
+	if _, err := New(server.URL).WithTransport(NewMock()).WithResolver(StaticResolver{}).Make(); !errors.Is(err, ErrCustomTransport) {
+		t.Fatalf("expected an error customising the mock, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Response wraps the http.Response returned by the server, augmenting it with
+// information about the way it was obtained.
+type Response struct {
+	*http.Response
+
+	// Attempts is the number of times the request was sent to the server.
+	Attempts int
+
+	// FromCache is true if the response was served from a cache, possibly after
+	// the server confirmed it is still current.
+	FromCache bool
+
+	// Timings is the breakdown of the time it took to get the response.
+	Timings Timings
+
+	// options are the decoding options of the Builder that generated the
+	// request.
+	options DecodeOptions
+
+	// builder is the Builder that generated the request.
+	builder *Builder
+
+	// compressed, if set, is the response body as received, before being
+	// decompressed.
+	compressed *countingBody
+
+	// document is the JSON response body, once decoded by Extract.
+	document interface{}
+	parsed   bool
+}
+
+// DecodeOptions is a set of flags relaxing or tightening the way JSON response
+// bodies are decoded, to cope with real-world APIs.
+type DecodeOptions uint
+
+const (
+	// StripBOM removes the UTF-8 byte order mark some servers put in front of
+	// the body.
+	StripBOM DecodeOptions = 1 << iota
+	// AllowTrailingCommas tolerates commas after the last element of objects and
+	// arrays.
+	AllowTrailingCommas
+	// AllowTrailingData ignores any data after the JSON document, instead of
+	// failing.
+	AllowTrailingData
+	// DisallowUnknownFields makes decoding fail if the body has fields that do
+	// not match any field of the target struct.
+	DisallowUnknownFields
+	// UseNumber decodes the numbers into interface{} values as json.Number
+	// instead of float64, so that no precision is lost (e.g. on large ids or
+	// amounts); see also Decimal.
+	UseNumber
+	// NegotiatedType decodes the bodies without a Content-Type, or with a
+	// generic one (text/plain or application/octet-stream), according to the
+	// media type preferred by the request Accept header.
+	NegotiatedType
+)
+
+const (
+	// StrictDecoding makes decoding fail on anything not matching the target,
+	// for contract checking (e.g. in tests).
+	StrictDecoding = DisallowUnknownFields
+	// LenientDecoding tolerates the most common deviations of real-world
+	// APIs: byte order marks, trailing commas and trailing garbage.
+	LenientDecoding = StripBOM | AllowTrailingCommas | AllowTrailingData
+)
+
+// DecodeOptions sets the options used to decode the responses to the requests
+// generated by this builder and its children.
+func (f *Builder) DecodeOptions(options DecodeOptions) *Builder {
+	f.decoding = options
+	return f
+}
+
+// Decode reads the response body and unmarshals it into the given value, using
+// the response Content-Type to choose between JSON (the default) and XML (see
+// also NegotiatedType); the response body is closed.
+func (r *Response) Decode(v interface{}) error {
+	return r.DecodeWith(v, r.options)
+}
+
+// DecodeWith is like Decode, but uses the given options instead of those of
+// the Builder that generated the request, e.g. to check a single response
+// strictly.
+func (r *Response) DecodeWith(v interface{}, options DecodeOptions) error {
+	defer r.Body.Close()
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return decode(r.contentType(options), data, v, options)
+}
+
+// bom is the UTF-8 byte order mark.
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+// decode unmarshals the given data into the given value according to its
+// content type and to the given options.
+func decode(contentType string, data []byte, v interface{}, options DecodeOptions) error {
+	if v == nil {
+		return nil
+	}
+	if options&StripBOM != 0 {
+		data = bytes.TrimPrefix(data, bom)
+	}
+	if raw, ok := v.(*[]byte); ok {
+		*raw = data
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if strings.HasSuffix(mediaType, "/xml") || strings.HasSuffix(mediaType, "+xml") {
+		return xml.Unmarshal(data, v)
+	}
+	if options&(AllowTrailingCommas|AllowTrailingData|DisallowUnknownFields|UseNumber) == 0 || len(bytes.TrimSpace(data)) == 0 {
+		return json.Unmarshal(data, v)
+	}
+	if options&AllowTrailingCommas != 0 {
+		data = stripTrailingCommas(data)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if options&DisallowUnknownFields != 0 {
+		decoder.DisallowUnknownFields()
+	}
+	if options&UseNumber != 0 {
+		decoder.UseNumber()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if options&AllowTrailingData == 0 {
+		if _, err := decoder.Token(); err != io.EOF {
+			return errors.New("invalid data after top-level JSON value")
+		}
+	}
+	return nil
+}
+
+// stripTrailingCommas removes the commas that directly precede the end of an
+// object or array, outside of strings.
+func stripTrailingCommas(data []byte) []byte {
+	result := make([]byte, 0, len(data))
+	quoted, escaped := false, false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case quoted:
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				quoted = false
+			}
+		case c == '"':
+			quoted = true
+		case c == ',':
+			j := i + 1
+			for j < len(data) && (data[j] == ' ' || data[j] == '\t' || data[j] == '\n' || data[j] == '\r') {
+				j++
+			}
+			if j < len(data) && (data[j] == '}' || data[j] == ']') {
+				continue
+			}
+		}
+		result = append(result, c)
+	}
+	return result
+}
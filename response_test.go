@@ -0,0 +1,111 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResponseDecode(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/json; charset=utf-8", `{"name": "John"}`},
+		{"text/xml", `<person><name>John</name></person>`},
+		{"application/atom+xml", `<person><name>John</name></person>`},
+	}
+	for _, test := range tests {
+		response := &Response{
+			Response: &http.Response{
+				Header: http.Header{"Content-Type": []string{test.contentType}},
+				Body:   ioutil.NopCloser(strings.NewReader(test.body)),
+			},
+		}
+		v := struct {
+			Name string `json:"name" xml:"name"`
+		}{}
+		if err := response.Decode(&v); err != nil {
+			t.Fatalf("error decoding %q: %v", test.contentType, err)
+		}
+		if v.Name != "John" {
+			t.Fatalf("invalid value decoding %q: got %q", test.contentType, v.Name)
+		}
+	}
+
+	raw := []byte{}
+	if err := decode("application/octet-stream", []byte("raw"), &raw, 0); err != nil || string(raw) != "raw" {
+		t.Fatalf("invalid raw decoding: got %q (%v)", raw, err)
+	}
+}
+
+func TestDecodeOptions(t *testing.T) {
+	type person struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+	tests := []struct {
+		body    string
+		options DecodeOptions
+		valid   bool
+	}{
+		{"\xEF\xBB\xBF{\"name\": \"John\"}", 0, false},
+		{"\xEF\xBB\xBF{\"name\": \"John\"}", StripBOM, true},
+		{`{"name": "John", "tags": ["a", "b",],}`, 0, false},
+		{`{"name": "John", "tags": ["a", "b",],}`, AllowTrailingCommas, true},
+		{`{"name": "John,}"}`, AllowTrailingCommas, true},
+		{`{"name": "John"} garbage`, 0, false},
+		{`{"name": "John"} garbage`, DisallowUnknownFields, false},
+		{`{"name": "John"} garbage`, AllowTrailingData, true},
+		{`{"name": "John", "age": 42}`, 0, true},
+		{`{"name": "John", "age": 42}`, DisallowUnknownFields, false},
+	}
+	for _, test := range tests {
+		v := person{}
+		err := decode("application/json", []byte(test.body), &v, test.options)
+		if test.valid && (err != nil || !strings.HasPrefix(v.Name, "John")) {
+			t.Fatalf("error decoding %q with options %b: %v", test.body, test.options, err)
+		} else if !test.valid && err == nil {
+			t.Fatalf("expected an error decoding %q with options %b", test.body, test.options)
+		}
+	}
+}
+
+func TestDecodeWith(t *testing.T) {
+	type person struct {
+		Name string `json:"name"`
+	}
+	tests := []struct {
+		body    string
+		options DecodeOptions
+		valid   bool
+	}{
+		{"\xEF\xBB\xBF{\"name\": \"John\",} garbage", 0, false},
+		{"\xEF\xBB\xBF{\"name\": \"John\",} garbage", LenientDecoding, true},
+		{`{"name": "John", "age": 42}`, LenientDecoding, true},
+		{`{"name": "John", "age": 42}`, StrictDecoding, false},
+		{`{"name": "John"}`, StrictDecoding | UseNumber, true},
+	}
+	for _, test := range tests {
+		response := &Response{
+			Response: &http.Response{
+				Header: http.Header{"Content-Type": []string{"application/json"}},
+				Body:   ioutil.NopCloser(strings.NewReader(test.body)),
+			},
+			// the per-call options replace those of the builder
+			options: LenientDecoding,
+		}
+		v := person{}
+		err := response.DecodeWith(&v, test.options)
+		if test.valid && (err != nil || v.Name != "John") {
+			t.Fatalf("error decoding %q with options %b: %v", test.body, test.options, err)
+		} else if !test.valid && err == nil {
+			t.Fatalf("expected an error decoding %q with options %b", test.body, test.options)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// maxBackoff is the upper bound of the delay between two attempts.
+const maxBackoff = time.Minute
+
+// Retry makes the Requestor retry failed idempotent requests (whose body, if
+// any, can be replayed), including those carrying an idempotency key (see
+// Builder.Idempotent), up to the given number of attempts overall, waiting an
+// exponentially growing, jittered delay starting at backoff between attempts,
+// or longer if the server asks so via the Retry-After header; which failures
+// are retried is decided by DefaultRetryable, unless RetryIf is used.
+func (r *Requestor) Retry(attempts int, backoff time.Duration) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.attempts = attempts
+	r.backoff = backoff
+	return r
+}
+
+// RetryIf sets the function that decides whether a failed attempt should be
+// retried, given the class of its error; this allows retry policies to act
+// differently per class, e.g. retrying DNS timeouts but never certificate
+// errors.
+func (r *Requestor) RetryIf(retryable func(class ErrorClass, err error) bool) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.retryable = retryable
+	return r
+}
+
+// ClassifyErrors sets a custom error classifier, used instead of Classify to
+// feed the retry decision.
+func (r *Requestor) ClassifyErrors(classifier func(err error) ErrorClass) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.classifier = classifier
+	return r
+}
+
+// retry executes the given request, retrying it according to the retry policy.
+func (r *Requestor) retry(f *Builder, request *http.Request) (*Response, error) {
+	if r.attempts <= 1 || !retriable(request) || !replayable(request) {
+		return r.hedge(f, request)
+	}
+	classify, retryable := r.policy()
+	for attempt := 1; ; attempt++ {
+		current := request
+		if attempt > 1 {
+			var err error
+			if current, err = rewind(request); err != nil {
+				return nil, err
+			}
+		}
+		response, err := r.hedge(f, current)
+		if err == nil {
+			response.Attempts += attempt - 1
+			return response, nil
+		}
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			httpErr.Attempts += attempt - 1
+		}
+		class := classify(err)
+		if attempt >= r.attempts || request.Context().Err() != nil || !retryable(class, err) {
+			return nil, err
+		}
+		delay := backoff(r.backoff, attempt, httpErr)
+		if deadline, ok := request.Context().Deadline(); ok && deadline.Before(time.Now().Add(delay)) {
+			return nil, err
+		}
+		log.Debugf("attempt %d of %s %s failed (%v: %v), retrying in %v", attempt, request.Method, RedactURL(request.URL), class, err, delay)
+		r.publish(&RetryScheduled{At: time.Now(), Method: request.Method, URL: RedactURL(request.URL), Attempt: attempt + 1, Delay: delay, Err: err})
+		if !sleep(request.Context(), delay) {
+			return nil, err
+		}
+	}
+}
+
+// sleep waits for the given delay, and returns false if the context is done
+// before it elapses.
+func sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// policy returns the error classifier and the retry decision in use.
+func (r *Requestor) policy() (func(err error) ErrorClass, func(class ErrorClass, err error) bool) {
+	classify, retryable := r.classifier, r.retryable
+	if classify == nil {
+		classify = Classify
+	}
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+	return classify, retryable
+}
+
+// rewind returns a copy of the given request with a fresh body, so that it can
+// be sent again.
+func rewind(request *http.Request) (*http.Request, error) {
+	if request.GetBody == nil {
+		return request, nil
+	}
+	body, err := request.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone := request.Clone(request.Context())
+	clone.Body = body
+	return clone, nil
+}
+
+// backoff returns how long to wait after the given failed attempt: the base
+// delay, doubled at each attempt and jittered, or the delay requested by the
+// server.
+func backoff(base time.Duration, attempt int, httpErr *HTTPError) time.Duration {
+	delay := base << uint(attempt-1)
+	if delay > maxBackoff || delay <= 0 {
+		delay = maxBackoff
+	}
+	if base <= 0 {
+		delay = 0
+	} else {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+	if httpErr != nil {
+		if after, ok := httpErr.RetryAfter(); ok && after > delay {
+			delay = after
+		}
+	}
+	return delay
+}
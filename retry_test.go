@@ -0,0 +1,70 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&count, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(getClient()).Retry(3, time.Millisecond)
+	response, err := requestor.Do(context.Background(), New(server.URL).Put().WithEntity(strings.NewReader("payload")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if string(body) != "payload" || response.Attempts != 3 {
+		t.Fatalf("expected the body to be replayed, got %q after %d attempts", body, response.Attempts)
+	}
+
+	atomic.StoreInt32(&count, -10)
+	_, err = requestor.Do(context.Background(), New(server.URL))
+	if !IsServerError(err) || err.(*HTTPError).Attempts != 3 {
+		t.Fatalf("expected a server error after 3 attempts, got %v", err)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// non-idempotent requests are never retried
+	requestor := NewRequestor(getClient()).Retry(3, time.Millisecond)
+	requestor.Do(context.Background(), New(server.URL).Post())
+	if n := atomic.SwapInt32(&count, 0); n != 1 {
+		t.Fatalf("POST requests must not be retried, got %d attempts", n)
+	}
+
+	requestor.RetryIf(func(class ErrorClass, err error) bool {
+		return class != ClassHTTP
+	})
+	requestor.Do(context.Background(), New(server.URL))
+	if n := atomic.SwapInt32(&count, 0); n != 1 {
+		t.Fatalf("expected the custom policy not to retry, got %d attempts", n)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dihedron/go-log"
+)
+
+// Values is the set of named values extracted from the responses of scenario
+// steps, and made available to the following ones (e.g. auth tokens, ids).
+type Values map[string]string
+
+// Extractor extracts values from a completed exchange into the scenario values.
+type Extractor func(exchange *Exchange, values Values) error
+
+// Step is a single step in a Scenario: a request, generated by a Builder out of
+// the values extracted so far (possibly via injectors), whose response is checked by a set of assertions
+// and from which further values are extracted.
+type Step struct {
+	name       string
+	build      func(values Values) *Builder
+	injectors  []Injector
+	extractors []Extractor
+	assertions []Assertion
+}
+
+// Extract adds value extractors to the step; they are run in order, after the
+// assertions have been evaluated.
+func (s *Step) Extract(extractors ...Extractor) *Step {
+	s.extractors = append(s.extractors, extractors...)
+	return s
+}
+
+// Assert adds assertions to the step.
+func (s *Step) Assert(assertions ...Assertion) *Step {
+	s.assertions = append(s.assertions, assertions...)
+	return s
+}
+
+// Scenario is an ordered list of steps, such as "login, read profile, logout",
+// that can be run once or on a schedule to monitor an API; values extracted from
+// the responses of a step are available to the following ones. A step fails if
+// its request fails, any of its assertions does not hold or any of its values
+// cannot be extracted; a non-2xx response is a failure only if the step has no
+// assertions. The steps following a failed one are skipped.
+type Scenario struct {
+	name  string
+	steps []*Step
+}
+
+// NewScenario returns a new, empty Scenario.
+func NewScenario(name string) *Scenario {
+	return &Scenario{name: name}
+}
+
+// Step appends a new step to the scenario; the given function is invoked when
+// the step is run to create the Builder for its request, given the values
+// extracted so far.
+func (s *Scenario) Step(name string, build func(values Values) *Builder) *Step {
+	step := &Step{name: name, build: build}
+	s.steps = append(s.steps, step)
+	return step
+}
+
+// ScenarioResult is the outcome of a scenario run.
+type ScenarioResult struct {
+	// Name is the name of the scenario.
+	Name string
+	// Started is the time at which the run started.
+	Started time.Time
+	// Duration is the overall duration of the run.
+	Duration time.Duration
+	// Steps contains the outcome of each step, in order.
+	Steps []Result
+	// Values contains the values extracted during the run.
+	Values Values
+}
+
+// Passed returns whether all the steps in the scenario passed.
+func (r *ScenarioResult) Passed() bool {
+	for _, step := range r.Steps {
+		if !step.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// Run runs the scenario once, using the given Requestor, starting with the
+// given initial values (which can be nil).
+func (s *Scenario) Run(ctx context.Context, requestor *Requestor, initial Values) *ScenarioResult {
+	result := &ScenarioResult{
+		Name:    s.name,
+		Started: time.Now(),
+		Values:  Values{},
+	}
+	for key, value := range initial {
+		result.Values[key] = value
+	}
+	failed := false
+	for _, step := range s.steps {
+		if failed || (ctx != nil && ctx.Err() != nil) {
+			log.Debugf("scenario %q: skipping step %q", s.name, step.name)
+			result.Steps = append(result.Steps, Result{Name: step.name, Skipped: true})
+			continue
+		}
+		outcome := s.run(ctx, requestor, step, result.Values)
+		failed = !outcome.Passed()
+		result.Steps = append(result.Steps, outcome)
+	}
+	result.Duration = time.Since(result.Started)
+	return result
+}
+
+func (s *Scenario) run(ctx context.Context, requestor *Requestor, step *Step, values Values) Result {
+	log.Debugf("scenario %q: running step %q", s.name, step.name)
+	result, _ := step.run(ctx, requestor, values)
+	return result
+}
+
+// run runs the step, and returns its outcome along with the reason why it
+// failed, if it did.
+func (s *Step) run(ctx context.Context, requestor *Requestor, values Values) (Result, error) {
+	started := time.Now()
+	f := s.build(values)
+	for _, injector := range s.injectors {
+		if err := injector(f, values); err != nil {
+			return Result{Name: s.name, Started: started, Error: err.Error()}, err
+		}
+	}
+	exchange := requestor.Execute(ctx, f)
+	result := Result{
+		Name:       s.name,
+		StatusCode: exchange.StatusCode(),
+		Started:    started,
+		Latency:    exchange.Latency,
+		Assertions: evaluate(exchange, s.assertions),
+	}
+	if exchange.Request != nil {
+		result.Method = exchange.Request.Method
+		result.URL = RedactURL(exchange.Request.URL)
+	}
+	// non-2xx responses are failures unless the step asserts on them
+	if exchange.Err != nil && (!IsHTTPError(exchange.Err) || len(s.assertions) == 0) {
+		result.Error = exchange.Err.Error()
+		return result, exchange.Err
+	}
+	if !result.Passed() {
+		return result, errors.New(result.failure())
+	}
+	for _, extractor := range s.extractors {
+		if err := extractor(exchange, values); err != nil {
+			result.Error = err.Error()
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// Schedule runs the scenario at the given interval, starting immediately, until
+// the context is cancelled; the outcome of each run is passed to the given
+// callback.
+func (s *Scenario) Schedule(ctx context.Context, requestor *Requestor, interval time.Duration, initial Values, callback func(result *ScenarioResult)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		result := s.Run(ctx, requestor, initial)
+		if ctx.Err() != nil {
+			return
+		}
+		if callback != nil {
+			callback(result)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
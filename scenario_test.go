@@ -0,0 +1,127 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newScenarioServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token": "abc123"}`))
+		case "/profile":
+			if r.Header.Get("Authorization") != "Bearer abc123" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"name": "John"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func statusIs(code int) Assertion {
+	return func(exchange *Exchange) AssertionResult {
+		result := AssertionResult{Name: fmt.Sprintf("status is %d", code), Passed: exchange.StatusCode() == code}
+		if !result.Passed {
+			result.Message = fmt.Sprintf("got %d", exchange.StatusCode())
+		}
+		return result
+	}
+}
+
+func TestScenario(t *testing.T) {
+	server := newScenarioServer()
+	defer server.Close()
+
+	base := New(server.URL)
+	scenario := NewScenario("profile")
+	scenario.Step("login", func(values Values) *Builder {
+		return base.New(http.MethodPost, "/login")
+	}).Assert(statusIs(http.StatusOK)).Extract(func(exchange *Exchange, values Values) error {
+		v := struct {
+			Token string `json:"token"`
+		}{}
+		if err := json.Unmarshal(exchange.Body, &v); err != nil {
+			return err
+		}
+		values["token"] = v.Token
+		return nil
+	})
+	scenario.Step("profile", func(values Values) *Builder {
+		return base.New(http.MethodGet, "/profile").Set().Header("Authorization", "Bearer "+values["token"])
+	}).Assert(statusIs(http.StatusOK))
+
+	result := scenario.Run(context.Background(), NewRequestor(getClient()), nil)
+	if !result.Passed() {
+		t.Fatalf("scenario should have passed: %+v", result.Steps)
+	}
+	if result.Values["token"] != "abc123" || len(result.Steps) != 2 || result.Steps[1].Method != http.MethodGet {
+		t.Fatalf("invalid scenario result: %+v", result)
+	}
+}
+
+func TestScenarioFailure(t *testing.T) {
+	server := newScenarioServer()
+	defer server.Close()
+
+	base := New(server.URL)
+	scenario := NewScenario("failing")
+	scenario.Step("profile", func(values Values) *Builder {
+		return base.New(http.MethodGet, "/profile")
+	}).Assert(statusIs(http.StatusUnauthorized))
+	scenario.Step("missing", func(values Values) *Builder {
+		return base.New(http.MethodGet, "/missing")
+	})
+	scenario.Step("never", func(values Values) *Builder {
+		return base.New(http.MethodGet, "/profile")
+	})
+
+	result := scenario.Run(context.Background(), NewRequestor(getClient()), Values{"user": "john"})
+	if result.Passed() {
+		t.Fatalf("scenario should have failed")
+	}
+	if !result.Steps[0].Passed() || result.Steps[0].StatusCode != http.StatusUnauthorized {
+		t.Fatalf("first step should have passed: %+v", result.Steps[0])
+	}
+	if !result.Steps[1].Failed() || result.Steps[1].StatusCode != http.StatusNotFound {
+		t.Fatalf("second step should have failed: %+v", result.Steps[1])
+	}
+	if !result.Steps[2].Skipped || result.Values["user"] != "john" {
+		t.Fatalf("third step should have been skipped: %+v", result.Steps[2])
+	}
+}
+
+func TestScenarioSchedule(t *testing.T) {
+	server := newScenarioServer()
+	defer server.Close()
+
+	scenario := NewScenario("login")
+	scenario.Step("login", func(values Values) *Builder {
+		return New(server.URL + "/login")
+	})
+	var runs int32
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	scenario.Schedule(ctx, NewRequestor(getClient()), 20*time.Millisecond, nil, func(result *ScenarioResult) {
+		if result.Passed() {
+			atomic.AddInt32(&runs, 1)
+		}
+	})
+	if n := atomic.LoadInt32(&runs); n < 2 {
+		t.Fatalf("expected at least 2 runs, got %d", n)
+	}
+}
@@ -0,0 +1,449 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrSchemaViolation is returned (wrapped in a *SchemaError) when a JSON body
+// does not conform to its JSON Schema.
+var ErrSchemaViolation = errors.New("JSON Schema violation")
+
+// SchemaViolation is a violation of a JSON Schema by a value.
+type SchemaViolation struct {
+	// Location is the location of the offending value, e.g. "$.items[2].id".
+	Location string
+	// Message describes the violation.
+	Message string
+}
+
+// String returns the violation in human-readable form.
+func (v SchemaViolation) String() string {
+	return v.Location + ": " + v.Message
+}
+
+// messages returns the given violations in human-readable form.
+func messages(violations []SchemaViolation) []string {
+	result := make([]string, len(violations))
+	for i, violation := range violations {
+		result[i] = violation.String()
+	}
+	return result
+}
+
+// SchemaError is returned when a JSON body does not conform to its JSON
+// Schema; it wraps ErrSchemaViolation.
+type SchemaError struct {
+	// Subject is what was validated, e.g. "POST https://example.com/users
+	// request body".
+	Subject string
+	// Violations are the violations of the schema.
+	Violations []SchemaViolation
+}
+
+// Error returns the violations as a single string.
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s: %v: %s", e.Subject, ErrSchemaViolation, strings.Join(messages(e.Violations), "; "))
+}
+
+// Unwrap returns ErrSchemaViolation.
+func (e *SchemaError) Unwrap() error {
+	return ErrSchemaViolation
+}
+
+// Schema is a JSON Schema, supporting the subset described in schemaValidator.
+type Schema struct {
+	validator *schemaValidator
+}
+
+// CompileSchema parses the given JSON Schema document.
+func CompileSchema(document []byte) (*Schema, error) {
+	var root interface{}
+	if err := json.Unmarshal(document, &root); err != nil {
+		return nil, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+	return &Schema{validator: &schemaValidator{root: root}}, nil
+}
+
+// Validate validates the given JSON document against the schema, and returns a
+// *SchemaError describing the violations, if any.
+func (s *Schema) Validate(data []byte) error {
+	return s.check("document", data)
+}
+
+// check validates the given JSON document, described by the given subject.
+func (s *Schema) check(subject string, data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return &SchemaError{Subject: subject, Violations: []SchemaViolation{{Location: "$", Message: fmt.Sprintf("invalid JSON: %v", err)}}}
+	}
+	if violations := s.validator.validate(value, s.validator.root, "$"); len(violations) > 0 {
+		return &SchemaError{Subject: subject, Violations: violations}
+	}
+	return nil
+}
+
+// RequestSchema makes Make validate the JSON bodies of the requests generated
+// by this builder and its children against the given schema, and fail with a
+// *SchemaError if they do not conform.
+func (f *Builder) RequestSchema(schema *Schema) *Builder {
+	return f.Validate(func(request *http.Request, body []byte) error {
+		if body == nil || !isJSON(request.Header.Get("Content-Type")) {
+			return nil
+		}
+		return schema.check(fmt.Sprintf("%s %s request body", request.Method, RedactURL(request.URL)), body)
+	})
+}
+
+// ResponseSchema makes the Requestor validate the JSON bodies of the
+// successful responses to the requests generated by this builder and its
+// children against the given schema, and fail with a *SchemaError if they do
+// not conform; the bodies are buffered, but can still be read.
+func (f *Builder) ResponseSchema(schema *Schema) *Builder {
+	f.responseSchema = schema
+	return f
+}
+
+// conform validates the body of the given response against the response
+// schema, if any.
+func (f *Builder) conform(request *http.Request, response *http.Response) error {
+	if f.responseSchema == nil || response.Body == nil || !isJSON(response.Header.Get("Content-Type")) {
+		return nil
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return f.responseSchema.check(fmt.Sprintf("%s %s response body", request.Method, RedactURL(request.URL)), data)
+}
+
+// schemaValidator validates values, as decoded by encoding/json into an
+// interface{}, against the commonly used subset of JSON Schema (as in OpenAPI):
+// $ref (local only), type, nullable, enum, const, allOf, anyOf, oneOf, not,
+// properties, required, additionalProperties, items, minItems, maxItems,
+// uniqueItems, minLength, maxLength, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum and multipleOf; formats are not checked.
+type schemaValidator struct {
+	// root is the document local references are resolved against.
+	root interface{}
+}
+
+// validate returns the violations of the given schema by the given value, each
+// prefixed by the location of the offending value (e.g. "$.items[2].id").
+func (v *schemaValidator) validate(value interface{}, schema interface{}, location string) []SchemaViolation {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		if allowed, ok := schema.(bool); ok && !allowed {
+			return []SchemaViolation{{Location: location, Message: "no value allowed"}}
+		}
+		return nil
+	}
+	if ref, ok := s["$ref"].(string); ok {
+		resolved, err := pointer(v.root, ref)
+		if err != nil {
+			return []SchemaViolation{{Location: location, Message: err.Error()}}
+		}
+		return v.validate(value, resolved, location)
+	}
+
+	violations := []SchemaViolation{}
+	fail := func(format string, args ...interface{}) {
+		violations = append(violations, SchemaViolation{Location: location, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil && s["nullable"] == true {
+		return nil
+	}
+	if types := schemaTypes(s["type"]); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if hasType(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("expected %s, got %s", strings.Join(types, " or "), typeOf(value))
+			return violations
+		}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if equal(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value %s not in %s", render(value), render(enum))
+		}
+	}
+	if constant, ok := s["const"]; ok && !equal(constant, value) {
+		fail("value %s is not %s", render(value), render(constant))
+	}
+
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			violations = append(violations, v.validate(value, sub, location)...)
+		}
+	}
+	if alternatives, ok := s["anyOf"].([]interface{}); ok {
+		if v.matches(value, alternatives, location) == 0 {
+			fail("value does not match any of the allowed schemas")
+		}
+	}
+	if one, ok := s["oneOf"].([]interface{}); ok {
+		if n := v.matches(value, one, location); n != 1 {
+			fail("value matches %d schemas instead of exactly one", n)
+		}
+	}
+	if not, ok := s["not"]; ok && len(v.validate(value, not, location)) == 0 {
+		fail("value matches a disallowed schema")
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		violations = append(violations, v.object(value, s, location)...)
+	case []interface{}:
+		if items, ok := s["items"]; ok {
+			for i, item := range value {
+				violations = append(violations, v.validate(item, items, fmt.Sprintf("%s[%d]", location, i))...)
+			}
+		}
+		if min, ok := numeric(s["minItems"]); ok && float64(len(value)) < min {
+			fail("expected at least %v items, got %d", min, len(value))
+		}
+		if max, ok := numeric(s["maxItems"]); ok && float64(len(value)) > max {
+			fail("expected at most %v items, got %d", max, len(value))
+		}
+		if s["uniqueItems"] == true {
+			for i := range value {
+				for j := i + 1; j < len(value); j++ {
+					if equal(value[i], value[j]) {
+						fail("items %d and %d are equal", i, j)
+					}
+				}
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(value))
+		if min, ok := numeric(s["minLength"]); ok && length < min {
+			fail("expected at least %v characters, got %v", min, length)
+		}
+		if max, ok := numeric(s["maxLength"]); ok && length > max {
+			fail("expected at most %v characters, got %v", max, length)
+		}
+		if pattern, ok := s["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err != nil {
+				fail("invalid pattern %q: %v", pattern, err)
+			} else if !re.MatchString(value) {
+				fail("value %q does not match pattern %q", value, pattern)
+			}
+		}
+	case float64, json.Number:
+		n, _ := numeric(value)
+		if min, ok := numeric(s["minimum"]); ok {
+			if exclusive, _ := s["exclusiveMinimum"].(bool); exclusive && n <= min {
+				fail("value %v not greater than %v", n, min)
+			} else if n < min {
+				fail("value %v less than %v", n, min)
+			}
+		}
+		if max, ok := numeric(s["maximum"]); ok {
+			if exclusive, _ := s["exclusiveMaximum"].(bool); exclusive && n >= max {
+				fail("value %v not less than %v", n, max)
+			} else if n > max {
+				fail("value %v greater than %v", n, max)
+			}
+		}
+		if min, ok := numeric(s["exclusiveMinimum"]); ok && n <= min {
+			fail("value %v not greater than %v", n, min)
+		}
+		if max, ok := numeric(s["exclusiveMaximum"]); ok && n >= max {
+			fail("value %v not less than %v", n, max)
+		}
+		if divisor, ok := numeric(s["multipleOf"]); ok && divisor > 0 {
+			if quotient := n / divisor; math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+				fail("value %v not a multiple of %v", n, divisor)
+			}
+		}
+	}
+	return violations
+}
+
+// object validates the properties of an object.
+func (v *schemaValidator) object(value map[string]interface{}, s map[string]interface{}, location string) []SchemaViolation {
+	violations := []SchemaViolation{}
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, ok := value[name]; !ok {
+					violations = append(violations, SchemaViolation{Location: location, Message: fmt.Sprintf("missing required property %q", name)})
+				}
+			}
+		}
+	}
+	properties, _ := s["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if property, ok := properties[key]; ok {
+			violations = append(violations, v.validate(value[key], property, location+"."+key)...)
+		} else if additional, ok := s["additionalProperties"]; ok {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				violations = append(violations, SchemaViolation{Location: location, Message: fmt.Sprintf("unexpected property %q", key)})
+			} else {
+				violations = append(violations, v.validate(value[key], additional, location+"."+key)...)
+			}
+		}
+	}
+	return violations
+}
+
+// matches returns how many of the given schemas the value matches.
+func (v *schemaValidator) matches(value interface{}, schemas []interface{}, location string) int {
+	n := 0
+	for _, schema := range schemas {
+		if len(v.validate(value, schema, location)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// pointer resolves a local JSON pointer reference (e.g. "#/components/schemas/User").
+func pointer(root interface{}, ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported reference %q", ref)
+	}
+	node := root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		switch current := node.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if node, ok = current[token]; !ok {
+				return nil, fmt.Errorf("unresolved reference %q", ref)
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(current) {
+				return nil, fmt.Errorf("unresolved reference %q", ref)
+			}
+			node = current[index]
+		default:
+			return nil, fmt.Errorf("unresolved reference %q", ref)
+		}
+	}
+	return node, nil
+}
+
+func schemaTypes(t interface{}) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := []string{}
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func hasType(value interface{}, t string) bool {
+	switch t {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := numeric(value)
+		return ok
+	case "integer":
+		n, ok := numeric(value)
+		return ok && n == math.Trunc(n)
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return true
+}
+
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64, json.Number:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func numeric(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func equal(a, b interface{}) bool {
+	if x, ok := numeric(a); ok {
+		y, ok := numeric(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func render(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
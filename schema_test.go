@@ -0,0 +1,107 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const userSchema = `{
+	"type": "object",
+	"required": ["name"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0}
+	}
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := CompileSchema([]byte(userSchema))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := schema.Validate([]byte(`{"name": "john", "age": 42}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = schema.Validate([]byte(`{"age": -1}`))
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || !errors.Is(err, ErrSchemaViolation) {
+		t.Fatalf("expected a schema error, got %v", err)
+	}
+	if len(schemaErr.Violations) != 2 {
+		t.Fatalf("expected 2 violations, got %v", schemaErr.Violations)
+	}
+	for _, violation := range schemaErr.Violations {
+		if violation.Location != "$" && violation.Location != "$.age" {
+			t.Fatalf("unexpected violation %v", violation)
+		}
+	}
+	if err := schema.Validate([]byte(`{`)); !errors.Is(err, ErrSchemaViolation) {
+		t.Fatalf("expected a schema error for invalid JSON, got %v", err)
+	}
+	if _, err := CompileSchema([]byte(`{`)); err == nil {
+		t.Fatalf("expected error for invalid schema")
+	}
+}
+
+func TestRequestSchema(t *testing.T) {
+	schema, err := CompileSchema([]byte(userSchema))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	base := New("http://www.example.com/users").RequestSchema(schema)
+	if _, err := base.New(http.MethodPost, "").WithJSONEntity(map[string]interface{}{"name": "john"}).Make(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = base.New(http.MethodPost, "").WithJSONEntity(map[string]interface{}{"name": ""}).Make()
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || len(schemaErr.Violations) != 1 || schemaErr.Violations[0].Location != "$.name" {
+		t.Fatalf("expected a violation at $.name, got %v", err)
+	}
+	if !strings.Contains(schemaErr.Subject, "POST http://www.example.com/users") {
+		t.Fatalf("unexpected subject %q", schemaErr.Subject)
+	}
+	// bodies that are not JSON are not validated
+	if _, err := base.New(http.MethodPost, "").WithStringEntity("name=", "application/x-www-form-urlencoded").Make(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestResponseSchema(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/invalid" {
+			w.Write([]byte(`{"name": 42}`))
+			return
+		}
+		w.Write([]byte(`{"name": "john"}`))
+	}))
+	defer server.Close()
+	schema, err := CompileSchema([]byte(userSchema))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	base := New(server.URL).ResponseSchema(schema)
+	response, err := NewRequestor(nil).Do(context.Background(), base.New("", "/valid"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if string(body) != `{"name": "john"}` {
+		t.Fatalf("expected the body to be readable, got %q", body)
+	}
+	_, err = NewRequestor(nil).Do(context.Background(), base.New("", "/invalid"))
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || len(schemaErr.Violations) != 1 || schemaErr.Violations[0].Location != "$.name" {
+		t.Fatalf("expected a violation at $.name, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Masked is the value replacing the masked fields in a Snapshot.
+const Masked = "[MASKED]"
+
+// Snapshot is the canonical form of a request, suitable for golden file
+// comparisons: the query parameters are sorted, the header names are in
+// canonical form and JSON bodies are re-encoded with sorted keys.
+type Snapshot struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   string
+}
+
+// String renders the snapshot as text, in a form similar to that of an HTTP/1.1
+// request: the request line, the sorted headers (one line per value) and, after
+// an empty line, the body.
+func (s *Snapshot) String() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(s.Method + " " + s.URL + "\n")
+	keys := make([]string, 0, len(s.Header))
+	for key := range s.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range s.Header[key] {
+			buffer.WriteString(key + ": " + value + "\n")
+		}
+	}
+	if s.Body != "" {
+		buffer.WriteString("\n" + s.Body + "\n")
+	}
+	return buffer.String()
+}
+
+// Mask modifies a snapshot before it is rendered, e.g. to hide volatile fields
+// such as dates and request ids that would make it unstable.
+type Mask func(snapshot *Snapshot)
+
+// MaskHeaders returns a Mask that replaces the values of the given headers.
+func MaskHeaders(headers ...string) Mask {
+	return func(snapshot *Snapshot) {
+		for _, header := range headers {
+			values := snapshot.Header.Values(header)
+			for i := range values {
+				values[i] = Masked
+			}
+		}
+	}
+}
+
+// MaskQueryParameters returns a Mask that replaces the values of the given
+// query parameters.
+func MaskQueryParameters(parameters ...string) Mask {
+	return func(snapshot *Snapshot) {
+		u, err := url.Parse(snapshot.URL)
+		if err != nil {
+			return
+		}
+		query := u.Query()
+		for _, parameter := range parameters {
+			for i := range query[parameter] {
+				query[parameter][i] = Masked
+			}
+		}
+		u.RawQuery = query.Encode()
+		snapshot.URL = u.String()
+	}
+}
+
+// MaskPattern returns a Mask that replaces all the matches of the given regular
+// expression in the URL, header values and body.
+func MaskPattern(expression, replacement string) Mask {
+	pattern := regexp.MustCompile(expression)
+	return func(snapshot *Snapshot) {
+		snapshot.URL = pattern.ReplaceAllString(snapshot.URL, replacement)
+		for _, values := range snapshot.Header {
+			for i, value := range values {
+				values[i] = pattern.ReplaceAllString(value, replacement)
+			}
+		}
+		snapshot.Body = pattern.ReplaceAllString(snapshot.Body, replacement)
+	}
+}
+
+// Snapshot returns the canonical form of the request generated by the builder,
+// after applying the given masks; the builder is left untouched, hence a body
+// that can only be read once (i.e. one set via WithEntity that is not a
+// bytes.Buffer, bytes.Reader or strings.Reader) is consumed, as by Make.
+func (f *Builder) Snapshot(masks ...Mask) (*Snapshot, error) {
+	request, err := f.Make()
+	if err != nil {
+		return nil, err
+	}
+	u := *request.URL
+	u.RawQuery = u.Query().Encode()
+	snapshot := &Snapshot{
+		Method: request.Method,
+		URL:    u.String(),
+		Header: request.Header.Clone(),
+	}
+	if snapshot.Header == nil {
+		snapshot.Header = http.Header{}
+	}
+	body, err := f.peek(request)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		snapshot.Body = canonicalBody(body)
+	}
+	for _, mask := range masks {
+		mask(snapshot)
+	}
+	return snapshot, nil
+}
+
+// Dump returns the text rendering of the canonical form of the request
+// generated by the builder, after applying the given masks; see Snapshot.
+func (f *Builder) Dump(masks ...Mask) (string, error) {
+	snapshot, err := f.Snapshot(masks...)
+	if err != nil {
+		return "", err
+	}
+	return snapshot.String(), nil
+}
+
+// Fingerprint returns the hex-encoded SHA-256 hash of the Dump of the request
+// generated by the builder, after applying the given masks.
+func (f *Builder) Fingerprint(masks ...Mask) (string, error) {
+	dump, err := f.Dump(masks...)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(dump))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// canonicalBody re-encodes JSON bodies with sorted keys and indentation, and
+// returns any other body as is.
+func canonicalBody(data []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return string(data)
+	}
+	canonical, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return string(data)
+	}
+	return strings.TrimSpace(string(canonical))
+}
@@ -0,0 +1,71 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	f := New("http://api.example.com/users").
+		Post().
+		Add().QueryParameter("b", "2").
+		Add().QueryParameter("a", "1").
+		Add().QueryParameter("ts", "1600000000").
+		Set().Header("x-request-id", "a0b1c2").
+		Set().Header("Date", "Mon, 14 Sep 2020 10:00:00 GMT").
+		ContentType("application/json").
+		WithEntity(strings.NewReader(`{"name": "john", "age": 42}`))
+	expected := `POST http://api.example.com/users?a=1&b=2&ts=%5BMASKED%5D
+Content-Type: application/json
+Date: [MASKED]
+X-Request-Id: [MASKED]
+
+{
+  "age": 42,
+  "name": "john"
+}
+`
+	masks := []Mask{MaskHeaders("X-Request-Id", "Date"), MaskQueryParameters("ts")}
+	for i := 0; i < 2; i++ {
+		actual, err := f.Dump(masks...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actual != expected {
+			t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+		}
+	}
+	first, _ := f.Fingerprint(masks...)
+	second, _ := f.New("", "").Set().Header("X-Request-Id", "d3e4f5").Fingerprint(masks...)
+	if first != second || len(first) != 64 {
+		t.Fatalf("expected stable fingerprints, got %q and %q", first, second)
+	}
+	request, _ := f.Make()
+	if request.Header.Get("X-Request-Id") != "a0b1c2" {
+		t.Fatalf("masks modified the builder headers: %v", request.Header)
+	}
+}
+
+func TestDumpUnbufferedBody(t *testing.T) {
+	f := New("http://api.example.com/notes").
+		Method(http.MethodPut).
+		ContentType("text/plain").
+		WithEntity(bufio.NewReader(strings.NewReader("id=abc-123")))
+	actual, err := f.Dump(MaskPattern(`abc-\d+`, "<id>"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(actual, "\nid=<id>\n") {
+		t.Fatalf("unexpected dump:\n%s", actual)
+	}
+	// the body is consumed, as by Make, but not replaced
+	if _, ok := f.body.(*bufio.Reader); !ok {
+		t.Fatalf("builder body replaced with %T", f.body)
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store is a key/value store with per-entry expiration, used by all the
+// subsystems that need to persist state (e.g. caches); it allows persistence to
+// be wired once, by choosing the store implementation. Implementations must be
+// safe for concurrent use. Get returns false if the key is missing or expired.
+// A zero TTL means that the entry never expires.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// expiration returns the expiration time corresponding to the given TTL, or
+// the zero time if the entry never expires.
+func expiration(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// expired returns whether an entry with the given expiration time has expired.
+func expired(expires time.Time) bool {
+	return !expires.IsZero() && !time.Now().Before(expires)
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// MemoryStore is an in-memory Store; expired entries are evicted lazily.
+type MemoryStore struct {
+	lock    sync.RWMutex
+	entries map[string]memoryEntry
+}
+
+// NewMemoryStore returns a new, empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: map[string]memoryEntry{},
+	}
+}
+
+// Get returns the value stored under the given key.
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.lock.RLock()
+	entry, ok := s.entries[key]
+	s.lock.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	if expired(entry.expires) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		// the entry may have been refreshed in the meantime
+		if current, ok := s.entries[key]; ok && current.expires.Equal(entry.expires) {
+			delete(s.entries, key)
+		}
+		return nil, false, nil
+	}
+	return append([]byte(nil), entry.value...), true, nil
+}
+
+// Set stores the value under the given key, with the given TTL.
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.entries[key] = memoryEntry{
+		value:   append([]byte(nil), value...),
+		expires: expiration(ttl),
+	}
+	return nil
+}
+
+// Delete removes the given key from the store.
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// List returns the sorted list of unexpired keys having the given prefix.
+func (s *MemoryStore) List(ctx context.Context, prefix string) ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	keys := []string{}
+	for key, entry := range s.entries {
+		if strings.HasPrefix(key, prefix) && !expired(entry.expires) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// LRUStore is an in-memory Store holding up to a maximum number of entries;
+// when full, the least recently used entry is evicted.
+type LRUStore struct {
+	lock     sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type lruEntry struct {
+	key string
+	memoryEntry
+}
+
+// NewLRUStore returns a new, empty LRUStore holding up to capacity entries.
+func NewLRUStore(capacity int) *LRUStore {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRUStore{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+// Get returns the value stored under the given key, and marks it as recently
+// used.
+func (s *LRUStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*lruEntry)
+	if expired(entry.expires) {
+		s.order.Remove(element)
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(element)
+	return append([]byte(nil), entry.value...), true, nil
+}
+
+// Set stores the value under the given key, with the given TTL, evicting the
+// least recently used entry if the store is full.
+func (s *LRUStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry := &lruEntry{
+		key: key,
+		memoryEntry: memoryEntry{
+			value:   append([]byte(nil), value...),
+			expires: expiration(ttl),
+		},
+	}
+	if element, ok := s.entries[key]; ok {
+		element.Value = entry
+		s.order.MoveToFront(element)
+		return nil
+	}
+	s.entries[key] = s.order.PushFront(entry)
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+// Delete removes the given key from the store.
+func (s *LRUStore) Delete(ctx context.Context, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if element, ok := s.entries[key]; ok {
+		s.order.Remove(element)
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// List returns the sorted list of unexpired keys having the given prefix.
+func (s *LRUStore) List(ctx context.Context, prefix string) ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	keys := []string{}
+	for key, element := range s.entries {
+		if strings.HasPrefix(key, prefix) && !expired(element.Value.(*lruEntry).expires) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// FileStore is a Store that keeps each entry in its own file under a directory;
+// file names are the base64 (URL-safe) encoding of the keys, and each file
+// starts with a line holding the expiration time. Writes are atomic.
+type FileStore struct {
+	directory string
+}
+
+// NewFileStore returns a new FileStore backed by the given directory, which is
+// created if it does not exist.
+func NewFileStore(directory string) (*FileStore, error) {
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{directory: directory}, nil
+}
+
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.directory, base64.RawURLEncoding.EncodeToString([]byte(key)))
+}
+
+// Get returns the value stored under the given key.
+func (s *FileStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := os.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	index := bytes.IndexByte(data, '\n')
+	if index < 0 {
+		return nil, false, fmt.Errorf("invalid store entry for key %q", key)
+	}
+	nanos, err := strconv.ParseInt(string(data[:index]), 10, 64)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid store entry for key %q: %w", key, err)
+	}
+	if nanos != 0 && expired(time.Unix(0, nanos)) {
+		os.Remove(s.path(key))
+		return nil, false, nil
+	}
+	return data[index+1:], true, nil
+}
+
+// Set stores the value under the given key, with the given TTL.
+func (s *FileStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	var nanos int64
+	if expires := expiration(ttl); !expires.IsZero() {
+		nanos = expires.UnixNano()
+	}
+	file, err := os.CreateTemp(s.directory, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := fmt.Fprintf(file, "%d\n", nanos); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(value); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), s.path(key))
+}
+
+// Delete removes the given key from the store.
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List returns the sorted list of unexpired keys having the given prefix.
+func (s *FileStore) List(ctx context.Context, prefix string) ([]string, error) {
+	files, err := os.ReadDir(s.directory)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		key, err := base64.RawURLEncoding.DecodeString(file.Name())
+		if err != nil || !strings.HasPrefix(string(key), prefix) {
+			continue
+		}
+		if _, ok, err := s.Get(ctx, string(key)); err == nil && ok {
+			keys = append(keys, string(key))
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLStore is a Store backed by a database table, accessed via database/sql;
+// the table must have the following columns (types may vary with the database):
+//
+//	CREATE TABLE requestor_store (
+//		store_key   VARCHAR(512) PRIMARY KEY,
+//		store_value BLOB,
+//		expires_at  BIGINT NOT NULL
+//	)
+//
+// where expires_at holds the expiration time in nanoseconds since the Unix
+// epoch, or 0 for entries that never expire. Expired entries are not returned
+// but are only removed when overwritten or deleted, or via Purge().
+type SQLStore struct {
+	db          *sql.DB
+	table       string
+	placeholder func(n int) string
+	upsert      string
+}
+
+// onConflict is the upsert clause of SQLite and PostgreSQL.
+const onConflict = "ON CONFLICT (store_key) DO UPDATE SET store_value = excluded.store_value, expires_at = excluded.expires_at"
+
+// NewSQLStore returns a new SQLStore using the given table; the table name is
+// used verbatim in queries, so it must never come from untrusted input. By
+// default, "?" query placeholders and the SQLite "INSERT ... ON CONFLICT"
+// upsert syntax are used.
+func NewSQLStore(db *sql.DB, table string) *SQLStore {
+	return &SQLStore{
+		db:    db,
+		table: table,
+		placeholder: func(n int) string {
+			return "?"
+		},
+		upsert: onConflict,
+	}
+}
+
+// PostgresPlaceholders makes the store use the "$1, $2..." query placeholders
+// that PostgreSQL drivers expect, along with its "INSERT ... ON CONFLICT"
+// upsert syntax.
+func (s *SQLStore) PostgresPlaceholders() *SQLStore {
+	s.placeholder = func(n int) string {
+		return fmt.Sprintf("$%d", n)
+	}
+	s.upsert = onConflict
+	return s
+}
+
+// MySQLPlaceholders makes the store use the "?" query placeholders and the
+// "INSERT ... ON DUPLICATE KEY UPDATE" upsert syntax of MySQL and MariaDB.
+func (s *SQLStore) MySQLPlaceholders() *SQLStore {
+	s.placeholder = func(n int) string {
+		return "?"
+	}
+	s.upsert = "ON DUPLICATE KEY UPDATE store_value = VALUES(store_value), expires_at = VALUES(expires_at)"
+	return s
+}
+
+// Get returns the value stored under the given key.
+func (s *SQLStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	query := fmt.Sprintf("SELECT store_value, expires_at FROM %s WHERE store_key = %s", s.table, s.placeholder(1))
+	var value []byte
+	var expires int64
+	err := s.db.QueryRowContext(ctx, query, key).Scan(&value, &expires)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	if expires != 0 && expired(time.Unix(0, expires)) {
+		return nil, false, nil
+	}
+	return value, true, nil
+}
+
+// Set stores the value under the given key, with the given TTL; the entry is
+// upserted in a single statement, so that concurrent calls for the same key do
+// not conflict.
+func (s *SQLStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	var expires int64
+	if t := expiration(ttl); !t.IsZero() {
+		expires = t.UnixNano()
+	}
+	query := fmt.Sprintf("INSERT INTO %s (store_key, store_value, expires_at) VALUES (%s, %s, %s) %s", s.table, s.placeholder(1), s.placeholder(2), s.placeholder(3), s.upsert)
+	_, err := s.db.ExecContext(ctx, query, key, value, expires)
+	return err
+}
+
+// Delete removes the given key from the store.
+func (s *SQLStore) Delete(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE store_key = %s", s.table, s.placeholder(1)), key)
+	return err
+}
+
+// List returns the sorted list of unexpired keys having the given prefix.
+func (s *SQLStore) List(ctx context.Context, prefix string) ([]string, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+	query := fmt.Sprintf(`SELECT store_key FROM %s WHERE store_key LIKE %s ESCAPE '\' AND (expires_at = 0 OR expires_at > %s) ORDER BY store_key`, s.table, s.placeholder(1), s.placeholder(2))
+	rows, err := s.db.QueryContext(ctx, query, escaped+"%", time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Purge removes all the expired entries from the table.
+func (s *SQLStore) Purge(ctx context.Context) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE expires_at <> 0 AND expires_at <= %s", s.table, s.placeholder(1))
+	_, err := s.db.ExecContext(ctx, query, time.Now().UnixNano())
+	return err
+}
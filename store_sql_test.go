@@ -0,0 +1,86 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func newSQLDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "store.db")+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TABLE requestor_store (
+		store_key   VARCHAR(512) PRIMARY KEY,
+		store_value BLOB,
+		expires_at  BIGINT NOT NULL
+	)`); err != nil {
+		t.Fatalf("error creating table: %v", err)
+	}
+	return db
+}
+
+func TestSQLStore(t *testing.T) {
+	testStore(t, NewSQLStore(newSQLDatabase(t), "requestor_store"))
+	testStore(t, NewSQLStore(newSQLDatabase(t), "requestor_store").PostgresPlaceholders())
+
+	ctx := context.Background()
+	store := NewSQLStore(newSQLDatabase(t), "requestor_store")
+	store.Set(ctx, "a_b", []byte("x"), 0)
+	store.Set(ctx, "axb", []byte("x"), 0)
+	store.Set(ctx, "a%", []byte("x"), 0)
+	if keys, err := store.List(ctx, "a_"); err != nil || len(keys) != 1 || keys[0] != "a_b" {
+		t.Fatalf("expected the LIKE wildcards to be escaped, got %v (%v)", keys, err)
+	}
+}
+
+func TestSQLStoreOverwrite(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLStore(newSQLDatabase(t), "requestor_store")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := store.Set(ctx, "key", []byte(fmt.Sprint(i)), time.Hour); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if keys, _ := store.List(ctx, ""); len(keys) != 1 {
+		t.Fatalf("expected a single entry, got %v", keys)
+	}
+	store.Set(ctx, "key", []byte("last"), 0)
+	if value, ok, err := store.Get(ctx, "key"); !ok || err != nil || string(value) != "last" {
+		t.Fatalf("invalid value: got %q, %t (%v)", value, ok, err)
+	}
+}
+
+func TestSQLStorePurge(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLDatabase(t)
+	store := NewSQLStore(db, "requestor_store")
+	store.Set(ctx, "expiring", []byte("x"), time.Millisecond)
+	store.Set(ctx, "permanent", []byte("x"), 0)
+	time.Sleep(5 * time.Millisecond)
+	if err := store.Purge(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM requestor_store").Scan(&count); err != nil || count != 1 {
+		t.Fatalf("expected only the permanent entry to be left, got %d (%v)", count, err)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	if _, ok, err := store.Get(ctx, "missing"); ok || err != nil {
+		t.Fatalf("expected missing key, got %t (%v)", ok, err)
+	}
+	if err := store.Set(ctx, "etag/https://example.com/a", []byte("value-a"), 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Set(ctx, "etag/https://example.com/b", []byte("value-b"), 20*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store.Set(ctx, "cache/https://example.com/a", []byte("value-c"), time.Hour)
+	if value, ok, err := store.Get(ctx, "etag/https://example.com/a"); !ok || err != nil || string(value) != "value-a" {
+		t.Fatalf("invalid value: got %q, %t (%v)", value, ok, err)
+	}
+	keys, err := store.List(ctx, "etag/")
+	if err != nil || len(keys) != 2 || keys[0] != "etag/https://example.com/a" {
+		t.Fatalf("invalid keys: got %v (%v)", keys, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok, _ := store.Get(ctx, "etag/https://example.com/b"); ok {
+		t.Fatalf("expected key to have expired")
+	}
+	if keys, _ := store.List(ctx, "etag/"); len(keys) != 1 {
+		t.Fatalf("expected expired key not to be listed, got %v", keys)
+	}
+	if err := store.Delete(ctx, "etag/https://example.com/a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Delete(ctx, "etag/https://example.com/a"); err != nil {
+		t.Fatalf("deleting a missing key should not fail: %v", err)
+	}
+	if keys, _ := store.List(ctx, ""); len(keys) != 1 || keys[0] != "cache/https://example.com/a" {
+		t.Fatalf("invalid keys after delete: %v", keys)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestLRUStore(t *testing.T) {
+	testStore(t, NewLRUStore(10))
+
+	ctx := context.Background()
+	store := NewLRUStore(2)
+	store.Set(ctx, "a", []byte("a"), 0)
+	store.Set(ctx, "b", []byte("b"), 0)
+	store.Get(ctx, "a")
+	store.Set(ctx, "c", []byte("c"), 0)
+	if keys, _ := store.List(ctx, ""); len(keys) != 2 || keys[0] != "a" || keys[1] != "c" {
+		t.Fatalf("expected the least recently used key to be evicted, got %v", keys)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	directory, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(directory)
+	store, err := NewFileStore(directory)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testStore(t, store)
+}
@@ -0,0 +1,72 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ErrSuspiciousRequest is returned by Make, in strict mode, when the request
+// has a suspicious configuration.
+var ErrSuspiciousRequest = errors.New("suspicious request")
+
+// unbound matches the URL variables that were not bound to a value.
+var unbound = regexp.MustCompile(`\{[_a-zA-Z]\w*\}`)
+
+// Strict makes Make fail with ErrSuspiciousRequest, rather than silently
+// generating the request, if this builder (or any of the children created from
+// it afterwards) has a configuration that is most likely a bug: an empty
+// method, a body on a GET or HEAD request, a body without Content-Type,
+// credentials sent over plain HTTP to a non-loopback host or unbound URL
+// variables. See Lint to check for these and other mistakes before sending.
+func (f *Builder) Strict() *Builder {
+	f.strict = true
+	return f
+}
+
+// suspicious returns the suspicious aspects of the given request, generated by
+// this builder.
+func (f *Builder) suspicious(request *http.Request) []Problem {
+	problems := []Problem{}
+	if f.method == "" {
+		problems = append(problems, Problem{Severity: LintError, Message: "empty method"})
+	}
+	if f.body != nil {
+		if request.Method == http.MethodGet || request.Method == http.MethodHead {
+			problems = append(problems, Problem{Severity: LintWarning, Message: fmt.Sprintf("body on %s request", request.Method)})
+		}
+		if request.Header.Get("Content-Type") == "" {
+			problems = append(problems, Problem{Severity: LintWarning, Message: "body without Content-Type"})
+		}
+	}
+	if request.URL.Scheme == "http" && !isLoopback(request.URL.Hostname()) {
+		if request.URL.User != nil {
+			problems = append(problems, Problem{Severity: LintWarning, Message: "credentials in URL over plain HTTP"})
+		}
+		for _, header := range []string{"Authorization", "Proxy-Authorization"} {
+			if request.Header.Get(header) != "" {
+				problems = append(problems, Problem{Severity: LintWarning, Message: fmt.Sprintf("%s header over plain HTTP", header)})
+			}
+		}
+	}
+	if variables := unbound.FindAllString(request.URL.Path, -1); len(variables) > 0 {
+		problems = append(problems, Problem{Severity: LintError, Message: fmt.Sprintf("unbound URL variables %s", strings.Join(variables, ", "))})
+	}
+	return problems
+}
+
+// isLoopback returns whether the given host is the local host.
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,162 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// WithTemplateEntity sets the body of the requests to the given text/template,
+// rendered with the given data each time a request is made, e.g. for XML/SOAP
+// payloads or JSON whose field order or formatting must be preserved:
+//
+//	f.WithTemplateEntity(`<GetUser><Id>{{.ID | xml}}</Id></GetUser>`, user)
+//
+// Besides the text/template built-ins, the template can use the functions
+// returned by TemplateFuncs; referring to a missing map key is an error. If the
+// template is invalid or its rendering fails, Make fails.
+func (f *Builder) WithTemplateEntity(text string, data interface{}) *Builder {
+	tmpl, err := template.New("body").Funcs(TemplateFuncs()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		f.err = fmt.Errorf("invalid body template: %w", err)
+		return f
+	}
+	return f.WithEntity(&templateBody{template: tmpl, data: data})
+}
+
+// templateBody is a request body rendered from a template by Make.
+type templateBody struct {
+	template *template.Template
+	data     interface{}
+}
+
+// Read fails, since the body must be rendered by Make.
+func (b *templateBody) Read(p []byte) (int, error) {
+	return 0, errors.New("template body must be rendered")
+}
+
+// render renders the template with its data.
+func (b *templateBody) render() (*bytes.Reader, error) {
+	var buffer bytes.Buffer
+	if err := b.template.Execute(&buffer, b.data); err != nil {
+		return nil, fmt.Errorf("error rendering body template: %w", err)
+	}
+	return bytes.NewReader(buffer.Bytes()), nil
+}
+
+// TemplateFuncs returns the functions available to body templates, on the
+// lines of those of the sprig library:
+//
+//	upper, lower, title, trim, trimPrefix, trimSuffix, replace, repeat,
+//	contains, hasPrefix, hasSuffix, split, join, quote, squote, indent,
+//	nindent, default, empty, required, json, xml, b64enc, b64dec, now, date,
+//	add, sub, mul, div, mod
+//
+// where json encodes its argument as JSON, xml escapes it for XML text and
+// attributes, and date formats a time.Time with a Go layout.
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"title":      strings.Title,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.Replace(s, old, new, -1) },
+		"repeat":     func(n int, s string) string { return strings.Repeat(s, n) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"split":      func(separator, s string) []string { return strings.Split(s, separator) },
+		"join":       join,
+		"quote":      func(v interface{}) string { return fmt.Sprintf("%q", fmt.Sprint(v)) },
+		"squote":     func(v interface{}) string { return "'" + fmt.Sprint(v) + "'" },
+		"indent":     indent,
+		"nindent":    func(spaces int, s string) string { return "\n" + indent(spaces, s) },
+		"default":    func(fallback, v interface{}) interface{} { return choose(empty(v), fallback, v) },
+		"empty":      empty,
+		"required":   required,
+		"json":       toJSON,
+		"xml":        escapeXML,
+		"b64enc":     func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"b64dec":     b64dec,
+		"now":        time.Now,
+		"date":       func(layout string, t time.Time) string { return t.Format(layout) },
+		"add":        func(a, b int) int { return a + b },
+		"sub":        func(a, b int) int { return a - b },
+		"mul":        func(a, b int) int { return a * b },
+		"div":        func(a, b int) int { return a / b },
+		"mod":        func(a, b int) int { return a % b },
+	}
+}
+
+func join(separator string, values interface{}) string {
+	v := reflect.ValueOf(values)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return fmt.Sprint(values)
+	}
+	parts := make([]string, v.Len())
+	for i := range parts {
+		parts[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(parts, separator)
+}
+
+func indent(spaces int, s string) string {
+	padding := strings.Repeat(" ", spaces)
+	return padding + strings.Replace(s, "\n", "\n"+padding, -1)
+}
+
+// empty returns whether the given value is nil or the zero value of its type.
+func empty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+func choose(condition bool, a, b interface{}) interface{} {
+	if condition {
+		return a
+	}
+	return b
+}
+
+func required(message string, v interface{}) (interface{}, error) {
+	if empty(v) {
+		return nil, errors.New(message)
+	}
+	return v, nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func escapeXML(v interface{}) (string, error) {
+	var buffer bytes.Buffer
+	err := xml.EscapeText(&buffer, []byte(fmt.Sprint(v)))
+	return buffer.String(), err
+}
+
+func b64dec(s string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	return string(data), err
+}
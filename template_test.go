@@ -0,0 +1,40 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestWithTemplateEntity(t *testing.T) {
+	type user struct {
+		ID    int
+		Name  string
+		Roles []string
+	}
+	api := New("https://api.example.com/soap").Post().ContentType("text/xml")
+	f := api.New("", "").WithTemplateEntity(`<User id="{{.ID}}"><Name>{{.Name | xml}}</Name><Roles>{{join "," .Roles | upper}}</Roles><Nick>{{default "none" ""}}</Nick></User>`, user{ID: 42, Name: "Tom & Jerry", Roles: []string{"admin", "dev"}})
+	for i := 0; i < 2; i++ {
+		request, err := f.Make()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		body, _ := ioutil.ReadAll(request.Body)
+		expected := `<User id="42"><Name>Tom &amp; Jerry</Name><Roles>ADMIN,DEV</Roles><Nick>none</Nick></User>`
+		if string(body) != expected || request.ContentLength != int64(len(expected)) || request.GetBody == nil {
+			t.Fatalf("unexpected body %q", body)
+		}
+	}
+
+	f = api.New(http.MethodPost, "").WithTemplateEntity(`{"name": {{json .name}}, "team": {{json .team}}}`, map[string]interface{}{"name": "alice"})
+	if _, err := f.Make(); err == nil {
+		t.Fatalf("expected error for missing key")
+	}
+	if _, err := api.New("", "").WithTemplateEntity(`{{.name`, nil).Make(); err == nil {
+		t.Fatalf("expected error for invalid template")
+	}
+}
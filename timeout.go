@@ -0,0 +1,73 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DefaultDialTimeout is the default maximum time to open a connection.
+const DefaultDialTimeout = 30 * time.Second
+
+// DialTimeout sets the maximum time to open a connection, including the
+// resolution of the host name (30 seconds by default).
+func (r *Requestor) DialTimeout(timeout time.Duration) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.dialTimeout = timeout
+	r.dialing()
+	return r
+}
+
+// TLSHandshakeTimeout sets the maximum time to perform the TLS handshake on
+// new connections (zero means no limit).
+func (r *Requestor) TLSHandshakeTimeout(timeout time.Duration) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if transport := r.ownTransport(); transport != nil {
+		transport.TLSHandshakeTimeout = timeout
+	}
+	return r
+}
+
+// ResponseHeaderTimeout sets the maximum time to wait for the response headers
+// once the request is completely sent (zero means no limit); it does not
+// include the time to read the body.
+func (r *Requestor) ResponseHeaderTimeout(timeout time.Duration) *Requestor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if transport := r.ownTransport(); transport != nil {
+		transport.ResponseHeaderTimeout = timeout
+	}
+	return r
+}
+
+// Timeout sets the overall deadline of the requests generated by the builder,
+// counted from the moment they are executed and covering all the attempts and
+// the reading of the response body; unlike the timeout of the http.Client, it
+// can be set per request. Zero removes the deadline.
+func (f *Builder) Timeout(timeout time.Duration) *Builder {
+	f.timeout = timeout
+	return f
+}
+
+// deadline binds the given request to a context expiring after the builder
+// timeout, if any; it returns the function to call with the response (if
+// any), which releases the context right away, or once its body is closed.
+func (f *Builder) deadline(request *http.Request) (*http.Request, func(*Response)) {
+	if f.timeout <= 0 {
+		return request, func(*Response) {}
+	}
+	ctx, cancel := context.WithTimeout(request.Context(), f.timeout)
+	return request.WithContext(ctx), func(response *Response) {
+		if response == nil || response.Body == nil {
+			cancel()
+			return
+		}
+		response.Body = &releasingBody{ReadCloser: response.Body, release: cancel}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	requestor := NewRequestor(nil)
+	api := New(server.URL + "/").Timeout(50 * time.Millisecond)
+	if _, err := requestor.Do(context.Background(), api.New("", "slow")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	response, err := requestor.Do(context.Background(), api.New("", "fast"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var body []byte
+	if err := response.Decode(&body); err != nil || string(body) != "hello" {
+		t.Fatalf("unexpected body %q (%v)", body, err)
+	}
+
+	requestor = NewRequestor(&http.Client{}).
+		DialTimeout(time.Second).
+		TLSHandshakeTimeout(2 * time.Second).
+		ResponseHeaderTimeout(50 * time.Millisecond)
+	if requestor.transport.TLSHandshakeTimeout != 2*time.Second || requestor.dialTimeout != time.Second {
+		t.Fatalf("expected the transport to be customised")
+	}
+	if _, err := requestor.Do(context.Background(), New(server.URL+"/slow")); err == nil {
+		t.Fatalf("expected response header timeout")
+	}
+	if _, err := requestor.Do(context.Background(), New(server.URL+"/fast")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings is the breakdown of the time it took to get a response, as measured
+// on the last attempt (except for Total), so that slow networks can be told
+// apart from slow servers; phases that did not take place (e.g. DNS and
+// connection, on reused connections) are zero.
+type Timings struct {
+	// DNS is the time spent resolving the host name.
+	DNS time.Duration
+	// Connect is the time spent opening the TCP connection.
+	Connect time.Duration
+	// TLS is the time spent in the TLS handshake.
+	TLS time.Duration
+	// TTFB (time to first byte) is the time from the start of the attempt to
+	// the first byte of the response.
+	TTFB time.Duration
+	// Total is the overall time it took to get the response, including retries
+	// and waits.
+	Total time.Duration
+	// Reused is set when the request was sent over an idle connection.
+	Reused bool
+}
+
+// timer collects the timings of an attempt via httptrace.
+type timer struct {
+	lock     sync.Mutex
+	started  time.Time
+	dns      time.Time
+	connect  time.Time
+	tls      time.Time
+	timings  Timings
+	received bool
+}
+
+// trace returns the given request with a client trace measuring its timings,
+// and the function returning them.
+func trace(request *http.Request) (*http.Request, func() Timings) {
+	t := &timer{started: time.Now()}
+	since := func(start time.Time) time.Duration {
+		if start.IsZero() {
+			return 0
+		}
+		return time.Since(start)
+	}
+	ct := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.dns = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			if t.timings.Reused {
+				return
+			}
+			t.timings.DNS = since(t.dns)
+		},
+		ConnectStart: func(network, addr string) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			if t.connect.IsZero() {
+				t.connect = time.Now()
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			if err == nil && t.timings.Connect == 0 && !t.timings.Reused {
+				t.timings.Connect = since(t.connect)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.tls = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			if t.timings.Reused {
+				return
+			}
+			t.timings.TLS = since(t.tls)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.timings.Reused = info.Reused
+			if info.Reused {
+				// the transport may still be dialing in the background, e.g.
+				// because it started before the idle connection was released:
+				// those phases belong to another connection
+				t.timings.DNS, t.timings.Connect, t.timings.TLS = 0, 0, 0
+			}
+		},
+		GotFirstResponseByte: func() {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			if !t.received {
+				t.received = true
+				t.timings.TTFB = since(t.started)
+			}
+		},
+	}
+	traced := request.WithContext(httptrace.WithClientTrace(request.Context(), ct))
+	return traced, func() Timings {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		return t.timings
+	}
+}
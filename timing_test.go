@@ -0,0 +1,45 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimings(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	u := server.URL
+	requestor := NewRequestor(server.Client())
+	response, err := requestor.Do(context.Background(), New(u))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	timings := response.Timings
+	if timings.Connect <= 0 || timings.TLS <= 0 || timings.Reused {
+		t.Fatalf("expected connection and TLS timings, got %+v", timings)
+	}
+	if timings.TTFB < 20*time.Millisecond || timings.Total < timings.TTFB {
+		t.Fatalf("expected TTFB of at least 20ms within the total, got %+v", timings)
+	}
+
+	response, err = requestor.Do(context.Background(), New(u))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if timings := response.Timings; !timings.Reused || timings.Connect != 0 || timings.TLS != 0 || timings.TTFB <= 0 {
+		t.Fatalf("expected a reused connection, got %+v", timings)
+	}
+}
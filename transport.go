@@ -0,0 +1,104 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TransportBuilder builds http.Transports with connection pooling and protocol
+// settings tuned for a given API, to be used via WithTransport, as follows:
+//
+//	transport := NewTransportBuilder().MaxIdleConnsPerHost(32).IdleConnTimeout(30 * time.Second).Build()
+//	api := New("https://api.example.com/").WithTransport(transport)
+//
+// Each transport has its own connection pool, so a transport should be built
+// once and shared by the builders of an API, rather than once per request.
+type TransportBuilder struct {
+	transport *http.Transport
+}
+
+// NewTransportBuilder returns a new TransportBuilder, starting from the
+// settings of http.DefaultTransport.
+func NewTransportBuilder() *TransportBuilder {
+	return &TransportBuilder{transport: http.DefaultTransport.(*http.Transport).Clone()}
+}
+
+// MaxIdleConns sets the maximum number of idle connections across all hosts
+// (zero means no limit).
+func (b *TransportBuilder) MaxIdleConns(n int) *TransportBuilder {
+	b.transport.MaxIdleConns = n
+	return b
+}
+
+// MaxIdleConnsPerHost sets the maximum number of idle connections kept per host
+// (by default, http.DefaultMaxIdleConnsPerHost); it should be raised for APIs
+// called with high concurrency, to avoid opening and closing connections all
+// the time.
+func (b *TransportBuilder) MaxIdleConnsPerHost(n int) *TransportBuilder {
+	b.transport.MaxIdleConnsPerHost = n
+	return b
+}
+
+// MaxConnsPerHost sets the maximum number of connections per host, whatever
+// their state (zero means no limit).
+func (b *TransportBuilder) MaxConnsPerHost(n int) *TransportBuilder {
+	b.transport.MaxConnsPerHost = n
+	return b
+}
+
+// IdleConnTimeout sets the maximum time an idle connection is kept in the pool
+// (zero means no limit).
+func (b *TransportBuilder) IdleConnTimeout(timeout time.Duration) *TransportBuilder {
+	b.transport.IdleConnTimeout = timeout
+	return b
+}
+
+// ForceAttemptHTTP2 sets whether HTTP/2 is attempted over TLS even when the
+// dialer or the TLS configuration are customised.
+func (b *TransportBuilder) ForceAttemptHTTP2(force bool) *TransportBuilder {
+	b.transport.ForceAttemptHTTP2 = force
+	return b
+}
+
+// DisableKeepAlives sets whether each connection is only used for a single
+// request.
+func (b *TransportBuilder) DisableKeepAlives(disable bool) *TransportBuilder {
+	b.transport.DisableKeepAlives = disable
+	return b
+}
+
+// DisableCompression sets whether the transport refrains from requesting and
+// transparently decompressing gzip responses.
+func (b *TransportBuilder) DisableCompression(disable bool) *TransportBuilder {
+	b.transport.DisableCompression = disable
+	return b
+}
+
+// TLSConfig sets the TLS configuration of the connections.
+func (b *TransportBuilder) TLSConfig(config *tls.Config) *TransportBuilder {
+	b.transport.TLSClientConfig = config
+	return b
+}
+
+// Proxy sets the proxy the requests are sent through; nil connects directly,
+// while by default the proxy is taken from the environment.
+func (b *TransportBuilder) Proxy(proxy *url.URL) *TransportBuilder {
+	if proxy == nil {
+		b.transport.Proxy = nil
+	} else {
+		b.transport.Proxy = http.ProxyURL(proxy)
+	}
+	return b
+}
+
+// Build returns a new transport with the current settings; the builder can be
+// used to build further transports afterwards.
+func (b *TransportBuilder) Build() *http.Transport {
+	return b.transport.Clone()
+}
@@ -0,0 +1,52 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTransportBuilder(t *testing.T) {
+	var remotes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remotes = append(remotes, r.RemoteAddr)
+	}))
+	defer server.Close()
+
+	builder := NewTransportBuilder().
+		MaxIdleConns(10).
+		MaxIdleConnsPerHost(4).
+		MaxConnsPerHost(8).
+		IdleConnTimeout(time.Minute).
+		ForceAttemptHTTP2(false).
+		Proxy(nil)
+	transport := builder.Build()
+	if transport.MaxIdleConns != 10 || transport.MaxIdleConnsPerHost != 4 || transport.MaxConnsPerHost != 8 || transport.IdleConnTimeout != time.Minute || transport.ForceAttemptHTTP2 || transport.Proxy != nil {
+		t.Fatalf("unexpected transport settings")
+	}
+	if other := builder.DisableKeepAlives(true).Build(); other == transport || transport.DisableKeepAlives {
+		t.Fatalf("expected each transport to be independent")
+	}
+
+	requestor := NewRequestor(nil)
+	for _, keepAlive := range []bool{true, false} {
+		remotes = nil
+		api := New(server.URL).WithTransport(NewTransportBuilder().DisableKeepAlives(!keepAlive).Build())
+		for i := 0; i < 2; i++ {
+			response, err := requestor.Do(context.Background(), api.New("", ""))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			response.Decode(nil)
+		}
+		if reused := remotes[0] == remotes[1]; reused != keepAlive {
+			t.Fatalf("expected connection reuse to be %v, got remote addresses %v", keepAlive, remotes)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/dihedron/go-log"
+)
+
+// DefaultUploadChunkSize is the default size of the chunks of an Upload.
+const DefaultUploadChunkSize = 8 << 20
+
+// ErrUploadProtocol is returned when the server does not follow the upload
+// protocol.
+var ErrUploadProtocol = errors.New("upload protocol violation")
+
+// UploadProtocol is a protocol for uploading large bodies in chunks, so that
+// interrupted uploads can be resumed.
+type UploadProtocol int8
+
+const (
+	// ContentRangeUpload sends the chunks as PUT requests to an upload session
+	// URL (e.g. a Google Cloud Storage resumable upload), with Content-Range
+	// headers; the server acknowledges all but the last chunk with a 308
+	// status code.
+	ContentRangeUpload UploadProtocol = iota
+	// TusUpload follows the tus.io 1.0.0 protocol: the upload is created by a
+	// POST request to the creation URL, and the chunks are sent as PATCH
+	// requests to the returned upload URL.
+	TusUpload
+)
+
+// tusVersion is the version of the tus.io protocol.
+const tusVersion = "1.0.0"
+
+// UploadState is the progress of an Upload, as persisted to resume it.
+type UploadState struct {
+	// URL is the upload URL the chunks are sent to.
+	URL string `json:"url"`
+	// Offset is the number of bytes acknowledged by the server.
+	Offset int64 `json:"offset"`
+	// Size is the size of the body being uploaded.
+	Size int64 `json:"size"`
+}
+
+// Upload uploads a large body in chunks, according to an UploadProtocol.
+type Upload struct {
+	requestor *Requestor
+	builder   *Builder
+	protocol  UploadProtocol
+	chunkSize int64
+	store     Store
+	key       string
+	progress  func(uploaded, total int64)
+	state     UploadState
+}
+
+// Upload creates an upload of a body to the URL of the given Builder, which is
+// the upload session URL for ContentRangeUpload and the creation URL for
+// TusUpload; the Builder headers are sent with all the requests.
+func (r *Requestor) Upload(f *Builder, protocol UploadProtocol) *Upload {
+	return &Upload{requestor: r, builder: f, protocol: protocol, chunkSize: DefaultUploadChunkSize}
+}
+
+// ChunkSize sets the size of the chunks the body is sent in.
+func (u *Upload) ChunkSize(size int64) *Upload {
+	if size > 0 {
+		u.chunkSize = size
+	}
+	return u
+}
+
+// Persist makes the progress of the upload persisted in the given store under
+// the given key after each chunk, so that an upload interrupted by the end of
+// the process can be resumed by a new Upload; the key is deleted once the
+// upload completes.
+func (u *Upload) Persist(store Store, key string) *Upload {
+	u.store = store
+	u.key = key
+	return u
+}
+
+// Progress sets a callback invoked after each chunk is acknowledged, with the
+// number of bytes uploaded so far and the size of the body.
+func (u *Upload) Progress(callback func(uploaded, total int64)) *Upload {
+	u.progress = callback
+	return u
+}
+
+// State returns the progress of the upload.
+func (u *Upload) State() UploadState {
+	return u.state
+}
+
+// Run uploads the given body of the given size, and returns the response to
+// the last chunk. If an upload of the same size was interrupted, as recorded
+// by the Upload itself or in its store, Run asks the server how much of it was
+// received and resumes it: if the body is an io.Seeker, it is positioned at the
+// resume offset, otherwise the bytes before it are read and discarded.
+func (u *Upload) Run(ctx context.Context, body io.Reader, size int64) (*Response, error) {
+	if err := u.load(ctx, size); err != nil {
+		return nil, err
+	}
+	var response *Response
+	var err error
+	if u.state.URL != "" {
+		if response, err = u.query(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if u.state.URL == "" {
+		if err := u.create(ctx, size); err != nil {
+			return nil, err
+		}
+	}
+	position := int64(0)
+	chunk := make([]byte, u.chunkSize)
+	for response == nil || u.state.Offset < size {
+		if err := skip(body, position, u.state.Offset); err != nil {
+			return nil, err
+		}
+		n, err := io.ReadFull(body, chunk[:min64(u.chunkSize, size-u.state.Offset)])
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		position = u.state.Offset + int64(n)
+		if response, err = u.send(ctx, chunk[:n]); err != nil {
+			return nil, err
+		}
+		if u.progress != nil {
+			u.progress(u.state.Offset, size)
+		}
+		if err := u.save(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if u.store != nil {
+		if err := u.store.Delete(ctx, u.key); err != nil {
+			log.Errorf("error deleting the state of upload %s: %v", u.key, err)
+		}
+	}
+	return response, nil
+}
+
+// load restores the persisted state of an interrupted upload of the given
+// size, if any.
+func (u *Upload) load(ctx context.Context, size int64) error {
+	if u.store != nil && u.state.URL == "" {
+		data, ok, err := u.store.Get(ctx, u.key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := json.Unmarshal(data, &u.state); err != nil {
+				return err
+			}
+		}
+	}
+	if u.state.Size != size {
+		u.state = UploadState{Size: size}
+	}
+	return nil
+}
+
+// save persists the state of the upload.
+func (u *Upload) save(ctx context.Context) error {
+	if u.store == nil {
+		return nil
+	}
+	data, err := json.Marshal(u.state)
+	if err != nil {
+		return err
+	}
+	return u.store.Set(ctx, u.key, data, 0)
+}
+
+// create starts the upload.
+func (u *Upload) create(ctx context.Context, size int64) error {
+	u.state = UploadState{Size: size}
+	if u.protocol != TusUpload {
+		u.state.URL = u.builder.url
+		return nil
+	}
+	f := u.builder.New(http.MethodPost, "")
+	f.SetHeader("Tus-Resumable", tusVersion).SetHeader("Upload-Length", strconv.FormatInt(size, 10))
+	response, err := u.requestor.Do(ctx, f)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	location, err := response.Location()
+	if err != nil {
+		return fmt.Errorf("%w: no upload URL returned (%v)", ErrUploadProtocol, err)
+	}
+	u.state.URL = location.String()
+	return u.save(ctx)
+}
+
+// query asks the server how much of the upload was received; it returns the
+// final response if the upload is already complete, and resets the upload URL
+// if it no longer exists.
+func (u *Upload) query(ctx context.Context) (*Response, error) {
+	var f *Builder
+	if u.protocol == TusUpload {
+		f = u.builder.New(http.MethodHead, u.state.URL)
+		f.SetHeader("Tus-Resumable", tusVersion)
+	} else {
+		f = u.builder.New(http.MethodPut, u.state.URL).WithEntity(nil)
+		f.SetHeader("Content-Range", fmt.Sprintf("bytes */%d", u.state.Size))
+	}
+	response, err := u.requestor.Do(ctx, f)
+	switch code := StatusCode(err); {
+	case code == http.StatusNotFound || code == http.StatusGone:
+		log.Debugf("upload no longer exists, starting over")
+		u.state.URL = ""
+		return nil, nil
+	case err != nil:
+		return nil, u.acknowledged(err)
+	}
+	response.Body.Close()
+	if u.protocol == TusUpload {
+		if u.state.Offset, err = strconv.ParseInt(response.Header.Get("Upload-Offset"), 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: invalid Upload-Offset (%v)", ErrUploadProtocol, err)
+		}
+		return nil, nil
+	}
+	u.state.Offset = u.state.Size
+	return response, nil
+}
+
+// send sends the given chunk, starting at the current offset; it returns the
+// response to the last chunk.
+func (u *Upload) send(ctx context.Context, chunk []byte) (*Response, error) {
+	var f *Builder
+	end := u.state.Offset + int64(len(chunk))
+	if u.protocol == TusUpload {
+		f = u.builder.New(http.MethodPatch, u.state.URL)
+		f.SetHeader("Tus-Resumable", tusVersion).
+			SetHeader("Upload-Offset", strconv.FormatInt(u.state.Offset, 10)).
+			SetHeader("Content-Type", "application/offset+octet-stream")
+	} else {
+		f = u.builder.New(http.MethodPut, u.state.URL)
+		f.SetHeader("Content-Range", fmt.Sprintf("bytes %d-%d/%d", u.state.Offset, end-1, u.state.Size))
+		if len(chunk) == 0 {
+			f.SetHeader("Content-Range", fmt.Sprintf("bytes */%d", u.state.Size))
+		}
+	}
+	f.WithEntity(bytes.NewReader(chunk))
+	response, err := u.requestor.Do(ctx, f)
+	if err != nil {
+		return nil, u.acknowledged(err)
+	}
+	if u.protocol == TusUpload {
+		response.Body.Close()
+		offset, err := strconv.ParseInt(response.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil || offset != end {
+			return nil, fmt.Errorf("%w: unexpected Upload-Offset %q after %d bytes", ErrUploadProtocol, response.Header.Get("Upload-Offset"), end)
+		}
+		u.state.Offset = offset
+		return response, nil
+	}
+	u.state.Offset = u.state.Size
+	return response, nil
+}
+
+// acknowledged updates the offset from the 308 status code by which the
+// server acknowledges the chunks but the last one in the ContentRangeUpload
+// protocol, and returns any other error.
+func (u *Upload) acknowledged(err error) error {
+	var httpErr *HTTPError
+	if u.protocol == TusUpload || !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusPermanentRedirect {
+		return err
+	}
+	u.state.Offset = 0
+	// e.g. "bytes=0-1048575"
+	if received := httpErr.Header.Get("Range"); received != "" {
+		var last int64
+		if _, err := fmt.Sscanf(received, "bytes=0-%d", &last); err != nil {
+			return fmt.Errorf("%w: invalid Range %q", ErrUploadProtocol, received)
+		}
+		u.state.Offset = last + 1
+	}
+	return nil
+}
+
+// skip moves the given body from the given position to the given offset.
+func skip(body io.Reader, position, offset int64) error {
+	if position == offset {
+		return nil
+	}
+	if seeker, ok := body.(io.Seeker); ok {
+		_, err := seeker.Seek(offset, io.SeekStart)
+		return err
+	}
+	if offset < position {
+		return fmt.Errorf("cannot rewind the upload body from %d to %d bytes", position, offset)
+	}
+	_, err := io.CopyN(ioutil.Discard, body, offset-position)
+	return err
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
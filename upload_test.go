@@ -0,0 +1,130 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// uploadServer implements both the tus.io and the Content-Range upload
+// protocols, failing the chunk requests in fail.
+type uploadServer struct {
+	received []byte
+	size     int64
+	requests int
+	fail     map[int]bool
+}
+
+func (s *uploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, _ := ioutil.ReadAll(r.Body)
+	switch r.Method {
+	case http.MethodPost:
+		s.size, _ = strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		s.received = nil
+		w.Header().Set("Location", "/files/1")
+		w.WriteHeader(http.StatusCreated)
+		return
+	case http.MethodHead:
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.received)))
+		return
+	}
+	s.requests++
+	if s.fail[s.requests] {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	switch r.Method {
+	case http.MethodPatch:
+		if r.Header.Get("Upload-Offset") != strconv.Itoa(len(s.received)) || r.Header.Get("Tus-Resumable") != "1.0.0" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		s.received = append(s.received, data...)
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.received)))
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPut:
+		var start, end, size int64
+		if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &size); err == nil {
+			if start != int64(len(s.received)) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			s.received = append(s.received, data...)
+		} else if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes */%d", &size); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if int64(len(s.received)) == size {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		if len(s.received) > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(s.received)-1))
+		}
+		w.WriteHeader(http.StatusPermanentRedirect)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	payload := []byte(strings.Repeat("0123456789", 100))
+	for _, protocol := range []UploadProtocol{ContentRangeUpload, TusUpload} {
+		handler := &uploadServer{fail: map[int]bool{3: true}}
+		server := httptest.NewServer(handler)
+		store := NewMemoryStore()
+		requestor := NewRequestor(nil)
+		f := New(server.URL + "/files")
+
+		var progress []int64
+		upload := requestor.Upload(f, protocol).ChunkSize(300).Persist(store, "upload").Progress(func(uploaded, total int64) {
+			if total != int64(len(payload)) {
+				t.Errorf("unexpected total %d", total)
+			}
+			progress = append(progress, uploaded)
+		})
+		if _, err := upload.Run(context.Background(), bytes.NewReader(payload), int64(len(payload))); StatusCode(err) != http.StatusInternalServerError {
+			t.Fatalf("expected the upload to be interrupted, got %v", err)
+		}
+		if state := upload.State(); state.Offset != 600 || state.URL == "" {
+			t.Fatalf("unexpected state %+v", state)
+		}
+
+		// a new upload resumes from the persisted state, with a body that
+		// cannot seek
+		response, err := requestor.Upload(f, protocol).ChunkSize(300).Persist(store, "upload").Run(context.Background(), ioutil.NopCloser(bytes.NewReader(payload)), int64(len(payload)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if protocol == ContentRangeUpload && response.StatusCode != http.StatusCreated {
+			t.Fatalf("unexpected status %d", response.StatusCode)
+		}
+		if !bytes.Equal(handler.received, payload) {
+			t.Fatalf("unexpected upload of %d bytes", len(handler.received))
+		}
+		// 3 chunks before and 2 after the interruption, plus the query of the
+		// offset for ContentRangeUpload
+		expected := 5
+		if protocol == ContentRangeUpload {
+			expected++
+		}
+		if handler.requests != expected {
+			t.Fatalf("expected %d requests, got %d", expected, handler.requests)
+		}
+		if len(progress) != 2 || progress[1] != 600 {
+			t.Fatalf("unexpected progress %v", progress)
+		}
+		if _, ok, _ := store.Get(context.Background(), "upload"); ok {
+			t.Fatalf("expected the state to be deleted")
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrInvalidRequest is returned (wrapped) by validators that reject a request.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// Validator checks a request generated by a Builder, along with its body (nil if
+// it has none), before Make returns it; an error makes Make fail.
+type Validator func(request *http.Request, body []byte) error
+
+// Validate adds validators to the builder and to all the children created from
+// it afterwards; they are run in order by Make.
+func (f *Builder) Validate(validators ...Validator) *Builder {
+	f.validators = append(f.validators, validators...)
+	return f
+}
+
+// validate runs the validators on the given request, generated by the builder;
+// the request body is buffered, but can still be read.
+func (f *Builder) validate(request *http.Request) error {
+	if len(f.validators) == 0 {
+		return nil
+	}
+	body, err := f.peek(request)
+	if err != nil {
+		return err
+	}
+	for _, validator := range f.validators {
+		if err := validator(request, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ErrUnresolvedVariable is returned when a request refers to a variable that
+// is not set (see Var).
+var ErrUnresolvedVariable = errors.New("unresolved variable")
+
+// varReference matches the references to the variables set via Var (also with
+// percent-encoded braces, as they are in URLs resolved via Path), and the
+// escaped dollar sign.
+var varReference = regexp.MustCompile(`\$\$|\$(?:\{|%7[bB])([_a-zA-Z][\w.-]*)(?:\}|%7[dD])`)
+
+// Var sets the given variable of this builder and its children, so that its
+// references as "${name}" are replaced with its value in the URL, the header
+// values, the query parameter values and the in-memory bodies (such as those
+// set via WithJSONEntity or WithTemplateEntity) of the requests, with the
+// escaping appropriate to each: the value is path-escaped in the URL path,
+// query-escaped in the URL query, and escaped for JSON strings, XML and forms
+// in bodies of the corresponding content types; "$$" stands for a literal
+// dollar sign. Once a variable is set, Make fails with ErrUnresolvedVariable
+// on any reference to a variable that is not. A nil value removes the
+// variable. Unlike Variable, which only applies to the URL "{name}"
+// placeholders, variables are set regardless of the current operation.
+func (f *Builder) Var(key string, value interface{}) *Builder {
+	defer f.write()()
+	if f.vars == nil {
+		f.vars = map[string]string{}
+	}
+	if value == nil {
+		delete(f.vars, key)
+	} else {
+		f.vars[key] = fmt.Sprintf("%v", value)
+	}
+	return f
+}
+
+// expandVars replaces the references to variables in the given string with
+// their values, escaped with the given function.
+func expandVars(s string, variables map[string]string, escape func(string) string) (string, error) {
+	var missing []string
+	result := varReference.ReplaceAllStringFunc(s, func(reference string) string {
+		if reference == "$$" {
+			return "$"
+		}
+		value, ok := variables[varReference.FindStringSubmatch(reference)[1]]
+		if !ok {
+			missing = append(missing, reference)
+			return reference
+		}
+		return escape(value)
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w %s", ErrUnresolvedVariable, strings.Join(missing, ", "))
+	}
+	return result, nil
+}
+
+// verbatim leaves the values as they are.
+func verbatim(s string) string {
+	return s
+}
+
+// expandURL returns the URL and the query parameters of the builder, with the
+// variables replaced.
+func (f *Builder) expandURL() (string, url.Values, error) {
+	base, query := f.url, ""
+	if i := strings.Index(base, "?"); i >= 0 {
+		base, query = base[:i], base[i:]
+	}
+	base, err := expandVars(base, f.vars, url.PathEscape)
+	if err != nil {
+		return "", nil, err
+	}
+	if query, err = expandVars(query, f.vars, url.QueryEscape); err != nil {
+		return "", nil, err
+	}
+	parameters := url.Values{}
+	for key, values := range f.parameters {
+		for _, value := range values {
+			if value, err = expandVars(value, f.vars, verbatim); err != nil {
+				return "", nil, fmt.Errorf("query parameter %s: %w", key, err)
+			}
+			parameters.Add(key, value)
+		}
+	}
+	return base + query, parameters, nil
+}
+
+// expandHeader returns a copy of the given header, with the variables replaced
+// in its values.
+func (f *Builder) expandHeader(header http.Header) (http.Header, error) {
+	expanded := http.Header{}
+	for key, values := range header {
+		for _, value := range values {
+			value, err := expandVars(value, f.vars, verbatim)
+			if err != nil {
+				return nil, fmt.Errorf("header %s: %w", key, err)
+			}
+			if strings.ContainsAny(value, "\r\n") {
+				return nil, fmt.Errorf("header %s: invalid line break in value", key)
+			}
+			expanded[key] = append(expanded[key], value)
+		}
+	}
+	return expanded, nil
+}
+
+// expandBody returns the given body, with the variables replaced, if it is held
+// in memory; the values are escaped according to the given content type.
+func (f *Builder) expandBody(body io.Reader, contentType string) (io.Reader, error) {
+	var data []byte
+	var err error
+	switch b := body.(type) {
+	case *bytes.Reader:
+		data, err = ioutil.ReadAll(io.NewSectionReader(b, 0, b.Size()))
+	case *strings.Reader:
+		data, err = ioutil.ReadAll(io.NewSectionReader(b, 0, b.Size()))
+	case *bytes.Buffer:
+		data = b.Bytes()
+	default:
+		return body, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	escape := verbatim
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		escape = escapeJSONString
+	case strings.HasSuffix(mediaType, "/xml") || strings.HasSuffix(mediaType, "+xml"):
+		escape = func(s string) string {
+			escaped, _ := escapeXML(s)
+			return escaped
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		escape = url.QueryEscape
+	}
+	expanded, err := expandVars(string(data), f.vars, escape)
+	if err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	return strings.NewReader(expanded), nil
+}
+
+// escapeJSONString escapes the given value for use within a JSON string.
+func escapeJSONString(s string) string {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(buffer.String()), `"`), `"`)
+}
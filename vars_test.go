@@ -0,0 +1,54 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestVar(t *testing.T) {
+	api := New("https://api.example.com/v1/${tenant}/").
+		Var("tenant", "acme corp").
+		Var("region", "eu&us").
+		Set().Header("X-Tenant", "${tenant}").
+		Set().QueryParameter("owner", "${tenant}").
+		Set().Header("X-Price", "$$5")
+	type order struct {
+		Note string `json:"note"`
+	}
+	f := api.New(http.MethodPost, "orders?region=${region}").WithJSONEntity(order{Note: `${tenant} "quoted"`}).Var("tenant", `a"b`)
+	request, err := f.Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.URL.String() != "https://api.example.com/v1/a%22b/orders?owner=a%22b&region=eu%26us" {
+		t.Fatalf("unexpected URL %s", request.URL)
+	}
+	if request.Header.Get("X-Tenant") != `a"b` || request.Header.Get("X-Price") != "$5" {
+		t.Fatalf("unexpected headers %v", request.Header)
+	}
+	body, _ := ioutil.ReadAll(request.Body)
+	if string(body) != `{"note":"a\"b \"quoted\""}` {
+		t.Fatalf("unexpected body %s", body)
+	}
+
+	request, err = api.New("", "users?region=${region}").Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.URL.Path != "/v1/acme corp/users" || request.URL.Query().Get("region") != "eu&us" || request.URL.Query().Get("owner") != "acme corp" {
+		t.Fatalf("unexpected URL %s", request.URL)
+	}
+
+	if _, err := api.New("", "").Set().Header("X-User", "${user}").Make(); !errors.Is(err, ErrUnresolvedVariable) {
+		t.Fatalf("expected unresolved variable, got %v", err)
+	}
+	if _, err := api.New("", "").Var("tenant", "a\r\nb").Make(); err == nil {
+		t.Fatalf("expected error for line break in header")
+	}
+}
@@ -0,0 +1,500 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/dihedron/go-log"
+)
+
+// ErrNoInteraction is returned by a replaying VCR when no recorded interaction
+// matches a request.
+var ErrNoInteraction = errors.New("no matching interaction in cassette")
+
+// RecordMode controls whether a VCR records new interactions or replays the
+// recorded ones.
+type RecordMode int8
+
+const (
+	// RecordOnce records all the interactions if the cassette does not exist,
+	// and only replays them otherwise.
+	RecordOnce RecordMode = iota
+	// ReplayOnly only replays the recorded interactions, and fails on requests
+	// not matching any of them.
+	ReplayOnly
+	// RecordNew replays the recorded interactions, and records the requests not
+	// matching any of them.
+	RecordNew
+	// RecordAlways sends all the requests and records them, replacing the
+	// existing cassette.
+	RecordAlways
+)
+
+// CassetteCodec encodes and decodes cassette files.
+type CassetteCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec is the CassetteCodec for JSON cassettes.
+type JSONCodec struct{}
+
+// Marshal encodes the given value as indented JSON.
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// Unmarshal decodes the given JSON data into the given value.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// RecordedRequest is a request as stored in a cassette.
+type RecordedRequest struct {
+	Method string      `json:"method" yaml:"method"`
+	URL    string      `json:"url" yaml:"url"`
+	Header http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body   string      `json:"body,omitempty" yaml:"body,omitempty"`
+	// Encoding is "base64" if the body is not valid UTF-8.
+	Encoding string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+}
+
+// RecordedResponse is a response as stored in a cassette.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code" yaml:"status_code"`
+	Status     string      `json:"status,omitempty" yaml:"status,omitempty"`
+	Header     http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body       string      `json:"body,omitempty" yaml:"body,omitempty"`
+	// Encoding is "base64" if the body is not valid UTF-8.
+	Encoding string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+}
+
+// Interaction is a request/response pair stored in a cassette.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request" yaml:"request"`
+	Response RecordedResponse `json:"response" yaml:"response"`
+}
+
+// cassette is the content of a cassette file.
+type cassette struct {
+	Interactions []*Interaction `json:"interactions" yaml:"interactions"`
+}
+
+// Matcher returns whether an incoming request (already scrubbed) matches a
+// recorded one.
+type Matcher func(actual, recorded *RecordedRequest) bool
+
+// MatchMethod matches requests having the same method.
+func MatchMethod(actual, recorded *RecordedRequest) bool {
+	return actual.Method == recorded.Method
+}
+
+// MatchURL matches requests having the same URL, regardless of the order of
+// the query parameters.
+func MatchURL(actual, recorded *RecordedRequest) bool {
+	a, err := url.Parse(actual.URL)
+	if err != nil {
+		return false
+	}
+	b, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	return a.Scheme == b.Scheme && a.Host == b.Host && a.Path == b.Path &&
+		a.Query().Encode() == b.Query().Encode()
+}
+
+// MatchPath matches requests having the same URL path, regardless of the
+// host and query parameters.
+func MatchPath(actual, recorded *RecordedRequest) bool {
+	a, err := url.Parse(actual.URL)
+	if err != nil {
+		return false
+	}
+	b, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	return a.Path == b.Path
+}
+
+// MatchHeaders returns a Matcher that matches requests having the same values
+// for the given headers.
+func MatchHeaders(headers ...string) Matcher {
+	return func(actual, recorded *RecordedRequest) bool {
+		for _, header := range headers {
+			if !reflect.DeepEqual(actual.Header.Values(header), recorded.Header.Values(header)) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchBody matches requests having the same body; JSON bodies are compared
+// semantically.
+func MatchBody(actual, recorded *RecordedRequest) bool {
+	if actual.Body == recorded.Body && actual.Encoding == recorded.Encoding {
+		return true
+	}
+	var a, b interface{}
+	if json.Unmarshal([]byte(actual.Body), &a) != nil || json.Unmarshal([]byte(recorded.Body), &b) != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Scrubber removes secrets from an interaction before it is stored in a
+// cassette; scrubbers are also applied to incoming requests before matching,
+// so that they match the scrubbed recordings.
+type Scrubber func(interaction *Interaction)
+
+// Redacted is the value replacing the scrubbed secrets.
+const Redacted = "[REDACTED]"
+
+// ScrubHeaders returns a Scrubber that redacts the given request and response
+// headers.
+func ScrubHeaders(headers ...string) Scrubber {
+	return func(interaction *Interaction) {
+		for _, header := range headers {
+			redact(interaction.Request.Header, header)
+			redact(interaction.Response.Header, header)
+		}
+	}
+}
+
+// ScrubQueryParameters returns a Scrubber that redacts the given query
+// parameters in the request URL.
+func ScrubQueryParameters(parameters ...string) Scrubber {
+	return func(interaction *Interaction) {
+		u, err := url.Parse(interaction.Request.URL)
+		if err != nil {
+			return
+		}
+		query := u.Query()
+		for _, parameter := range parameters {
+			if _, ok := query[parameter]; ok {
+				query.Set(parameter, Redacted)
+			}
+		}
+		u.RawQuery = query.Encode()
+		interaction.Request.URL = u.String()
+	}
+}
+
+// ScrubBody returns a Scrubber that replaces all the matches of the given
+// regular expression in the request and response bodies, e.g.
+// ScrubBody(`"password":\s*"[^"]*"`, `"password": "[REDACTED]"`).
+func ScrubBody(expression, replacement string) Scrubber {
+	pattern := regexp.MustCompile(expression)
+	return func(interaction *Interaction) {
+		if interaction.Request.Encoding == "" {
+			interaction.Request.Body = pattern.ReplaceAllString(interaction.Request.Body, replacement)
+		}
+		if interaction.Response.Encoding == "" {
+			interaction.Response.Body = pattern.ReplaceAllString(interaction.Response.Body, replacement)
+		}
+	}
+}
+
+// scrubSensitive redacts the sensitive headers and query parameters (see
+// RegisterSensitiveHeaders and RegisterSensitiveQueryParameters).
+func scrubSensitive(interaction *Interaction) {
+	interaction.Request.Header = RedactHeader(interaction.Request.Header)
+	interaction.Response.Header = RedactHeader(interaction.Response.Header)
+	if u, err := url.Parse(interaction.Request.URL); err == nil {
+		redactQuery(u)
+		interaction.Request.URL = u.String()
+	}
+}
+
+func redact(header http.Header, name string) {
+	if values := header.Values(name); len(values) > 0 {
+		header.Del(name)
+		for range values {
+			header.Add(name, Redacted)
+		}
+	}
+}
+
+// VCR is an http.RoundTripper that records the requests it sends, along with
+// their responses, to a cassette file, and replays them later without touching
+// the network, e.g. to test code using a Builder against a real API once and
+// offline afterwards:
+//
+//	vcr, err := request.NewVCR("testdata/users.json", request.RecordOnce, nil)
+//	requestor := request.NewRequestor(&http.Client{Transport: vcr})
+//
+// By default, requests match recordings with the same method and URL, and the
+// Authorization, Cookie, Proxy-Authorization and Set-Cookie headers, as well as
+// the registered sensitive headers and query parameters (see
+// RegisterSensitiveHeaders), are scrubbed. Each recording is replayed once, in order, as long as there are
+// unused matching ones; afterwards, the first matching one is replayed again.
+// It is safe for concurrent use.
+type VCR struct {
+	lock      sync.Mutex
+	path      string
+	mode      RecordMode
+	codec     CassetteCodec
+	transport http.RoundTripper
+	matchers  []Matcher
+	scrubbers []Scrubber
+	cassette  cassette
+	used      []bool
+}
+
+// NewVCR returns a new VCR using the cassette at the given path, which is
+// loaded unless the mode is RecordAlways; the codec can be nil for JSON
+// cassettes, whereas other formats (e.g. YAML) need their own codec.
+func NewVCR(path string, mode RecordMode, codec CassetteCodec) (*VCR, error) {
+	if codec == nil {
+		if ext := strings.ToLower(filepath.Ext(path)); ext != ".json" {
+			return nil, fmt.Errorf("no codec for cassette %q", path)
+		}
+		codec = JSONCodec{}
+	}
+	v := &VCR{
+		path:      path,
+		mode:      mode,
+		codec:     codec,
+		transport: http.DefaultTransport,
+		matchers:  []Matcher{MatchMethod, MatchURL},
+		scrubbers: []Scrubber{scrubSensitive},
+	}
+	if mode == RecordAlways {
+		return v, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && mode == RecordOnce {
+		v.mode = RecordAlways
+		return v, nil
+	} else if os.IsNotExist(err) && mode == RecordNew {
+		return v, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := codec.Unmarshal(data, &v.cassette); err != nil {
+		return nil, fmt.Errorf("invalid cassette %q: %w", path, err)
+	}
+	if mode == RecordOnce {
+		v.mode = ReplayOnly
+	}
+	v.used = make([]bool, len(v.cassette.Interactions))
+	return v, nil
+}
+
+// Transport sets the transport used to send the requests being recorded.
+func (v *VCR) Transport(transport http.RoundTripper) *VCR {
+	v.transport = transport
+	return v
+}
+
+// Match replaces the matchers used to find the recording of a request; all of
+// them must match.
+func (v *VCR) Match(matchers ...Matcher) *VCR {
+	v.matchers = matchers
+	return v
+}
+
+// Scrub adds scrubbers, applied in order to each interaction before it is
+// recorded.
+func (v *VCR) Scrub(scrubbers ...Scrubber) *VCR {
+	v.scrubbers = append(v.scrubbers, scrubbers...)
+	return v
+}
+
+// Interactions returns the interactions in the cassette.
+func (v *VCR) Interactions() []*Interaction {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return append([]*Interaction(nil), v.cassette.Interactions...)
+}
+
+// RoundTrip replays the recorded response to the given request, or sends the
+// request and records its response, according to the mode of the VCR.
+func (v *VCR) RoundTrip(request *http.Request) (*http.Response, error) {
+	interaction := &Interaction{Request: RecordedRequest{
+		Method: request.Method,
+		URL:    request.URL.String(),
+		Header: request.Header.Clone(),
+	}}
+	var body []byte
+	if request.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		interaction.Request.Body, interaction.Request.Encoding = encodeBody(body)
+	}
+	if interaction.Request.Header == nil {
+		interaction.Request.Header = http.Header{}
+	}
+	for _, scrub := range v.scrubbers {
+		scrub(interaction)
+	}
+
+	v.lock.Lock()
+	if v.mode != RecordAlways {
+		if recorded := v.find(&interaction.Request); recorded != nil {
+			v.lock.Unlock()
+			log.Debugf("replaying recorded response to %s %s", request.Method, RedactURL(request.URL))
+			return replay(request, recorded)
+		}
+	}
+	mode := v.mode
+	v.lock.Unlock()
+	if mode == ReplayOnly {
+		return nil, fmt.Errorf("%s %s: %w %q", request.Method, RedactURL(request.URL), ErrNoInteraction, v.path)
+	}
+
+	log.Debugf("recording response to %s %s", request.Method, RedactURL(request.URL))
+	outgoing := request.Clone(request.Context())
+	if request.Body != nil {
+		outgoing.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	response, err := v.transport.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(data))
+	interaction.Response = RecordedResponse{
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Header:     response.Header.Clone(),
+	}
+	interaction.Response.Body, interaction.Response.Encoding = encodeBody(data)
+	if interaction.Response.Header == nil {
+		interaction.Response.Header = http.Header{}
+	}
+	for _, scrub := range v.scrubbers {
+		scrub(interaction)
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.cassette.Interactions = append(v.cassette.Interactions, interaction)
+	v.used = append(v.used, true)
+	if err := v.save(); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// find returns the recorded response to the given request, if any; it must be
+// called with the lock held.
+func (v *VCR) find(request *RecordedRequest) *RecordedResponse {
+	first := -1
+	for i, interaction := range v.cassette.Interactions {
+		if !v.matches(request, &interaction.Request) {
+			continue
+		}
+		if !v.used[i] {
+			v.used[i] = true
+			return &interaction.Response
+		}
+		if first < 0 {
+			first = i
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+	return &v.cassette.Interactions[first].Response
+}
+
+func (v *VCR) matches(actual, recorded *RecordedRequest) bool {
+	for _, match := range v.matchers {
+		if !match(actual, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+// save writes the cassette atomically; it must be called with the lock held.
+func (v *VCR) save() error {
+	data, err := v.codec.Marshal(&v.cassette)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(v.path), ".cassette-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), v.path)
+}
+
+// replay returns the recorded response as a response to the given request.
+func replay(request *http.Request, recorded *RecordedResponse) (*http.Response, error) {
+	body, err := decodeBody(recorded.Body, recorded.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	status := recorded.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode))
+	}
+	return &http.Response{
+		Status:        status,
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}, nil
+}
+
+func encodeBody(data []byte) (string, string) {
+	if utf8.Valid(data) {
+		return string(data), ""
+	}
+	return base64.StdEncoding.EncodeToString(data), "base64"
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return nil, fmt.Errorf("unsupported body encoding %q", encoding)
+}
@@ -0,0 +1,125 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestVCR(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		fmt.Fprintf(w, "%s %s %d", r.URL.Path, body, n)
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	send := func(vcr *VCR, f *Builder) string {
+		response, err := NewRequestor(&http.Client{Transport: vcr}).Do(context.Background(), f)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer response.Body.Close()
+		data, _ := ioutil.ReadAll(response.Body)
+		return string(data)
+	}
+	base := New(server.URL).Set().Header("Authorization", "Bearer secret")
+
+	vcr, err := NewVCR(path, RecordOnce, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	vcr.Match(MatchMethod, MatchURL, MatchBody)
+	first := send(vcr, base.New(http.MethodGet, "/users"))
+	second := send(vcr, base.New(http.MethodGet, "/users"))
+	post := send(vcr, base.New(http.MethodPost, "/users").WithEntity(strings.NewReader(`{"a": 1}`)))
+	if calls != 3 {
+		t.Fatalf("expected 3 requests to be sent, got %d", calls)
+	}
+	data, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("secrets not scrubbed from cassette: %s", data)
+	}
+
+	vcr, err = NewVCR(path, RecordOnce, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	vcr.Match(MatchMethod, MatchURL, MatchBody)
+	if actual := send(vcr, base.New(http.MethodGet, "/users")); actual != first {
+		t.Fatalf("expected %q, got %q", first, actual)
+	}
+	if actual := send(vcr, base.New(http.MethodGet, "/users")); actual != second {
+		t.Fatalf("expected %q, got %q", second, actual)
+	}
+	if actual := send(vcr, base.New(http.MethodGet, "/users")); actual != first {
+		t.Fatalf("expected %q once recordings are exhausted, got %q", first, actual)
+	}
+	if actual := send(vcr, base.New(http.MethodPost, "/users").WithEntity(strings.NewReader(`{ "a" : 1 }`))); actual != post {
+		t.Fatalf("expected %q, got %q", post, actual)
+	}
+	if calls != 3 {
+		t.Fatalf("expected no further requests to be sent, got %d", calls)
+	}
+	_, err = NewRequestor(&http.Client{Transport: vcr}).Do(context.Background(), base.New(http.MethodGet, "/groups"))
+	if !errors.Is(err, ErrNoInteraction) {
+		t.Fatalf("expected missing interaction, got %v", err)
+	}
+
+	vcr, err = NewVCR(path, RecordNew, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	send(vcr, base.New(http.MethodGet, "/groups"))
+	send(vcr, base.New(http.MethodGet, "/users"))
+	if calls != 4 || len(vcr.Interactions()) != 4 {
+		t.Fatalf("expected only the new request to be recorded, got %d calls and %d interactions", calls, len(vcr.Interactions()))
+	}
+}
+
+func TestVCRScrubbers(t *testing.T) {
+	interaction := &Interaction{
+		Request: RecordedRequest{
+			Method: http.MethodPost,
+			URL:    "http://www.example.com/login?api_key=secret&page=1",
+			Header: http.Header{"X-Token": {"secret"}},
+			Body:   `{"user": "john", "password": "secret"}`,
+		},
+		Response: RecordedResponse{StatusCode: http.StatusOK, Header: http.Header{}},
+	}
+	ScrubHeaders("X-Token")(interaction)
+	ScrubQueryParameters("api_key")(interaction)
+	ScrubBody(`"password":\s*"[^"]*"`, `"password": "`+Redacted+`"`)(interaction)
+	if interaction.Request.Header.Get("X-Token") != Redacted {
+		t.Fatalf("header not scrubbed: %v", interaction.Request.Header)
+	}
+	if interaction.Request.URL != "http://www.example.com/login?api_key=%5BREDACTED%5D&page=1" {
+		t.Fatalf("query parameter not scrubbed: %s", interaction.Request.URL)
+	}
+	if strings.Contains(interaction.Request.Body, "secret") {
+		t.Fatalf("body not scrubbed: %s", interaction.Request.Body)
+	}
+}
+
+func TestVCRReplayOnly(t *testing.T) {
+	if _, err := NewVCR(filepath.Join(t.TempDir(), "missing.json"), ReplayOnly, nil); err == nil {
+		t.Fatalf("expected error on missing cassette")
+	}
+	if _, err := NewVCR(filepath.Join(t.TempDir(), "cassette.yaml"), RecordOnce, nil); err == nil {
+		t.Fatalf("expected error on cassette without codec")
+	}
+}
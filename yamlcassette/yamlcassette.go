@@ -0,0 +1,29 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package yamlcassette provides the codec for YAML VCR cassettes (also usable
+// for profiles, catalogs and builder configurations), kept apart from the
+// request package so that the YAML dependency is only pulled in by those who
+// need it:
+//
+//	vcr, err := request.NewVCR("testdata/users.yaml", request.RecordOnce, yamlcassette.Codec{})
+package yamlcassette
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// Codec is the cassette codec for YAML cassettes; it satisfies the request
+// package CassetteCodec interface.
+type Codec struct{}
+
+// Marshal encodes the given value as YAML.
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+// Unmarshal decodes the given YAML data into the given value.
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
@@ -0,0 +1,80 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package yamlcassette
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	request "github.com/dihedron/go-requestor"
+)
+
+func TestCodec(t *testing.T) {
+	type entry struct {
+		Method string      `yaml:"method"`
+		Header http.Header `yaml:"header"`
+	}
+	expected := entry{Method: http.MethodGet, Header: http.Header{"Accept": {"application/json"}}}
+	data, err := Codec{}.Marshal(expected)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actual := entry{}
+	if err := (Codec{}).Unmarshal(data, &actual); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestBuilderConfig(t *testing.T) {
+	api := request.New("https://api.example.com/v1/").Method(http.MethodPost).UserAgent("worker/1.0")
+	data, err := Codec{}.Marshal(api)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restored := &request.Builder{}
+	if err := (Codec{}).Unmarshal(data, restored); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(restored.Config(), api.Config()) {
+		t.Fatalf("expected %v, got %v", api.Config(), restored.Config())
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.yaml")
+	if err := ioutil.WriteFile(path, []byte(`
+CreateUser:
+  method: POST
+  path: users
+  headers:
+    Content-Type: application/json
+  body: '{"name": "{{.name}}"}'
+  expect: [201]
+`), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	catalog, err := request.LoadCatalog(path, Codec{}, request.New("https://api.example.com/"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f, err := catalog.Builder("CreateUser", map[string]interface{}{"name": "alice"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r, err := f.Make()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.Method != http.MethodPost || r.URL.String() != "https://api.example.com/users" || string(body) != `{"name": "alice"}` {
+		t.Fatalf("unexpected request %s %s %s", r.Method, r.URL, body)
+	}
+}